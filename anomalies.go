@@ -0,0 +1,225 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// AnomalyKind is the kind of an anomaly in the dependency graph.
+type AnomalyKind string
+
+const (
+	// DuplicateFileAnomaly is a file claimed by more than one package.
+	// Changes to the file are attributed to only one of them.
+	DuplicateFileAnomaly AnomalyKind = "duplicate-file"
+	// EmptyPackageAnomaly is a package matching the patterns that has no Go
+	// files satisfying the build constraints. It is left out of the graph.
+	EmptyPackageAnomaly AnomalyKind = "empty-package"
+	// IgnoredFileAnomaly is a Go file that is excluded by its build
+	// constraints on every platform, so no variant of its package compiles
+	// it.
+	IgnoredFileAnomaly AnomalyKind = "ignored-file"
+	// DuplicatePackageAnomaly is an import path provided by more than one
+	// directory, e.g. by several modules. The packages are merged in the
+	// graph.
+	DuplicatePackageAnomaly AnomalyKind = "duplicate-package"
+)
+
+// Anomaly is a problem found while building the dependency graph that can
+// cause changes to be attributed to the wrong packages.
+type Anomaly struct {
+	Kind AnomalyKind `json:"kind"`
+	// ImportPaths are the sorted import paths of the packages involved.
+	ImportPaths []string `json:"import_paths,omitempty"`
+	// Files are the sorted absolute paths of the files or, for duplicate
+	// packages, of the directories involved.
+	Files []string `json:"files,omitempty"`
+}
+
+// String describes the anomaly.
+func (a Anomaly) String() string {
+	switch a.Kind {
+	case DuplicateFileAnomaly:
+		return fmt.Sprintf("%s is claimed by several packages: %s", strings.Join(a.Files, ", "), strings.Join(a.ImportPaths, ", "))
+	case EmptyPackageAnomaly:
+		return fmt.Sprintf("%s has no Go files that satisfy the build constraints", strings.Join(a.ImportPaths, ", "))
+	case IgnoredFileAnomaly:
+		return fmt.Sprintf("%s of %s is excluded by its build constraints on every platform", strings.Join(a.Files, ", "), strings.Join(a.ImportPaths, ", "))
+	case DuplicatePackageAnomaly:
+		return fmt.Sprintf("%s is provided by several directories: %s", strings.Join(a.ImportPaths, ", "), strings.Join(a.Files, ", "))
+	}
+	return fmt.Sprintf("%s: %s %s", a.Kind, strings.Join(a.ImportPaths, ", "), strings.Join(a.Files, ", "))
+}
+
+// An AnomalyReporter is a Packager that reports the anomalies it found while
+// building the dependency graph.
+type AnomalyReporter interface {
+	// Anomalies returns the anomalies in the dependency graph.
+	Anomalies() ([]Anomaly, error)
+}
+
+// anomalies returns the anomalies reported by g's packager.
+func (g *GTA) anomalies() ([]Anomaly, error) {
+	reporter, ok := g.packager.(AnomalyReporter)
+	if !ok {
+		return nil, errors.New("the packager does not report anomalies")
+	}
+	return reporter.Anomalies()
+}
+
+// graphAnomalies returns the anomalies among the packages in roots, which
+// match the patterns, and their dependencies. ctx is used to evaluate the
+// build constraints of ignored files.
+func graphAnomalies(roots []*packages.Package, ctx build.Context) []Anomaly {
+	// the import paths of the packages that claim each file, and the
+	// directories that provide each import path.
+	claims := make(map[string]map[string]struct{})
+	dirs := make(map[string]map[string]struct{})
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		if len(pkg.GoFiles) == 0 || strings.HasSuffix(pkg.PkgPath, ".test") {
+			return
+		}
+
+		// test variants claim the files of the package they test.
+		pkgPath := normalizeImportPath(pkg)
+		for _, fn := range append(append([]string(nil), pkg.GoFiles...), pkg.OtherFiles...) {
+			addClaim(claims, fn, pkgPath)
+		}
+		addClaim(dirs, pkgPath, filepath.Dir(pkg.GoFiles[0]))
+	})
+
+	var anomalies []Anomaly
+	for fn, pkgPaths := range claims {
+		if len(pkgPaths) > 1 {
+			anomalies = append(anomalies, Anomaly{Kind: DuplicateFileAnomaly, ImportPaths: sortedSet(pkgPaths), Files: []string{fn}})
+		}
+	}
+	for pkgPath, pkgDirs := range dirs {
+		if len(pkgDirs) > 1 {
+			anomalies = append(anomalies, Anomaly{Kind: DuplicatePackageAnomaly, ImportPaths: []string{pkgPath}, Files: sortedSet(pkgDirs)})
+		}
+	}
+
+	empty := make(map[string]struct{})
+	for _, pkg := range roots {
+		if strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+
+		pkgPath := normalizeImportPath(pkg)
+		if len(pkg.GoFiles) == 0 {
+			empty[pkgPath] = struct{}{}
+		}
+
+		for _, fn := range pkg.IgnoredFiles {
+			if _, ok := claims[fn]; ok || filepath.Ext(fn) != ".go" {
+				continue
+			}
+			if !matchesAnyPlatform(ctx, fn) {
+				anomalies = append(anomalies, Anomaly{Kind: IgnoredFileAnomaly, ImportPaths: []string{pkgPath}, Files: []string{fn}})
+			}
+		}
+	}
+	for pkgPath := range empty {
+		// a package whose tests have Go files is not empty.
+		if _, ok := dirs[pkgPath]; !ok {
+			anomalies = append(anomalies, Anomaly{Kind: EmptyPackageAnomaly, ImportPaths: []string{pkgPath}})
+		}
+	}
+
+	sortAnomalies(anomalies)
+	return dedupeAnomalies(anomalies)
+}
+
+// addClaim adds value to the set of key in m.
+func addClaim(m map[string]map[string]struct{}, key, value string) {
+	if _, ok := m[key]; !ok {
+		m[key] = make(map[string]struct{})
+	}
+	m[key][value] = struct{}{}
+}
+
+// sortedSet returns the sorted members of set.
+func sortedSet(set map[string]struct{}) []string {
+	s := make([]string, 0, len(set))
+	for v := range set {
+		s = append(s, v)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// sortAnomalies sorts anomalies by kind, then import paths, then files.
+func sortAnomalies(anomalies []Anomaly) {
+	sort.Slice(anomalies, func(i, j int) bool {
+		a, b := anomalies[i], anomalies[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if ka, kb := strings.Join(a.ImportPaths, ","), strings.Join(b.ImportPaths, ","); ka != kb {
+			return ka < kb
+		}
+		return strings.Join(a.Files, ",") < strings.Join(b.Files, ",")
+	})
+}
+
+// dedupeAnomalies removes the adjacent duplicates from the sorted anomalies,
+// e.g. an ignored file reported by each variant of its package.
+func dedupeAnomalies(anomalies []Anomaly) []Anomaly {
+	var deduped []Anomaly
+	for i, a := range anomalies {
+		if i > 0 && a.String() == anomalies[i-1].String() {
+			continue
+		}
+		deduped = append(deduped, a)
+	}
+	return deduped
+}
+
+// Platforms whose build constraints are evaluated for ignored files.
+var (
+	knownOS   = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "js", "linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows"}
+	knownArch = []string{"386", "amd64", "arm", "arm64", "loong64", "mips", "mips64", "mips64le", "mipsle", "ppc64", "ppc64le", "riscv64", "s390x", "wasm"}
+)
+
+// matchesAnyPlatform reports whether the build constraints of the Go file fn
+// are satisfied for any known platform, with or without cgo, using the build
+// tags of ctx.
+func matchesAnyPlatform(ctx build.Context, fn string) bool {
+	// read the file once rather than for every platform.
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return true
+	}
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	dir, name := filepath.Split(fn)
+	for _, goos := range knownOS {
+		for _, goarch := range knownArch {
+			for _, cgo := range []bool{false, true} {
+				ctx.GOOS, ctx.GOARCH, ctx.CgoEnabled = goos, goarch, cgo
+				if ok, err := ctx.MatchFile(dir, name); ok || err != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGraphAnomalies(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "a/a.go", "package a\n")
+	writeFile(t, dir, "a/gen.go", "// +build ignore\n\npackage main\n")
+	writeFile(t, dir, "a/a_windows.go", "package a\n")
+
+	shared := filepath.Join(dir, "shared", "shared.go")
+	dup := &packages.Package{
+		ID:      "example.com/dup",
+		PkgPath: "example.com/dup",
+		GoFiles: []string{filepath.Join(dir, "vendor", "dup", "dup.go")},
+	}
+	b := &packages.Package{
+		ID:      "example.com/b",
+		PkgPath: "example.com/b",
+		GoFiles: []string{shared},
+		Imports: map[string]*packages.Package{"example.com/dup": dup},
+	}
+	a := &packages.Package{
+		ID:           "example.com/a",
+		PkgPath:      "example.com/a",
+		GoFiles:      []string{filepath.Join(dir, "a", "a.go"), shared},
+		IgnoredFiles: []string{filepath.Join(dir, "a", "gen.go"), filepath.Join(dir, "a", "a_windows.go")},
+		Imports:      map[string]*packages.Package{"example.com/b": b},
+	}
+	// the test variant of a claims the files of a without being a
+	// duplicate.
+	aTest := &packages.Package{
+		ID:           "example.com/a [example.com/a.test]",
+		PkgPath:      "example.com/a",
+		GoFiles:      append(append([]string(nil), a.GoFiles...), filepath.Join(dir, "a", "a_test.go")),
+		IgnoredFiles: a.IgnoredFiles,
+	}
+	dup2 := &packages.Package{
+		ID:      "example.com/dup",
+		PkgPath: "example.com/dup",
+		GoFiles: []string{filepath.Join(dir, "dup", "dup.go")},
+	}
+	empty := &packages.Package{
+		ID:      "example.com/empty",
+		PkgPath: "example.com/empty",
+	}
+
+	got := graphAnomalies([]*packages.Package{a, aTest, dup2, empty}, build.Default)
+
+	want := []Anomaly{
+		{Kind: DuplicateFileAnomaly, ImportPaths: []string{"example.com/a", "example.com/b"}, Files: []string{shared}},
+		{Kind: DuplicatePackageAnomaly, ImportPaths: []string{"example.com/dup"}, Files: []string{filepath.Join(dir, "dup"), filepath.Join(dir, "vendor", "dup")}},
+		{Kind: EmptyPackageAnomaly, ImportPaths: []string{"example.com/empty"}},
+		{Kind: IgnoredFileAnomaly, ImportPaths: []string{"example.com/a"}, Files: []string{filepath.Join(dir, "a", "gen.go")}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_AnomaliesUnsupported(t *testing.T) {
+	pkgr := &testPackager{
+		graph: &Graph{},
+	}
+
+	gta, err := New(SetDiffer(&testDiffer{}), SetPackager(pkgr), SetAnomalyReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,463 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Risk describes how a dependent package may be affected by changes to the
+// exported API of the packages it depends on.
+type Risk string
+
+const (
+	// CompileRisk indicates that a package references an exported identifier
+	// whose declaration was changed or removed, so it may no longer compile.
+	CompileRisk Risk = "compile"
+
+	// BehaviorRisk indicates that a package does not reference any changed
+	// exported identifier. It should still compile, but its behavior may
+	// change.
+	BehaviorRisk Risk = "behavior"
+)
+
+var errNoBaseReader = errors.New("no differ can read the base content of changed files")
+
+// reportAPI sets the APIChanges and Risks of cp from the changed files in m.
+func (g *GTA) reportAPI(cp *Packages, m *marks) error {
+	changes := make(map[string]map[string]struct{})
+	for importPath, files := range m.files {
+		changed, err := g.changedAPI(files)
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		changes[importPath] = make(map[string]struct{}, len(changed))
+		for _, name := range changed {
+			changes[importPath][name] = struct{}{}
+		}
+
+		if _, ok := cp.Dependencies[importPath]; ok || g.included(importPath) {
+			if cp.APIChanges == nil {
+				cp.APIChanges = make(map[string][]string)
+			}
+			cp.APIChanges[importPath] = changed
+		}
+	}
+
+	compileRisks, err := g.compileRisks(changes, m.graph)
+	if err != nil {
+		return err
+	}
+
+	for _, dependents := range cp.Dependencies {
+		for _, pkg := range dependents {
+			if cp.Risks == nil {
+				cp.Risks = make(map[string]Risk)
+			}
+
+			risk := BehaviorRisk
+			if _, ok := compileRisks[pkg.ImportPath]; ok {
+				risk = CompileRisk
+			}
+			cp.Risks[pkg.ImportPath] = risk
+		}
+	}
+
+	return nil
+}
+
+// compileRisks returns the set of import paths of the packages that reference
+// the changed exported identifiers of the packages in changes. Only the
+// direct dependents of the changed packages need to be type checked, because
+// no other package can refer to the identifiers.
+func (g *GTA) compileRisks(changes map[string]map[string]struct{}, graph *Graph) (map[string]struct{}, error) {
+	risks := make(map[string]struct{})
+	if len(changes) == 0 || graph == nil {
+		return risks, nil
+	}
+
+	seen := make(map[string]struct{})
+	var patterns []string
+	for importPath := range changes {
+		for dependent := range graph.graph[importPath] {
+			if _, ok := seen[dependent]; ok {
+				continue
+			}
+			seen[dependent] = struct{}{}
+			patterns = append(patterns, dependent)
+		}
+	}
+	if len(patterns) == 0 {
+		return risks, nil
+	}
+	sort.Strings(patterns)
+
+	// the packages are only loaded again when their type information was not
+	// loaded along with the dependency graph.
+	infos := g.loadedTypesInfo(patterns)
+	if infos == nil {
+		var err error
+		if infos, err = typeCheck(g.loader, g.loadConfig(), patterns); err != nil {
+			return nil, err
+		}
+	}
+
+	for importPath, info := range infos {
+		if referencesChangedAPI(info, changes) {
+			risks[importPath] = struct{}{}
+		}
+	}
+
+	return risks, nil
+}
+
+// typeCheck loads the packages matching patterns with loader and cfg, whose
+// mode is extended with SyntaxLoadMode, including their test variants when
+// cfg.Tests is set. It returns the type information of each package keyed by
+// its normalized import path; the information of a package's variants is
+// merged. Type errors are ignored so that the information that could be
+// determined is still returned.
+func typeCheck(loader Loader, cfg *packages.Config, patterns []string) (map[string]*types.Info, error) {
+	cfg.Mode |= packages.NeedName | SyntaxLoadMode
+
+	roots, err := loader.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(map[string]*types.Info)
+	for _, pkg := range roots {
+		// Ignore the test binary packages
+		if len(pkg.GoFiles) == 0 || strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		mergeTypesInfo(infos, pkg)
+	}
+
+	return infos, nil
+}
+
+// mergeTypesInfo merges the uses and selections of the type information of
+// pkg, if any, into those of its normalized import path in infos.
+func mergeTypesInfo(infos map[string]*types.Info, pkg *packages.Package) {
+	if pkg.TypesInfo == nil {
+		return
+	}
+
+	importPath := normalizeImportPath(pkg)
+	info, ok := infos[importPath]
+	if !ok {
+		info = &types.Info{
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		infos[importPath] = info
+	}
+	for id, obj := range pkg.TypesInfo.Uses {
+		info.Uses[id] = obj
+	}
+	for sel, selection := range pkg.TypesInfo.Selections {
+		info.Selections[sel] = selection
+	}
+}
+
+// referencesChangedAPI reports whether info records a reference to any of the
+// changed exported identifiers in changes.
+func referencesChangedAPI(info *types.Info, changes map[string]map[string]struct{}) bool {
+	for _, obj := range info.Uses {
+		if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+			// methods and fields are checked through info.Selections below.
+			continue
+		}
+		if _, ok := changes[obj.Pkg().Path()][obj.Name()]; ok {
+			return true
+		}
+	}
+
+	for _, sel := range info.Selections {
+		obj := sel.Obj()
+		if obj.Pkg() == nil {
+			continue
+		}
+		changed, ok := changes[obj.Pkg().Path()]
+		if !ok {
+			continue
+		}
+
+		recv := namedType(sel.Recv())
+		if recv == nil || recv.Obj().Pkg() != obj.Pkg() {
+			// the selection is promoted through an embedded field of a type from
+			// another package, so the declaring type is unknown. Be conservative.
+			return true
+		}
+
+		name := recv.Obj().Name()
+		if _, ok := changed[name]; ok {
+			return true
+		}
+		if _, ok := changed[name+"."+obj.Name()]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namedType returns the named type of t, dereferencing pointers, or nil when t
+// is not a named type.
+func namedType(t types.Type) *types.Named {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// changedAPI returns the sorted exported identifiers of the package in the
+// directory of files whose declarations were changed or removed by the changes
+// to files. files must all be in the same directory.
+func (g *GTA) changedAPI(files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Dir(files[0])
+	changed := make(map[string]struct{})
+	for _, fn := range files {
+		if isAPIFile(fn) {
+			changed[filepath.Base(fn)] = struct{}{}
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	before := make(map[string]string)
+	after := make(map[string]string)
+
+	// the unchanged files declare the same identifiers before and after the
+	// change.
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, fi := range infos {
+		if fi.IsDir() || !isAPIFile(fi.Name()) {
+			continue
+		}
+
+		fn := filepath.Join(dir, fi.Name())
+		src, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+
+		api := exportedAPI(fn, src)
+		mergeAPI(after, api)
+		if _, ok := changed[fi.Name()]; !ok {
+			mergeAPI(before, api)
+		}
+	}
+
+	for base := range changed {
+		fn := filepath.Join(dir, base)
+		src, err := g.readBase(fn)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err == errNoBaseReader:
+			// without the original content, conservatively treat every exported
+			// identifier in the file as changed.
+			src, err = ioutil.ReadFile(fn)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			for name := range exportedAPI(fn, src) {
+				before[name] = ""
+			}
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		mergeAPI(before, exportedAPI(fn, src))
+	}
+
+	var names []string
+	for name, decl := range before {
+		if after[name] != decl {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// readBase reads the content of the file at abs before it was changed using
+// the first of g's differs that is a BaseReader.
+func (g *GTA) readBase(abs string) ([]byte, error) {
+	for _, ld := range g.differs {
+		if br, ok := ld.differ.(BaseReader); ok {
+			return br.ReadBase(abs)
+		}
+	}
+	return nil, errNoBaseReader
+}
+
+// isAPIFile reports whether the file fn can declare the exported API of a
+// package.
+func isAPIFile(fn string) bool {
+	return filepath.Ext(fn) == ".go" && !strings.HasSuffix(fn, "_test.go")
+}
+
+func mergeAPI(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// exportedAPI returns a map of the exported identifiers declared in the Go
+// source src to a normalized form of their declarations. Methods are
+// identified as "T.M" where T is the name of the receiver's type, and methods
+// of unexported types are omitted. Variables and constants declared without a
+// type are identified by their initializers, since their types are those of
+// the initializers. Syntax errors are ignored so that the declarations that
+// could be parsed are still returned.
+func exportedAPI(filename string, src []byte) map[string]string {
+	api := make(map[string]string)
+	if src == nil {
+		return api
+	}
+
+	f, _ := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+	if f == nil {
+		return api
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+
+			name, sig := decl.Name.Name, types.ExprString(decl.Type)
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv := decl.Recv.List[0].Type
+				if !ast.IsExported(receiverName(recv)) {
+					continue
+				}
+				name = receiverName(recv) + "." + name
+				sig = "(" + types.ExprString(recv) + ") " + sig
+			}
+			api[name] = sig
+		case *ast.GenDecl:
+			// the type and values of the last constant specification with
+			// values, which those without values repeat.
+			var constType ast.Expr
+			var constValues []ast.Expr
+			for i, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+
+					assign := ""
+					if spec.Assign.IsValid() {
+						assign = "= "
+					}
+					api[spec.Name.Name] = "type " + assign + types.ExprString(spec.Type)
+				case *ast.ValueSpec:
+					typ, values := spec.Type, spec.Values
+					if decl.Tok == token.CONST {
+						if len(values) == 0 {
+							typ, values = constType, constValues
+						}
+						constType, constValues = typ, values
+					}
+
+					for j, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						if typ != nil {
+							api[name.Name] = decl.Tok.String() + " " + types.ExprString(typ)
+							continue
+						}
+						api[name.Name] = decl.Tok.String() + " = " + initializer(values, len(spec.Names), j, i)
+					}
+				}
+			}
+		}
+	}
+
+	return api
+}
+
+// initializer returns the text of the initializer of the j-th of the n names
+// of a value specification, the i-th of its declaration. The index of the
+// result is added when a single call initializes several names, and the value
+// of iota when the initializer refers to it.
+func initializer(values []ast.Expr, n, j, i int) string {
+	var value ast.Expr
+	var s string
+	switch {
+	case len(values) == 1 && n > 1:
+		value = values[0]
+		s = fmt.Sprintf("%s [%d]", types.ExprString(value), j)
+	case j < len(values):
+		value = values[j]
+		s = types.ExprString(value)
+	default:
+		return ""
+	}
+
+	ast.Inspect(value, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Name == "iota" {
+			s += fmt.Sprintf(" (iota %d)", i)
+			return false
+		}
+		return true
+	})
+	return s
+}
+
+// receiverName returns the name of the type of the receiver expression expr.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch x := expr.(type) {
+		case *ast.StarExpr:
+			expr = x.X
+		case *ast.ParenExpr:
+			expr = x.X
+		case *ast.IndexExpr:
+			expr = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return types.ExprString(expr)
+		}
+	}
+}
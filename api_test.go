@@ -0,0 +1,234 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/packages/packagestest"
+)
+
+var _ BaseReader = &testBaseDiffer{}
+
+type testBaseDiffer struct {
+	testDiffer
+	base map[string][]byte
+}
+
+func (t *testBaseDiffer) ReadBase(abs string) ([]byte, error) {
+	b, ok := t.base[abs]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: abs, Err: os.ErrNotExist}
+	}
+	return b, nil
+}
+
+func TestExportedAPI(t *testing.T) {
+	src := []byte(`package foo
+
+// F is documented.
+func F(a int) error { return nil }
+
+func f() {}
+
+type T struct {
+	X int
+	y string
+}
+
+type A = T
+
+func (t *T) M() {}
+
+func (t T) m() {}
+
+const (
+	C     = 1
+	D int = 2
+	e     = 3
+)
+
+var V, W string
+
+var (
+	X    = time.Second
+	Y, Z = split()
+)
+
+type Kind int
+
+const (
+	K0 Kind = iota
+	K1
+)
+
+const (
+	I0 = iota * 10
+	I1
+)
+
+type t2 struct{}
+
+func (t *t2) Exported() {}
+`)
+
+	want := map[string]string{
+		"F":    "func(a int) error",
+		"T":    "type struct{X int; y string}",
+		"A":    "type = T",
+		"T.M":  "(*T) func()",
+		"C":    "const = 1",
+		"D":    "const int",
+		"V":    "var string",
+		"W":    "var string",
+		"X":    "var = time.Second",
+		"Y":    "var = split() [0]",
+		"Z":    "var = split() [1]",
+		"Kind": "type int",
+		"K0":   "const Kind",
+		"K1":   "const Kind",
+		"I0":   "const = iota * 10 (iota 0)",
+		"I1":   "const = iota * 10 (iota 1)",
+	}
+
+	got := exportedAPI("foo.go", src)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestTypeCheck_Mode(t *testing.T) {
+	var got packages.LoadMode
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg.Mode
+		return nil, nil
+	})
+
+	cfg := &packages.Config{Mode: packages.NeedModule}
+	if _, err := typeCheck(loader, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []packages.LoadMode{packages.NeedModule, packages.NeedName, packages.NeedTypes, packages.NeedTypesInfo} {
+		if got&mode == 0 {
+			t.Errorf("got mode %v, want it to include %v", got, mode)
+		}
+	}
+}
+
+func TestGTA_APIReport(t *testing.T) {
+	const testModule = "gta.test"
+
+	packagestest.TestAll(t, func(t *testing.T, exporter packagestest.Exporter) {
+		e := packagestest.Export(t, exporter, []packagestest.Module{
+			{
+				Name: testModule,
+				Files: map[string]interface{}{
+					"api/api.go": `package api
+
+func F() int { return 1 }
+
+func G() {}
+
+// the comment and body changed, but not the declaration.
+func H() {}
+`,
+					"user/user.go": `package user
+
+import "gta.test/api"
+
+func User() int { return api.F() }
+`,
+					"other/other.go": `package other
+
+import "gta.test/api"
+
+func Other() { api.G(); api.H() }
+`,
+					"top/top.go": `package top
+
+import "gta.test/user"
+
+func Top() int { return user.User() }
+`,
+				},
+			},
+		})
+		t.Cleanup(e.Cleanup)
+
+		apiFile := exporter.Filename(e, testModule, "api/api.go")
+		difr := &testBaseDiffer{
+			testDiffer: testDiffer{
+				diff: map[string]Directory{
+					exporter.Filename(e, testModule, "api"): {Exists: true, Files: []string{"api.go"}},
+				},
+			},
+			base: map[string][]byte{
+				apiFile: []byte(`package api
+
+func F() {}
+
+func G() {}
+
+func H() { println() }
+`),
+			},
+		}
+
+		t.Cleanup(chdir(t, exporter.Filename(e, testModule, "")))
+
+		for _, v := range e.Config.Env {
+			sl := strings.SplitN(v, "=", 2)
+			if sl[0] != "GOPATH" {
+				continue
+			}
+
+			defer func(v string) {
+				build.Default.GOPATH = v
+			}(build.Default.GOPATH)
+
+			build.Default.GOPATH = sl[1]
+		}
+		defer AllSetenv(t, e.Config.Env)()
+
+		cfg := newLoadConfig(nil)
+		e.Config.Mode = cfg.Mode
+		e.Config.BuildFlags = cfg.BuildFlags
+		e.Config.Tests = cfg.Tests
+
+		sut, err := New(SetDiffer(difr), SetPackager(newPackager(defaultLoader, e.Config, build.Default, []string{testModule + "/"})), SetAPIReport(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := sut.ChangedPackages()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wantAPIChanges := map[string][]string{
+			testModule + "/api": {"F"},
+		}
+		if diff := cmp.Diff(wantAPIChanges, got.APIChanges); diff != "" {
+			t.Errorf("APIChanges (-want, +got)\n%s", diff)
+		}
+
+		wantRisks := map[string]Risk{
+			testModule + "/user":  CompileRisk,
+			testModule + "/other": BehaviorRisk,
+			testModule + "/top":   BehaviorRisk,
+		}
+		if diff := cmp.Diff(wantRisks, got.Risks); diff != "" {
+			t.Errorf("Risks (-want, +got)\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// A BinaryFileDiffer is a Differ that reports which of the changed files are
+// binary files.
+type BinaryFileDiffer interface {
+	// BinaryFiles returns the set of the absolute paths of the changed files
+	// that are binary files.
+	BinaryFiles() (map[string]struct{}, error)
+}
+
+// binaryFiles returns the changed binary files reported by g's differs.
+func (g *GTA) binaryFiles() (map[string]struct{}, error) {
+	binary := make(map[string]struct{})
+	reported := false
+	for _, ld := range g.differs {
+		bfd, ok := ld.differ.(BinaryFileDiffer)
+		if !ok {
+			continue
+		}
+		reported = true
+
+		files, err := bfd.BinaryFiles()
+		if err != nil {
+			return nil, err
+		}
+		for abs := range files {
+			binary[abs] = struct{}{}
+		}
+	}
+	if !reported {
+		return nil, errors.New("the differ does not report binary files")
+	}
+	return binary, nil
+}
+
+// withoutBinaryFiles returns dir, the changed directory abs, without the
+// files in binary.
+func withoutBinaryFiles(abs string, dir Directory, binary map[string]struct{}) Directory {
+	files := make([]string, 0, len(dir.Files))
+	for _, fn := range dir.Files {
+		if _, ok := binary[filepath.Join(abs, fn)]; !ok {
+			files = append(files, fn)
+		}
+	}
+	dir.Files = files
+	return dir
+}
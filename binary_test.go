@@ -0,0 +1,170 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// binaryDiffer is a testDiffer that reports binary files.
+type binaryDiffer struct {
+	testDiffer
+	binary map[string]struct{}
+}
+
+func (d *binaryDiffer) BinaryFiles() (map[string]struct{}, error) {
+	return d.binary, nil
+}
+
+func TestGitDiffer_IgnoreBinary(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	writeFile(t, dir, "image.png", "\x89PNG\x00\x01\x02")
+	writeFile(t, dir, "baz.go", "package foo\n")
+	repo.Commit("add fixture")
+
+	tests := []struct {
+		desc         string
+		ignoreBinary bool
+		want         map[string]bool
+	}{
+		{
+			desc: "binary",
+			want: map[string]bool{
+				filepath.Join(dir, "image.png"): true,
+				filepath.Join(dir, "baz.go"):    true,
+			},
+		},
+		{
+			desc:         "ignore binary",
+			ignoreBinary: true,
+			want: map[string]bool{
+				filepath.Join(dir, "baz.go"): true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("HEAD~1"), SetIgnoreBinary(tt.ignoreBinary)).DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitDiffer_BinaryFiles(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	writeFile(t, dir, "image.png", "\x89PNG\x00\x01\x02")
+	repo.Commit("add fixture")
+
+	difr := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"))
+	got, err := difr.(BinaryFileDiffer).BinaryFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct{}{
+		filepath.Join(dir, "image.png"): {},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_BinaryFileMapping(t *testing.T) {
+	// dirA has a changed binary fixture that is mapped to B, and dirC has a
+	// changed binary file that is not mapped.
+	difr := &binaryDiffer{
+		testDiffer: testDiffer{
+			diff: map[string]Directory{
+				"dirA": {Exists: true, Files: []string{"fixture.bin"}},
+				"dirC": {Exists: true, Files: []string{"logo.png"}},
+			},
+		},
+		binary: map[string]struct{}{
+			filepath.Join("dirA", "fixture.bin"): {},
+			filepath.Join("dirC", "logo.png"):    {},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{},
+		},
+	}
+
+	tests := []struct {
+		desc    string
+		mapping bool
+		want    []Package
+	}{
+		{
+			desc: "default",
+			want: []Package{{ImportPath: "A"}, {ImportPath: "B"}, {ImportPath: "C"}},
+		},
+		{
+			desc:    "mapping",
+			mapping: true,
+			want:    []Package{{ImportPath: "B"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			gta, err := New(
+				SetDiffer(difr),
+				SetPackager(pkgr),
+				SetSpecMappings(SpecMapping{Patterns: []string{"dirA/*.bin"}, Packages: []string{"B"}}),
+				SetBinaryFileMapping(tt.mapping),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := gta.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got.AllChanges); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGTA_BinaryFileMappingUnsupported(t *testing.T) {
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirA": {Exists: true, Files: []string{"fixture.bin"}},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(&testPackager{}), SetBinaryFileMapping(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error")
+	}
+}
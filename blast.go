@@ -0,0 +1,50 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import "errors"
+
+// A BlastRadius is the impact of the changes to a directly changed package.
+type BlastRadius struct {
+	// Dependents is the number of packages within the prefixes that the
+	// changes to the package affect, not counting the package itself.
+	Dependents int `json:"dependents"`
+
+	// Fraction is Dependents divided by the number of packages within the
+	// prefixes, from 0 to 1.
+	Fraction float64 `json:"fraction"`
+}
+
+// blastRadii returns the blast radii of the directly changed packages of cp.
+func (g *GTA) blastRadii(cp *Packages) (map[string]BlastRadius, error) {
+	namer, ok := g.packager.(PackageNamer)
+	if !ok {
+		return nil, errors.New("the packager does not report package names")
+	}
+
+	names, err := namer.PackageNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	for importPath := range names {
+		if g.included(importPath) {
+			total++
+		}
+	}
+
+	radii := make(map[string]BlastRadius, len(cp.Changes))
+	for _, pkg := range cp.Changes {
+		radius := BlastRadius{Dependents: len(cp.Dependencies[pkg.ImportPath])}
+		if total > 0 {
+			radius.Fraction = float64(radius.Dependents) / float64(total)
+		}
+		radii[pkg.ImportPath] = radius
+	}
+	return radii, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_BlastRadius(t *testing.T) {
+	// A depends on B
+	// C depends on A
+	// B and D changed; nothing depends on D
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true},
+			"dirD": Directory{Exists: true},
+		},
+	}
+
+	pkgr := &namedTestPackager{
+		testPackager: testPackager{
+			dirs2Imports: map[string]string{
+				"dirA": "A",
+				"dirB": "B",
+				"dirC": "C",
+				"dirD": "D",
+			},
+			graph: &Graph{
+				graph: map[string]map[string]bool{
+					"B": map[string]bool{
+						"A": true,
+					},
+					"A": map[string]bool{
+						"C": true,
+					},
+				},
+			},
+			errs: make(map[string]error),
+		},
+		names: map[string]string{
+			"A": "a",
+			"B": "b",
+			"C": "c",
+			"D": "d",
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetBlastRadiusReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]BlastRadius{
+		"B": {Dependents: 2, Fraction: 0.5},
+		"D": {Dependents: 0, Fraction: 0},
+	}
+	if diff := cmp.Diff(want, pkgs.BlastRadii); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_BlastRadiusUnsupported(t *testing.T) {
+	pkgr := &testPackager{
+		graph: &Graph{},
+	}
+
+	gta, err := New(SetDiffer(&testDiffer{}), SetPackager(pkgr), SetBlastRadiusReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A BuildGrapher is a Packager that distinguishes the imports of the code of
+// packages from the imports of their tests.
+type BuildGrapher interface {
+	// BuildDependentGraph returns the dependent graph of the packages whose
+	// non-test Go files import them, i.e. without the edges that are only
+	// due to test files.
+	BuildDependentGraph() (*Graph, error)
+}
+
+// BuildDependentGraph returns the dependent graph of the packages whose
+// non-test Go files import them.
+func (p *packageContext) BuildDependentGraph() (*Graph, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.buildReverse == nil {
+		p.buildReverse = buildReverseGraph(p.loaded)
+	}
+
+	graph := make(map[string]map[string]bool)
+	for k, dependents := range p.buildReverse {
+		inner := make(map[string]bool, len(dependents))
+		for dependent := range dependents {
+			inner[dependent] = true
+		}
+		graph[k] = inner
+	}
+	return &Graph{graph: graph}, nil
+}
+
+// buildReverseGraph returns the reverse dependency graph of roots and their
+// dependencies that only has the imports of the packages themselves, not of
+// their test variants, external tests, or test binaries.
+func buildReverseGraph(roots []*packages.Package) map[string]map[string]struct{} {
+	reverse := make(map[string]map[string]struct{})
+	seen := make(map[string]struct{})
+	var add func(pkg *packages.Package)
+	add = func(pkg *packages.Package) {
+		if _, ok := seen[pkg.ID]; ok {
+			return
+		}
+		seen[pkg.ID] = struct{}{}
+
+		// test variants have IDs of the form "path [path.test]", and external
+		// tests and test binaries have paths with a _test or .test suffix.
+		if pkg.ID != pkg.PkgPath || strings.HasSuffix(pkg.PkgPath, "_test") || strings.HasSuffix(pkg.PkgPath, ".test") {
+			return
+		}
+
+		for _, imported := range pkg.Imports {
+			add(imported)
+			importedPath := packagePath(imported)
+			if importedPath == pkg.PkgPath {
+				continue
+			}
+			if _, ok := reverse[importedPath]; !ok {
+				reverse[importedPath] = make(map[string]struct{})
+			}
+			reverse[importedPath][pkg.PkgPath] = struct{}{}
+		}
+	}
+
+	for _, pkg := range roots {
+		add(pkg)
+	}
+	return reverse
+}
+
+// buildTestAffected splits the packages in cp.AllChanges into the packages
+// whose compiled code is affected by the changes in m, and the packages that
+// are only affected through their tests: their own test files and fixtures,
+// and the test files of the packages that depend on the changed packages.
+// Every package is build-affected when g's packager does not implement
+// BuildGrapher.
+func (g *GTA) buildTestAffected(cp *Packages, m *marks) (build, test []string, err error) {
+	bg, ok := g.packager.(BuildGrapher)
+	if !ok {
+		return stringify(cp.AllChanges), []string{}, nil
+	}
+
+	graph, err := bg.BuildDependentGraph()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sources []string
+	for changed := range m.paths {
+		builds, err := g.changesBuild(changed, m.files[changed])
+		if err != nil {
+			return nil, nil, err
+		}
+		if builds {
+			sources = append(sources, changed)
+		}
+	}
+
+	// each graph is traversed with its own marks, because the traversal stops
+	// at nodes that are already marked.
+	affected := make(map[string]bool)
+	for _, tg := range g.traversed(graph) {
+		marked := make(map[string]bool)
+		for _, source := range sources {
+			if g.maxDepth > 0 {
+				marked[source] = true
+				for _, importPath := range tg.Reachable(source, g.maxDepth) {
+					marked[importPath] = true
+				}
+				continue
+			}
+			tg.Traverse(source, marked)
+		}
+		for importPath := range marked {
+			affected[importPath] = true
+		}
+	}
+
+	build, test = []string{}, []string{}
+	for _, pkg := range cp.AllChanges {
+		if affected[pkg.ImportPath] {
+			build = append(build, pkg.ImportPath)
+		} else {
+			test = append(test, pkg.ImportPath)
+		}
+	}
+	return build, test, nil
+}
+
+// changesBuild reports whether the changed files of the package importPath
+// change its compiled code. Test files, files within testdata directories,
+// and the package's fixtures do not. Packages that were changed without
+// changed files of their own, e.g. because of a go.mod file or a mapping,
+// change their compiled code.
+func (g *GTA) changesBuild(importPath string, files []string) (bool, error) {
+	if len(files) == 0 {
+		return true, nil
+	}
+
+	for _, fn := range files {
+		if strings.HasSuffix(fn, "_test.go") || inTestdata(fn) {
+			continue
+		}
+
+		consumers, err := g.fixtureConsumers(fn)
+		if err != nil {
+			return false, err
+		}
+		fixture := false
+		for _, consumer := range consumers {
+			if consumer == importPath {
+				fixture = true
+			}
+		}
+		if !fixture {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inTestdata reports whether the file fn is within a testdata directory.
+func inTestdata(fn string) bool {
+	for _, elem := range strings.Split(filepath.ToSlash(filepath.Dir(fn)), "/") {
+		if elem == "testdata" {
+			return true
+		}
+	}
+	return false
+}
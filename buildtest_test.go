@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+// newBuildTestModule creates a module example.com/m whose package svc imports
+// the package lib, and whose package check only imports lib in its tests, and
+// returns its directory and the loader of its packages.
+func newBuildTestModule(t *testing.T) (string, Loader) {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"lib/testdata", "svc", "check"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.mod", "module example.com/m\n\ngo 1.16\n")
+	writeFile(t, dir, "lib/lib.go", "package lib\n")
+	writeFile(t, dir, "lib/lib_test.go", "package lib\n")
+	writeFile(t, dir, "lib/testdata/golden.txt", "golden\n")
+	writeFile(t, dir, "svc/svc.go", "package svc\n\nimport _ \"example.com/m/lib\"\n")
+	writeFile(t, dir, "check/check.go", "package check\n")
+	writeFile(t, dir, "check/check_test.go", "package check\n\nimport _ \"example.com/m/lib\"\n")
+
+	module := &packages.Module{Path: "example.com/m", Dir: dir, Main: true}
+	lib := &packages.Package{
+		ID:      "example.com/m/lib",
+		Name:    "lib",
+		PkgPath: "example.com/m/lib",
+		GoFiles: []string{filepath.Join(dir, "lib", "lib.go")},
+		Module:  module,
+	}
+	libTest := &packages.Package{
+		ID:      "example.com/m/lib [example.com/m/lib.test]",
+		Name:    "lib",
+		PkgPath: "example.com/m/lib",
+		GoFiles: []string{filepath.Join(dir, "lib", "lib.go"), filepath.Join(dir, "lib", "lib_test.go")},
+		Module:  module,
+	}
+	svc := &packages.Package{
+		ID:      "example.com/m/svc",
+		Name:    "svc",
+		PkgPath: "example.com/m/svc",
+		GoFiles: []string{filepath.Join(dir, "svc", "svc.go")},
+		Imports: map[string]*packages.Package{"example.com/m/lib": lib},
+		Module:  module,
+	}
+	check := &packages.Package{
+		ID:      "example.com/m/check",
+		Name:    "check",
+		PkgPath: "example.com/m/check",
+		GoFiles: []string{filepath.Join(dir, "check", "check.go")},
+		Module:  module,
+	}
+	checkTest := &packages.Package{
+		ID:      "example.com/m/check [example.com/m/check.test]",
+		Name:    "check",
+		PkgPath: "example.com/m/check",
+		GoFiles: []string{filepath.Join(dir, "check", "check.go"), filepath.Join(dir, "check", "check_test.go")},
+		Imports: map[string]*packages.Package{"example.com/m/lib": lib},
+		Module:  module,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{lib, libTest, svc, check, checkTest}, nil
+	})
+	return dir, loader
+}
+
+func TestPackageContext_BuildDependentGraph(t *testing.T) {
+	_, loader := newBuildTestModule(t)
+
+	pkgr := NewPackagerWithLoader(loader, []string{"example.com/m/..."}, nil)
+	graph, err := pkgr.(BuildGrapher).BuildDependentGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]bool{
+		"example.com/m/lib": {"example.com/m/svc": true},
+	}
+	if diff := cmp.Diff(want, graph.graph); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_BuildTestReport(t *testing.T) {
+	dir, loader := newBuildTestModule(t)
+
+	tests := []struct {
+		desc      string
+		diff      map[string]Directory
+		wantBuild []string
+		wantTest  []string
+	}{
+		{
+			desc: "code",
+			diff: map[string]Directory{
+				filepath.Join(dir, "lib"): {Exists: true, Files: []string{"lib.go"}},
+			},
+			wantBuild: []string{"example.com/m/lib", "example.com/m/svc"},
+			wantTest:  []string{"example.com/m/check"},
+		},
+		{
+			desc: "test file",
+			diff: map[string]Directory{
+				filepath.Join(dir, "lib"): {Exists: true, Files: []string{"lib_test.go"}},
+			},
+			wantBuild: []string{},
+			wantTest:  []string{"example.com/m/check", "example.com/m/lib", "example.com/m/svc"},
+		},
+		{
+			desc: "code and test file",
+			diff: map[string]Directory{
+				filepath.Join(dir, "lib"):   {Exists: true, Files: []string{"lib_test.go"}},
+				filepath.Join(dir, "check"): {Exists: true, Files: []string{"check.go"}},
+			},
+			wantBuild: []string{"example.com/m/check"},
+			wantTest:  []string{"example.com/m/lib", "example.com/m/svc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			gt, err := New(SetDir(dir), SetLoader(loader), SetPrefixes("example.com/m/"), SetDiffer(&testDiffer{diff: tt.diff}), SetBuildTestReport(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantBuild, pkgs.BuildAffected); diff != "" {
+				t.Errorf("BuildAffected (-want, +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantTest, pkgs.TestAffected); diff != "" {
+				t.Errorf("TestAffected (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChangesBuild(t *testing.T) {
+	gt := &GTA{}
+	tests := []struct {
+		files []string
+		want  bool
+	}{
+		{files: nil, want: true},
+		{files: []string{"/m/lib/lib.go"}, want: true},
+		{files: []string{"/m/lib/lib_test.go"}, want: false},
+		{files: []string{"/m/lib/testdata/golden.txt"}, want: false},
+		{files: []string{"/m/lib/lib_test.go", "/m/lib/lib.s"}, want: true},
+	}
+
+	for _, tt := range tests {
+		got, err := gt.changesBuild("example.com/m/lib", tt.files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("changesBuild(%q) = %v; want %v", tt.files, got, tt.want)
+		}
+	}
+}
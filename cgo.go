@@ -0,0 +1,164 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A CgoFiler is a Packager that knows the files outside of their directories
+// that the packages that match the patterns it loaded include with cgo.
+type CgoFiler interface {
+	// CgoFiles returns the sorted absolute paths of the include directories
+	// named by the #cgo directives, and of the files included with relative
+	// paths, of the packages that match the patterns, keyed by their import
+	// paths. Directories and files within the packages' own directories are
+	// omitted.
+	CgoFiles() (map[string][]string, error)
+}
+
+// CgoFiles returns the sorted absolute paths of the include directories named
+// by the #cgo directives, and of the files included with relative paths, of
+// the packages that match the patterns, keyed by their import paths.
+func (p *packageContext) CgoFiles() (map[string][]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.cgo == nil {
+		p.cgo = cgoFiles(p.loaded)
+	}
+	return p.cgo, nil
+}
+
+// cgoFiles returns the sorted absolute paths of the include directories and
+// the included files outside of the packages' directories that the cgo
+// preambles of the Go files of roots refer to, keyed by the import paths of
+// the packages, into which test variants and external tests are flattened.
+func cgoFiles(roots []*packages.Package) map[string][]string {
+	sets := make(map[string]map[string]struct{})
+	for _, pkg := range roots {
+		if len(pkg.GoFiles) == 0 || strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		pkgPath := normalizeImportPath(pkg)
+		for _, fn := range pkg.GoFiles {
+			if filepath.Ext(fn) != ".go" {
+				continue
+			}
+			for _, ref := range cgoReferences(fn) {
+				if _, ok := sets[pkgPath]; !ok {
+					sets[pkgPath] = make(map[string]struct{})
+				}
+				sets[pkgPath][ref] = struct{}{}
+			}
+		}
+	}
+
+	refs := setsToLists(sets)
+	for _, list := range refs {
+		sort.Strings(list)
+	}
+	return refs
+}
+
+// cgoReferences returns the absolute paths of the existing include
+// directories of the -I, -iquote, and -isystem flags of the #cgo directives
+// of the Go file fn, and of the existing files of its #include directives with
+// relative paths, that are outside of fn's directory. Directories that contain
+// fn's directory are omitted, because every change would be within them.
+func cgoReferences(fn string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), fn, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	// the preamble of import "C" is its doc comment, which is that of the
+	// declaration when it is not in a parenthesized import block.
+	var preambles []string
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			is := spec.(*ast.ImportSpec)
+			if path, err := strconv.Unquote(is.Path.Value); err != nil || path != "C" {
+				continue
+			}
+			doc := is.Doc
+			if doc == nil && !gd.Lparen.IsValid() {
+				doc = gd.Doc
+			}
+			if doc != nil {
+				preambles = append(preambles, doc.Text())
+			}
+		}
+	}
+
+	dir := filepath.Dir(fn)
+	var refs []string
+	add := func(p string, wantDir bool) {
+		p = strings.Replace(p, "${SRCDIR}", dir, -1)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		p = filepath.Clean(p)
+		fi, err := os.Stat(p)
+		if err != nil || fi.IsDir() != wantDir || withinDir(p, dir) || withinDir(dir, p) {
+			return
+		}
+		refs = append(refs, p)
+	}
+
+	for _, preamble := range preambles {
+		scanner := bufio.NewScanner(strings.NewReader(preamble))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			switch {
+			case strings.HasPrefix(line, "#cgo "):
+				i := strings.Index(line, ":")
+				if i < 0 {
+					continue
+				}
+				fields := strings.Fields(line[i+1:])
+				for j := 0; j < len(fields); j++ {
+					for _, flag := range []string{"-I", "-iquote", "-isystem"} {
+						if !strings.HasPrefix(fields[j], flag) {
+							continue
+						}
+						if inc := strings.TrimPrefix(fields[j], flag); inc != "" {
+							add(inc, true)
+						} else if j+1 < len(fields) {
+							j++
+							add(fields[j], true)
+						}
+						break
+					}
+				}
+			case strings.HasPrefix(line, "#include"):
+				inc := strings.TrimSpace(strings.TrimPrefix(line, "#include"))
+				if !strings.HasPrefix(inc, `"`) {
+					continue
+				}
+				if end := strings.Index(inc[1:], `"`); end >= 0 {
+					add(inc[1:end+1], false)
+				}
+			}
+		}
+	}
+	return refs
+}
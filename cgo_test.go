@@ -0,0 +1,129 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+// newCgoModule creates a module example.com/m whose package pkg includes
+// headers from other directories with cgo, and returns its directory and the
+// loader of its packages.
+func newCgoModule(t *testing.T) (string, Loader) {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"include", "sys", "common", "pkg", "cmd"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.mod", "module example.com/m\n\ngo 1.16\n")
+	writeFile(t, dir, "include/foo.h", "int foo(void);\n")
+	writeFile(t, dir, "sys/sys.h", "int sys(void);\n")
+	writeFile(t, dir, "common/util.h", "int util(void);\n")
+	writeFile(t, dir, "common/other.h", "int other(void);\n")
+	writeFile(t, dir, "pkg/local.h", "int local(void);\n")
+	writeFile(t, dir, "pkg/pkg.go", `package pkg
+
+// #cgo CFLAGS: -I${SRCDIR}/../include -I /usr/include/nonexistent -I. -O2
+// #cgo linux CPPFLAGS: -isystem ../sys
+// #include "../common/util.h"
+// #include "local.h"
+// #include <stdio.h>
+import "C"
+`)
+	writeFile(t, dir, "cmd/main.go", "package main\n\nimport (\n\t\"fmt\"\n\n\t_ \"example.com/m/pkg\"\n)\n\nfunc main() { fmt.Println() }\n")
+
+	module := &packages.Module{Path: "example.com/m", Dir: dir, Main: true}
+	pkg := &packages.Package{
+		ID:      "example.com/m/pkg",
+		Name:    "pkg",
+		PkgPath: "example.com/m/pkg",
+		GoFiles: []string{filepath.Join(dir, "pkg", "pkg.go")},
+		Module:  module,
+	}
+	cmd := &packages.Package{
+		ID:      "example.com/m/cmd",
+		Name:    "main",
+		PkgPath: "example.com/m/cmd",
+		GoFiles: []string{filepath.Join(dir, "cmd", "main.go")},
+		Imports: map[string]*packages.Package{"example.com/m/pkg": pkg},
+		Module:  module,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{pkg, cmd}, nil
+	})
+	return dir, loader
+}
+
+func TestPackageContext_CgoFiles(t *testing.T) {
+	dir, loader := newCgoModule(t)
+
+	pkgr := NewPackagerWithLoader(loader, []string{"example.com/m/..."}, nil)
+	got, err := pkgr.(CgoFiler).CgoFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"example.com/m/pkg": {
+			filepath.Join(dir, "common", "util.h"),
+			filepath.Join(dir, "include"),
+			filepath.Join(dir, "sys"),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_CgoFiles(t *testing.T) {
+	dir, loader := newCgoModule(t)
+
+	tests := []struct {
+		desc string
+		dir  string
+		file string
+		want []string
+	}{
+		{desc: "include directory", dir: "include", file: "foo.h", want: []string{"example.com/m/cmd", "example.com/m/pkg"}},
+		{desc: "isystem directory", dir: "sys", file: "sys.h", want: []string{"example.com/m/cmd", "example.com/m/pkg"}},
+		{desc: "included file", dir: "common", file: "util.h", want: []string{"example.com/m/cmd", "example.com/m/pkg"}},
+		{desc: "not included", dir: "common", file: "other.h", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			difr := &testDiffer{
+				diff: map[string]Directory{
+					filepath.Join(dir, tt.dir): {Exists: true, Files: []string{tt.file}},
+				},
+			}
+			gt, err := New(SetDir(dir), SetLoader(loader), SetPrefixes("example.com/m/"), SetDiffer(difr))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
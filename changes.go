@@ -0,0 +1,202 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStatus describes how a file was changed.
+type FileStatus string
+
+const (
+	// StatusAdded indicates that a file was added.
+	StatusAdded FileStatus = "added"
+
+	// StatusModified indicates that the content of a file was changed.
+	StatusModified FileStatus = "modified"
+
+	// StatusDeleted indicates that a file was deleted.
+	StatusDeleted FileStatus = "deleted"
+
+	// StatusRenamed indicates that a file was moved from OldPath.
+	StatusRenamed FileStatus = "renamed"
+
+	// StatusCopied indicates that a file was copied from OldPath.
+	StatusCopied FileStatus = "copied"
+)
+
+// A FileChange describes a change to a file.
+type FileChange struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+
+	// Status describes how the file was changed. When it is empty, whether
+	// the file exists is determined from the file system.
+	Status FileStatus `json:"status,omitempty"`
+
+	// OldPath is the absolute path the file was renamed or copied from.
+	OldPath string `json:"old_path,omitempty"`
+}
+
+// validate returns an error when c is not a valid change.
+func (c FileChange) validate() error {
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %q must be absolute", c.Path)
+	}
+
+	switch c.Status {
+	case "", StatusAdded, StatusModified, StatusDeleted:
+		if c.OldPath != "" {
+			return fmt.Errorf("old path of %q must only be set when it was renamed or copied", c.Path)
+		}
+	case StatusRenamed, StatusCopied:
+		if !filepath.IsAbs(c.OldPath) {
+			return fmt.Errorf("old path %q of %q must be absolute", c.OldPath, c.Path)
+		}
+	default:
+		return fmt.Errorf("invalid status %q of %q", c.Status, c.Path)
+	}
+
+	return nil
+}
+
+// A FileChangeDiffer is a Differ that describes how each changed file was
+// changed.
+type FileChangeDiffer interface {
+	Differ
+
+	// FileChanges returns the changes to the changed files. Files whose
+	// status is unknown have an empty status.
+	FileChanges() ([]FileChange, error)
+}
+
+// NewFileChangesDiffer returns a Differ that operates on a list of changes to
+// files. Renamed files are treated as the deletion of the old path and the
+// addition of the new path, and copied files as the addition of the new path.
+// The existence of files is determined by their status, so that deletions are
+// conveyed without regard to the state of the file system. The returned Differ
+// is also a FileChangeDiffer.
+func NewFileChangesDiffer(changes []FileChange) (Differ, error) {
+	files := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+
+		switch c.Status {
+		case "":
+			files[c.Path] = exists(c.Path)
+		case StatusDeleted:
+			files[c.Path] = false
+		case StatusRenamed:
+			files[c.OldPath] = false
+			files[c.Path] = true
+		default:
+			files[c.Path] = true
+		}
+	}
+
+	return &changesDiffer{files: files, changes: changes}, nil
+}
+
+// changesDiffer implements the Differ interface for a set of changed files
+// whose existence is known.
+type changesDiffer struct {
+	// files maps the absolute paths of changed files to whether they exist.
+	files map[string]bool
+	// changes are the changes the files were determined from, if any.
+	changes []FileChange
+}
+
+// FileChanges returns the changes to the changed files.
+func (d *changesDiffer) FileChanges() ([]FileChange, error) {
+	return append([]FileChange(nil), d.changes...), nil
+}
+
+// Diff returns a set of changed directories. The keys of the returned map are
+// absolute paths.
+func (d *changesDiffer) Diff() (map[string]Directory, error) {
+	dirs := make(map[string]Directory)
+	for abs := range d.files {
+		absdir := filepath.Dir(abs)
+		dir, ok := dirs[absdir]
+		if !ok {
+			dir.Exists = exists(absdir)
+		}
+
+		dir.Files = append(dir.Files, filepath.Base(abs))
+		dirs[absdir] = dir
+	}
+
+	return dirs, nil
+}
+
+// DiffFiles returns a set of changed files. The keys of the returned map are
+// absolute paths. The map values indicate whether or not the file exists.
+func (d *changesDiffer) DiffFiles() (map[string]bool, error) {
+	files := make(map[string]bool, len(d.files))
+	for abs, ok := range d.files {
+		files[abs] = ok
+	}
+	return files, nil
+}
+
+// fileChanges returns a map of the absolute paths of the files changed
+// according to difr to their changes. The paths that files were renamed from
+// are deleted. The status of files whose status difr does not know, including
+// all files when difr is not a FileChangeDiffer, is determined from whether
+// they exist now and, when difr is a BaseReader, before the change.
+func fileChanges(difr Differ) (map[string]FileChange, error) {
+	files, err := difr.DiffFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FileChange, len(files))
+	if fcd, ok := difr.(FileChangeDiffer); ok {
+		described, err := fcd.FileChanges()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range described {
+			if c.Status == "" {
+				continue
+			}
+			if _, ok := files[c.Path]; ok {
+				changes[c.Path] = c
+			}
+			if _, ok := files[c.OldPath]; ok && c.Status == StatusRenamed {
+				if _, ok := changes[c.OldPath]; !ok {
+					changes[c.OldPath] = FileChange{Path: c.OldPath, Status: StatusDeleted}
+				}
+			}
+		}
+	}
+
+	br, _ := difr.(BaseReader)
+	for abs, exists := range files {
+		if _, ok := changes[abs]; ok {
+			continue
+		}
+
+		status := StatusDeleted
+		if exists {
+			status = StatusModified
+			if br != nil {
+				if _, err := br.ReadBase(abs); errors.Is(err, os.ErrNotExist) {
+					status = StatusAdded
+				}
+			}
+		}
+		changes[abs] = FileChange{Path: abs, Status: status}
+	}
+	return changes, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileChangesDiffer(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.go")
+	writeFile(t, dir, "existing.go", "package foo\n")
+
+	changes := []FileChange{
+		{Path: existing},
+		{Path: filepath.Join(dir, "missing.go")},
+		{Path: filepath.Join(dir, "added.go"), Status: StatusAdded},
+		{Path: filepath.Join(dir, "deleted.go"), Status: StatusDeleted},
+		{Path: filepath.Join(dir, "new", "renamed.go"), Status: StatusRenamed, OldPath: filepath.Join(dir, "old", "renamed.go")},
+		{Path: filepath.Join(dir, "copy.go"), Status: StatusCopied, OldPath: existing},
+	}
+
+	sut, err := NewFileChangesDiffer(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotFiles, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := map[string]bool{
+		existing:                                true,
+		filepath.Join(dir, "missing.go"):        false,
+		filepath.Join(dir, "added.go"):          true,
+		filepath.Join(dir, "deleted.go"):        false,
+		filepath.Join(dir, "new", "renamed.go"): true,
+		filepath.Join(dir, "old", "renamed.go"): false,
+		filepath.Join(dir, "copy.go"):           true,
+	}
+	if diff := cmp.Diff(wantFiles, gotFiles); diff != "" {
+		t.Errorf("DiffFiles (-want, +got)\n%s", diff)
+	}
+
+	gotDirs, err := sut.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range gotDirs {
+		sort.Strings(d.Files)
+	}
+
+	wantDirs := map[string]Directory{
+		dir: Directory{
+			Exists: true,
+			Files:  []string{"added.go", "copy.go", "deleted.go", "existing.go", "missing.go"},
+		},
+		filepath.Join(dir, "new"): Directory{Files: []string{"renamed.go"}},
+		filepath.Join(dir, "old"): Directory{Files: []string{"renamed.go"}},
+	}
+	if diff := cmp.Diff(wantDirs, gotDirs); diff != "" {
+		t.Errorf("Diff (-want, +got)\n%s", diff)
+	}
+}
+
+func TestFileChangesDiffer_Invalid(t *testing.T) {
+	tests := []struct {
+		desc   string
+		change FileChange
+	}{
+		{
+			desc:   "relative path",
+			change: FileChange{Path: "foo.go"},
+		},
+		{
+			desc:   "unknown status",
+			change: FileChange{Path: "/foo.go", Status: "moved"},
+		},
+		{
+			desc:   "rename without old path",
+			change: FileChange{Path: "/foo.go", Status: StatusRenamed},
+		},
+		{
+			desc:   "old path without rename",
+			change: FileChange{Path: "/foo.go", Status: StatusModified, OldPath: "/bar.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if _, err := NewFileChangesDiffer([]FileChange{tt.change}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
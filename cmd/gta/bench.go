@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/gta"
+	"github.com/digitalocean/gta/internal/synthetic"
+	"golang.org/x/tools/go/packages"
+)
+
+// bench measures the time to build and traverse synthetic dependency graphs.
+// It returns an error when a measurement exceeds its budget so that it can be
+// used to catch performance regressions.
+func bench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	flagPackages := fs.String("packages", "10000,50000", "comma separated numbers of packages in the synthetic graphs")
+	flagFanout := fs.Int("fanout", 5, "maximum number of imports of each synthetic package")
+	flagSeed := fs.Int64("seed", 1, "seed used to generate the synthetic graphs")
+	flagCount := fs.Int("count", 5, "number of times to run each measurement")
+	flagMaxGraph := fs.Duration("max-graph", 0, "fail when building a graph takes longer than this on average, when non-zero")
+	flagMaxTraverse := fs.Duration("max-traverse", 0, "fail when traversing a graph takes longer than this on average, when non-zero")
+	fs.Parse(args)
+
+	if *flagCount < 1 {
+		return fmt.Errorf("-count must be positive")
+	}
+
+	var exceeded []string
+	for _, s := range parseStringSlice(*flagPackages) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid number of packages %q: %w", s, err)
+		}
+
+		pkgs := synthetic.Packages(n, *flagFanout, *flagSeed)
+		loader := gta.LoaderFunc(func(*packages.Config, ...string) ([]*packages.Package, error) {
+			return pkgs, nil
+		})
+
+		var graph *gta.Graph
+		start := time.Now()
+		for i := 0; i < *flagCount; i++ {
+			graph, err = gta.NewPackagerWithLoader(loader, nil, nil).DependentGraph()
+			if err != nil {
+				return err
+			}
+		}
+		graphDuration := time.Since(start) / time.Duration(*flagCount)
+
+		start = time.Now()
+		for i := 0; i < *flagCount; i++ {
+			// the first package is depended on by the most packages.
+			graph.Traverse(synthetic.ImportPath(0), make(map[string]bool))
+		}
+		traverseDuration := time.Since(start) / time.Duration(*flagCount)
+
+		fmt.Printf("packages=%d\tgraph=%v\ttraverse=%v\n", n, graphDuration, traverseDuration)
+
+		if *flagMaxGraph > 0 && graphDuration > *flagMaxGraph {
+			exceeded = append(exceeded, fmt.Sprintf("building a graph of %d packages took %v, more than %v", n, graphDuration, *flagMaxGraph))
+		}
+		if *flagMaxTraverse > 0 && traverseDuration > *flagMaxTraverse {
+			exceeded = append(exceeded, fmt.Sprintf("traversing a graph of %d packages took %v, more than %v", n, traverseDuration, *flagMaxTraverse))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("performance budget exceeded: %s", strings.Join(exceeded, "; "))
+	}
+
+	return nil
+}
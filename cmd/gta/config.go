@@ -0,0 +1,217 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/digitalocean/gta"
+)
+
+// config is the format of the configuration file. Relative paths in the file
+// are relative to the directory that contains the file.
+type config struct {
+	// dir is the absolute path of the directory that contains the file.
+	dir string
+
+	// Include and Ignore are used when -include and -ignore are not set.
+	Include []string `json:"include,omitempty"`
+	Ignore  []string `json:"ignore,omitempty"`
+
+	// Triggers are the glob patterns of the files whose changes mark every
+	// package, such as build scripts.
+	Triggers []string        `json:"triggers,omitempty"`
+	Mappings []mappingConfig `json:"mappings,omitempty"`
+
+	Fixtures   []fixtureConfig    `json:"fixtures,omitempty"`
+	Migrations []migrationsConfig `json:"migrations,omitempty"`
+	Specs      []specConfig       `json:"specs,omitempty"`
+	TagSets    []tagSetConfig     `json:"tag_sets,omitempty"`
+
+	// DetectGenerateInputs enables the detection of the inputs of go:generate
+	// directives, and Generators adds inputs for generators that read files
+	// not named by the arguments of their directives.
+	DetectGenerateInputs bool              `json:"detect_generate_inputs,omitempty"`
+	Generators           []generatorConfig `json:"generators,omitempty"`
+}
+
+// fixtureConfig describes a directory of test fixtures that is shared by
+// packages.
+type fixtureConfig struct {
+	Dir             string   `json:"dir"`
+	Consumers       []string `json:"consumers,omitempty"`
+	DetectConsumers bool     `json:"detect_consumers,omitempty"`
+}
+
+// migrationsConfig describes a directory of database migrations and the
+// packages that embed or reference it.
+type migrationsConfig struct {
+	Dir            string   `json:"dir"`
+	Packages       []string `json:"packages,omitempty"`
+	DetectPackages bool     `json:"detect_packages,omitempty"`
+}
+
+// mappingConfig maps the files matched by a glob pattern to the directories of
+// packages or, when they start with a colon, pseudo-targets, like the rules of
+// a mapping rules file.
+type mappingConfig struct {
+	Pattern string   `json:"pattern"`
+	Targets []string `json:"targets"`
+}
+
+// specConfig maps API specification files, matched by glob patterns, to the
+// packages generated from them.
+type specConfig struct {
+	Patterns []string `json:"patterns"`
+	Packages []string `json:"packages"`
+}
+
+// generatorConfig maps a generator run by go:generate directives to the glob
+// patterns of its inputs, relative to the directories of the packages with
+// the directives.
+type generatorConfig struct {
+	Generator string   `json:"generator"`
+	Patterns  []string `json:"patterns"`
+}
+
+// tagSetConfig is a named build tag configuration under which the affected
+// packages are reported in the json output.
+type tagSetConfig struct {
+	Name   string   `json:"name"`
+	Tags   []string `json:"tags,omitempty"`
+	GOOS   string   `json:"goos,omitempty"`
+	GOARCH string   `json:"goarch,omitempty"`
+}
+
+// loadConfig reads the configuration file fn.
+func loadConfig(fn string) (*config, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := new(config)
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.dir = filepath.Dir(abs)
+
+	return cfg, nil
+}
+
+// path returns the absolute path of the path p from the configuration file.
+func (c *config) path(p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(p))
+}
+
+// options returns the gta options for the configuration.
+func (c *config) options() []gta.Option {
+	var fixtures []gta.Fixture
+	for _, f := range c.Fixtures {
+		fixtures = append(fixtures, gta.Fixture{
+			Dir:             c.path(f.Dir),
+			Consumers:       f.Consumers,
+			DetectConsumers: f.DetectConsumers,
+		})
+	}
+
+	var migrations []gta.Migrations
+	for _, m := range c.Migrations {
+		migrations = append(migrations, gta.Migrations{
+			Dir:            c.path(m.Dir),
+			Packages:       m.Packages,
+			DetectPackages: m.DetectPackages,
+		})
+	}
+
+	var specs []gta.SpecMapping
+	for _, spec := range c.Specs {
+		var patterns []string
+		for _, pattern := range spec.Patterns {
+			patterns = append(patterns, c.path(pattern))
+		}
+		specs = append(specs, gta.SpecMapping{
+			Patterns: patterns,
+			Packages: spec.Packages,
+		})
+	}
+
+	var tagSets []gta.TagSet
+	for _, set := range c.TagSets {
+		tagSets = append(tagSets, gta.TagSet{
+			Name:   set.Name,
+			Tags:   set.Tags,
+			GOOS:   set.GOOS,
+			GOARCH: set.GOARCH,
+		})
+	}
+
+	opts := []gta.Option{
+		gta.SetFixtures(fixtures...),
+		gta.SetMigrations(migrations...),
+		gta.SetSpecMappings(specs...),
+		gta.SetTagSets(tagSets...),
+	}
+
+	if triggers := c.triggers(); len(triggers) > 0 {
+		opts = append(opts, gta.SetTriggerFiles(triggers...))
+	}
+
+	if c.DetectGenerateInputs || len(c.Generators) > 0 {
+		var mappings []gta.GenerateMapping
+		for _, gen := range c.Generators {
+			mappings = append(mappings, gta.GenerateMapping{
+				Generator: gen.Generator,
+				Patterns:  gen.Patterns,
+			})
+		}
+		opts = append(opts, gta.SetGenerateInputs(mappings...))
+	}
+
+	return opts
+}
+
+// triggers returns the absolute glob patterns of the trigger files of the
+// configuration.
+func (c *config) triggers() []string {
+	var triggers []string
+	for _, pattern := range c.Triggers {
+		triggers = append(triggers, c.path(pattern))
+	}
+	return triggers
+}
+
+// mappingRules returns the mapping rules of the configuration.
+func (c *config) mappingRules() []gta.MappingRule {
+	var rules []gta.MappingRule
+	for _, m := range c.Mappings {
+		rule := gta.MappingRule{Pattern: c.path(m.Pattern)}
+		for _, target := range m.Targets {
+			if strings.HasPrefix(target, ":") {
+				rule.Targets = append(rule.Targets, strings.TrimPrefix(target, ":"))
+				continue
+			}
+			rule.Dirs = append(rule.Dirs, c.path(target))
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"os"
+
+	"github.com/digitalocean/gta"
+)
+
+// writeDependencyGraph writes the dependency graph loaded by gt to the file
+// fn.
+func writeDependencyGraph(fn string, gt *gta.GTA) error {
+	graph, err := gt.ExportDependencyGraph()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+
+	if err := graph.Encode(f); err != nil {
+		f.Close()
+		os.Remove(fn)
+		return err
+	}
+
+	return f.Close()
+}
+
+// readDependencyGraph reads the dependency graph in the file fn.
+func readDependencyGraph(fn string) (*gta.DependencyGraph, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return gta.DecodeDependencyGraph(f)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/digitalocean/gta"
+)
+
+// parseExportFlags parses the arguments of the export command and returns the
+// path of the archive to write.
+func parseExportFlags(args []string) string {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	flagOutput := fs.String("o", "", "path of the archive to write")
+	fs.Parse(args)
+
+	return *flagOutput
+}
+
+// export writes the archive of the analysis of gt to the file fn.
+func export(gt *gta.GTA, fn string, prov gta.Provenance) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+
+	if err := gt.Export(f, prov); err != nil {
+		f.Close()
+		os.Remove(fn)
+		return err
+	}
+
+	return f.Close()
+}
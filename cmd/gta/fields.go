@@ -0,0 +1,56 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/digitalocean/gta"
+)
+
+// checkJSONFields returns an error when any of fields is not a field of the
+// json output.
+func checkJSONFields(fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	all := gta.JSONFields()
+	known := make(map[string]bool, len(all))
+	for _, f := range all {
+		known[f] = true
+	}
+	for _, field := range fields {
+		if !known[field] {
+			return fmt.Errorf("unknown json field %q: must be one of %s", field, strings.Join(all, ", "))
+		}
+	}
+	return nil
+}
+
+// selectJSONFields returns the json encoding of pkgs with only fields.
+func selectJSONFields(pkgs *gta.Packages, fields []string) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(pkgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if v, ok := all[field]; ok {
+			selected[field] = v
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"testing"
+
+	"github.com/digitalocean/gta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckJSONFields(t *testing.T) {
+	tests := []struct {
+		desc    string
+		fields  []string
+		wantErr bool
+	}{
+		{
+			desc: "none",
+		},
+		{
+			desc:   "known",
+			fields: []string{"dependencies", "changes", "all_changes", "meta", "partial"},
+		},
+		{
+			desc:    "unknown",
+			fields:  []string{"changes", "owners"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if err := checkJSONFields(tt.fields); (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want an error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelectJSONFields(t *testing.T) {
+	pkgs := &gta.Packages{
+		Changes:    []gta.Package{{ImportPath: "a"}},
+		AllChanges: []gta.Package{{ImportPath: "a"}, {ImportPath: "b"}},
+		Partial:    true,
+	}
+
+	got, err := selectJSONFields(pkgs, []string{"all_changes", "partial", "orphans"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"all_changes": `["a","b"]`,
+		"partial":     `true`,
+	}
+	gotStrings := make(map[string]string)
+	for k, v := range got {
+		gotStrings[k] = string(v)
+	}
+	if diff := cmp.Diff(want, gotStrings); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/digitalocean/gta"
+)
+
+// graphFlags are the flags of the graph command.
+type graphFlags struct {
+	format   string
+	affected bool
+
+	// query is the query to answer, deps, rdeps, path, why, or cycles, and
+	// args are its packages. The graph is written when query is empty.
+	query      string
+	args       []string
+	transitive bool
+	maxDepth   int
+}
+
+// parseGraphFlags parses the arguments of the graph command.
+func parseGraphFlags(args []string) (*graphFlags, error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "deps", "rdeps", "path", "why", "cycles":
+			return parseGraphQueryFlags(args[0], args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	flagFormat := fs.String("format", "dot", "format of the graph: dot, json, or graphml; json and graphml include the files of the packages")
+	flagAffected := fs.Bool("affected", false, "write only the affected packages and the edges between them")
+	fs.Parse(args)
+
+	switch *flagFormat {
+	case "dot", "json", "graphml":
+	default:
+		return nil, fmt.Errorf("unknown graph format %q", *flagFormat)
+	}
+
+	return &graphFlags{format: *flagFormat, affected: *flagAffected}, nil
+}
+
+// parseGraphQueryFlags parses the arguments of the graph query query.
+func parseGraphQueryFlags(query string, args []string) (*graphFlags, error) {
+	fs := flag.NewFlagSet("graph "+query, flag.ExitOnError)
+	flagTransitive := fs.Bool("transitive", false, "list the packages reachable through any number of imports instead of only direct imports")
+	flagMaxDepth := fs.Int("max-depth", 0, "the maximum number of imports between the packages; implies -transitive; zero means no limit")
+	fs.Parse(args)
+
+	want := 1
+	switch query {
+	case "path", "why":
+		want = 2
+	case "cycles":
+		want = 0
+	}
+	if fs.NArg() != want {
+		return nil, fmt.Errorf("graph %s takes %d package(s), got %d", query, want, fs.NArg())
+	}
+	if *flagMaxDepth < 0 {
+		return nil, errors.New("-max-depth must not be negative")
+	}
+
+	return &graphFlags{
+		query:      query,
+		args:       fs.Args(),
+		transitive: *flagTransitive || *flagMaxDepth > 0,
+		maxDepth:   *flagMaxDepth,
+	}, nil
+}
+
+// queryGraph writes the answer to the query of gf about graph, a graph of
+// dependents, to w, one package per line: the packages that the package
+// imports for deps, the packages that import it for rdeps, the packages of a
+// shortest chain of imports from the first package to the second for path,
+// and the chain that explains why changing the first package affects the
+// second for why. For cycles, the packages of each import cycle that contains
+// a package with one of the include prefixes, or of every import cycle when
+// there are no include prefixes, are written on a line, separated by spaces.
+func queryGraph(w io.Writer, gf *graphFlags, graph *gta.Graph, include []string) error {
+	nodes := make(map[string]struct{})
+	for _, node := range graph.Nodes() {
+		nodes[node] = struct{}{}
+	}
+	for _, pkg := range gf.args {
+		if _, ok := nodes[pkg]; !ok {
+			return fmt.Errorf("%s is not in the dependency graph", pkg)
+		}
+	}
+
+	if gf.query == "cycles" {
+		for _, cycle := range graph.Cycles() {
+			if len(include) > 0 && !anyHasPrefixIn(cycle, include) {
+				continue
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(cycle, " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	depth := gf.maxDepth
+	if !gf.transitive {
+		depth = 1
+	}
+
+	var answer []string
+	switch gf.query {
+	case "deps":
+		answer = graph.Reversed().Reachable(gf.args[0], depth)
+	case "rdeps":
+		answer = graph.Reachable(gf.args[0], depth)
+	case "path":
+		// the dependencies are followed from the first package.
+		answer = graph.Reversed().Path(gf.args[0], gf.args[1])
+		if answer == nil || (gf.maxDepth > 0 && len(answer)-1 > gf.maxDepth) {
+			return fmt.Errorf("%s does not import %s", gf.args[0], gf.args[1])
+		}
+	case "why":
+		var err error
+		if answer, err = graph.Why(gf.args[0], gf.args[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, pkg := range answer {
+		if _, err := fmt.Fprintln(w, pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGraph writes the dependency graph of an analysis by gt whose results
+// are packages to w in the format of gf. The directly changed packages are
+// highlighted.
+func writeGraph(w io.Writer, gf *graphFlags, gt *gta.GTA, graph *gta.Graph, packages *gta.Packages) error {
+	if graph == nil {
+		return errors.New("the dependency graph was not built")
+	}
+
+	var changes []string
+	for _, pkg := range packages.Changes {
+		changes = append(changes, pkg.ImportPath)
+	}
+	opts := []gta.GraphOption{gta.GraphHighlight(changes...)}
+	if gf.affected {
+		var affected []string
+		for _, pkg := range packages.AllChanges {
+			affected = append(affected, pkg.ImportPath)
+		}
+		opts = append(opts, gta.GraphNodes(affected...))
+	}
+
+	switch gf.format {
+	case "json", "graphml":
+		files, err := gt.PackageFiles()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, gta.GraphFiles(files))
+		if gf.format == "json" {
+			return graph.WriteJSON(w, opts...)
+		}
+		return graph.WriteGraphML(w, opts...)
+	}
+	return graph.WriteDOT(w, opts...)
+}
+
+// anyHasPrefixIn reports whether any of pkgs has one of prefixes.
+func anyHasPrefixIn(pkgs, prefixes []string) bool {
+	for _, pkg := range pkgs {
+		if hasPrefixIn(pkg, prefixes) {
+			return true
+		}
+	}
+	return false
+}
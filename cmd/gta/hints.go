@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/gta"
+)
+
+// minWholeRepo is the number of included packages below which affecting all
+// of them is not suspicious.
+const minWholeRepo = 10
+
+// graphRecorder is a gta.Reporter that records the dependency graph of the
+// analysis before reporting the changed packages with a GTA.
+type graphRecorder struct {
+	gt    *gta.GTA
+	graph *gta.Graph
+}
+
+// Report implements the gta.Reporter interface.
+func (r *graphRecorder) Report(impact *gta.Impact) (*gta.Packages, error) {
+	r.graph = impact.Graph
+	return r.gt.Report(impact)
+}
+
+// hintInput describes an analysis whose results hints explain.
+type hintInput struct {
+	// vcs is the version control system or other source of the changes.
+	vcs     string
+	base    string
+	include []string
+	// files are the changed files reported by the differ.
+	files map[string]bool
+	// triggers are the absolute glob patterns of the trigger files.
+	triggers []string
+	// nodes are the packages of the dependency graph, which are nil when it
+	// was not built.
+	nodes    []string
+	packages *gta.Packages
+}
+
+// hints returns hints about the likely causes of results that are commonly
+// misinterpreted: no affected packages, or every included package affected.
+func hints(in hintInput) []string {
+	switch {
+	case len(in.packages.AllChanges) == 0:
+		return emptyHints(in)
+	case in.nodes != nil:
+		return wholeRepoHints(in)
+	}
+	return nil
+}
+
+// emptyHints returns hints about why no packages are affected.
+func emptyHints(in hintInput) []string {
+	switch {
+	case len(in.files) > 0 && len(in.include) > 0:
+		return []string{fmt.Sprintf("no package matching -include contains any of the %d changed files; check -include, or use -config to map non-Go files to packages", len(in.files))}
+	case len(in.files) > 0:
+		return []string{fmt.Sprintf("no package contains any of the %d files that the %s differ reported as changed relative to %s; check that -vcs and -base find the intended changes, or use -config to map non-Go files to packages", len(in.files), in.vcs, in.base)}
+	}
+
+	switch in.vcs {
+	case "git":
+		return []string{fmt.Sprintf("no files changed relative to %s; check -base and fetch it if it is out of date, e.g. with -fetch-remote origin, or use -include-working-tree to include uncommitted changes", in.base)}
+	case "hg", "p4", "svn":
+		return []string{fmt.Sprintf("no files changed relative to %s; check -base", in.base)}
+	}
+	return []string{fmt.Sprintf("the %s differ reported no changed files", in.vcs)}
+}
+
+// wholeRepoHints returns hints about why every included package is affected,
+// when they are.
+func wholeRepoHints(in hintInput) []string {
+	// every package is included when -include is not set.
+	included := len(in.nodes)
+	if len(in.include) > 0 {
+		included = 0
+		for _, node := range in.nodes {
+			if hasPrefixIn(node, in.include) {
+				included++
+			}
+		}
+	}
+	if included < minWholeRepo || len(in.packages.AllChanges) < included {
+		return nil
+	}
+
+	var out []string
+	for abs := range in.files {
+		switch filepath.Base(abs) {
+		case "go.mod", "go.sum", "modules.txt":
+			out = append(out, fmt.Sprintf("%s changed, which can affect every package that imports a dependency; use -file-changes to see the changes", abs))
+		}
+		for _, pattern := range in.triggers {
+			if gta.MatchGlob(filepath.ToSlash(pattern), filepath.ToSlash(abs)) {
+				out = append(out, fmt.Sprintf("trigger file %s changed, which marks every package as changed; check the triggers of -config", abs))
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+
+	// the changed packages with the most dependents are the likeliest causes.
+	changes := append([]gta.Package(nil), in.packages.Changes...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return len(in.packages.Dependencies[changes[i].ImportPath]) > len(in.packages.Dependencies[changes[j].ImportPath])
+	})
+	if len(changes) > 0 {
+		top := changes[0].ImportPath
+		out = append(out, fmt.Sprintf("all %d included packages are affected; %s changed and %d packages depend on it; use -api to see whether its exported API changed", included, top, len(in.packages.Dependencies[top])))
+	}
+	return out
+}
+
+// hasPrefixIn reports whether s has any of the prefixes.
+func hasPrefixIn(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/digitalocean/gta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEmptyHints(t *testing.T) {
+	files := map[string]bool{"/repo/README.md": true, "/repo/docs/index.md": true}
+
+	tests := []struct {
+		desc string
+		in   hintInput
+		want []string
+	}{
+		{
+			desc: "include",
+			in:   hintInput{vcs: "git", base: "origin/master", include: []string{"example.com/foo/"}, files: files},
+			want: []string{"no package matching -include contains any of the 2 changed files; check -include, or use -config to map non-Go files to packages"},
+		},
+		{
+			desc: "no include",
+			in:   hintInput{vcs: "git", base: "origin/master", files: files},
+			want: []string{"no package contains any of the 2 files that the git differ reported as changed relative to origin/master; check that -vcs and -base find the intended changes, or use -config to map non-Go files to packages"},
+		},
+		{
+			desc: "no changes",
+			in:   hintInput{vcs: "hg", base: "default", include: []string{"example.com/foo/"}},
+			want: []string{"no files changed relative to default; check -base"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.in.packages = &gta.Packages{}
+			if diff := cmp.Diff(tt.want, hints(tt.in)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWholeRepoHints(t *testing.T) {
+	var nodes []string
+	pkgs := &gta.Packages{Dependencies: make(map[string][]gta.Package)}
+	for i := 0; i < minWholeRepo; i++ {
+		node := fmt.Sprintf("example.com/foo/p%d", i)
+		nodes = append(nodes, node)
+		pkgs.AllChanges = append(pkgs.AllChanges, gta.Package{ImportPath: node})
+		if i > 0 {
+			pkgs.Dependencies[nodes[0]] = append(pkgs.Dependencies[nodes[0]], gta.Package{ImportPath: node})
+		}
+	}
+	pkgs.Changes = pkgs.AllChanges[:1]
+
+	tests := []struct {
+		desc string
+		in   hintInput
+		want []string
+	}{
+		{
+			desc: "include",
+			in:   hintInput{include: []string{"example.com/foo/"}, files: map[string]bool{"/repo/p0/p0.go": true}},
+			want: []string{"all 10 included packages are affected; example.com/foo/p0 changed and 9 packages depend on it; use -api to see whether its exported API changed"},
+		},
+		{
+			desc: "no include",
+			in:   hintInput{files: map[string]bool{"/repo/go.mod": true}},
+			want: []string{
+				"/repo/go.mod changed, which can affect every package that imports a dependency; use -file-changes to see the changes",
+				"all 10 included packages are affected; example.com/foo/p0 changed and 9 packages depend on it; use -api to see whether its exported API changed",
+			},
+		},
+		{
+			desc: "trigger",
+			in:   hintInput{triggers: []string{"/repo/Makefile", "/repo/*.mk"}, files: map[string]bool{"/repo/Makefile": true}},
+			want: []string{
+				"trigger file /repo/Makefile changed, which marks every package as changed; check the triggers of -config",
+				"all 10 included packages are affected; example.com/foo/p0 changed and 9 packages depend on it; use -api to see whether its exported API changed",
+			},
+		},
+		{
+			desc: "not included",
+			in:   hintInput{include: []string{"example.com/bar/"}, files: map[string]bool{"/repo/go.mod": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tt.in.vcs, tt.in.base = "git", "origin/master"
+			tt.in.nodes, tt.in.packages = nodes, pkgs
+			if diff := cmp.Diff(tt.want, hints(tt.in)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
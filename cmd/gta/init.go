@@ -0,0 +1,329 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generatedComment matches the comment that marks generated Go files, see
+// https://golang.org/s/generatedcode.
+var generatedComment = []byte("// Code generated ")
+
+// initConfig inspects the repository in the current directory and writes a
+// starter configuration file.
+func initConfig(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	flagOutput := fs.String("o", ".gta.json", "path of the configuration file to write")
+	flagForce := fs.Bool("f", false, "overwrite the configuration file when it exists")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*flagOutput); err == nil && !*flagForce {
+		return fmt.Errorf("%s already exists; use -f to overwrite it", *flagOutput)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	s, err := scanRepository(wd)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeConfig(&buf, s.config()); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*flagOutput, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "wrote %s\n", *flagOutput)
+	for _, note := range s.notes() {
+		fmt.Fprintf(os.Stderr, "  %s\n", note)
+	}
+	fmt.Fprintf(os.Stderr, "use it with gta -config %s\n", *flagOutput)
+	return nil
+}
+
+// repositoryScan describes the layout of a repository.
+type repositoryScan struct {
+	// modules is a map of the slash separated directories, relative to the
+	// root of the scan, of the modules to their paths.
+	modules map[string]string
+	// commands are the import paths of the packages in cmd directories.
+	commands []string
+	// generated are the import paths of the packages whose Go files are all
+	// generated.
+	generated []string
+	// protos is whether there are protocol buffer definitions.
+	protos bool
+	// docs is whether there is a docs directory at the root.
+	docs bool
+	// codeowners is the slash separated path of the CODEOWNERS file, if any.
+	codeowners string
+	// triggers are the slash separated paths or glob patterns of the build
+	// scripts and CI configuration at the root.
+	triggers []string
+}
+
+// triggerFiles are the slash separated glob patterns, relative to the root of
+// a repository, of the common build scripts and CI configuration files whose
+// changes can affect every package.
+var triggerFiles = []string{
+	"Makefile",
+	"*.mk",
+	".github/workflows/*.yml",
+	".github/workflows/*.yaml",
+	".gitlab-ci.yml",
+	".circleci/config.yml",
+}
+
+// scanRepository walks the tree rooted at root to describe its layout.
+func scanRepository(root string) (*repositoryScan, error) {
+	s := &repositoryScan{modules: make(map[string]string)}
+
+	// the Go files of each directory and whether all of them are generated.
+	goDirs := make(map[string]bool)
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		name := info.Name()
+		if info.IsDir() {
+			// skip the trees the go command ignores.
+			if rel != "." && (name[0] == '.' || name[0] == '_' || name == "testdata" || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			if rel == "docs" {
+				s.docs = true
+			}
+			return nil
+		}
+
+		switch {
+		case name == "go.mod":
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if modPath := modulePath(b); modPath != "" {
+				s.modules[path.Dir(rel)] = modPath
+			}
+		case name == "CODEOWNERS" && (rel == name || rel == "docs/"+name):
+			s.codeowners = rel
+		case strings.HasSuffix(name, ".proto"):
+			s.protos = true
+		case strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go"):
+			dir := path.Dir(rel)
+			generated, err := isGenerated(p)
+			if err != nil {
+				return err
+			}
+			all, ok := goDirs[dir]
+			goDirs[dir] = generated && (all || !ok)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// .github/CODEOWNERS is skipped with the other hidden directories.
+	if _, err := os.Stat(filepath.Join(root, ".github", "CODEOWNERS")); err == nil {
+		s.codeowners = ".github/CODEOWNERS"
+	}
+
+	for _, pattern := range triggerFiles {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) > 0 {
+			s.triggers = append(s.triggers, pattern)
+		}
+	}
+
+	for dir, generated := range goDirs {
+		importPath, ok := s.importPath(dir)
+		if !ok {
+			continue
+		}
+		if generated {
+			s.generated = append(s.generated, importPath)
+		}
+		if dir == "cmd" || strings.HasPrefix(dir, "cmd/") || strings.Contains(dir, "/cmd/") {
+			s.commands = append(s.commands, importPath)
+		}
+	}
+	sort.Strings(s.generated)
+	sort.Strings(s.commands)
+
+	return s, nil
+}
+
+// importPath returns the import path of the package in the slash separated
+// directory dir, using the module that contains it.
+func (s *repositoryScan) importPath(dir string) (string, bool) {
+	for d := dir; ; d = path.Dir(d) {
+		if modPath, ok := s.modules[d]; ok {
+			if d == dir {
+				return modPath, true
+			}
+			rel := strings.TrimPrefix(dir, d+"/")
+			if d == "." {
+				rel = dir
+			}
+			return path.Join(modPath, rel), true
+		}
+		if d == "." {
+			return "", false
+		}
+	}
+}
+
+// config returns a starter configuration for the scanned repository.
+func (s *repositoryScan) config() *config {
+	cfg := &config{
+		Ignore: []string{"**/*.md"},
+	}
+
+	for _, modPath := range s.modules {
+		cfg.Include = append(cfg.Include, modPath)
+	}
+	sort.Strings(cfg.Include)
+
+	if s.docs {
+		cfg.Ignore = append(cfg.Ignore, "docs/**")
+	}
+
+	cfg.Triggers = s.triggers
+
+	// the generated packages are regenerated when the protocol buffer
+	// definitions change; the packages of the mapping are to be refined.
+	if s.protos && len(s.generated) > 0 {
+		cfg.Specs = append(cfg.Specs, specConfig{
+			Patterns: []string{"**/*.proto"},
+			Packages: s.generated,
+		})
+	}
+
+	return cfg
+}
+
+// writeConfig writes cfg to w as json, with empty lists for the triggers and
+// mappings when cfg has none, to be filled in.
+func writeConfig(w io.Writer, cfg *config) error {
+	type field struct {
+		name  string
+		value interface{}
+	}
+	fields := []field{
+		{"include", cfg.Include},
+		{"ignore", cfg.Ignore},
+		{"triggers", cfg.Triggers},
+		{"mappings", cfg.Mappings},
+	}
+	if len(cfg.Specs) > 0 {
+		fields = append(fields, field{"specs", cfg.Specs})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteString(",\n")
+		}
+		b, err := json.MarshalIndent(field.value, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		// nil slices are written as empty lists, to be filled in.
+		if string(b) == "null" {
+			b = []byte("[]")
+		}
+		fmt.Fprintf(&buf, "  %q: %s", field.name, b)
+	}
+	buf.WriteString("\n}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// notes returns what was found in the repository that cannot be configured
+// automatically.
+func (s *repositoryScan) notes() []string {
+	var notes []string
+	if len(s.modules) == 0 {
+		notes = append(notes, "no go.mod files were found; set include to the prefixes of the packages to analyze")
+	}
+	if len(s.commands) > 0 {
+		notes = append(notes, fmt.Sprintf("found %d command packages; use -roots to analyze what a command depends on, e.g. -roots %s", len(s.commands), s.commands[0]))
+	}
+	if len(s.generated) > 0 {
+		notes = append(notes, fmt.Sprintf("found %d generated packages; refine the specs mapping to the files they are generated from", len(s.generated)))
+	}
+	if s.codeowners != "" {
+		notes = append(notes, fmt.Sprintf("found %s; gta does not use code owners", s.codeowners))
+	}
+	notes = append(notes, `add the files that map to packages to mappings, e.g. {"pattern": "migrations/*.sql", "targets": ["internal/db"]}`)
+	return notes
+}
+
+// modulePath returns the module path declared in the go.mod file content b.
+func modulePath(b []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// isGenerated reports whether the Go file fn has the comment that marks
+// generated files before its package clause.
+func isGenerated(fn string) (bool, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte("package ")) {
+			return false, nil
+		}
+		if bytes.HasPrefix(line, generatedComment) && bytes.HasSuffix(line, []byte(" DO NOT EDIT.")) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newInitRepo returns a repository with the layouts that gta init detects.
+func newInitRepo(t *testing.T) *gtatest.Repo {
+	return gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"cmd/foo/main.go":    "package main\n\nfunc main() {}\n",
+		"internal/db/db.go":  "package db\n",
+		"gen/pb/foo.pb.go":   "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n",
+		"api/foo.proto":      "syntax = \"proto3\";\n",
+		"docs/index.md":      "# foo\n",
+		"Makefile":           "test:\n\tgo test ./...\n",
+		".github/CODEOWNERS": "* @team\n",
+		"tools/go.mod":       "module example.com/foo/tools\n",
+		"tools/lint/lint.go": "package main\n",
+		"testdata/x/x.go":    "package x\n",
+		"vendor/modules.txt": "",
+	})
+}
+
+func TestScanRepository(t *testing.T) {
+	repo := newInitRepo(t)
+
+	s, err := scanRepository(repo.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &repositoryScan{
+		modules: map[string]string{
+			".":     "example.com/foo",
+			"tools": "example.com/foo/tools",
+		},
+		commands:   []string{"example.com/foo/cmd/foo"},
+		generated:  []string{"example.com/foo/gen/pb"},
+		protos:     true,
+		docs:       true,
+		codeowners: ".github/CODEOWNERS",
+		triggers:   []string{"Makefile"},
+	}
+	if diff := cmp.Diff(want, s, cmp.AllowUnexported(repositoryScan{})); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestInitConfig(t *testing.T) {
+	repo := newInitRepo(t)
+	repo.Chdir()
+
+	if err := initConfig(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(repo.Path(".gta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stub := range []string{`"triggers": [`, `"mappings": []`} {
+		if !strings.Contains(string(b), stub) {
+			t.Errorf("expected the configuration to contain %s:\n%s", stub, b)
+		}
+	}
+
+	got, err := loadConfig(repo.Path(".gta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &config{
+		dir:      repo.Dir,
+		Include:  []string{"example.com/foo", "example.com/foo/tools"},
+		Ignore:   []string{"**/*.md", "docs/**"},
+		Triggers: []string{"Makefile"},
+		Mappings: []mappingConfig{},
+		Specs: []specConfig{
+			{Patterns: []string{"**/*.proto"}, Packages: []string{"example.com/foo/gen/pb"}},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(config{})); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if err := initConfig(nil); err == nil {
+		t.Error("expected an error when the configuration exists")
+	}
+	if err := initConfig([]string{"-f"}); err != nil {
+		t.Errorf("unexpected error with -f: %v", err)
+	}
+}
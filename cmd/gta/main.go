@@ -11,6 +11,8 @@ in the LICENSE file.
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -19,6 +21,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -29,68 +32,747 @@ import (
 
 func main() {
 	log.SetFlags(log.Lshortfile | log.Ltime)
-	flagBase := flag.String("base", "origin/master", "base, branch to diff against")
+	flagBase := flag.String("base", "origin/master", "base, branch to diff against; defaults to default when -vcs is hg and to BASE:HEAD, a revision range, when -vcs is svn; when -vcs is git, several comma separated branches can be given to report the union of their changes")
+	flagVCS := flag.String("vcs", "git", "version control system used to determine changes; git, hg, p4, or svn")
 	flagInclude := flag.String("include", "", "define changes to be filtered with a set of comma separated prefixes")
+	flagPatterns := flag.String("patterns", "", "comma separated go list patterns of the packages to load instead of those within -include, e.g. ./...; a pattern starting with - excludes the packages it matches, e.g. -./gen/...")
+	flagRepoScope := flag.Bool("repo-scope", false, "load exactly the packages of the repository that contains the working directory, excluding those of nested checkouts, instead of those that -include expands to")
+	flagModules := flag.String("modules", "", "comma separated paths of the modules to analyze, excluding the modules nested within them; defaults to all modules")
+	flagRoots := flag.String("roots", "", "comma separated import paths of root packages, optionally followed by /...; only the roots and the packages they depend on are reported")
+	flagDeployFiles := flag.String("deploy-files", "", "mark only the packages in their directories, not their dependents, when build and deployment files such as Dockerfiles and Kubernetes manifests change; default recognizes the common files, otherwise a comma separated list of patterns of file names")
+	flagTestdata := flag.String("testdata", "tests", "how changes to files within testdata directories affect the packages that own them; tests marks only the owning packages, dependents marks their dependents too, and ignore ignores the changes")
+	flagDirection := flag.String("direction", "dependents", "direction in which the dependency graph is followed from changed packages; dependents, dependencies, or both")
+	flagMaxDepth := flag.Int("max-depth", 0, "the maximum number of imports to follow from each changed package, e.g. 1 for only the direct importers; zero means no limit")
+	flagFetchRemote := flag.String("fetch-remote", "", "remote to fetch the base from when it is missing, deepening shallow clones as needed; only supported when -vcs is git")
+	flagMergeBase := flag.Bool("merge-base", true, "compare against the merge base of the base and HEAD, like git diff base...HEAD; when false, compare against the base itself; only supported when -vcs is git")
+	flagSince := flag.Duration("since", 0, "diff against the newest commit committed before the duration, e.g. 24h, instead of the base; only supported when -vcs is git")
+	flagSinceTag := flag.String("since-tag", "", "diff against the most recent tag reachable from HEAD that matches the glob, e.g. v*, instead of the base; only supported when -vcs is git")
+	flagLastCommits := flag.Int("last-commits", 0, "diff the last n commits instead of against the base; only supported when -vcs is git")
+	flagChangelists := flag.String("changelists", "", "comma separated numbers of the submitted or shelved changelists to diff; defaults to the files opened in the client workspace; only supported when -vcs is p4")
 	flagMerge := flag.Bool("merge", false, "diff using the latest merge commit")
+	flagIncludeWorkingTree := flag.Bool("include-working-tree", false, "include uncommitted changes and untracked files in the working tree; only supported when -vcs is git")
+	flagIgnore := flag.String("ignore", "", "comma separated glob patterns, relative to the root of the repository, of changed files to ignore, e.g. docs/**; only supported when -vcs is git")
+	flagIgnoreGenerated := flag.Bool("ignore-generated", false, "ignore changed files marked with the linguist-generated attribute in .gitattributes; only supported when -vcs is git")
+	flagIgnoreWhitespace := flag.Bool("ignore-whitespace", false, "ignore changed files whose changes are all whitespace; only supported when -vcs is git")
+	flagIgnoreBinary := flag.Bool("ignore-binary", false, "ignore changed binary files, such as images; only supported when -vcs is git")
+	flagMapBinary := flag.Bool("map-binary", false, "mark only the packages that changed binary files are mapped to by the fixtures, migrations, and specs of the configuration, rather than the packages in their directories; only supported when -vcs is git")
+	flagSymbolImpact := flag.Bool("symbol-impact", false, "only mark the dependents that refer to the exported identifiers that changed, directly or through the unexported identifiers they refer to, and their dependents; only effective when git, hg, p4, or svn determine changes")
+	flagIgnoreComments := flag.Bool("ignore-comments", false, "ignore changed go files whose only changes are to comments; only effective when git, hg, p4, or svn determine changes")
+	flagStaged := flag.Bool("staged", false, "diff only the staged changes in the index against the base; only supported when -vcs is git")
 	flagJSON := flag.Bool("json", false, "output list of changes as json")
+	flagJSONFields := flag.String("json-fields", "", "comma separated fields to include in the json output, e.g. changes,all_changes; defaults to all fields")
 	flagBuildableOnly := flag.Bool("buildable-only", true, "keep buildable changed packages only")
-	flagChangedFiles := flag.String("changed-files", "", "path to a file containing a newline separated list of files that have changed")
+	flagChangedFiles := flag.String("changed-files", "", "path to a file containing a newline separated list of files that have changed, or a json document describing the change to each file; - reads from standard input")
+	flagPatch := flag.String("patch", "", "path to a unified diff, such as the output of git format-patch, whose changed files are used; - reads from standard input")
+	flagNameStatus := flag.String("name-status", "", "path to a saved output of git diff --name-status or git diff --raw, with or without -z, whose changes are used; paths are relative to -repo-root or the root of the repository; - reads from standard input")
+	flagRepoRoot := flag.String("repo-root", "", "directory that relative paths in -changed-files and -patch are relative to, and that -overlay mirrors, such as the root of the repository")
+	flagModuleDir := flag.String("module-dir", "", "directory of the go module to analyze when it is not at the root of the repository, e.g. backend; changes are still determined for the whole repository")
+	flagOverlay := flag.String("overlay", "", "directory that mirrors the layout of the repository, e.g. of freshly generated code, whose files that differ from the repository are changes too")
 	flagTags := flag.String("tags", "", "a list of build tags to consider")
+	flagConfig := flag.String("config", "", "path to a json configuration file")
+	flagMappings := flag.String("mappings", gta.MappingRulesFile, "path to a file of rules that map non-Go files to packages and pseudo-targets, one 'pattern -> target...' per line; ignored when it does not exist unless the flag is set")
+	flagParallelism := flag.Int("p", 0, "the number of packages that can be loaded in parallel; defaults to the go command's default")
+	flagCache := flag.Bool("cache", false, "cache the dependency graph on disk, keyed by the commit, go.mod, go.sum, and build tags, and reuse it while they are unchanged; the cache is not used when the working tree has uncommitted changes")
+	flagCacheDir := flag.String("cache-dir", "", "directory of the dependency graph cache; implies -cache; defaults to gta in the user's cache directory")
+	flagWriteGraph := flag.String("write-graph", "", "path to write the loaded dependency graph to, so that jobs at the same commit can reuse it with -read-graph")
+	flagReadGraph := flag.String("read-graph", "", "path to a dependency graph written with -write-graph to use instead of loading packages; the repository must be at the same path and commit")
+	flagCacheKeyExtras := flag.String("cache-key-extras", "", "a comma separated list of extra inputs to the key of the dependency graph cache, e.g. the version of a code generator; the cached graph is reused only while they are unchanged")
+	flagNoCache := flag.Bool("no-cache", false, "do not read or write the dependency graph cache, even when -cache or -cache-dir is set")
+	flagTimeout := flag.Duration("timeout", 0, "maximum duration of loading packages, e.g. 5m; zero means no limit")
+	flagPartial := flag.Bool("partial", false, "when -timeout elapses before the dependency graph is built, report the changed packages and the packages matching -include as a conservative superset instead of failing; the json output is marked as partial")
+	flagSlimLoad := flag.Bool("slim-load", false, "load packages without the details of their dependencies to lower the memory used; import chains through dependencies outside of the included packages are not followed")
+	flagBuildFlags := flag.String("build-flags", "", "space separated build flags, e.g. -mod=vendor, of the go commands run to load packages; build tags are set with -tags")
+	flagLoadSyntax := flag.Bool("load-syntax", false, "load the syntax trees and type information of the packages along with the dependency graph, so that -api does not load the dependents again; uses much more memory and bypasses -cache")
+	flagMemoryLimit := flag.String("memory-limit", "", "soft memory limit, in the format of GOMEMLIMIT, for the go commands run to load packages")
+	flagDirectMarker := flag.String("direct-marker", "", "prefix for directly changed packages in the plain output; when it is not set, directly changed packages are colored when writing to a terminal")
+	flagGitHubPR := flag.String("github-pr", "", "determine changes from the files changed by a GitHub pull request, given as owner/repo#number, using the GitHub API; the token in GITHUB_TOKEN is used when it is set")
+	flagMetadata := flag.Bool("metadata", false, "report the description, owner, and tier of each affected package, read from the PACKAGE.json file in its directory and its documentation, in the json output")
+	flagMeta := flag.Bool("meta", false, "report the duration of each phase of the analysis, the size of the dependency graph, and the version of gta in the json output")
+	flagFileChanges := flag.Bool("file-changes", false, "report whether each changed file of the changed packages was added, modified, deleted, renamed, or copied in the json output")
+	flagPlatforms := flag.String("platforms", "", "a comma separated list of platforms, e.g. linux/amd64,darwin/arm64, for which to report the affected packages in the json output; changes to Go and assembly files only affect the platforms for which they are built")
+	flagBuildTest := flag.Bool("build-test", false, "report the affected packages whose compiled code is affected, which need to be built, separately from those that are only affected through their tests in the json output")
+	flagExcludeVendored := flag.Bool("exclude-vendored", false, "omit the packages of vendored modules from the output; changes to them still affect the packages that depend on them")
+	flagReasons := flag.Bool("reasons", false, "report the import chain from the nearest changed package to each affected dependent, which explains why it is affected, in the json output")
+	flagKinds := flag.Bool("kinds", false, "report whether only tests, only non-Go files, or code changed in each changed package in the json output")
+	flagPrioritize := flag.String("prioritize", "", "comma separated paths of the output of past go test -json runs; changed packages whose tests failed most often are listed first")
+	flagLoadErrors := flag.String("load-errors", "warn", "how to handle errors found while loading packages, such as syntax errors and unresolved imports: warn prints them and reports them in the json output, fail exits with an error, and ignore ignores them")
+	flagStrict := flag.Bool("strict", false, "fail when packages have list or parse errors, such as unresolved imports caused by misconfigured build tags, which would otherwise shrink the set of affected packages")
+	flagAnomalies := flag.Bool("anomalies", false, "warn about anomalies in the dependency graph that can cause changes to be attributed to the wrong packages, such as files claimed by several packages, and report them in the json output")
+	flagBlastRadius := flag.Bool("blast-radius", false, "report the number of dependents of each directly changed package, and the fraction of the included packages they are, in the json output")
+	flagOrphans := flag.Bool("orphans", false, "report the packages that no package depends on and that are not main packages in the json output")
+	flagWriteManifest := flag.String("write-manifest", "", "path of a json manifest to write that records the inputs of the analysis")
+	flagFromManifest := flag.String("from-manifest", "", "path of a manifest written by -write-manifest to reproduce the analysis from")
+	flagHints := flag.Bool("hints", true, "print hints about likely causes to standard error when no packages, or all included packages, are affected")
+	flagAPI := flag.Bool("api", false, "report changes to exported APIs and whether dependents risk failing to compile in the json output")
 
 	flag.Parse()
 
+	if flag.Arg(0) == "init" {
+		if err := initConfig(flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "config" {
+		if err := configCommand(flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "bench" {
+		if err := bench(flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	var exportPath string
+	if flag.Arg(0) == "export" {
+		exportPath = parseExportFlags(flag.Args()[1:])
+		if len(exportPath) == 0 {
+			fatal("-o must be set when using export")
+		}
+	}
+
+	var graphCmd *graphFlags
+	if flag.Arg(0) == "graph" {
+		var err error
+		if graphCmd, err = parseGraphFlags(flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		if *flagJSON {
+			fatal("-json must not be set when using graph")
+		}
+	}
+
+	var statsCmd *statsFlags
+	if flag.Arg(0) == "stats" {
+		var err error
+		if statsCmd, err = parseStatsFlags(flag.Args()[1:]); err != nil {
+			fatal(err)
+		}
+		if *flagJSON {
+			fatal("-json must not be set when using stats; use stats -json instead")
+		}
+	}
+
+	var checkPackages []string
+	if flag.Arg(0) == "check" {
+		// errors exit with a status other than the one for packages that
+		// are not affected, so that a gating job does not mistake them.
+		errorStatus = checkErrorStatus
+		checkPackages = flag.Args()[1:]
+		if len(checkPackages) == 0 {
+			fatal("usage: gta [flags] check package...; exits with 0 when any package is affected, 1 when none is, and 2 on errors")
+		}
+		if *flagJSON {
+			fatal("-json must not be set when using check")
+		}
+	}
+
+	var affectedBy []string
+	if flag.Arg(0) == "affected-by" {
+		var err error
+		affectedBy, err = absPaths(flag.Args()[1:])
+		if err != nil {
+			fatal(err)
+		}
+		if len(affectedBy) == 0 {
+			fatal("at least one file must be provided to affected-by")
+		}
+		if *flagMerge || len(*flagChangedFiles) > 0 {
+			fatal("-merge and -changed-files must not be set when using affected-by")
+		}
+	}
+
+	// gitDiffer is whether git is run to determine the changes, which the flags
+	// that change how it reports them require.
+	gitDiffer := *flagVCS == "git" && len(*flagChangedFiles) == 0 && len(affectedBy) == 0 && len(*flagPatch) == 0 && len(*flagGitHubPR) == 0 && len(*flagNameStatus) == 0 && len(*flagFromManifest) == 0
+
+	if *flagIncludeWorkingTree && (*flagVCS != "git" || len(*flagChangedFiles) > 0 || len(affectedBy) > 0) {
+		fatal("-include-working-tree is only supported when using git to determine changes")
+	}
+
+	if *flagStaged && (*flagVCS != "git" || len(*flagChangedFiles) > 0 || len(affectedBy) > 0) {
+		fatal("-staged is only supported when using git to determine changes")
+	}
+
+	if len(*flagIgnore) > 0 && (*flagVCS != "git" || len(*flagChangedFiles) > 0 || len(affectedBy) > 0) {
+		fatal("-ignore is only supported when using git to determine changes")
+	}
+
+	if !*flagMergeBase && !gitDiffer {
+		fatal("-merge-base=false is only supported when using git to determine changes")
+	}
+
+	if len(*flagFetchRemote) > 0 && !gitDiffer {
+		fatal("-fetch-remote is only supported when using git to determine changes")
+	}
+
+	if *flagIgnoreGenerated && !gitDiffer {
+		fatal("-ignore-generated is only supported when using git to determine changes")
+	}
+
+	if *flagIgnoreWhitespace && !gitDiffer {
+		fatal("-ignore-whitespace is only supported when using git to determine changes")
+	}
+
+	if *flagIgnoreBinary && !gitDiffer {
+		fatal("-ignore-binary is only supported when using git to determine changes")
+	}
+
+	if *flagMapBinary && !gitDiffer {
+		fatal("-map-binary is only supported when using git to determine changes")
+	}
+
+	if *flagIgnoreBinary && *flagMapBinary {
+		fatal("-ignore-binary and -map-binary must not both be set")
+	}
+
+	if *flagStaged && (*flagMerge || *flagIncludeWorkingTree) {
+		fatal("-merge and -include-working-tree must not be set when using -staged")
+	}
+
+	if len(*flagPatch) > 0 && (*flagMerge || *flagStaged || *flagIncludeWorkingTree || len(*flagChangedFiles) > 0 || len(affectedBy) > 0 || len(*flagGitHubPR) > 0) {
+		fatal("-merge, -staged, -include-working-tree, -changed-files, -github-pr, and affected-by must not be set when using -patch")
+	}
+
+	if len(*flagNameStatus) > 0 && (*flagMerge || *flagStaged || *flagIncludeWorkingTree || len(*flagChangedFiles) > 0 || len(*flagPatch) > 0 || len(affectedBy) > 0 || len(*flagGitHubPR) > 0) {
+		fatal("-merge, -staged, -include-working-tree, -changed-files, -patch, -github-pr, and affected-by must not be set when using -name-status")
+	}
+
+	if len(*flagRepoRoot) > 0 && len(*flagChangedFiles) == 0 && len(*flagPatch) == 0 && len(*flagNameStatus) == 0 && len(*flagOverlay) == 0 {
+		fatal("-repo-root must only be set when using -changed-files, -patch, -name-status, or -overlay")
+	}
+
 	if *flagJSON && *flagBuildableOnly {
-		log.Fatal("-buildable-only must be set to false when using -json")
+		fatal("-buildable-only must be set to false when using -json")
+	}
+
+	jsonFields := parseStringSlice(*flagJSONFields)
+	if err := checkJSONFields(jsonFields); err != nil {
+		fatal(err)
+	}
+
+	if *flagPartial && (*flagTimeout <= 0 || len(*flagInclude) == 0) {
+		fatal("-timeout and -include must be set when using -partial")
+	}
+
+	err := checkJSONOnly(*flagJSON, []flagUse{
+		{"api", *flagAPI},
+		{"json-fields", len(jsonFields) > 0},
+		{"metadata", *flagMetadata},
+		{"meta", *flagMeta},
+		{"file-changes", *flagFileChanges},
+		{"kinds", *flagKinds},
+		{"reasons", *flagReasons},
+		{"build-test", *flagBuildTest},
+		{"platforms", *flagPlatforms != ""},
+		{"blast-radius", *flagBlastRadius},
+		{"orphans", *flagOrphans},
+	})
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(*flagChangelists) > 0 && *flagVCS != "p4" {
+		fatal("-changelists must only be used with -vcs p4")
+	}
+
+	if *flagVCS == "p4" && (*flagMerge || isFlagSet("base")) {
+		fatal("-merge and -base must not be used with -vcs p4; use -changelists")
+	}
+
+	if *flagVCS == "svn" && *flagMerge {
+		fatal("-merge must not be used with -vcs svn; use -base with a revision range")
+	}
+
+	if (*flagSince > 0 || *flagLastCommits > 0 || len(*flagSinceTag) > 0) && (*flagMerge || isFlagSet("base") || *flagVCS != "git") {
+		fatal("-since, -since-tag, and -last-commits must only be used with -vcs git, and without -merge and -base")
+	}
+
+	if (*flagSince > 0 && *flagLastCommits > 0) || (len(*flagSinceTag) > 0 && (*flagSince > 0 || *flagLastCommits > 0)) {
+		fatal("-since, -since-tag, and -last-commits must not be used together")
 	}
 
 	if *flagMerge && len(*flagChangedFiles) > 0 {
-		log.Fatal("changed files must not be provided when using the latest merge commit")
+		fatal("changed files must not be provided when using the latest merge commit")
+	}
+
+	if len(*flagGitHubPR) > 0 && (*flagMerge || *flagStaged || *flagIncludeWorkingTree || len(*flagChangedFiles) > 0 || len(affectedBy) > 0) {
+		fatal("-merge, -staged, -include-working-tree, -changed-files, and affected-by must not be set when using -github-pr")
+	}
+
+	var replay *manifest
+	if len(*flagFromManifest) > 0 {
+		if *flagMerge || *flagStaged || *flagIncludeWorkingTree || len(*flagChangedFiles) > 0 || len(affectedBy) > 0 {
+			fatal("-merge, -staged, -include-working-tree, -changed-files, and affected-by must not be set when using -from-manifest")
+		}
+		if isFlagSet("include") || isFlagSet("tags") {
+			fatal("-include and -tags must not be set when using -from-manifest; they are read from the manifest")
+		}
+
+		var err error
+		replay, err = readManifest(*flagFromManifest)
+		if err != nil {
+			fatal(fmt.Errorf("could not read manifest: %w", err))
+		}
+	}
+
+	var prog progress
+	ctx, cancel := interruptContext(&prog)
+	defer cancel()
+
+	// the timeout only bounds loading packages; the differs use ctx so that
+	// the changes are still known when it elapses.
+	loadCtx := ctx
+	if *flagTimeout > 0 {
+		var cancelLoad context.CancelFunc
+		loadCtx, cancelLoad = context.WithTimeout(ctx, *flagTimeout)
+		defer cancelLoad()
+	}
+
+	base := *flagBase
+	if *flagVCS == "hg" && !isFlagSet("base") {
+		base = "default"
+	}
+	if *flagVCS == "svn" && !isFlagSet("base") {
+		base = "BASE:HEAD"
+	}
+	if *flagVCS == "p4" {
+		base = "opened files"
+		if len(*flagChangelists) > 0 {
+			base = "changelists " + *flagChangelists
+		}
+	}
+
+	var cfg *config
+	var configHash string
+	if len(*flagConfig) > 0 {
+		var err error
+		if configHash, err = hashFile(*flagConfig); err != nil {
+			fatal(fmt.Errorf("could not load config: %w", err))
+		}
+
+		cfg, err = loadConfig(*flagConfig)
+		if err != nil {
+			fatal(fmt.Errorf("could not load config: %w", err))
+		}
 	}
 
+	include := parseStringSlice(*flagInclude)
+	ignore := parseStringSlice(*flagIgnore)
+	if cfg != nil && !isFlagSet("include") {
+		include = cfg.Include
+	}
+	if cfg != nil && !isFlagSet("ignore") {
+		ignore = cfg.Ignore
+	}
 	var tags []string
 	for _, v := range parseStringSlice(*flagTags) {
 		tags = append(tags, strings.Fields(v)...)
 	}
+	if replay != nil {
+		include, tags = replay.Include, replay.Tags
+	}
 
 	options := []gta.Option{
-		gta.SetPrefixes(parseStringSlice(*flagInclude)...),
+		gta.SetPrefixes(include...),
+		gta.SetModules(parseStringSlice(*flagModules)...),
+		gta.SetPatterns(parseStringSlice(*flagPatterns)...),
+		gta.SetRepoScope(*flagRepoScope),
+		gta.SetRoots(parseStringSlice(*flagRoots)...),
+		gta.SetDirection(gta.Direction(*flagDirection)),
+		gta.SetTestdataMode(gta.TestdataMode(*flagTestdata)),
+		gta.SetMaxDepth(*flagMaxDepth),
 		gta.SetTags(tags...),
+		gta.SetAPIReport(*flagAPI),
+		gta.SetBlastRadiusReport(*flagBlastRadius),
+		gta.SetOrphanReport(*flagOrphans),
+		gta.SetAnomalyReport(*flagAnomalies),
+		gta.SetLoadErrorMode(gta.LoadErrorMode(*flagLoadErrors)),
+		gta.SetStrict(*flagStrict),
+		gta.SetChangeKindReport(*flagKinds),
+		gta.SetReasonReport(*flagReasons),
+		gta.SetExcludeVendored(*flagExcludeVendored),
+		gta.SetBuildTestReport(*flagBuildTest),
+		gta.SetPlatforms(parseStringSlice(*flagPlatforms)...),
+		gta.SetFileChangeReport(*flagFileChanges),
+		gta.SetMetaReport(*flagMeta),
+		gta.SetMetadataReport(*flagMetadata),
+		gta.SetIgnoreCommentChanges(*flagIgnoreComments),
+		gta.SetSymbolImpact(*flagSymbolImpact),
+		gta.SetBinaryFileMapping(*flagMapBinary),
+		gta.SetParallelism(*flagParallelism),
+		gta.SetContext(loadCtx),
+		gta.SetPartial(*flagPartial),
+	}
+
+	if len(*flagMemoryLimit) > 0 {
+		options = append(options, gta.SetMemoryLimit(*flagMemoryLimit))
+	}
+
+	if len(*flagBuildFlags) > 0 {
+		options = append(options, gta.SetBuildFlags(strings.Fields(*flagBuildFlags)...))
+	}
+
+	if *flagSlimLoad {
+		options = append(options, gta.SetLoadMode(gta.RequiredLoadMode))
+	}
+
+	if *flagLoadSyntax {
+		options = append(options, gta.SetLoadSyntax(true))
+	}
+
+	switch *flagDeployFiles {
+	case "":
+	case "default":
+		options = append(options, gta.SetDeployFiles())
+	default:
+		options = append(options, gta.SetDeployFiles(parseStringSlice(*flagDeployFiles)...))
 	}
 
-	if len(*flagChangedFiles) == 0 {
-		// override the differ to use the git differ instead.
-		gitDifferOptions := []gta.GitDifferOption{
-			gta.SetBaseBranch(*flagBase),
-			gta.SetUseMergeCommit(*flagMerge),
+	if len(*flagModuleDir) > 0 {
+		options = append(options, gta.SetModuleDir(*flagModuleDir))
+	}
+
+	if (*flagCache || len(*flagCacheDir) > 0) && !*flagNoCache {
+		dir := *flagCacheDir
+		if len(dir) == 0 {
+			var err error
+			dir, err = gta.DefaultGraphCacheDir()
+			if err != nil {
+				fatalf("can't determine the cache directory: %v", err)
+			}
 		}
-		options = append(options, gta.SetDiffer(gta.NewGitDiffer(gitDifferOptions...)))
-	} else {
-		sl, err := changedFiles(*flagChangedFiles)
+		options = append(options, gta.SetGraphCache(dir), gta.SetCacheKeyExtras(parseStringSlice(*flagCacheKeyExtras)...))
+	}
+
+	if len(*flagReadGraph) > 0 {
+		graph, err := readDependencyGraph(*flagReadGraph)
 		if err != nil {
-			log.Fatal(fmt.Errorf("could not read changed file list: %w", err))
+			fatalf("can't read dependency graph: %v", err)
 		}
-		options = append(options, gta.SetDiffer(gta.NewFileDiffer(sl)))
+		options = append(options, gta.SetDependencyGraph(graph))
 	}
 
+	var rules []gta.MappingRule
+	if cfg != nil {
+		options = append(options, cfg.options()...)
+		rules = cfg.mappingRules()
+	}
+
+	fileRules, err := gta.ReadMappingRules(*flagMappings)
+	switch {
+	case err == nil:
+		rules = append(rules, fileRules...)
+	case !os.IsNotExist(err) || isFlagSet("mappings"):
+		fatalf("can't read mapping rules: %v", err)
+	}
+	if len(rules) > 0 {
+		options = append(options, gta.SetMappingRules(rules...))
+	}
+
+	var difr gta.Differ
+	differType := *flagVCS
+	switch {
+	case replay != nil:
+		differType = replay.Differ
+		if err := replay.checkConfig(configHash); err != nil {
+			fatal(err)
+		}
+		if head := revision(*flagVCS, "HEAD"); head != replay.HeadRevision {
+			log.Printf("warning: the manifest was written at revision %q, but the current revision is %q", replay.HeadRevision, head)
+		}
+
+		var err error
+		difr, err = replay.differ(*flagVCS)
+		if err != nil {
+			fatal(fmt.Errorf("could not read manifest: %w", err))
+		}
+	case len(*flagGitHubPR) > 0:
+		differType = "github-pr"
+		owner, repo, number, err := parseGitHubPR(*flagGitHubPR)
+		if err != nil {
+			fatal(err)
+		}
+		root, err := repositoryRoot("git")
+		if err != nil {
+			fatal(err)
+		}
+		githubDifferOptions := []gta.GitHubDifferOption{
+			gta.SetGitHubRoot(root),
+			gta.SetGitHubContext(ctx),
+		}
+		// GITHUB_API_URL is set by GitHub Actions, including on GitHub
+		// Enterprise Server.
+		if apiURL := os.Getenv("GITHUB_API_URL"); len(apiURL) > 0 {
+			githubDifferOptions = append(githubDifferOptions, gta.SetGitHubBaseURL(apiURL))
+		}
+		difr = gta.NewGitHubPRDiffer(owner, repo, number, os.Getenv("GITHUB_TOKEN"), githubDifferOptions...)
+	case len(*flagPatch) > 0:
+		differType = "patch"
+		var err error
+		difr, err = patchDiffer(*flagPatch, *flagRepoRoot)
+		if err != nil {
+			fatal(fmt.Errorf("could not read patch: %w", err))
+		}
+	case len(*flagNameStatus) > 0:
+		differType = "name-status"
+		var err error
+		difr, err = nameStatusDiffer(*flagNameStatus, *flagRepoRoot, *flagVCS)
+		if err != nil {
+			fatal(fmt.Errorf("could not read name-status listing: %w", err))
+		}
+	case len(affectedBy) > 0:
+		// the files are known, so there is no need to consult a differ.
+		differType = "affected-by"
+		difr = gta.NewFileDiffer(affectedBy)
+	case len(*flagChangedFiles) == 0:
+		switch *flagVCS {
+		case "git":
+			// override the differ to use the git differ instead.
+			gitDifferOptions := []gta.GitDifferOption{
+				gta.SetBaseBranches(parseStringSlice(base)...),
+				gta.SetGitContext(ctx),
+				gta.SetUseMergeCommit(*flagMerge),
+				gta.SetUseMergeBase(*flagMergeBase),
+				gta.SetSince(*flagSince),
+				gta.SetCommitCount(*flagLastCommits),
+				gta.SetSinceLatestTag(*flagSinceTag),
+				gta.SetFetchMissingBase(*flagFetchRemote),
+				gta.SetIncludeWorkingTree(*flagIncludeWorkingTree),
+				gta.SetStagedOnly(*flagStaged),
+				gta.SetIgnore(ignore...),
+				gta.SetIgnoreGenerated(*flagIgnoreGenerated),
+				gta.SetIgnoreWhitespace(*flagIgnoreWhitespace),
+				gta.SetIgnoreBinary(*flagIgnoreBinary),
+			}
+			difr = gta.NewGitDiffer(gitDifferOptions...)
+		case "hg":
+			hgDifferOptions := []gta.HgDifferOption{
+				gta.SetHgBase(base),
+				gta.SetHgContext(ctx),
+				gta.SetHgUseMergeCommit(*flagMerge),
+			}
+			difr = gta.NewHgDiffer(hgDifferOptions...)
+		case "p4":
+			changelists, err := parseChangelists(*flagChangelists)
+			if err != nil {
+				fatal(err)
+			}
+			p4DifferOptions := []gta.P4DifferOption{
+				gta.SetP4Changelists(changelists...),
+				gta.SetP4Context(ctx),
+			}
+			difr = gta.NewP4Differ(p4DifferOptions...)
+		case "svn":
+			from, to := base, "HEAD"
+			if i := strings.Index(base, ":"); i >= 0 {
+				from, to = base[:i], base[i+1:]
+			}
+			svnDifferOptions := []gta.SVNDifferOption{
+				gta.SetSVNRevisions(from, to),
+				gta.SetSVNContext(ctx),
+			}
+			difr = gta.NewSVNDiffer(svnDifferOptions...)
+		default:
+			fatalf("unknown version control system %q: must be git, hg, p4, or svn", *flagVCS)
+		}
+	default:
+		differType = "changed-files"
+		var err error
+		difr, err = changedFilesDiffer(*flagChangedFiles, *flagRepoRoot)
+		if err != nil {
+			fatal(fmt.Errorf("could not read changed file list: %w", err))
+		}
+	}
+	options = append(options, gta.SetDiffer(difr))
+
+	if len(*flagOverlay) > 0 {
+		root := *flagRepoRoot
+		if len(root) == 0 {
+			var err error
+			if root, err = repositoryRoot(*flagVCS); err != nil {
+				fatal(err)
+			}
+		}
+		options = append(options, gta.AddDiffer("overlay", gta.NewOverlayDiffer(*flagOverlay, root)))
+	}
+
+	// the graph is recorded to tell whether all included packages are
+	// affected, and to write it.
+	recorder := new(graphRecorder)
+	if *flagHints || graphCmd != nil {
+		options = append(options, gta.SetReporter(recorder))
+	}
+
+	prog.set(fmt.Sprintf("loading %s", packagePatterns(include)))
 	gt, err := gta.New(options...)
 	if err != nil {
-		log.Fatalf("can't prepare gta: %v", err)
+		fatalf("can't prepare gta: %v", err)
 	}
+	recorder.gt = gt
 
+	if len(*flagWriteGraph) > 0 {
+		if err := writeDependencyGraph(*flagWriteGraph, gt); err != nil {
+			fatalf("can't write dependency graph: %v", err)
+		}
+	}
+
+	if statsCmd != nil {
+		prog.set("building dependency graph")
+		if err := writeStats(os.Stdout, statsCmd, gt); err != nil {
+			fatalf("can't compute graph statistics: %v", err)
+		}
+		return
+	}
+
+	if graphCmd != nil && len(graphCmd.query) > 0 {
+		prog.set("building dependency graph")
+		graph, err := gt.DependencyGraph()
+		if err != nil {
+			fatalf("can't build dependency graph: %v", err)
+		}
+		if err := queryGraph(os.Stdout, graphCmd, graph, include); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if len(*flagWriteManifest) > 0 {
+		prog.set("writing manifest")
+		m, err := newManifest(*flagVCS, difr)
+		if err != nil {
+			fatalf("can't write manifest: %v", err)
+		}
+		m.Differ, m.Include, m.Tags, m.ConfigHash = differType, include, tags, configHash
+		if replay != nil {
+			m.Base, m.BaseRevision = replay.Base, replay.BaseRevision
+		} else if differType == *flagVCS && *flagSince == 0 && *flagLastCommits == 0 && len(*flagSinceTag) == 0 {
+			// several git bases are recorded as comma separated lists.
+			var revisions []string
+			for _, b := range parseStringSlice(base) {
+				revisions = append(revisions, revision(*flagVCS, b))
+			}
+			m.Base, m.BaseRevision = base, strings.Join(revisions, ",")
+		}
+		if err := m.write(*flagWriteManifest); err != nil {
+			fatalf("can't write manifest: %v", err)
+		}
+	}
+
+	if len(exportPath) > 0 {
+		prov := gta.Provenance{
+			Args: os.Args[1:],
+		}
+		if replay != nil {
+			prov.Base = replay.Base
+		} else if differType == *flagVCS {
+			prov.Base = base
+		}
+		prog.set("determining changed packages for export")
+		if err := export(gt, exportPath, prov); err != nil {
+			fatalf("can't export analysis: %v", err)
+		}
+		return
+	}
+
+	prog.set(fmt.Sprintf("determining changed packages using %s", changeSource(*flagVCS, base, changedFilesSource(*flagChangedFiles, *flagFromManifest, *flagGitHubPR, *flagPatch, *flagNameStatus), affectedBy)))
 	packages, err := gt.ChangedPackages()
 	if err != nil {
-		log.Fatalf("can't list dirty packages: %v", err)
+		fatalf("can't list dirty packages: %v", err)
+	}
+
+	if *flagPrioritize != "" {
+		history, err := readTestHistory(parseStringSlice(*flagPrioritize))
+		if err != nil {
+			fatalf("can't read test history: %v", err)
+		}
+		packages.AllChanges = history.Prioritize(packages.AllChanges)
+	}
+
+	if packages.Partial {
+		log.Printf("warning: loading packages timed out after %v; reporting all included packages", *flagTimeout)
+	}
+
+	for _, loadErr := range packages.LoadErrors {
+		log.Printf("warning: %s", loadErr)
+	}
+
+	for _, anomaly := range packages.Anomalies {
+		log.Printf("warning: %s", anomaly)
+	}
+
+	for _, change := range packages.ModulePathChanges {
+		log.Printf("warning: %s; all of its packages are marked as changed", change)
+	}
+
+	for internal, violations := range packages.VisibilityViolations {
+		for _, pkg := range violations {
+			log.Printf("warning: %s imports %s from outside of %s", pkg, internal, packages.InternalScopes[internal])
+		}
+	}
+
+	if graphCmd != nil {
+		if err := writeGraph(os.Stdout, graphCmd, gt, recorder.graph, packages); err != nil {
+			fatalf("can't write graph: %v", err)
+		}
+		return
+	}
+
+	if *flagHints && !packages.Partial && len(checkPackages) == 0 {
+		files, err := difr.DiffFiles()
+		if err != nil {
+			fatalf("can't list changed files: %v", err)
+		}
+		in := hintInput{
+			vcs:      differType,
+			base:     base,
+			include:  include,
+			files:    files,
+			packages: packages,
+		}
+		if cfg != nil {
+			in.triggers = cfg.triggers()
+		}
+		if recorder.graph != nil {
+			in.nodes = recorder.graph.Nodes()
+		}
+		for _, hint := range hints(in) {
+			log.Printf("hint: %s", hint)
+		}
+	}
+
+	if len(checkPackages) > 0 {
+		// exit with a non-zero status when none of the packages are affected
+		// so that the command can gate a job.
+		for _, pkg := range checkPackages {
+			if packages.Contains(pkg) {
+				return
+			}
+		}
+		os.Exit(checkUnaffectedStatus)
 	}
 
 	if *flagJSON {
-		err = json.NewEncoder(os.Stdout).Encode(packages)
+		var v interface{} = packages
+		if len(jsonFields) > 0 {
+			if v, err = selectJSONFields(packages, jsonFields); err != nil {
+				fatal(err)
+			}
+		}
+		err = json.NewEncoder(os.Stdout).Encode(v)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		return
 	}
 
-	strung := stringify(packages.AllChanges, *flagBuildableOnly)
+	color := len(*flagDirectMarker) == 0 && terminal.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == ""
+	strung := plainPackages(packages, *flagBuildableOnly, *flagDirectMarker, color)
 
 	if terminal.IsTerminal(syscall.Stdin) {
 		for _, pkg := range strung {
@@ -102,6 +784,79 @@ func main() {
 	fmt.Println(strings.Join(strung, " "))
 }
 
+// The exit statuses of gta check.
+const (
+	checkUnaffectedStatus = 1
+	checkErrorStatus      = 2
+)
+
+// errorStatus is the exit status of fatal and fatalf.
+var errorStatus = 1
+
+// fatal is like log.Fatal, but exits with errorStatus.
+func fatal(v ...interface{}) {
+	log.Output(2, fmt.Sprint(v...))
+	os.Exit(errorStatus)
+}
+
+// fatalf is like log.Fatalf, but exits with errorStatus.
+func fatalf(format string, v ...interface{}) {
+	log.Output(2, fmt.Sprintf(format, v...))
+	os.Exit(errorStatus)
+}
+
+// flagUse describes whether a flag is set.
+type flagUse struct {
+	name string
+	set  bool
+}
+
+// checkJSONOnly returns an error when any of flags, which only affect the json
+// output, is set without -json.
+func checkJSONOnly(jsonOutput bool, flags []flagUse) error {
+	if jsonOutput {
+		return nil
+	}
+	for _, f := range flags {
+		if f.set {
+			return fmt.Errorf("-json must be set when using -%s", f.name)
+		}
+	}
+	return nil
+}
+
+// plainPackages returns the import paths of the affected packages for the
+// plain output, keeping only the buildable packages when buildableOnly is
+// true, and marking the directly changed packages with marker, or with color
+// when color is true.
+func plainPackages(pkgs *gta.Packages, buildableOnly bool, marker string, color bool) []string {
+	// the packages of partial results could not be loaded to know whether
+	// they are buildable.
+	strung := stringify(pkgs.AllChanges, buildableOnly && !pkgs.Partial)
+
+	// distinguish the packages that were changed directly from those that
+	// depend on them.
+	return markDirect(strung, pkgs.Changes, marker, color)
+}
+
+// readTestHistory returns the outcomes of the test runs in the files at paths,
+// which are the output of go test -json.
+func readTestHistory(paths []string) (*gta.TestHistory, error) {
+	history := gta.NewTestHistory()
+	for _, fn := range paths {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		err = history.Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+	}
+	return history, nil
+}
+
 func stringify(pkgs []gta.Package, validOnly bool) []string {
 	var out []string
 	for _, pkg := range pkgs {
@@ -112,12 +867,156 @@ func stringify(pkgs []gta.Package, validOnly bool) []string {
 	return out
 }
 
-func changedFiles(fn string) ([]string, error) {
-	b, err := ioutil.ReadFile(fn)
+// Escape sequences used to color directly changed packages.
+const (
+	colorDirect = "\x1b[1;32m"
+	colorReset  = "\x1b[0m"
+)
+
+// markDirect returns importPaths with the import paths of the packages in
+// direct prefixed by marker, or colored when color is true.
+func markDirect(importPaths []string, direct []gta.Package, marker string, color bool) []string {
+	if len(marker) == 0 && !color {
+		return importPaths
+	}
+
+	directs := make(map[string]struct{}, len(direct))
+	for _, pkg := range direct {
+		directs[pkg.ImportPath] = struct{}{}
+	}
+
+	out := make([]string, len(importPaths))
+	for i, importPath := range importPaths {
+		out[i] = importPath
+		if _, ok := directs[importPath]; !ok {
+			continue
+		}
+
+		if color {
+			out[i] = colorDirect + importPath + colorReset
+		} else {
+			out[i] = marker + importPath
+		}
+	}
+	return out
+}
+
+// changedFilesDiffer returns a differ for the changed files in the file fn,
+// or in standard input when fn is -. The file is either a newline separated
+// list of paths or a JSON document describing the change to each file. The
+// paths must be absolute unless repoRoot is set, in which case relative paths
+// are relative to repoRoot.
+func changedFilesDiffer(fn, repoRoot string) (gta.Differ, error) {
+	var b []byte
+	var err error
+	if fn == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(repoRoot) > 0 {
+		if repoRoot, err = filepath.Abs(repoRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc struct {
+			Files []gta.FileChange `json:"files"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if len(repoRoot) > 0 {
+			for i, c := range doc.Files {
+				doc.Files[i].Path = rootedPath(repoRoot, c.Path)
+				if len(c.OldPath) > 0 {
+					doc.Files[i].OldPath = rootedPath(repoRoot, c.OldPath)
+				}
+			}
+		}
+		return gta.NewFileChangesDiffer(doc.Files)
+	}
+
+	sl, err := changedFiles(b, len(repoRoot) > 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(repoRoot) > 0 {
+		return gta.NewFileDifferWithRoot(repoRoot, sl), nil
+	}
+	return gta.NewFileDiffer(sl), nil
+}
+
+// patchDiffer returns a differ for the files changed by the unified diff in
+// the file fn, or in standard input when fn is -. The paths in the diff are
+// relative to repoRoot, or the current directory when repoRoot is not set.
+func patchDiffer(fn, repoRoot string) (gta.Differ, error) {
+	r := os.Stdin
+	if fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if len(repoRoot) == 0 {
+		return gta.NewPatchDiffer(r)
+	}
+
+	root, err := filepath.Abs(repoRoot)
 	if err != nil {
 		return nil, err
 	}
+	return gta.NewPatchDifferWithRoot(root, r)
+}
+
+// nameStatusDiffer returns a differ for the saved git diff --name-status or
+// --raw output in the file fn, or standard input when fn is -. The paths are
+// relative to repoRoot or, when it is empty, to the root of the repository
+// determined using vcs.
+func nameStatusDiffer(fn, repoRoot, vcs string) (gta.Differ, error) {
+	r := os.Stdin
+	if fn != "-" {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
 
+	root := repoRoot
+	if len(root) == 0 {
+		var err error
+		if root, err = repositoryRoot(vcs); err != nil {
+			return nil, err
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return gta.NewNameStatusDifferWithRoot(root, r)
+}
+
+// rootedPath returns fn joined to root when fn is relative.
+func rootedPath(root, fn string) string {
+	if filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(root, filepath.FromSlash(fn))
+}
+
+func changedFiles(b []byte, allowRelative bool) ([]string, error) {
 	sl := strings.Split(string(b), "\n")
 	n := 0
 	for _, s := range sl {
@@ -125,8 +1024,8 @@ func changedFiles(fn string) ([]string, error) {
 			continue
 		}
 
-		if !filepath.IsAbs(s) {
-			return nil, errors.New("all changed files paths must be absolute paths")
+		if !allowRelative && !filepath.IsAbs(s) {
+			return nil, errors.New("all changed files paths must be absolute paths unless -repo-root is set")
 		}
 
 		sl[n] = s
@@ -143,6 +1042,19 @@ func keepChangedFile(s string) bool {
 	return len(s) > 0
 }
 
+// parseChangelists parses the comma separated changelist numbers in s.
+func parseChangelists(s string) ([]int, error) {
+	var changelists []int
+	for _, cl := range parseStringSlice(s) {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid changelist %q", cl)
+		}
+		changelists = append(changelists, n)
+	}
+	return changelists, nil
+}
+
 func parseStringSlice(s string) []string {
 	var values []string
 	for _, s := range strings.Split(s, ",") {
@@ -154,3 +1066,88 @@ func parseStringSlice(s string) []string {
 	}
 	return values
 }
+
+// isFlagSet reports whether the flag named name was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// packagePatterns describes the packages that are loaded for prefixes.
+func packagePatterns(prefixes []string) string {
+	if len(prefixes) == 0 {
+		return "all packages"
+	}
+	return strings.Join(prefixes, ", ")
+}
+
+// parseGitHubPR parses a pull request given as owner/repo#number.
+func parseGitHubPR(s string) (owner, repo string, number int, err error) {
+	invalid := fmt.Errorf("invalid pull request %q: must be owner/repo#number", s)
+
+	i := strings.LastIndex(s, "#")
+	if i < 0 {
+		return "", "", 0, invalid
+	}
+	number, err = strconv.Atoi(s[i+1:])
+	if err != nil || number <= 0 {
+		return "", "", 0, invalid
+	}
+
+	parts := strings.Split(s[:i], "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", 0, invalid
+	}
+	return parts[0], parts[1], number, nil
+}
+
+// changedFilesSource returns the file, patch, or pull request that changed
+// files are read from, if any.
+func changedFilesSource(changedFiles, fromManifest, githubPR, patch, nameStatus string) string {
+	if len(patch) > 0 {
+		return patch
+	}
+	if len(nameStatus) > 0 {
+		return nameStatus
+	}
+	if len(fromManifest) > 0 {
+		return fromManifest
+	}
+	if len(githubPR) > 0 {
+		return githubPR
+	}
+	return changedFiles
+}
+
+// changeSource describes where changes are read from.
+func changeSource(vcs, base, changedFiles string, affectedBy []string) string {
+	if len(affectedBy) > 0 {
+		return strings.Join(affectedBy, ", ")
+	}
+	if changedFiles == "-" {
+		return "standard input"
+	}
+	if len(changedFiles) > 0 {
+		return changedFiles
+	}
+	return fmt.Sprintf("%s against %s", vcs, base)
+}
+
+// absPaths returns the absolute paths of paths, which are relative to the
+// current working directory when they are not absolute.
+func absPaths(paths []string) ([]string, error) {
+	var out []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, abs)
+	}
+	return out, nil
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/gta"
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestMain runs gta with the arguments of the test binary instead of the tests
+// when GTA_TEST_MAIN is set, so that tests can check its exit status.
+func TestMain(m *testing.M) {
+	if os.Getenv("GTA_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCheckExitStatus(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n",
+	})
+	repo.Checkout("feature")
+	repo.WriteFile("a/a.go", "package a\n\nconst A = 1\n")
+	repo.Commit("change a")
+
+	tests := []struct {
+		desc string
+		base string
+		pkg  string
+		want int
+	}{
+		{
+			desc: "affected",
+			base: gtatest.BaseBranch,
+			pkg:  "example.com/foo/a",
+			want: 0,
+		},
+		{
+			desc: "not affected",
+			base: gtatest.BaseBranch,
+			pkg:  "example.com/foo/b",
+			want: checkUnaffectedStatus,
+		},
+		{
+			desc: "error",
+			base: "missing",
+			pkg:  "example.com/foo/a",
+			want: checkErrorStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-include", "example.com/foo/", "-base", tt.base, "check", tt.pkg)
+			cmd.Dir = repo.Dir
+			cmd.Env = append(os.Environ(), "GTA_TEST_MAIN=1")
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+
+			got := 0
+			if err := cmd.Run(); err != nil {
+				exitErr, ok := err.(*exec.ExitError)
+				if !ok {
+					t.Fatal(err)
+				}
+				got = exitErr.ExitCode()
+			}
+			if got != tt.want {
+				t.Errorf("got exit status %d, want %d:\n%s", got, tt.want, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCheckJSONOnly(t *testing.T) {
+	tests := []struct {
+		desc    string
+		json    bool
+		flags   []flagUse
+		wantErr string
+	}{
+		{
+			desc:  "unset",
+			flags: []flagUse{{"api", false}, {"orphans", false}},
+		},
+		{
+			desc:  "json",
+			json:  true,
+			flags: []flagUse{{"api", true}, {"orphans", true}},
+		},
+		{
+			desc:    "without json",
+			flags:   []flagUse{{"api", false}, {"orphans", true}, {"meta", true}},
+			wantErr: "-json must be set when using -orphans",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := checkJSONOnly(tt.json, tt.flags)
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("got error %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlainPackages(t *testing.T) {
+	pkgs := &gta.Packages{
+		Changes: []gta.Package{{ImportPath: "a", Dir: "/src/a"}},
+		AllChanges: []gta.Package{
+			{ImportPath: "a", Dir: "/src/a"},
+			{ImportPath: "b", Dir: "/src/b"},
+			{ImportPath: "c"},
+		},
+	}
+
+	tests := []struct {
+		desc          string
+		partial       bool
+		buildableOnly bool
+		marker        string
+		color         bool
+		want          []string
+	}{
+		{
+			desc: "plain",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			desc:          "buildable only",
+			buildableOnly: true,
+			want:          []string{"a", "b"},
+		},
+		{
+			desc:          "partial",
+			partial:       true,
+			buildableOnly: true,
+			want:          []string{"a", "b", "c"},
+		},
+		{
+			desc:   "marker",
+			marker: "*",
+			want:   []string{"*a", "b", "c"},
+		},
+		{
+			desc:  "color",
+			color: true,
+			want:  []string{colorDirect + "a" + colorReset, "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			pkgs.Partial = tt.partial
+			got := plainPackages(pkgs, tt.buildableOnly, tt.marker, tt.color)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitOnlyFlags(t *testing.T) {
+	tests := []struct {
+		desc string
+		args []string
+		want string
+	}{
+		{
+			desc: "merge-base with p4",
+			args: []string{"-vcs", "p4", "-merge-base=false"},
+			want: "-merge-base=false is only supported when using git to determine changes",
+		},
+		{
+			desc: "merge-base with changed-files",
+			args: []string{"-changed-files", "changes.txt", "-merge-base=false"},
+			want: "-merge-base=false is only supported when using git to determine changes",
+		},
+		{
+			desc: "fetch-remote with hg",
+			args: []string{"-vcs", "hg", "-fetch-remote", "origin"},
+			want: "-fetch-remote is only supported when using git to determine changes",
+		},
+		{
+			desc: "fetch-remote with affected-by",
+			args: []string{"-fetch-remote", "origin", "affected-by", "a.go"},
+			want: "-fetch-remote is only supported when using git to determine changes",
+		},
+		{
+			desc: "ignore-generated with p4",
+			args: []string{"-vcs", "p4", "-ignore-generated"},
+			want: "-ignore-generated is only supported when using git to determine changes",
+		},
+		{
+			desc: "ignore-whitespace with svn",
+			args: []string{"-vcs", "svn", "-ignore-whitespace"},
+			want: "-ignore-whitespace is only supported when using git to determine changes",
+		},
+		{
+			desc: "ignore-binary with hg",
+			args: []string{"-vcs", "hg", "-ignore-binary"},
+			want: "-ignore-binary is only supported when using git to determine changes",
+		},
+		{
+			desc: "ignore-binary with changed-files",
+			args: []string{"-changed-files", "changes.txt", "-ignore-binary"},
+			want: "-ignore-binary is only supported when using git to determine changes",
+		},
+		{
+			desc: "map-binary with patch",
+			args: []string{"-patch", "changes.patch", "-map-binary"},
+			want: "-map-binary is only supported when using git to determine changes",
+		},
+		{
+			desc: "map-binary with github-pr",
+			args: []string{"-github-pr", "digitalocean/gta#1", "-map-binary"},
+			want: "-map-binary is only supported when using git to determine changes",
+		},
+		{
+			desc: "map-binary with name-status",
+			args: []string{"-name-status", "changes.txt", "-map-binary"},
+			want: "-map-binary is only supported when using git to determine changes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], tt.args...)
+			cmd.Dir = t.TempDir()
+			cmd.Env = append(os.Environ(), "GTA_TEST_MAIN=1")
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+
+			err := cmd.Run()
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				t.Fatalf("got error %v, want exit status %d", err, errorStatus)
+			}
+			if got := exitErr.ExitCode(); got != errorStatus {
+				t.Errorf("got exit status %d, want %d", got, errorStatus)
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("got output %q, want it to contain %q", stderr.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/gta"
+)
+
+// manifestFormat is the version of the manifest's layout.
+const manifestFormat = 1
+
+// manifest records the inputs of an analysis so that a later run can
+// reproduce or audit it.
+type manifest struct {
+	Format int `json:"format"`
+
+	// Version is the version of gta that wrote the manifest.
+	Version string `json:"version"`
+
+	// Differ is what determined the changed files: git, hg, changed-files, or
+	// affected-by.
+	Differ string `json:"differ"`
+
+	// Base is the base the changes were determined against, and BaseRevision
+	// and HeadRevision identify the compared revisions.
+	Base         string `json:"base,omitempty"`
+	BaseRevision string `json:"base_revision,omitempty"`
+	HeadRevision string `json:"head_revision,omitempty"`
+
+	Include []string `json:"include,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+
+	// ConfigHash is the SHA-256 hash of the configuration file.
+	ConfigHash string `json:"config_hash,omitempty"`
+
+	// Files are the changed files, relative to the root of the repository.
+	Files []manifestFile `json:"files"`
+}
+
+// manifestFile describes a changed file in a manifest.
+type manifestFile struct {
+	Path    string `json:"path"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// newManifest returns a manifest of the changed files reported by difr. vcs
+// is used to identify the revisions of the repository.
+func newManifest(vcs string, difr gta.Differ) (*manifest, error) {
+	files, err := difr.DiffFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := repositoryRoot(vcs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &manifest{
+		Format:       manifestFormat,
+		Version:      version(),
+		HeadRevision: revision(vcs, "HEAD"),
+		Files:        []manifestFile{},
+	}
+
+	for abs, ok := range files {
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, manifestFile{
+			Path:    filepath.ToSlash(rel),
+			Deleted: !ok,
+		})
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+
+	return m, nil
+}
+
+// write writes m to the file fn.
+func (m *manifest) write(fn string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fn, append(b, '\n'), 0644)
+}
+
+// readManifest reads the manifest in the file fn.
+func readManifest(fn string) (*manifest, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(manifest)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	if m.Format != manifestFormat {
+		return nil, fmt.Errorf("unsupported manifest format %d", m.Format)
+	}
+	return m, nil
+}
+
+// checkConfig returns an error when configHash, the hash of the configuration
+// file of the current run, differs from the one recorded in m.
+func (m *manifest) checkConfig(configHash string) error {
+	if m.ConfigHash != configHash {
+		return fmt.Errorf("the configuration differs from the one recorded in the manifest: hash %q, recorded %q", configHash, m.ConfigHash)
+	}
+	return nil
+}
+
+// differ returns a differ that reports the changed files recorded in m,
+// relative to the root of the repository determined using vcs.
+func (m *manifest) differ(vcs string) (gta.Differ, error) {
+	root, err := repositoryRoot(vcs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]gta.FileChange, 0, len(m.Files))
+	for _, f := range m.Files {
+		status := gta.StatusModified
+		if f.Deleted {
+			status = gta.StatusDeleted
+		}
+		changes = append(changes, gta.FileChange{
+			Path:   filepath.Join(root, filepath.FromSlash(f.Path)),
+			Status: status,
+		})
+	}
+
+	return gta.NewFileChangesDiffer(changes)
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the content of the file fn.
+func hashFile(fn string) (string, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// version returns the version of gta.
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return info.Main.Version
+}
+
+// repositoryRoot returns the root of the repository that contains the
+// current directory using vcs, or the current directory when it is not in a
+// repository.
+func repositoryRoot(vcs string) (string, error) {
+	return repositoryRootOf(vcs, "")
+}
+
+// repositoryRootOf is like repositoryRoot, but for the repository that
+// contains dir, or dir when it is not in a repository. An empty dir is the
+// current directory.
+func repositoryRootOf(vcs, dir string) (string, error) {
+	var args []string
+	switch vcs {
+	case "git":
+		args = []string{"rev-parse", "--show-toplevel"}
+	case "hg":
+		args = []string{"root"}
+	case "p4":
+		args = []string{"-F", "%clientRoot%", "-ztag", "info"}
+	case "svn":
+		args = []string{"info", "--show-item", "wc-root"}
+	}
+
+	if len(args) > 0 {
+		cmd := exec.Command(vcs, args...)
+		cmd.Dir = dir
+		if out, err := cmd.Output(); err == nil {
+			return strings.TrimSpace(string(out)), nil
+		}
+	}
+	if dir != "" {
+		return filepath.Abs(dir)
+	}
+	return os.Getwd()
+}
+
+// revision returns the identifier of the revision rev using vcs, or an empty
+// string when it cannot be determined. For hg, HEAD is taken to be the working
+// directory's parent.
+func revision(vcs, rev string) string {
+	var cmd *exec.Cmd
+	switch vcs {
+	case "git":
+		cmd = exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	case "hg":
+		if rev == "HEAD" {
+			rev = "."
+		}
+		cmd = exec.Command("hg", "log", "-r", rev, "--template", "{node}")
+		cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	default:
+		return ""
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/gta"
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestManifest_RoundTrip(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"a/a.go":    "package a\n",
+		"b/b.go":    "package b\n\nimport _ \"example.com/foo/a\"\n",
+		"c/c.go":    "package c\n",
+		"d/d.go":    "package d\n",
+		"README.md": "foo\n",
+	})
+	repo.Checkout("feature")
+	repo.WriteFile("a/a.go", "package a\n\nconst A = 1\n")
+	repo.Remove("d/d.go")
+	repo.WriteFile("README.md", "bar\n")
+	repo.Commit("change")
+	repo.Chdir()
+
+	changedPackages := func(difr gta.Differ) *gta.Packages {
+		t.Helper()
+
+		gt, err := gta.New(gta.SetDiffer(difr), gta.SetPrefixes("example.com/foo/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs, err := gt.ChangedPackages()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkgs
+	}
+
+	difr := gta.NewGitDiffer(gta.SetBaseBranch(gtatest.BaseBranch))
+	want := changedPackages(difr)
+	var got []string
+	for _, pkg := range want.AllChanges {
+		got = append(got, pkg.ImportPath)
+	}
+	if diff := cmp.Diff([]string{"example.com/foo/a", "example.com/foo/b", "example.com/foo/d"}, got); diff != "" {
+		t.Fatalf("packages before the round trip (-want, +got)\n%s", diff)
+	}
+
+	m, err := newManifest("git", difr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Differ, m.Base, m.Include = "git", gtatest.BaseBranch, []string{"example.com/foo/"}
+
+	fn := repo.Path("manifest.json")
+	if err := m.write(fn); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := readManifest(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(m, replay); diff != "" {
+		t.Errorf("manifest (-want, +got)\n%s", diff)
+	}
+
+	wantFiles := []manifestFile{
+		{Path: "README.md"},
+		{Path: "a/a.go"},
+		{Path: "d/d.go", Deleted: true},
+	}
+	if diff := cmp.Diff(wantFiles, replay.Files); diff != "" {
+		t.Errorf("files (-want, +got)\n%s", diff)
+	}
+
+	replayed, err := replay.differ("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, changedPackages(replayed)); diff != "" {
+		t.Errorf("packages (-want, +got)\n%s", diff)
+	}
+}
+
+func TestReadManifest_Format(t *testing.T) {
+	fn := t.TempDir() + "/manifest.json"
+	if err := ioutil.WriteFile(fn, []byte(`{"format": 2, "differ": "git", "files": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := readManifest(fn)
+	if err == nil || !strings.Contains(err.Error(), "unsupported manifest format 2") {
+		t.Errorf("got error %v, want an unsupported format error", err)
+	}
+}
+
+func TestManifest_CheckConfig(t *testing.T) {
+	m := &manifest{Format: manifestFormat, ConfigHash: "abc"}
+	if err := m.checkConfig("abc"); err != nil {
+		t.Errorf("unexpected error for the recorded hash: %v", err)
+	}
+	if err := m.checkConfig("def"); err == nil {
+		t.Error("expected an error for a different hash")
+	}
+	if err := m.checkConfig(""); err == nil {
+		t.Error("expected an error without a configuration")
+	}
+}
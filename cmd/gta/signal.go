@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// progress records what gta is doing so that it can be reported when gta is
+// interrupted.
+type progress struct {
+	mu    sync.Mutex
+	stage string
+}
+
+// set records that gta is in stage.
+func (p *progress) set(stage string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stage = stage
+}
+
+func (p *progress) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stage
+}
+
+// interruptContext returns a context that is cancelled when the process
+// receives SIGINT or SIGTERM, so that in-flight git commands and package
+// loading are stopped instead of being orphaned. The stage recorded in p is
+// logged when a signal is received. A second signal exits immediately.
+func interruptContext(p *progress) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			log.Printf("received %v while %s; cancelling", sig, p.get())
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(sigs)
+			return
+		}
+
+		sig := <-sigs
+		log.Printf("received %v; exiting", sig)
+		os.Exit(130)
+	}()
+
+	return ctx, cancel
+}
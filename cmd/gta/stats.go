@@ -0,0 +1,68 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/digitalocean/gta"
+)
+
+// statsFlags are the flags of the stats command.
+type statsFlags struct {
+	top  int
+	json bool
+}
+
+// parseStatsFlags parses the arguments of the stats command.
+func parseStatsFlags(args []string) (*statsFlags, error) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	flagTop := fs.Int("top", 10, "the number of packages with the most transitive dependents to list")
+	flagJSON := fs.Bool("json", false, "write the statistics as json")
+	fs.Parse(args)
+
+	if *flagTop < 0 {
+		return nil, errors.New("-top must not be negative")
+	}
+	return &statsFlags{top: *flagTop, json: *flagJSON}, nil
+}
+
+// writeStats writes the statistics of the dependency graph loaded by gt to w
+// in the format of sf.
+func writeStats(w io.Writer, sf *statsFlags, gt *gta.GTA) error {
+	graph, err := gt.ExportDependencyGraph()
+	if err != nil {
+		return err
+	}
+	stats := graph.Stats(sf.top)
+
+	if sf.json {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "packages\t%d\n", stats.Nodes)
+	fmt.Fprintf(tw, "imports\t%d\n", stats.Edges)
+	fmt.Fprintf(tw, "average fan-in\t%.2f\n", stats.AvgFanIn)
+	fmt.Fprintf(tw, "average fan-out\t%.2f\n", stats.AvgFanOut)
+	fmt.Fprintf(tw, "max fan-in\t%d\t%s\n", stats.MaxFanIn.Count, stats.MaxFanIn.ImportPath)
+	fmt.Fprintf(tw, "max fan-out\t%d\t%s\n", stats.MaxFanOut.Count, stats.MaxFanOut.ImportPath)
+	if len(stats.TopDependents) > 0 {
+		fmt.Fprintln(tw, "\ntransitive dependents")
+		for _, pc := range stats.TopDependents {
+			fmt.Fprintf(tw, "%d\t%s\n", pc.Count, pc.ImportPath)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/gta"
+	"golang.org/x/tools/go/packages"
+)
+
+// configProblem is a rule of the configuration file that matches nothing in
+// the repository or that conflicts with another rule.
+type configProblem struct {
+	// Rule identifies the rule, e.g. fixtures[0].
+	Rule    string `json:"rule"`
+	Problem string `json:"problem"`
+}
+
+// configCommand runs the config subcommand named by the first of args.
+func configCommand(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("usage: gta config validate [-config file] [-json]")
+	}
+	return validateConfig(args[1:], os.Stdout)
+}
+
+// validateConfig loads the configuration file, resolves its rules against the
+// repository that contains the file, and writes the problems it finds to w.
+// It returns an error when there are problems or when the packages of the
+// repository can't be listed.
+func validateConfig(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	flagConfig := fs.String("config", ".gta.json", "path of the configuration file to validate")
+	flagJSON := fs.Bool("json", false, "write the problems as json")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(*flagConfig)
+	if err != nil {
+		return err
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName, Dir: cfg.dir}, "./...")
+	if err != nil {
+		return fmt.Errorf("can't list the packages of the repository: %v", err)
+	}
+	var importPaths []string
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return fmt.Errorf("can't list the packages of the repository: %v", pkg.Errors[0])
+		}
+		importPaths = append(importPaths, pkg.PkgPath)
+	}
+
+	// ignore patterns are relative to the root of the repository, like the
+	// git differ that applies them.
+	root, err := repositoryRootOf("git", cfg.dir)
+	if err != nil {
+		return err
+	}
+
+	problems, err := cfg.validate(root, importPaths)
+	if err != nil {
+		return err
+	}
+
+	if *flagJSON {
+		if problems == nil {
+			problems = []configProblem{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Fprintf(w, "%s: %s\n", p.Rule, p.Problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problems in %s", len(problems), *flagConfig)
+	}
+	return nil
+}
+
+// validate returns the problems of the rules of c, given the root of the
+// repository and the import paths of its packages. Paths are resolved like
+// they are when gta runs: ignore patterns against the root, and the other
+// patterns and directories against the directory of c.
+func (c *config) validate(root string, importPaths []string) ([]configProblem, error) {
+	known := make(map[string]bool)
+	for _, importPath := range importPaths {
+		known[importPath] = true
+	}
+
+	// the slash separated absolute paths of the files of the repository, and
+	// the same paths relative to root.
+	var files, rels []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(p))
+		rels = append(rels, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []configProblem
+	report := func(rule, format string, args ...interface{}) {
+		problems = append(problems, configProblem{Rule: rule, Problem: fmt.Sprintf(format, args...)})
+	}
+	matches := func(pattern string, names []string) bool {
+		for _, fn := range names {
+			if gta.MatchGlob(filepath.ToSlash(pattern), fn) {
+				return true
+			}
+		}
+		return false
+	}
+	checkPackages := func(rule string, pkgs []string) {
+		for _, pkg := range pkgs {
+			if !known[pkg] {
+				report(rule, "package %s is not in the repository", pkg)
+			}
+		}
+	}
+	checkDir := func(rule, dir string) {
+		if info, err := os.Stat(c.path(dir)); err != nil || !info.IsDir() {
+			report(rule, "directory %s does not exist", dir)
+		}
+	}
+
+	for i, prefix := range c.Include {
+		matched := false
+		for importPath := range known {
+			if strings.HasPrefix(importPath, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report(fmt.Sprintf("include[%d]", i), "prefix %s matches no package", prefix)
+		}
+	}
+
+	for i, pattern := range c.Ignore {
+		if !matches(pattern, rels) {
+			report(fmt.Sprintf("ignore[%d]", i), "pattern %s matches no file", pattern)
+		}
+	}
+
+	for i, pattern := range c.triggers() {
+		if !matches(pattern, files) {
+			report(fmt.Sprintf("triggers[%d]", i), "pattern %s matches no file", c.Triggers[i])
+		}
+	}
+
+	rules := c.mappingRules()
+	for i, m := range c.Mappings {
+		rule := fmt.Sprintf("mappings[%d]", i)
+		if !matches(rules[i].Pattern, files) {
+			report(rule, "pattern %s matches no file", m.Pattern)
+		}
+		if len(m.Targets) == 0 {
+			report(rule, "has no targets")
+		}
+		for _, target := range m.Targets {
+			if !strings.HasPrefix(target, ":") {
+				checkDir(rule, target)
+			}
+		}
+	}
+
+	// the rules that claim each directory, to find those claimed twice.
+	dirRules := make(map[string][]string)
+	for i, f := range c.Fixtures {
+		rule := fmt.Sprintf("fixtures[%d]", i)
+		checkDir(rule, f.Dir)
+		checkPackages(rule, f.Consumers)
+		if len(f.Consumers) == 0 && !f.DetectConsumers {
+			report(rule, "has no consumers and does not detect them")
+		}
+		dirRules[c.path(f.Dir)] = append(dirRules[c.path(f.Dir)], rule)
+	}
+	for i, m := range c.Migrations {
+		rule := fmt.Sprintf("migrations[%d]", i)
+		checkDir(rule, m.Dir)
+		checkPackages(rule, m.Packages)
+		if len(m.Packages) == 0 && !m.DetectPackages {
+			report(rule, "has no packages and does not detect them")
+		}
+		dirRules[c.path(m.Dir)] = append(dirRules[c.path(m.Dir)], rule)
+	}
+	var dirs []string
+	for dir, rules := range dirRules {
+		if len(rules) > 1 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		rules := dirRules[dir]
+		report(rules[len(rules)-1], "claims directory %s, like %s", dir, strings.Join(rules[:len(rules)-1], ", "))
+	}
+
+	for i, spec := range c.Specs {
+		rule := fmt.Sprintf("specs[%d]", i)
+		if len(spec.Patterns) == 0 {
+			report(rule, "has no patterns")
+		}
+		for _, pattern := range spec.Patterns {
+			if !matches(c.path(pattern), files) {
+				report(rule, "pattern %s matches no file", pattern)
+			}
+		}
+		if len(spec.Packages) == 0 {
+			report(rule, "has no packages")
+		}
+		checkPackages(rule, spec.Packages)
+	}
+
+	for i, gen := range c.Generators {
+		rule := fmt.Sprintf("generators[%d]", i)
+		if gen.Generator == "" {
+			report(rule, "has no generator")
+		}
+		if len(gen.Patterns) == 0 {
+			report(rule, "has no patterns")
+		}
+	}
+
+	names := make(map[string]string)
+	for i, set := range c.TagSets {
+		rule := fmt.Sprintf("tag_sets[%d]", i)
+		if set.Name == "" {
+			report(rule, "has no name")
+			continue
+		}
+		if other, ok := names[set.Name]; ok {
+			report(rule, "has the name %s, like %s", set.Name, other)
+			continue
+		}
+		names[set.Name] = rule
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateConfig(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"a/a.go":                 "package a\n",
+		"b/b.go":                 "package b\n",
+		"a/testdata/fixture.txt": "fixture\n",
+		"migrations/1_init.sql":  "CREATE TABLE foo ();\n",
+		"api/foo.proto":          "syntax = \"proto3\";\n",
+		"README.md":              "foo\n",
+	})
+
+	tests := []struct {
+		desc   string
+		config string
+		want   []configProblem
+	}{
+		{
+			desc: "valid",
+			config: `{
+				"include": ["example.com/foo/"],
+				"ignore": ["**/*.md"],
+				"triggers": ["README.md"],
+				"mappings": [{"pattern": "migrations/*.sql", "targets": ["a", ":db"]}],
+				"fixtures": [{"dir": "a/testdata", "consumers": ["example.com/foo/a"]}],
+				"migrations": [{"dir": "migrations", "detect_packages": true}],
+				"specs": [{"patterns": ["api/*.proto"], "packages": ["example.com/foo/b"]}],
+				"generators": [{"generator": "stringer", "patterns": ["*.go"]}],
+				"tag_sets": [{"name": "integration", "tags": ["integration"]}]
+			}`,
+			want: []configProblem{},
+		},
+		{
+			desc: "rules matching nothing",
+			config: `{
+				"include": ["example.com/bar/"],
+				"ignore": ["docs/**"],
+				"triggers": ["Makefile"],
+				"mappings": [{"pattern": "migrations/*.sql", "targets": ["db"]}],
+				"fixtures": [{"dir": "b/testdata", "consumers": ["example.com/foo/c"]}],
+				"migrations": [{"dir": "migrations"}],
+				"specs": [{"patterns": ["api/*.yaml"], "packages": ["example.com/foo/b"]}],
+				"generators": [{"generator": "", "patterns": ["*.go"]}]
+			}`,
+			want: []configProblem{
+				{Rule: "include[0]", Problem: "prefix example.com/bar/ matches no package"},
+				{Rule: "ignore[0]", Problem: "pattern docs/** matches no file"},
+				{Rule: "triggers[0]", Problem: "pattern Makefile matches no file"},
+				{Rule: "mappings[0]", Problem: "directory db does not exist"},
+				{Rule: "fixtures[0]", Problem: "directory b/testdata does not exist"},
+				{Rule: "fixtures[0]", Problem: "package example.com/foo/c is not in the repository"},
+				{Rule: "migrations[0]", Problem: "has no packages and does not detect them"},
+				{Rule: "specs[0]", Problem: "pattern api/*.yaml matches no file"},
+				{Rule: "generators[0]", Problem: "has no generator"},
+			},
+		},
+		{
+			desc: "conflicts",
+			config: `{
+				"fixtures": [{"dir": "migrations", "detect_consumers": true}],
+				"migrations": [{"dir": "migrations", "detect_packages": true}],
+				"tag_sets": [{"name": "linux", "goos": "linux"}, {"name": "linux", "tags": ["linux"]}]
+			}`,
+			want: []configProblem{
+				{Rule: "migrations[0]", Problem: "claims directory " + repo.Path("migrations") + ", like fixtures[0]"},
+				{Rule: "tag_sets[1]", Problem: "has the name linux, like tag_sets[0]"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			repo.WriteFile(".gta.json", tt.config)
+
+			var buf bytes.Buffer
+			err := validateConfig([]string{"-config", repo.Path(".gta.json"), "-json"}, &buf)
+			if len(tt.want) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(tt.want) > 0 && err == nil {
+				t.Error("expected an error")
+			}
+
+			var got []configProblem
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateConfigResolution(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"a/a.go":                "package a\n",
+		"a/migrations/1.sql":    "CREATE TABLE foo ();\n",
+		"docs/index.md":         "foo\n",
+		"Makefile":              "all:\n",
+		"tools/.gta.json":       "{}\n",
+		"tools/tools.go":        "package tools\n",
+		"tools/testdata/a.json": "{}\n",
+	})
+
+	tests := []struct {
+		desc   string
+		config string
+		want   []configProblem
+	}{
+		{
+			desc: "valid",
+			config: `{
+				"ignore": ["docs/**"],
+				"triggers": ["../Makefile"],
+				"mappings": [{"pattern": "../a/migrations/*.sql", "targets": ["../a"]}],
+				"fixtures": [{"dir": "testdata", "detect_consumers": true}]
+			}`,
+			want: []configProblem{},
+		},
+		{
+			desc: "relative to the configuration",
+			config: `{
+				"ignore": ["testdata/**"],
+				"triggers": ["Makefile"],
+				"mappings": [{"pattern": "a/migrations/*.sql", "targets": ["../a"]}]
+			}`,
+			want: []configProblem{
+				{Rule: "ignore[0]", Problem: "pattern testdata/** matches no file"},
+				{Rule: "triggers[0]", Problem: "pattern Makefile matches no file"},
+				{Rule: "mappings[0]", Problem: "pattern a/migrations/*.sql matches no file"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			repo.WriteFile("tools/.gta.json", tt.config)
+
+			var buf bytes.Buffer
+			err := validateConfig([]string{"-config", repo.Path("tools/.gta.json"), "-json"}, &buf)
+			if len(tt.want) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(tt.want) > 0 && err == nil {
+				t.Error("expected an error")
+			}
+
+			var got []configProblem
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateConfigLoadError(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", map[string]string{
+		"a/a.go":    "package a\n",
+		"a/b.go":    "package b\n",
+		".gta.json": "{}\n",
+	})
+
+	var buf bytes.Buffer
+	err := validateConfig([]string{"-config", repo.Path(".gta.json")}, &buf)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := "can't list the packages of the repository"; !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %q, want it to contain %q", err, want)
+	}
+}
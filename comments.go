@@ -0,0 +1,150 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"go/scanner"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dropCommentChanges returns dirs without the Go files whose only changes are
+// to comments, and without the directories that have no changed files left.
+// Files whose content before the change cannot be read are kept.
+func (g *GTA) dropCommentChanges(dirs map[string]Directory) (map[string]Directory, error) {
+	kept := make(map[string]Directory, len(dirs))
+	for abs, dir := range dirs {
+		if !dir.Exists {
+			kept[abs] = dir
+			continue
+		}
+
+		var files []string
+		for _, fn := range dir.Files {
+			commentsOnly, err := g.commentsOnly(filepath.Join(abs, fn))
+			if err != nil {
+				return nil, err
+			}
+			if !commentsOnly {
+				files = append(files, fn)
+			}
+		}
+
+		// a directory whose files were all dropped is not changed, but a
+		// directory without changed files is reported as it was.
+		if len(files) == 0 && len(dir.Files) > 0 {
+			continue
+		}
+		dir.Files = files
+		kept[abs] = dir
+	}
+	return kept, nil
+}
+
+// commentsOnly reports whether the only changes to the Go file at abs are to
+// comments. Directives, such as build constraints and //go:embed, are not
+// considered comments, and files that use cgo are never considered to have
+// changed only comments because cgo reads the comment before import "C".
+func (g *GTA) commentsOnly(abs string) (bool, error) {
+	if filepath.Ext(abs) != ".go" {
+		return false, nil
+	}
+
+	after, err := ioutil.ReadFile(abs)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	before, err := g.readBase(abs)
+	switch {
+	case errors.Is(err, os.ErrNotExist), err == errNoBaseReader:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	beforeTokens, ok := codeTokens(before)
+	if !ok {
+		return false, nil
+	}
+	afterTokens, ok := codeTokens(after)
+	if !ok || len(beforeTokens) != len(afterTokens) {
+		return false, nil
+	}
+	for i := range beforeTokens {
+		if beforeTokens[i] != afterTokens[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// codeToken is a token of Go source code and its literal value.
+type codeToken struct {
+	tok token.Token
+	lit string
+}
+
+// codeTokens returns the tokens of the Go source src that are not comments,
+// except for directives. It returns false when src cannot be scanned or uses
+// cgo.
+func codeTokens(src []byte) ([]codeToken, bool) {
+	return scanCodeTokens(src, true)
+}
+
+// scanCodeTokens is like codeTokens, but only returns false when src uses cgo
+// when cgo is true, e.g. because src is a declaration rather than a file.
+func scanCodeTokens(src []byte, cgo bool) ([]codeToken, bool) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	var failed bool
+	s.Init(file, src, func(token.Position, string) { failed = true }, scanner.ScanComments)
+
+	var tokens []codeToken
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		switch tok {
+		case token.COMMENT:
+			if !isDirective(lit) {
+				continue
+			}
+		case token.SEMICOLON:
+			// explicit and automatically inserted semicolons are equivalent.
+			lit = ""
+		case token.STRING:
+			// any "C" string is taken to be the import of cgo, which is
+			// conservative.
+			if cgo && lit == `"C"` {
+				return nil, false
+			}
+		}
+		tokens = append(tokens, codeToken{tok: tok, lit: lit})
+	}
+	return tokens, !failed
+}
+
+// isDirective reports whether the comment is a directive to the go command or
+// the compiler rather than documentation.
+func isDirective(comment string) bool {
+	return strings.HasPrefix(comment, "//go:") ||
+		strings.HasPrefix(comment, "// +build") ||
+		strings.HasPrefix(comment, "//export ") ||
+		strings.HasPrefix(comment, "//line ")
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_IgnoreCommentChanges(t *testing.T) {
+	// A depends on B and C
+	dir := t.TempDir()
+	dirB, dirC := filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, d := range []string{dirB, dirC} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dirB, "b.go", "// Package b does things.\npackage b\n\n// B is a number.\nvar B = 1\n")
+	writeFile(t, dirC, "c.go", "package c\n\nvar C = 2\n")
+
+	difr := &testBaseDiffer{
+		testDiffer: testDiffer{
+			diff: map[string]Directory{
+				dirB: Directory{Exists: true, Files: []string{"b.go"}},
+				dirC: Directory{Exists: true, Files: []string{"c.go"}},
+			},
+		},
+		base: map[string][]byte{
+			filepath.Join(dirB, "b.go"): []byte("package b\n\nvar B = 1 // a number\n"),
+			filepath.Join(dirC, "c.go"): []byte("package c\n\n// C is a number.\nvar C = 1\n"),
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{"A": true},
+			"C": map[string]bool{"A": true},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			dirB:   "B",
+			dirC:   "C",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetIgnoreCommentChanges(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Package{
+		{ImportPath: "C"},
+	}
+	if diff := cmp.Diff(want, pkgs.Changes); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestCodeTokens(t *testing.T) {
+	tests := []struct {
+		desc   string
+		before string
+		after  string
+		equal  bool
+	}{
+		{
+			desc:   "doc comment",
+			before: "package a\n\nfunc F() {}\n",
+			after:  "package a\n\n// F does nothing.\nfunc F() {}\n",
+			equal:  true,
+		},
+		{
+			desc:   "line comment and semicolon",
+			before: "package a\n\nvar a = 1; var b = 2\n",
+			after:  "package a\n\nvar a = 1 // one\nvar b = 2\n",
+			equal:  true,
+		},
+		{
+			desc:   "code",
+			before: "package a\n\nvar a = 1\n",
+			after:  "package a\n\nvar a = 2\n",
+		},
+		{
+			desc:   "build constraint",
+			before: "package a\n",
+			after:  "// +build linux\n\npackage a\n",
+		},
+		{
+			desc:   "go directive",
+			before: "package a\n\nimport _ \"embed\"\n\nvar s string\n",
+			after:  "package a\n\nimport _ \"embed\"\n\n//go:embed s.txt\nvar s string\n",
+		},
+		{
+			desc:   "cgo",
+			before: "package a\n\nimport \"C\"\n",
+			after:  "package a\n\n// #include <stdio.h>\nimport \"C\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			before, ok1 := codeTokens([]byte(tt.before))
+			after, ok2 := codeTokens([]byte(tt.after))
+			got := ok1 && ok2 && cmp.Equal(before, after, cmp.AllowUnexported(codeToken{}))
+			if got != tt.equal {
+				t.Errorf("equal = %v; want %v", got, tt.equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import "context"
+
+// NewCompositeDiffer returns a Differ whose changes are the union of the
+// changes of differs, e.g. to combine a git differ with a list of generated
+// files declared by another tool. A file or directory exists when any of the
+// differs reports that it exists. The returned Differ is also a BaseReader
+// that reads the base content of files using the first of differs that is a
+// BaseReader.
+func NewCompositeDiffer(differs ...Differ) Differ {
+	return &compositeDiffer{differs: differs}
+}
+
+// compositeDiffer implements the Differ and BaseReader interfaces by combining
+// several differs.
+type compositeDiffer struct {
+	differs []Differ
+}
+
+// Diff returns a set of changed directories. The keys of the returned map are
+// absolute paths.
+func (d *compositeDiffer) Diff() (map[string]Directory, error) {
+	dirs := make(map[string]Directory)
+	for _, differ := range d.differs {
+		diffed, err := differ.Diff()
+		if err != nil {
+			return nil, err
+		}
+		mergeDirs(dirs, diffed)
+	}
+	return dirs, nil
+}
+
+// DiffContext is like Diff, with ctx as the context of the commands that d's
+// differs run.
+func (d *compositeDiffer) DiffContext(ctx context.Context) (map[string]Directory, error) {
+	dirs := make(map[string]Directory)
+	for _, differ := range d.differs {
+		diffed, err := diffContext(ctx, differ)
+		if err != nil {
+			return nil, err
+		}
+		mergeDirs(dirs, diffed)
+	}
+	return dirs, nil
+}
+
+// DiffFiles returns a set of changed files. The keys of the returned map are
+// absolute paths. The map values indicate whether or not the file exists.
+func (d *compositeDiffer) DiffFiles() (map[string]bool, error) {
+	files := make(map[string]bool)
+	for _, differ := range d.differs {
+		diffed, err := differ.DiffFiles()
+		if err != nil {
+			return nil, err
+		}
+		for abs, exists := range diffed {
+			files[abs] = files[abs] || exists
+		}
+	}
+	return files, nil
+}
+
+// ReadBase returns the content of the file at abs before it was changed using
+// the first of d's differs that is a BaseReader.
+func (d *compositeDiffer) ReadBase(abs string) ([]byte, error) {
+	for _, differ := range d.differs {
+		if br, ok := differ.(BaseReader); ok {
+			return br.ReadBase(abs)
+		}
+	}
+	return nil, errNoBaseReader
+}
+
+// FileChanges returns the changes described by each of d's differs that is a
+// FileChangeDiffer.
+func (d *compositeDiffer) FileChanges() ([]FileChange, error) {
+	var changes []FileChange
+	for _, differ := range d.differs {
+		if fcd, ok := differ.(FileChangeDiffer); ok {
+			described, err := fcd.FileChanges()
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, described...)
+		}
+	}
+	return changes, nil
+}
+
+// BinaryFiles returns the binary files reported by each of d's differs that is
+// a BinaryFileDiffer.
+func (d *compositeDiffer) BinaryFiles() (map[string]struct{}, error) {
+	binary := make(map[string]struct{})
+	for _, differ := range d.differs {
+		if bfd, ok := differ.(BinaryFileDiffer); ok {
+			files, err := bfd.BinaryFiles()
+			if err != nil {
+				return nil, err
+			}
+			for abs := range files {
+				binary[abs] = struct{}{}
+			}
+		}
+	}
+	return binary, nil
+}
+
+// mergeDirs merges the changed directories in src into dst. The files of
+// directories in both are combined.
+func mergeDirs(dst, src map[string]Directory) {
+	for abs, dir := range src {
+		existing, ok := dst[abs]
+		if !ok {
+			// copy the files so that merging files from other differs does not
+			// modify the differ's value.
+			dir.Files = append([]string(nil), dir.Files...)
+			dst[abs] = dir
+			continue
+		}
+
+		existing.Exists = existing.Exists || dir.Exists
+		existing.Files = mergeFiles(existing.Files, dir.Files)
+		dst[abs] = existing
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompositeDiffer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "foo.go", "package foo\n")
+	writeFile(t, dir, "foo.pb.go", "package foo\n")
+
+	sut := NewCompositeDiffer(
+		NewFileDiffer([]string{
+			filepath.Join(dir, "foo.go"),
+			filepath.Join(dir, "bar", "bar.go"),
+		}),
+		NewFileDiffer([]string{
+			filepath.Join(dir, "foo.go"),
+			filepath.Join(dir, "foo.pb.go"),
+		}),
+	)
+
+	gotDirs, err := sut.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range gotDirs {
+		sort.Strings(d.Files)
+	}
+	wantDirs := map[string]Directory{
+		dir:                       {Exists: true, Files: []string{"foo.go", "foo.pb.go"}},
+		filepath.Join(dir, "bar"): {Exists: false, Files: []string{"bar.go"}},
+	}
+	if diff := cmp.Diff(wantDirs, gotDirs); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	gotFiles, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := map[string]bool{
+		filepath.Join(dir, "foo.go"):        true,
+		filepath.Join(dir, "foo.pb.go"):     true,
+		filepath.Join(dir, "bar", "bar.go"): false,
+	}
+	if diff := cmp.Diff(wantFiles, gotFiles); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if _, err := sut.(BaseReader).ReadBase(filepath.Join(dir, "foo.go")); err != errNoBaseReader {
+		t.Errorf("ReadBase(foo.go) err = %v; want %v", err, errNoBaseReader)
+	}
+}
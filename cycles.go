@@ -0,0 +1,75 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import "sort"
+
+// Cycles returns the cycles of g, as the sorted nodes of each strongly
+// connected component of more than one node, sorted by their first node. The
+// go command rejects import cycles between packages, but the graph of a
+// GTA's packager flattens test packages into the packages they test, so an
+// external test that imports a package that imports the tested package forms
+// a cycle.
+func (g *Graph) Cycles() [][]string {
+	// Tarjan's algorithm: index is the order in which each node was visited,
+	// and lowlink the lowest index reachable from it through the nodes on the
+	// stack.
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var cycles [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for edge := range g.graph[node] {
+			if _, ok := index[edge]; !ok {
+				visit(edge)
+				if lowlink[edge] < lowlink[node] {
+					lowlink[node] = lowlink[edge]
+				}
+			} else if onStack[edge] && index[edge] < lowlink[node] {
+				lowlink[node] = index[edge]
+			}
+		}
+
+		if lowlink[node] != index[node] {
+			return
+		}
+
+		var component []string
+		for {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[n] = false
+			component = append(component, n)
+			if n == node {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, node := range g.Nodes() {
+		if _, ok := index[node]; !ok {
+			visit(node)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i][0] < cycles[j][0]
+	})
+	return cycles
+}
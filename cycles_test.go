@@ -0,0 +1,71 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGraphCycles(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"a": {"b": true},
+			"b": {"c": true, "a": true},
+			"c": {"d": true},
+			"d": {"e": true, "d": true},
+			"e": {"c": true},
+			"f": {"a": true},
+		},
+	}
+
+	want := [][]string{{"a", "b"}, {"c", "d", "e"}}
+	if diff := cmp.Diff(want, graph.Cycles()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	acyclic := &Graph{graph: map[string]map[string]bool{"a": {"b": true}}}
+	if got := acyclic.Cycles(); len(got) != 0 {
+		t.Errorf("got cycles %v, want none", got)
+	}
+}
+
+func TestGraphCycles_ExternalTest(t *testing.T) {
+	// the external tests of a import b, which imports a.
+	a := &packages.Package{
+		ID:      "example.com/a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go"},
+	}
+	b := &packages.Package{
+		ID:      "example.com/b",
+		PkgPath: "example.com/b",
+		GoFiles: []string{"/src/b/b.go"},
+		Imports: map[string]*packages.Package{"example.com/a": a},
+	}
+	aXTest := &packages.Package{
+		ID:      "example.com/a_test [example.com/a.test]",
+		PkgPath: "example.com/a_test",
+		GoFiles: []string{"/src/a/a_test.go"},
+		Imports: map[string]*packages.Package{"example.com/b": b},
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{a, b, aXTest}, nil
+	})
+
+	graph, err := NewPackagerWithLoader(loader, nil, nil).DependentGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"example.com/a", "example.com/b"}}
+	if diff := cmp.Diff(want, graph.Cycles()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
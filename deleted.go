@@ -0,0 +1,78 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// deletedImportPath returns the import path of the package that was in the
+// directory abs before the change, when abs no longer contains a package.
+func (g *GTA) deletedImportPath(abs string) (string, error) {
+	importPath, ok, err := g.baseImportPath(abs)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return stripVendor(importPath), nil
+	}
+	return g.findImportPath(abs)
+}
+
+// baseImportPath returns the import path of the directory abs according to
+// the go.mod file of the module that contained abs before the change, so that
+// packages are attributed correctly even when their module was deleted or
+// moved along with them. It returns false when the content before the change
+// cannot be read or abs was not within a module.
+func (g *GTA) baseImportPath(abs string) (string, bool, error) {
+	abs = filepath.Clean(abs)
+	for dir := abs; ; {
+		b, err := g.readBase(filepath.Join(dir, "go.mod"))
+		switch {
+		case err == errNoBaseReader:
+			return "", false, nil
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return "", false, err
+		default:
+			modPath := goModModulePath(b)
+			if modPath == "" {
+				return "", false, nil
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return "", false, err
+			}
+			return path.Join(modPath, filepath.ToSlash(rel)), true, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false, nil
+		}
+		dir = parent
+	}
+}
+
+// goModModulePath returns the module path declared in the content of a go.mod
+// file, or an empty string when it declares none.
+func goModModulePath(b []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
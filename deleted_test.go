@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_DeletedModule(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	// the deleted package is in a nested module, so its import path cannot be
+	// derived from the directories that remain.
+	for _, d := range []string{"sub", "sub/pkg", "user"} {
+		if err := os.Mkdir(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.mod", "module example.com/root\n")
+	writeFile(t, dir, "sub/go.mod", "module example.com/other\n")
+	writeFile(t, dir, "sub/pkg/pkg.go", "package pkg\n")
+	writeFile(t, dir, "user/user.go", "package user\n")
+	repo.Commit("modules")
+	repo.Git("branch", "-f", "base")
+
+	repo.Git("rm", "-q", "-r", "sub")
+	repo.Git("commit", "-q", "-m", "delete")
+
+	difr := NewGitDiffer(SetWorkingDir(dir), SetEnv(gtatest.Env...), SetBaseBranch("base"))
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			dir:                        "example.com/root",
+			filepath.Join(dir, "user"): "example.com/root/user",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"example.com/other/pkg": {
+					"example.com/root/user": true,
+				},
+			},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Package{
+		{ImportPath: "example.com/other/pkg"},
+		{ImportPath: "example.com/root/user"},
+	}
+	if diff := cmp.Diff(want, got.AllChanges); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGoModModulePath(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{desc: "plain", in: "module example.com/foo\n\ngo 1.15\n", want: "example.com/foo"},
+		{desc: "quoted", in: "// comment\nmodule \"example.com/foo\"\n", want: "example.com/foo"},
+		{desc: "none", in: "go 1.15\n", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			if got := goModModulePath([]byte(tt.in)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A DependencyGraph is the dependency graph loaded by a GTA's default
+// packager, along with what the packager knows about the packages, such as
+// their directories and files. It can be encoded once, e.g. on a large
+// builder, and decoded by the jobs that analyze changes at the same commit,
+// which then do not load the packages themselves. The graph refers to the
+// packages' files by absolute paths, so the jobs need the repository at the
+// same path.
+type DependencyGraph struct {
+	// key identifies the commit and configuration the graph was loaded for,
+	// as the key of the graph cache. It is empty when it could not be
+	// determined.
+	key  string
+	snap *graphSnapshot
+
+	indexOnce sync.Once
+	// packagesByFile are the import paths of the packages of files.
+	packagesByFile map[string]string
+	// packagesByDir are the sorted import paths of the packages in
+	// directories.
+	packagesByDir map[string][]string
+}
+
+// encodedDependencyGraph is the gob encoding of a DependencyGraph.
+type encodedDependencyGraph struct {
+	Version  int
+	Key      string
+	Snapshot *graphSnapshot
+}
+
+// ExportDependencyGraph returns the dependency graph of g's packager, which
+// must be the default packager.
+func (g *GTA) ExportDependencyGraph() (*DependencyGraph, error) {
+	p, ok := g.packager.(*packageContext)
+	if !ok {
+		return nil, errors.New("only the dependency graph of the default packager can be exported")
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	// the graph is exported without a key when the key cannot be determined,
+	// e.g. when the working tree has uncommitted changes.
+	key, _ := g.graphCacheKey(g.loadConfig())
+	return &DependencyGraph{key: key, snap: p.snapshot()}, nil
+}
+
+// Encode writes d to w.
+func (d *DependencyGraph) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(encodedDependencyGraph{
+		Version:  graphCacheVersion,
+		Key:      d.key,
+		Snapshot: d.snap,
+	})
+}
+
+// DecodeDependencyGraph reads a dependency graph written by
+// DependencyGraph.Encode from r. Graphs written by other versions of gta are
+// rejected.
+func DecodeDependencyGraph(r io.Reader) (*DependencyGraph, error) {
+	var enc encodedDependencyGraph
+	if err := gob.NewDecoder(r).Decode(&enc); err != nil {
+		return nil, fmt.Errorf("decoding dependency graph, %v", err)
+	}
+	if enc.Version != graphCacheVersion {
+		return nil, fmt.Errorf("dependency graph has version %d, want %d", enc.Version, graphCacheVersion)
+	}
+	if enc.Snapshot == nil {
+		enc.Snapshot = &graphSnapshot{}
+	}
+	return &DependencyGraph{key: enc.Key, snap: enc.Snapshot}, nil
+}
+
+// SetDependencyGraph sets the dependency graph that a GTA's default packager
+// uses instead of loading packages. New returns an error when the graph was
+// loaded for a different commit, build tags, or prefixes than the GTA's, as
+// far as that can be determined.
+func SetDependencyGraph(d *DependencyGraph) Option {
+	return func(g *GTA) error {
+		g.depGraph = d
+		return nil
+	}
+}
+
+// dependencyGraphPackager returns the packager of g's dependency graph.
+func (g *GTA) dependencyGraphPackager() (Packager, error) {
+	if g.depGraph.key != "" {
+		key, err := g.graphCacheKey(g.loadConfig())
+		if err == nil && key != g.depGraph.key {
+			return nil, errors.New("the dependency graph was loaded for a different commit or configuration")
+		}
+	}
+	return g.depGraph.snap.packager(g.buildContext(g.tags)), nil
+}
+
+// LoadErrors returns the errors found while loading the packages of d.
+func (d *DependencyGraph) LoadErrors() LoadErrors {
+	return d.snap.LoadErrors
+}
+
+// PackageForFile returns the package that the file at path belongs to: the
+// package whose files include it or, when no package does, e.g. for files in
+// testdata directories or in directories without Go files, the package in the
+// nearest directory that contains it. The second value returned is false when
+// no package contains the file. Relative paths are relative to the working
+// directory.
+func (d *DependencyGraph) PackageForFile(path string) (*Package, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	d.index()
+
+	if importPath, ok := d.packagesByFile[abs]; ok {
+		return &Package{ImportPath: importPath, Dir: d.snap.Dirs[importPath]}, true
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if importPaths := d.packagesByDir[dir]; len(importPaths) > 0 {
+			return &Package{ImportPath: importPaths[0], Dir: dir}, true
+		}
+		if dir == filepath.Dir(dir) {
+			return nil, false
+		}
+	}
+}
+
+// PackagesForDir returns the packages in the directory dir, sorted by their
+// import paths. Relative paths are relative to the working directory.
+func (d *DependencyGraph) PackagesForDir(dir string) []*Package {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	d.index()
+
+	var pkgs []*Package
+	for _, importPath := range d.packagesByDir[abs] {
+		pkgs = append(pkgs, &Package{ImportPath: importPath, Dir: abs})
+	}
+	return pkgs
+}
+
+// index indexes the packages of d by their files and directories.
+func (d *DependencyGraph) index() {
+	d.indexOnce.Do(func() {
+		d.packagesByFile = make(map[string]string)
+		d.packagesByDir = make(map[string][]string)
+		for importPath, dir := range d.snap.Dirs {
+			d.packagesByDir[dir] = append(d.packagesByDir[dir], importPath)
+		}
+		for _, importPaths := range d.packagesByDir {
+			sort.Strings(importPaths)
+		}
+		for importPath, files := range d.snap.Files {
+			for _, fn := range files {
+				// a file of several packages belongs to the first of them.
+				if other, ok := d.packagesByFile[fn]; !ok || importPath < other {
+					d.packagesByFile[fn] = importPath
+				}
+			}
+		}
+	})
+}
+
+// DependentsOfModule returns the sorted import paths of the packages that
+// match the patterns that d was loaded for, e.g. the packages of the
+// repository, and that import a package of the module modulePath, directly or
+// transitively, such as the packages affected by upgrading the module. The
+// packages of the module itself are not included. The packages of the module
+// are those whose import paths have the module path as a prefix, so they
+// include the packages of modules nested within it.
+func (d *DependencyGraph) DependentsOfModule(modulePath string) []string {
+	inModule := func(importPath string) bool {
+		return importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/")
+	}
+
+	var queue []string
+	visited := make(map[string]bool)
+	for importPath := range d.snap.Reverse {
+		if inModule(importPath) {
+			queue = append(queue, importPath)
+			visited[importPath] = true
+		}
+	}
+
+	var dependents []string
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, dependent := range d.snap.Reverse[node] {
+			if visited[dependent] {
+				continue
+			}
+			visited[dependent] = true
+			queue = append(queue, dependent)
+			if _, ok := d.snap.Names[dependent]; ok && !inModule(dependent) {
+				dependents = append(dependents, dependent)
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
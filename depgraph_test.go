@@ -0,0 +1,197 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestDependencyGraph_EncodeDecode(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", nil)
+	dir := repo.Dir
+
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		b := &packages.Package{
+			ID:      "example.com/foo/b",
+			Name:    "b",
+			PkgPath: "example.com/foo/b",
+			GoFiles: []string{filepath.Join(dir, "b", "b.go")},
+		}
+		a := &packages.Package{
+			ID:      "example.com/foo/a",
+			Name:    "a",
+			PkgPath: "example.com/foo/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Imports: map[string]*packages.Package{"example.com/foo/b": b},
+		}
+		return []*packages.Package{a, b}, nil
+	})
+
+	gt, err := New(SetDir(dir), SetLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := gt.ExportDependencyGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.key == "" {
+		t.Error("expected the graph to have a key")
+	}
+
+	var buf bytes.Buffer
+	if err := exported.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeDependencyGraph(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failingLoader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return nil, errors.New("packages must not be loaded")
+	})
+	gt, err = New(SetDir(dir), SetLoader(failingLoader), SetDependencyGraph(decoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := gt.DependencyGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGraph := map[string]map[string]bool{
+		"example.com/foo/b": {"example.com/foo/a": true},
+	}
+	if diff := cmp.Diff(wantGraph, graph.graph); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	dirs, err := gt.packager.(PackageLocator).PackageDirs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDirs := map[string]string{
+		"example.com/foo/a": filepath.Join(dir, "a"),
+		"example.com/foo/b": filepath.Join(dir, "b"),
+	}
+	if diff := cmp.Diff(wantDirs, dirs); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	// a graph loaded for other build tags is rejected.
+	if _, err := New(SetDir(dir), SetLoader(failingLoader), SetTags("integration"), SetDependencyGraph(decoded)); err == nil {
+		t.Error("expected an error for a graph loaded with other tags")
+	}
+}
+
+func TestDecodeDependencyGraph_Version(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(encodedDependencyGraph{Version: graphCacheVersion - 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeDependencyGraph(&buf); err == nil {
+		t.Error("expected an error for a graph of another version")
+	}
+}
+
+func TestGTA_ExportDependencyGraph_Packager(t *testing.T) {
+	gt, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gt.ExportDependencyGraph(); err == nil {
+		t.Error("expected an error for a packager other than the default packager")
+	}
+}
+
+func TestDependencyGraph_PackageForFile(t *testing.T) {
+	d := &DependencyGraph{snap: &graphSnapshot{
+		Dirs: map[string]string{
+			"example.com/foo/a":      "/src/foo/a",
+			"example.com/foo/a/sub":  "/src/foo/a/sub",
+			"example.com/foo/a_test": "/src/foo/a",
+		},
+		Files: map[string][]string{
+			"example.com/foo/a":      {"/src/foo/a/a.go", "/src/foo/a/a_test.go"},
+			"example.com/foo/a/sub":  {"/src/foo/a/sub/sub.go"},
+			"example.com/foo/a_test": {"/src/foo/a/x_test.go"},
+		},
+	}}
+
+	tests := []struct {
+		path string
+		want *Package
+	}{
+		{path: "/src/foo/a/a.go", want: &Package{ImportPath: "example.com/foo/a", Dir: "/src/foo/a"}},
+		{path: "/src/foo/a/x_test.go", want: &Package{ImportPath: "example.com/foo/a_test", Dir: "/src/foo/a"}},
+		{path: "/src/foo/a/sub/sub.go", want: &Package{ImportPath: "example.com/foo/a/sub", Dir: "/src/foo/a/sub"}},
+		{path: "/src/foo/a/sub/testdata/in.txt", want: &Package{ImportPath: "example.com/foo/a/sub", Dir: "/src/foo/a/sub"}},
+		{path: "/src/foo/a/README.md", want: &Package{ImportPath: "example.com/foo/a", Dir: "/src/foo/a"}},
+		{path: "/src/foo/go.mod"},
+	}
+	for _, tt := range tests {
+		got, ok := d.PackageForFile(tt.path)
+		if ok != (tt.want != nil) {
+			t.Errorf("PackageForFile(%q) ok = %v; want %v", tt.path, ok, tt.want != nil)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("PackageForFile(%q) (-want, +got)\n%s", tt.path, diff)
+		}
+	}
+
+	want := []*Package{
+		{ImportPath: "example.com/foo/a", Dir: "/src/foo/a"},
+		{ImportPath: "example.com/foo/a_test", Dir: "/src/foo/a"},
+	}
+	if diff := cmp.Diff(want, d.PackagesForDir("/src/foo/a")); diff != "" {
+		t.Errorf("PackagesForDir (-want, +got)\n%s", diff)
+	}
+	if got := d.PackagesForDir("/src/foo"); got != nil {
+		t.Errorf("PackagesForDir(%q) = %v; want nil", "/src/foo", got)
+	}
+}
+
+func TestDependencyGraph_DependentsOfModule(t *testing.T) {
+	// example.com/app/a imports example.com/lib/x, which imports
+	// example.com/lib/y. example.com/app/b imports example.com/wrap, which
+	// imports example.com/lib. example.com/app/c imports example.com/app/b.
+	// example.com/library is another module that shares a prefix.
+	d := &DependencyGraph{snap: &graphSnapshot{
+		Reverse: map[string][]string{
+			"example.com/lib/y":   {"example.com/lib/x"},
+			"example.com/lib/x":   {"example.com/app/a"},
+			"example.com/lib":     {"example.com/wrap"},
+			"example.com/wrap":    {"example.com/app/b"},
+			"example.com/app/b":   {"example.com/app/c"},
+			"example.com/library": {"example.com/app/d"},
+		},
+		Names: map[string]string{
+			"example.com/app/a": "a",
+			"example.com/app/b": "b",
+			"example.com/app/c": "c",
+			"example.com/app/d": "d",
+		},
+	}}
+
+	want := []string{"example.com/app/a", "example.com/app/b", "example.com/app/c"}
+	if diff := cmp.Diff(want, d.DependentsOfModule("example.com/lib")); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if got := d.DependentsOfModule("example.com/unused"); got != nil {
+		t.Errorf("DependentsOfModule(%q) = %v; want nil", "example.com/unused", got)
+	}
+}
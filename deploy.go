@@ -0,0 +1,109 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDeployFiles are the patterns of the names of the build and
+// deployment files that are recognized when SetDeployFiles is given no
+// patterns.
+var DefaultDeployFiles = []string{
+	"Dockerfile",
+	"Dockerfile.*",
+	"*.Dockerfile",
+	"*.dockerfile",
+	".dockerignore",
+	"docker-compose*.yml",
+	"docker-compose*.yaml",
+	"kustomization.yml",
+	"kustomization.yaml",
+	"skaffold.yaml",
+	"Chart.yaml",
+	"Procfile",
+}
+
+// SetDeployFiles enables the attribution of build and deployment files, such
+// as Dockerfiles and Kubernetes manifests, to the packages in their
+// directories. Changes to the files whose names match the patterns, with the
+// syntax of path.Match, or that are Kubernetes manifests mark the packages but
+// not their dependents, so that pipelines that rebuild images of the packages
+// can be driven by the changed packages. The patterns default to
+// DefaultDeployFiles.
+func SetDeployFiles(patterns ...string) Option {
+	return func(g *GTA) error {
+		if len(patterns) == 0 {
+			patterns = DefaultDeployFiles
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return err
+			}
+		}
+		g.deployFiles = patterns
+		return nil
+	}
+}
+
+// splitDeployFiles returns dir without its files that are deployment files
+// according to g, and the deployment files.
+func (g *GTA) splitDeployFiles(abs string, dir Directory) (Directory, []string) {
+	var files, deploy []string
+	for _, fn := range dir.Files {
+		if g.isDeployFile(filepath.Join(abs, fn)) {
+			deploy = append(deploy, fn)
+			continue
+		}
+		files = append(files, fn)
+	}
+	dir.Files = files
+	return dir, deploy
+}
+
+// isDeployFile reports whether the file fn is a deployment file according to
+// g.
+func (g *GTA) isDeployFile(fn string) bool {
+	name := filepath.Base(fn)
+	for _, pattern := range g.deployFiles {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		return isKubernetesManifest(fn)
+	}
+	return false
+}
+
+// isKubernetesManifest reports whether the YAML file fn is a Kubernetes
+// manifest, which has top level apiVersion and kind fields. Files that cannot
+// be read, e.g. because they were deleted, are not.
+func isKubernetesManifest(fn string) bool {
+	f, err := os.Open(fn)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var apiVersion, kind bool
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		apiVersion = apiVersion || strings.HasPrefix(line, "apiVersion:")
+		kind = kind || strings.HasPrefix(line, "kind:")
+		if apiVersion && kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_DeployFiles(t *testing.T) {
+	// A depends on B
+	// B ships a Dockerfile and a Kubernetes manifest
+	dir := t.TempDir()
+	writeFile(t, dir, "deployment.yaml", "apiVersion: apps/v1\nkind: Deployment\n")
+	writeFile(t, dir, "config.yaml", "listen: :8080\n")
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"/repo/a": "A",
+			dir:       "B",
+		},
+		graph: graph,
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		opts  []Option
+		want  []string
+	}{
+		{name: "disabled", files: []string{"Dockerfile"}, want: []string{"A", "B"}},
+		{name: "dockerfile", files: []string{"Dockerfile"}, opts: []Option{SetDeployFiles()}, want: []string{"B"}},
+		{name: "manifest", files: []string{"deployment.yaml"}, opts: []Option{SetDeployFiles()}, want: []string{"B"}},
+		{name: "other yaml", files: []string{"config.yaml"}, opts: []Option{SetDeployFiles()}, want: []string{"A", "B"}},
+		{name: "patterns", files: []string{"Dockerfile"}, opts: []Option{SetDeployFiles("*.tf")}, want: []string{"A", "B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			difr := &testDiffer{
+				diff: map[string]Directory{
+					dir: Directory{Exists: true, Files: tt.files},
+				},
+			}
+			gt, err := New(append([]Option{SetDiffer(difr), SetPackager(pkgr)}, tt.opts...)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, stringify(got.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsKubernetesManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "service.yaml", "# the service\napiVersion: v1\nkind: Service\nmetadata:\n  name: foo\n")
+	writeFile(t, dir, "values.yaml", "image:\n  kind: distroless\n")
+
+	tests := map[string]bool{
+		"service.yaml": true,
+		"values.yaml":  false,
+		"deleted.yaml": false,
+	}
+	for name, want := range tests {
+		if got := isKubernetesManifest(filepath.Join(dir, name)); got != want {
+			t.Errorf("isKubernetesManifest(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
@@ -8,13 +8,18 @@ package gta
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // A Differ implements provides methods that return values to understand the
@@ -30,6 +35,26 @@ type Differ interface {
 	DiffFiles() (map[string]bool, error)
 }
 
+// A DifferContext is a Differ that can determine the changes with a context.
+// The commands that it runs to determine the changes are killed when the
+// context is done.
+type DifferContext interface {
+	// DiffContext is like Diff, with ctx as the context of the commands
+	// instead of the differ's context.
+	DiffContext(ctx context.Context) (map[string]Directory, error)
+}
+
+// A BaseReader is a Differ that can read the content of changed files as it
+// was before the change.
+type BaseReader interface {
+	Differ
+
+	// ReadBase returns the content of the file at the absolute path abs
+	// before it was changed. The returned error wraps os.ErrNotExist when the
+	// file did not exist before the change.
+	ReadBase(abs string) ([]byte, error)
+}
+
 // GitDifferOption is an option function used to modify a git differ
 type GitDifferOption func(*git)
 
@@ -40,10 +65,199 @@ func SetUseMergeCommit(useMergeCommit bool) GitDifferOption {
 	}
 }
 
+// SetUseMergeBase sets whether a git differ compares HEAD against the merge
+// base of the base branch and HEAD, like git diff base...HEAD, which is the
+// default. When it is false, HEAD is compared against the base branch
+// directly, like git diff base..HEAD, so that changes that landed on the base
+// branch after the fork point are reported too.
+func SetUseMergeBase(useMergeBase bool) GitDifferOption {
+	return func(gd *git) {
+		gd.noMergeBase = !useMergeBase
+	}
+}
+
 // SetBaseBranch sets the baseBranch field on a git differ
 func SetBaseBranch(baseBranch string) GitDifferOption {
 	return func(gd *git) {
 		gd.baseBranch = baseBranch
+		gd.baseBranches = nil
+	}
+}
+
+// SetBaseBranches sets several base branches that a git differ compares HEAD
+// against, e.g. origin/main and the release branches that changes are
+// cherry-picked to. The changed files are the union of the files changed
+// relative to each base. The first base is the one whose content is read by
+// ReadBase. It has no effect when the latest merge commit is used.
+func SetBaseBranches(baseBranches ...string) GitDifferOption {
+	return func(gd *git) {
+		if len(baseBranches) == 0 {
+			return
+		}
+		gd.baseBranch = baseBranches[0]
+		gd.baseBranches = baseBranches
+	}
+}
+
+// SetCommitCount sets a git differ to compare HEAD against the commit count
+// commits before it, to find the changes of the last count commits, instead
+// of against the base branch. When HEAD has fewer ancestors, every file in
+// HEAD is changed. It has no effect when the latest merge commit is used.
+func SetCommitCount(count int) GitDifferOption {
+	return func(gd *git) {
+		gd.commitCount = count
+	}
+}
+
+// SetSince sets a git differ to compare HEAD against the newest commit that
+// was committed before since elapsed, to find the changes committed in a time
+// window such as the last day, instead of against the base branch. When no
+// commit is that old, every file in HEAD is changed. It has no effect when the
+// latest merge commit is used.
+func SetSince(since time.Duration) GitDifferOption {
+	return func(gd *git) {
+		gd.since = since
+	}
+}
+
+// SetSinceLatestTag sets a git differ to compare HEAD against the most recent
+// tag reachable from HEAD whose name matches the glob pattern, e.g. v*, to find
+// the changes since the last release, instead of against the base branch. A
+// matching tag that points at HEAD itself is the most recent tag. It has no
+// effect when the latest merge commit is used.
+func SetSinceLatestTag(pattern string) GitDifferOption {
+	return func(gd *git) {
+		gd.tagPattern = pattern
+	}
+}
+
+// SetWorkingDir sets the directory in which a git differ runs git. When it is
+// not set, git is run in the current working directory.
+func SetWorkingDir(dir string) GitDifferOption {
+	return func(gd *git) {
+		gd.dir = dir
+	}
+}
+
+// SetRepoRoot sets the root of the repository whose changes a git differ
+// determines, so that gta can be used from outside of the repository. git is
+// run in dir unless a working directory is set with SetWorkingDir.
+func SetRepoRoot(dir string) GitDifferOption {
+	return func(gd *git) {
+		gd.repoRoot = dir
+	}
+}
+
+// SetGitExecutable sets the path of the git executable that a git differ
+// runs. When it is not set, git is looked up in the directories named by the
+// PATH environment variable.
+func SetGitExecutable(path string) GitDifferOption {
+	return func(gd *git) {
+		gd.executable = path
+	}
+}
+
+// SetEnv sets additional environment variables, each in the form "key=value",
+// for the git commands run by a git differ. They are appended to the
+// environment of the current process.
+func SetEnv(env ...string) GitDifferOption {
+	return func(gd *git) {
+		gd.env = env
+	}
+}
+
+// SetCommandTimeout sets the maximum duration of each git command run by a git
+// differ. A timeout of zero means git commands do not time out.
+func SetCommandTimeout(timeout time.Duration) GitDifferOption {
+	return func(gd *git) {
+		gd.timeout = timeout
+	}
+}
+
+// SetIncludeWorkingTree sets whether a git differ includes the uncommitted
+// changes in the working tree, including untracked files that are not
+// ignored, in addition to the committed changes.
+func SetIncludeWorkingTree(includeWorkingTree bool) GitDifferOption {
+	return func(gd *git) {
+		gd.includeWorkingTree = includeWorkingTree
+	}
+}
+
+// SetStagedOnly sets whether a git differ compares the index, rather than
+// HEAD, against the base branch, so that unstaged changes are ignored. This is
+// useful in pre-commit hooks. When it is set, the merge commit and working
+// tree options are ignored.
+func SetStagedOnly(stagedOnly bool) GitDifferOption {
+	return func(gd *git) {
+		gd.stagedOnly = stagedOnly
+	}
+}
+
+// SetIgnore sets the slash separated glob patterns, relative to the root of the
+// repository, of files that a git differ does not report as changed. A "**"
+// element in a pattern matches zero or more directories, e.g. docs/**. Ignored
+// files are dropped as git's output is read, so that ignoring the bulk of a
+// huge diff, such as a regenerated directory, keeps memory bounded.
+func SetIgnore(patterns ...string) GitDifferOption {
+	return func(gd *git) {
+		gd.ignore = patterns
+	}
+}
+
+// SetIgnoreGenerated sets whether a git differ drops the changed files that
+// are marked as generated with the linguist-generated attribute in
+// .gitattributes files, such as protobuf code and mocks, so that regenerating
+// them does not mark every package that depends on them as changed.
+func SetIgnoreGenerated(ignoreGenerated bool) GitDifferOption {
+	return func(gd *git) {
+		gd.ignoreGenerated = ignoreGenerated
+	}
+}
+
+// SetIgnoreWhitespace sets whether a git differ drops the modified files whose
+// changes are all whitespace, like git diff --ignore-all-space, so that
+// reformatting does not mark the packages that contain the files as changed.
+func SetIgnoreWhitespace(ignoreWhitespace bool) GitDifferOption {
+	return func(gd *git) {
+		gd.ignoreWhitespace = ignoreWhitespace
+	}
+}
+
+// SetIgnoreBinary sets whether a git differ drops the changed files that git
+// considers binary, such as images and other fixtures, so that changing them
+// does not mark the packages in their directories as changed.
+func SetIgnoreBinary(ignoreBinary bool) GitDifferOption {
+	return func(gd *git) {
+		gd.ignoreBinary = ignoreBinary
+	}
+}
+
+// SetFetchMissingBase sets the remote that a git differ fetches the base
+// branch from when it is missing, as it often is in shallow clones made by CI
+// systems, and deepens the clone until the merge base of the base branch and
+// HEAD is present. When the base branch is a remote-tracking branch of the
+// remote, e.g. origin/master, the corresponding branch is fetched. When remote
+// is empty, which is the default, nothing is fetched.
+func SetFetchMissingBase(remote string) GitDifferOption {
+	return func(gd *git) {
+		gd.fetchRemote = remote
+	}
+}
+
+// SetFetchDepth sets the number of commits that a git differ fetches at a time
+// when it fetches a missing base branch or deepens a shallow clone. It
+// defaults to 50.
+func SetFetchDepth(depth int) GitDifferOption {
+	return func(gd *git) {
+		gd.fetchDepth = depth
+	}
+}
+
+// SetGitContext sets the context of the git commands run by a git differ. The
+// commands are killed when ctx is done.
+func SetGitContext(ctx context.Context) GitDifferOption {
+	return func(gd *git) {
+		gd.ctx = ctx
 	}
 }
 
@@ -58,8 +272,12 @@ func NewGitDiffer(opts ...GitDifferOption) Differ {
 		opt(g)
 	}
 
-	return &differ{
-		diff: g.diff,
+	return &gitDiffer{
+		differ: differ{
+			diff:        g.diff,
+			diffContext: g.diffContext,
+		},
+		git: g,
 	}
 }
 
@@ -77,17 +295,111 @@ func NewFileDiffer(files []string) Differ {
 	}
 }
 
+// NewFileDifferWithRoot returns a Differ that operates on a list of paths of
+// changed files. Relative paths are interpreted relative to root, such as the
+// root of a repository, so that the output of tools like git diff --name-only
+// can be used as is.
+func NewFileDifferWithRoot(root string, files []string) Differ {
+	abs := make([]string, len(files))
+	for i, fn := range files {
+		if filepath.IsAbs(fn) {
+			abs[i] = fn
+			continue
+		}
+		abs[i] = filepath.Join(root, filepath.FromSlash(fn))
+	}
+
+	return NewFileDiffer(abs)
+}
+
+// labeledDiffer is a Differ whose changes are attributed to label.
+type labeledDiffer struct {
+	label  string
+	differ Differ
+}
+
 type differ struct {
 	diff func() (map[string]struct{}, error)
+	// diffContext, when it is set, determines the changed files with a
+	// context.
+	diffContext func(context.Context) (map[string]struct{}, error)
+}
+
+// gitDiffer implements the BaseReader interface using git.
+type gitDiffer struct {
+	differ
+	git *git
+}
+
+// ReadBase returns the content of the file at abs as of the merge base of the
+// commits being compared.
+func (d *gitDiffer) ReadBase(abs string) ([]byte, error) {
+	return d.git.readBase(abs)
+}
+
+// FileChanges returns the changes to the changed files, detecting renames and
+// copies between the merge base of the commits being compared and HEAD, or
+// the index or working tree when they are included.
+func (d *gitDiffer) FileChanges() ([]FileChange, error) {
+	return d.git.fileChanges()
+}
+
+// BinaryFiles returns the changed files that git considers binary. Untracked
+// files included from the working tree are not reported.
+func (d *gitDiffer) BinaryFiles() (map[string]struct{}, error) {
+	return d.git.binary()
 }
 
 // git implements the Differ interface using a git version control method.
 type git struct {
-	baseBranch     string
-	useMergeCommit bool
-	onceDiff       sync.Once
-	changedFiles   map[string]struct{}
-	diffErr        error
+	baseBranch         string
+	baseBranches       []string
+	useMergeCommit     bool
+	includeWorkingTree bool
+	stagedOnly         bool
+	noMergeBase        bool
+	commitCount        int
+	since              time.Duration
+	tagPattern         string
+	fetchRemote        string
+	fetchDepth         int
+	ignore             []string
+	ignoreGenerated    bool
+	ignoreWhitespace   bool
+	ignoreBinary       bool
+	dir                string
+	repoRoot           string
+	executable         string
+	env                []string
+	timeout            time.Duration
+	ctx                context.Context
+	changedFiles       map[string]struct{}
+	diffErr            error
+
+	// diffMu guards the determination of the changes, which are remembered
+	// once diffed is true. callCtx is the context of the commands while the
+	// changes are determined with a context.
+	diffMu  sync.Mutex
+	diffed  bool
+	callCtx context.Context
+
+	// root, parent1, and parent2 are set by diff: root is the absolute path of
+	// the repository and parent1 and parent2 are the first commits compared.
+	root    string
+	parent1 string
+	parent2 string
+
+	onceMergeBase sync.Once
+	mergeBase     string
+	mergeBaseErr  error
+
+	// diffArgs are the arguments of the comparisons made by diff, which
+	// binaryFiles repeats to find the binary files.
+	diffArgs [][]string
+
+	onceBinary  sync.Once
+	binaryFiles map[string]struct{}
+	binaryErr   error
 }
 
 // A Directory describes changes to a directory and its contents.
@@ -103,6 +415,40 @@ func (d *differ) Diff() (map[string]Directory, error) {
 	if err != nil {
 		return nil, err
 	}
+	return dirsOf(files), nil
+}
+
+// DiffContext is like Diff, with ctx as the context of the commands run to
+// determine the changes. Differs that do not run commands only check whether
+// ctx is done.
+func (d *differ) DiffContext(ctx context.Context) (map[string]Directory, error) {
+	var files map[string]struct{}
+	var err error
+	if d.diffContext != nil {
+		files, err = d.diffContext(ctx)
+	} else if err = ctx.Err(); err == nil {
+		files, err = d.diff()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dirsOf(files), nil
+}
+
+// diffContext returns the changed directories according to difr, using ctx
+// as the context of its commands when it is a DifferContext.
+func diffContext(ctx context.Context, difr Differ) (map[string]Directory, error) {
+	if dc, ok := difr.(DifferContext); ok {
+		return dc.DiffContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return difr.Diff()
+}
+
+// dirsOf returns the changed directories that contain the changed files.
+func dirsOf(files map[string]struct{}) map[string]Directory {
 
 	existsDirs := make(map[string]Directory, len(files))
 	for abs := range files {
@@ -117,7 +463,7 @@ func (d *differ) Diff() (map[string]Directory, error) {
 		existsDirs[absdir] = dir
 	}
 
-	return existsDirs, nil
+	return existsDirs
 }
 
 // DiffFiles returns a set of changed files. The keys of the returned map are
@@ -137,8 +483,67 @@ func (d *differ) DiffFiles() (map[string]bool, error) {
 	return existsFiles, nil
 }
 
-func getMergeParents() (parent1 string, rightwardParents []string, err error) {
-	out, err := exec.Command("git", "log", "-1", "--pretty=format:%p").Output()
+// context returns a context that is done when g's context, or the context
+// that the changes are being determined with, is done or g's command timeout
+// elapses.
+func (g *git) context() (context.Context, context.CancelFunc) {
+	ctx := g.ctx
+	if g.callCtx != nil {
+		ctx = g.callCtx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if g.timeout > 0 {
+		return context.WithTimeout(ctx, g.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// command returns a command that runs g's git executable with args in g's
+// working directory, or the root of the repository when only it is set, and
+// environment. The command is killed when ctx is done.
+func (g *git) command(ctx context.Context, args ...string) *exec.Cmd {
+	executable := g.executable
+	if executable == "" {
+		executable = "git"
+	}
+
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Dir = g.dir
+	if cmd.Dir == "" {
+		cmd.Dir = g.repoRoot
+	}
+	if len(g.env) > 0 {
+		cmd.Env = append(os.Environ(), g.env...)
+	}
+	return cmd
+}
+
+// output runs git with args and returns its standard output.
+func (g *git) output(args ...string) ([]byte, error) {
+	ctx, cancel := g.context()
+	defer cancel()
+
+	out, err := g.command(ctx, args...).Output()
+	if err != nil {
+		return nil, commandError(ctx, args, err)
+	}
+	return out, nil
+}
+
+// commandError returns err annotated with the git subcommand in args. When
+// ctx is done, the returned error wraps ctx's error instead of err.
+func commandError(ctx context.Context, args []string, err error) error {
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return fmt.Errorf("git %s: %w", args[0], err)
+}
+
+func (g *git) mergeParents() (parent1 string, rightwardParents []string, err error) {
+	out, err := g.output("log", "-1", "--pretty=format:%p")
 	if err != nil {
 		return
 	}
@@ -153,7 +558,7 @@ func getMergeParents() (parent1 string, rightwardParents []string, err error) {
 	}
 
 	// for squash-merge/rebase commits, get the most recent merge commit hash and use as left parent
-	out, err = exec.Command("git", "log", "-1", "--merges", "--pretty=format:%h").Output()
+	out, err = g.output("log", "-1", "--merges", "--pretty=format:%h")
 	if err != nil {
 		return
 	}
@@ -164,62 +569,631 @@ func getMergeParents() (parent1 string, rightwardParents []string, err error) {
 
 // diff returns a set of changed files.
 func (g *git) diff() (map[string]struct{}, error) {
-	g.onceDiff.Do(func() {
+	return g.diffContext(nil)
+}
+
+// diffContext returns a set of changed files. When ctx is not nil, it is the
+// context of the git commands instead of g's context. Failures due to ctx
+// being done are not remembered, so that the changes can be determined again.
+func (g *git) diffContext(ctx context.Context) (map[string]struct{}, error) {
+	g.diffMu.Lock()
+	defer g.diffMu.Unlock()
+
+	if !g.diffed {
+		g.callCtx, g.diffArgs = ctx, nil
 		files, err := func() (map[string]struct{}, error) {
-			// We get the root of the repository to build our full path.
-			out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+			root, err := g.toplevel()
 			if err != nil {
 				return nil, err
 			}
-			root := strings.TrimSpace(string(out))
-			parent1 := g.baseBranch
-			rightwardParents := []string{"HEAD"}
-			if g.useMergeCommit {
-				parent1, rightwardParents, err = getMergeParents()
+
+			windowed := !g.useMergeCommit && (g.commitCount > 0 || g.since > 0 || g.tagPattern != "")
+			if windowed {
+				// the commit at the start of the window is compared directly.
+				base, err := g.windowBase()
 				if err != nil {
 					return nil, err
 				}
+				g.baseBranch, g.baseBranches, g.noMergeBase = base, nil, true
 			}
 
-			files := make(map[string]struct{})
+			if (!g.useMergeCommit || g.stagedOnly) && !windowed {
+				for _, base := range g.bases() {
+					if err := g.ensureBase(base); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			if g.stagedOnly {
+				return g.diffStaged(root)
+			}
 
-			for _, parent2 := range rightwardParents {
-				// get the names of all affected files without doing rename detection.
-				cmd := exec.Command("git", "diff", fmt.Sprintf("%s...%s", parent1, parent2), "--name-only", "--no-renames")
-				stdout, err := cmd.StdoutPipe()
+			leftwardParents := g.bases()
+			rightwardParents := []string{"HEAD"}
+			if g.useMergeCommit {
+				var parent1 string
+				parent1, rightwardParents, err = g.mergeParents()
 				if err != nil {
 					return nil, err
 				}
+				leftwardParents = []string{parent1}
+			}
 
-				if err := cmd.Start(); err != nil {
-					return nil, err
+			g.root, g.parent1, g.parent2 = root, leftwardParents[0], rightwardParents[0]
+
+			files := make(map[string]struct{})
+
+			for _, parent1 := range leftwardParents {
+				for _, parent2 := range rightwardParents {
+					// get the names of all affected files without doing rename detection.
+					changedPaths, err := g.diffPaths(root, g.revisionRange(parent1, parent2), "--no-renames")
+					if err != nil {
+						return nil, err
+					}
+
+					for path := range changedPaths {
+						files[path] = struct{}{}
+					}
 				}
+			}
 
-				changedPaths, err := diffPaths(root, stdout)
+			if g.includeWorkingTree {
+				// staged and unstaged changes to tracked files.
+				changedPaths, err := g.diffPaths(root, "HEAD", "--no-renames")
 				if err != nil {
 					return nil, err
 				}
-
 				for path := range changedPaths {
 					files[path] = struct{}{}
 				}
 
-				err = cmd.Wait()
+				// untracked files that are not ignored; :/ matches the whole
+				// repository regardless of the working directory.
+				untrackedPaths, err := g.paths(root, false, "ls-files", "-z", "--others", "--exclude-standard", "--full-name", "--", ":/")
 				if err != nil {
 					return nil, err
 				}
+				for path := range untrackedPaths {
+					files[path] = struct{}{}
+				}
 			}
 			return files, nil
 		}()
+		if err == nil && g.ignoreGenerated {
+			files, err = g.dropGenerated(files)
+		}
+		g.callCtx = nil
+		if err != nil && ctx != nil && ctx.Err() != nil {
+			return nil, err
+		}
+
+		g.diffed = true
+		g.changedFiles, g.diffErr = files, err
 		if err != nil {
-			g.diffErr = err
-			return
+			g.changedFiles = nil
+		}
+	}
+
+	return g.changedFiles, g.diffErr
+}
+
+// toplevel returns the absolute path of the root of the repository.
+func (g *git) toplevel() (string, error) {
+	if g.repoRoot != "" {
+		return filepath.Abs(g.repoRoot)
+	}
+
+	// We get the root of the repository to build our full path.
+	out, err := g.output("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// diffStaged returns the set of files that differ between the index and the
+// merge base of each base branch and HEAD, or the base branches themselves
+// when the merge base is not used.
+func (g *git) diffStaged(root string) (map[string]struct{}, error) {
+	g.root, g.parent1, g.parent2 = root, g.baseBranch, "HEAD"
+
+	files := make(map[string]struct{})
+	for _, base := range g.bases() {
+		mergeBase, err := g.compareBase(base, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+
+		// get the names of all affected files without doing rename detection.
+		changedPaths, err := g.diffPaths(root, "--cached", mergeBase, "--no-renames")
+		if err != nil {
+			return nil, err
+		}
+		for path := range changedPaths {
+			files[path] = struct{}{}
+		}
+	}
+	return files, nil
+}
+
+// bases returns the base branches that g compares HEAD against.
+func (g *git) bases() []string {
+	if len(g.baseBranches) > 0 {
+		return g.baseBranches
+	}
+	return []string{g.baseBranch}
+}
+
+// maxFetchDepth is the depth beyond which ensureBase fetches the complete
+// history instead of deepening a shallow clone further.
+const maxFetchDepth = 10000
+
+// ensureBase fetches the base branch base from g's fetch remote when it is
+// missing and deepens a shallow clone until the merge base of base and HEAD is
+// present. It does nothing when no fetch remote is set.
+func (g *git) ensureBase(base string) error {
+	if g.fetchRemote == "" {
+		return nil
+	}
+
+	depth := g.fetchDepth
+	if depth <= 0 {
+		depth = 50
+	}
+
+	if !g.hasCommit(base) {
+		branch := strings.TrimPrefix(base, g.fetchRemote+"/")
+		dst := "refs/heads/" + branch
+		if branch != base {
+			dst = fmt.Sprintf("refs/remotes/%s/%s", g.fetchRemote, branch)
+		}
+		refspec := fmt.Sprintf("+refs/heads/%s:%s", branch, dst)
+		if _, err := g.output("fetch", "--quiet", "--depth", strconv.Itoa(depth), g.fetchRemote, refspec); err != nil {
+			return fmt.Errorf("fetching missing base %s, %w", base, err)
+		}
+	}
+
+	if g.noMergeBase {
+		return nil
+	}
+
+	for {
+		if _, err := g.output("merge-base", base, "HEAD"); err == nil {
+			return nil
 		}
 
-		g.changedFiles = files
+		out, err := g.output("rev-parse", "--is-shallow-repository")
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(out)) != "true" {
+			// the history is complete, so there is no merge base to fetch;
+			// diffing reports the error.
+			return nil
+		}
+
+		args := []string{"fetch", "--quiet", "--deepen", strconv.Itoa(depth), g.fetchRemote}
+		if depth > maxFetchDepth {
+			args = []string{"fetch", "--quiet", "--unshallow", g.fetchRemote}
+		}
+		if _, err := g.output(args...); err != nil {
+			return fmt.Errorf("deepening shallow clone, %w", err)
+		}
+		depth *= 2
+	}
+}
+
+// dropGenerated returns files without the files that have the
+// linguist-generated attribute set.
+func (g *git) dropGenerated(files map[string]struct{}) (map[string]struct{}, error) {
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	var in bytes.Buffer
+	for abs := range files {
+		rel, err := filepath.Rel(g.root, abs)
+		if err != nil {
+			return nil, err
+		}
+		in.WriteString(filepath.ToSlash(rel))
+		in.WriteByte(0)
+	}
+
+	ctx, cancel := g.context()
+	defer cancel()
+
+	args := []string{"check-attr", "-z", "--stdin", "linguist-generated"}
+	cmd := g.command(ctx, args...)
+	// the paths are relative to the root of the repository.
+	cmd.Dir = g.root
+	cmd.Stdin = &in
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, commandError(ctx, args, err)
+	}
+
+	// the output is a sequence of NUL terminated path, attribute, and value
+	// triples.
+	fields := bytes.Split(out, []byte{0})
+	kept := make(map[string]struct{}, len(files))
+	for i := 0; i+2 < len(fields); i += 3 {
+		abs := filepath.Join(g.root, filepath.FromSlash(string(fields[i])))
+		switch string(fields[i+2]) {
+		case "set", "true":
+			continue
+		}
+		kept[abs] = struct{}{}
+	}
+	return kept, nil
+}
+
+// emptyTree is the object name of git's empty tree, which HEAD is compared
+// against when the window of commits to diff contains all of history.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// windowBase returns the commit at the start of g's window of commits, or the
+// empty tree when the window contains all of history.
+func (g *git) windowBase() (string, error) {
+	if g.tagPattern != "" {
+		return g.latestTag()
+	}
+
+	if g.commitCount > 0 {
+		rev := fmt.Sprintf("HEAD~%d", g.commitCount)
+		out, err := g.output("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+		if err != nil {
+			return emptyTree, nil
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	before := time.Now().Add(-g.since).UTC().Format(time.RFC3339)
+	out, err := g.output("rev-list", "-1", "--before="+before, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if base := strings.TrimSpace(string(out)); base != "" {
+		return base, nil
+	}
+	return emptyTree, nil
+}
+
+// latestTag returns the commit of the most recent tag reachable from HEAD that
+// matches g's tag pattern.
+func (g *git) latestTag() (string, error) {
+	out, err := g.output("describe", "--tags", "--abbrev=0", "--match", g.tagPattern, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("finding the latest tag matching %q, %w", g.tagPattern, err)
+	}
+	tag := strings.TrimSpace(string(out))
+	out, err = g.output("rev-parse", "--verify", tag+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// hasCommit reports whether the commit rev exists in the repository.
+func (g *git) hasCommit(rev string) bool {
+	_, err := g.output("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	return err == nil
+}
+
+// revisionRange returns the revision range that git diff compares to find the
+// changes between parent1 and parent2.
+func (g *git) revisionRange(parent1, parent2 string) string {
+	if g.noMergeBase {
+		return fmt.Sprintf("%s..%s", parent1, parent2)
+	}
+	return fmt.Sprintf("%s...%s", parent1, parent2)
+}
+
+// compareBase returns the commit that the changes between parent1 and parent2
+// are determined from: their merge base, or parent1 when the merge base is not
+// used.
+func (g *git) compareBase(parent1, parent2 string) (string, error) {
+	if parent1 == emptyTree {
+		return emptyTree, nil
+	}
+
+	args := []string{"merge-base", parent1, parent2}
+	if g.noMergeBase {
+		args = []string{"rev-parse", "--verify", parent1 + "^{commit}"}
+	}
+
+	out, err := g.output(args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// readBase returns the content of the file at abs as of the commit that the
+// changes found by diff are determined from.
+func (g *git) readBase(abs string) ([]byte, error) {
+	if _, err := g.diff(); err != nil {
+		return nil, err
+	}
+
+	g.onceMergeBase.Do(func() {
+		g.mergeBase, g.mergeBaseErr = g.compareBase(g.parent1, g.parent2)
 	})
+	if g.mergeBaseErr != nil {
+		return nil, g.mergeBaseErr
+	}
 
-	return g.changedFiles, g.diffErr
+	rel, err := filepath.Rel(g.root, abs)
+	if err != nil {
+		return nil, err
+	}
+	object := fmt.Sprintf("%s:%s", g.mergeBase, filepath.ToSlash(rel))
+
+	// git cat-file exits with a non-zero status when the object does not
+	// exist.
+	if _, err := g.output("cat-file", "-e", object); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, &os.PathError{Op: "read", Path: abs, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+
+	return g.output("cat-file", "blob", object)
+}
+
+// fileChanges returns the changes to g's changed files. The status of files
+// that git diff does not list, such as untracked files, is empty.
+func (g *git) fileChanges() ([]FileChange, error) {
+	files, err := g.diff()
+	if err != nil {
+		return nil, err
+	}
+
+	g.onceMergeBase.Do(func() {
+		g.mergeBase, g.mergeBaseErr = g.compareBase(g.parent1, g.parent2)
+	})
+	if g.mergeBaseErr != nil {
+		return nil, g.mergeBaseErr
+	}
+
+	args := []string{"diff", "--name-status", "-z", "--find-renames", "--find-copies"}
+	switch {
+	case g.stagedOnly:
+		args = append(args, "--cached", g.mergeBase)
+	case g.includeWorkingTree:
+		args = append(args, g.mergeBase)
+	default:
+		args = append(args, g.mergeBase, g.parent2)
+	}
+	out, err := g.output(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := nameStatusChanges(g.root, out)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []FileChange
+	for _, c := range changes {
+		_, changed := files[c.Path]
+		_, changedOld := files[c.OldPath]
+		if changed || changedOld {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// nameStatusChanges returns the changes listed in out, the output of git diff
+// --name-status -z, with paths relative to root.
+func nameStatusChanges(root string, out []byte) ([]FileChange, error) {
+	return nameStatusFieldChanges(root, strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00"))
+}
+
+// nameStatusFieldChanges returns the changes listed in fields, the NUL
+// separated fields of the output of git diff --name-status -z or --raw -z,
+// with paths relative to root.
+func nameStatusFieldChanges(root string, fields []string) ([]FileChange, error) {
+	abs := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+
+	var changes []FileChange
+	for i := 0; i < len(fields) && fields[i] != ""; {
+		status := fields[i]
+		if strings.HasPrefix(status, ":") {
+			// the status of --raw output follows the modes and object names,
+			// e.g. ":100644 100644 bcd1234 0123456 M".
+			meta := strings.Fields(status)
+			status = meta[len(meta)-1]
+		}
+		if i+1 >= len(fields) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		// copies and renames, whose status includes a similarity score, are
+		// followed by both the source and the destination.
+		switch status[0] {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			c := FileChange{Path: abs(fields[i+2]), Status: StatusRenamed, OldPath: abs(fields[i+1])}
+			if status[0] == 'C' {
+				c.Status = StatusCopied
+			}
+			changes = append(changes, c)
+			i += 3
+			continue
+		case 'A':
+			changes = append(changes, FileChange{Path: abs(fields[i+1]), Status: StatusAdded})
+		case 'D':
+			changes = append(changes, FileChange{Path: abs(fields[i+1]), Status: StatusDeleted})
+		default:
+			changes = append(changes, FileChange{Path: abs(fields[i+1]), Status: StatusModified})
+		}
+		i += 2
+	}
+	return changes, nil
+}
+
+// diffPaths runs git diff with args and returns the absolute paths of the
+// files it lists relative to root. Binary files are dropped when g ignores
+// them, and modified files whose changes are all whitespace are dropped when
+// g ignores whitespace.
+func (g *git) diffPaths(root string, args ...string) (map[string]struct{}, error) {
+	g.diffArgs = append(g.diffArgs, args)
+
+	changedPaths, err := g.paths(root, true, append([]string{"diff", "--name-status", "-z"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.ignoreBinary {
+		binary, err := g.binaryPaths(root, args...)
+		if err != nil {
+			return nil, err
+		}
+		for abs := range binary {
+			delete(changedPaths, abs)
+		}
+	}
+
+	if !g.ignoreWhitespace {
+		return changedPaths, nil
+	}
+
+	// git diff lists the files whose changes are all whitespace even with -w,
+	// but --numstat does not.
+	modified, err := g.paths(root, true, append([]string{"diff", "--name-status", "-z", "--diff-filter=M"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(modified) == 0 {
+		return changedPaths, nil
+	}
+
+	numstatArgs := append([]string{"diff", "--numstat", "-z", "--ignore-all-space"}, args...)
+	out, err := g.output(numstatArgs...)
+	if err != nil {
+		return nil, err
+	}
+	substantive := make(map[string]struct{})
+	for _, record := range strings.Split(string(out), "\x00") {
+		// each record is the number of added and deleted lines and the path,
+		// separated by tabs.
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		substantive[filepath.Join(root, filepath.FromSlash(fields[2]))] = struct{}{}
+	}
+
+	for abs := range modified {
+		if _, ok := substantive[abs]; !ok {
+			delete(changedPaths, abs)
+		}
+	}
+	return changedPaths, nil
+}
+
+// binaryPaths runs git diff --numstat with args and returns the absolute paths
+// of the binary files it lists relative to root.
+func (g *git) binaryPaths(root string, args ...string) (map[string]struct{}, error) {
+	out, err := g.output(append([]string{"diff", "--numstat", "-z"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	binary := make(map[string]struct{})
+	for _, record := range strings.Split(string(out), "\x00") {
+		// the numbers of added and deleted lines of binary files are -.
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 || fields[0] != "-" || fields[1] != "-" || fields[2] == "" {
+			continue
+		}
+		binary[filepath.Join(root, filepath.FromSlash(fields[2]))] = struct{}{}
+	}
+	return binary, nil
+}
+
+// binary returns the changed files that are binary in any of the comparisons
+// made by diff.
+func (g *git) binary() (map[string]struct{}, error) {
+	files, err := g.diff()
+	if err != nil {
+		return nil, err
+	}
+
+	g.onceBinary.Do(func() {
+		binary := make(map[string]struct{})
+		for _, args := range g.diffArgs {
+			paths, err := g.binaryPaths(g.root, args...)
+			if err != nil {
+				g.binaryErr = err
+				return
+			}
+			for abs := range paths {
+				if _, ok := files[abs]; ok {
+					binary[abs] = struct{}{}
+				}
+			}
+		}
+		g.binaryFiles = binary
+	})
+
+	return g.binaryFiles, g.binaryErr
+}
+
+// keep reports whether the file at the slash separated path rel, relative to
+// the root of the repository, is not ignored.
+func (g *git) keep(rel string) bool {
+	for _, pattern := range g.ignore {
+		if matchGlob(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// paths runs git with args and returns the absolute paths of the files it
+// lists relative to root. The output is parsed as it is read, and ignored files
+// are dropped as they are read, so that the memory used is bounded by the
+// number of files that are kept even for huge diffs. When nameStatus is true,
+// the output is in the format of git diff --name-status -z, and otherwise it
+// is a NUL separated list of paths.
+func (g *git) paths(root string, nameStatus bool, args ...string) (map[string]struct{}, error) {
+	ctx, cancel := g.context()
+	defer cancel()
+
+	cmd := g.command(ctx, args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, commandError(ctx, args, err)
+	}
+
+	changedPaths, err := streamPaths(root, stdout, nameStatus, g.keep)
+	if err != nil {
+		// stop git so that Wait does not block on a full pipe.
+		cancel()
+		cmd.Wait()
+		return nil, err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, commandError(ctx, args, err)
+	}
+
+	return changedPaths, nil
 }
 
 // diffPaths returns the path that have changed.
@@ -242,6 +1216,69 @@ func diffPaths(root string, r io.Reader) (map[string]struct{}, error) {
 	return paths, scanner.Err()
 }
 
+// streamPaths returns the absolute paths of the files listed in r relative to
+// root, omitting those for which keep returns false. r is either in the format
+// of git diff --name-status -z, when nameStatus is true, or a NUL separated
+// list of paths.
+func streamPaths(root string, r io.Reader, nameStatus bool, keep func(rel string) bool) (map[string]struct{}, error) {
+	paths := make(map[string]struct{})
+
+	add := func(rel string) error {
+		if !keep(rel) {
+			return nil
+		}
+
+		full, err := filepath.Abs(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		paths[full] = struct{}{}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanNUL)
+	for scanner.Scan() {
+		if !nameStatus {
+			if err := add(scanner.Text()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// copies and renames are followed by both the source and the
+		// destination.
+		n := 1
+		if status := scanner.Text(); strings.HasPrefix(status, "C") || strings.HasPrefix(status, "R") {
+			n = 2
+		}
+		for i := 0; i < n; i++ {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, err
+				}
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err := add(scanner.Text()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return paths, scanner.Err()
+}
+
+// scanNUL is a bufio.SplitFunc that returns NUL terminated tokens.
+func scanNUL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func exists(path string) bool {
 	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
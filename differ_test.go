@@ -8,8 +8,19 @@ package gta
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/digitalocean/gta/gtatest"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -86,3 +97,691 @@ bar/baz/qux/corge.go
 		})
 	}
 }
+
+func Test_streamPaths(t *testing.T) {
+	var tests = []struct {
+		desc       string
+		buf        string
+		nameStatus bool
+		want       map[string]struct{}
+	}{
+		{
+			desc:       "name status",
+			buf:        "M\x00foo/bar.go\x00D\x00foo/with space.go\x00R100\x00old/qux.go\x00new/qux.go\x00",
+			nameStatus: true,
+			want: map[string]struct{}{
+				"/repo/foo/bar.go":        struct{}{},
+				"/repo/foo/with space.go": struct{}{},
+				"/repo/old/qux.go":        struct{}{},
+				"/repo/new/qux.go":        struct{}{},
+			},
+		},
+		{
+			desc: "paths",
+			buf:  "foo/bar.go\x00foo/new\nline.go\x00",
+			want: map[string]struct{}{
+				"/repo/foo/bar.go":       struct{}{},
+				"/repo/foo/new\nline.go": struct{}{},
+			},
+		},
+		{
+			desc:       "ignored",
+			buf:        "A\x00vendor/a/a.go\x00A\x00vendor/b/b/b.go\x00M\x00foo/bar.go\x00",
+			nameStatus: true,
+			want: map[string]struct{}{
+				"/repo/foo/bar.go": struct{}{},
+			},
+		},
+	}
+
+	keep := func(rel string) bool {
+		return !matchGlob("vendor/**", rel)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := streamPaths("/repo", strings.NewReader(tt.buf), tt.nameStatus, keep)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := streamPaths("/repo", strings.NewReader("R100\x00old/qux.go\x00"), true, keep); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated rename: err = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// writeFile writes content to the file identified by the slash separated path
+// fn relative to dir.
+func writeFile(t *testing.T, dir, fn, content string) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(fn)), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newChangedRepo returns a repository with a branch named base and a commit
+// on top of base that modifies foo.go and adds bar.go.
+func newChangedRepo(t *testing.T) *gtatest.Repo {
+	t.Helper()
+
+	repo := gtatest.NewRepo(t, "", map[string]string{"foo.go": "package foo\n"})
+	repo.Git("branch", "base")
+	repo.WriteFiles(map[string]string{
+		"foo.go": "package foo\n\nvar Foo int\n",
+		"bar.go": "package foo\n",
+	})
+	repo.Commit("change")
+	return repo
+}
+
+func TestNewFileDifferWithRoot(t *testing.T) {
+	sut := NewFileDifferWithRoot("/repo", []string{"foo/foo.go", "/abs/bar.go"})
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.FromSlash("/repo/foo/foo.go"): false,
+		"/abs/bar.go":                          false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_WorkingDir(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetEnv(gtatest.Env...), SetBaseBranch("base"))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"): true,
+		filepath.Join(dir, "bar.go"): true,
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_RepoRoot(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut := NewGitDiffer(SetRepoRoot(dir), SetEnv(gtatest.Env...), SetBaseBranch("base"))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"): true,
+		filepath.Join(dir, "bar.go"): true,
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_GitExecutable(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the wrapper records that it was run before running git.
+	bin := t.TempDir()
+	log := filepath.Join(bin, "log")
+	wrapper := filepath.Join(bin, "wrapped-git")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$1\" >> %q\nexec %q \"$@\"\n", log, gitPath)
+	if err := ioutil.WriteFile(wrapper, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sut := NewGitDiffer(SetRepoRoot(dir), SetGitExecutable(wrapper), SetBaseBranch("base"))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"): true,
+		filepath.Join(dir, "bar.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if b, err := ioutil.ReadFile(log); err != nil || len(b) == 0 {
+		t.Errorf("the git executable was not run: %v", err)
+	}
+}
+
+func TestGitDiffer_DiffContext(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sut.(DifferContext).DiffContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v; want %v", err, context.Canceled)
+	}
+
+	// the cancellation is not remembered.
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"): true,
+		filepath.Join(dir, "bar.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_CommandTimeout(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetCommandTimeout(time.Nanosecond))
+	_, err := sut.Diff()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestGitDiffer_Context(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetGitContext(ctx))
+	_, err := sut.Diff()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestGitDiffer_IncludeWorkingTree(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	writeFile(t, dir, "foo.go", "package foo\n\nvar Foo string\n")
+	writeFile(t, dir, "staged.go", "package foo\n")
+	repo.Git("add", "staged.go")
+	writeFile(t, dir, "untracked.go", "package foo\n")
+	writeFile(t, dir, filepath.Join(".git", "info", "exclude"), "ignored.go\n")
+	writeFile(t, dir, "ignored.go", "package foo\n")
+
+	tests := []struct {
+		desc               string
+		includeWorkingTree bool
+		want               map[string]bool
+	}{
+		{
+			desc: "committed changes only",
+			want: map[string]bool{},
+		},
+		{
+			desc:               "include working tree",
+			includeWorkingTree: true,
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"):       true,
+				filepath.Join(dir, "staged.go"):    true,
+				filepath.Join(dir, "untracked.go"): true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			// compare HEAD to itself so that only the working tree changes are
+			// reported.
+			sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("HEAD"), SetIncludeWorkingTree(tt.includeWorkingTree))
+			got, err := sut.DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitDiffer_StagedOnly(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	writeFile(t, dir, "staged.go", "package foo\n")
+	repo.Git("add", "staged.go")
+	writeFile(t, dir, "unstaged.go", "package foo\n")
+	repo.Git("add", "unstaged.go")
+	repo.Git("commit", "-m", "add unstaged.go")
+	writeFile(t, dir, "unstaged.go", "package foo\n\nvar Unstaged string\n")
+	writeFile(t, dir, "untracked.go", "package foo\n")
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetStagedOnly(true))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unstaged.go was added since the base branch, so it is reported even
+	// though its latest changes are not staged.
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"):      true,
+		filepath.Join(dir, "bar.go"):      true,
+		filepath.Join(dir, "staged.go"):   true,
+		filepath.Join(dir, "unstaged.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	// removing bar.go from the index undoes its addition, even though it is
+	// still in HEAD and the working tree.
+	repo.Git("rm", "-q", "--cached", "bar.go")
+	sut = NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetStagedOnly(true))
+	got, err = sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(want, filepath.Join(dir, "bar.go"))
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("after removing bar.go from the index (-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_Ignore(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetIgnore("bar.go"))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_IgnoreGenerated(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	if err := os.Mkdir(filepath.Join(dir, "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, ".gitattributes", "gen/** linguist-generated\nmock_*.go linguist-generated=true\nbar.go linguist-generated=false\n")
+	writeFile(t, dir, "gen/foo.pb.go", "package gen\n")
+	writeFile(t, dir, "mock_foo.go", "package foo\n")
+	repo.Commit("generated")
+
+	got, err := NewGitDiffer(SetWorkingDir(filepath.Join(dir, "gen")), SetBaseBranch("base"), SetIgnoreGenerated(true)).DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, ".gitattributes"): true,
+		filepath.Join(dir, "foo.go"):         true,
+		filepath.Join(dir, "bar.go"):         true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_IgnoreWhitespace(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	// reformat foo.go, change bar.go, and add baz.go, which is empty.
+	writeFile(t, dir, "foo.go", "package  foo\n\nvar Foo   int\n")
+	writeFile(t, dir, "bar.go", "package foo\n\nvar Bar int\n")
+	writeFile(t, dir, "baz.go", "")
+	repo.Commit("reformat")
+
+	tests := []struct {
+		desc             string
+		ignoreWhitespace bool
+		want             map[string]bool
+	}{
+		{
+			desc: "whitespace",
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"): true,
+				filepath.Join(dir, "bar.go"): true,
+				filepath.Join(dir, "baz.go"): true,
+			},
+		},
+		{
+			desc:             "ignore whitespace",
+			ignoreWhitespace: true,
+			want: map[string]bool{
+				filepath.Join(dir, "bar.go"): true,
+				filepath.Join(dir, "baz.go"): true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("HEAD~1"), SetIgnoreWhitespace(tt.ignoreWhitespace)).DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitDiffer_UseMergeBase(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	// land a change on the base branch after the fork point.
+	repo.Git("checkout", "-q", "base")
+	writeFile(t, dir, "baz.go", "package foo\n")
+	repo.Commit("base change")
+	repo.Git("checkout", "-q", "-")
+
+	tests := []struct {
+		desc         string
+		useMergeBase bool
+		want         map[string]bool
+		wantBase     string
+	}{
+		{
+			desc:         "merge base",
+			useMergeBase: true,
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"): true,
+				filepath.Join(dir, "bar.go"): true,
+			},
+		},
+		{
+			desc:         "base branch",
+			useMergeBase: false,
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"): true,
+				filepath.Join(dir, "bar.go"): true,
+				filepath.Join(dir, "baz.go"): false,
+			},
+			wantBase: "package foo\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			sut := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), SetUseMergeBase(tt.useMergeBase))
+			got, err := sut.DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+
+			// baz.go only exists in the base branch.
+			b, err := sut.(BaseReader).ReadBase(filepath.Join(dir, "baz.go"))
+			if tt.wantBase == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("ReadBase(baz.go) err = %v; want a not exist error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.wantBase {
+				t.Errorf("ReadBase(baz.go) = %q; want %q", b, tt.wantBase)
+			}
+		})
+	}
+}
+
+func TestGitDiffer_BaseBranches(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	// the release branch is cut before the changes of HEAD landed on the base
+	// branch, and HEAD has changed since.
+	repo.Git("branch", "release", "base")
+	repo.Git("branch", "-f", "base", "HEAD")
+	writeFile(t, dir, "qux.go", "package foo\n")
+	repo.Commit("qux")
+
+	tests := []struct {
+		desc string
+		opt  GitDifferOption
+		want map[string]bool
+	}{
+		{
+			desc: "single base",
+			opt:  SetBaseBranch("base"),
+			want: map[string]bool{
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+		{
+			desc: "several bases",
+			opt:  SetBaseBranches("base", "release"),
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"): true,
+				filepath.Join(dir, "bar.go"): true,
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := NewGitDiffer(SetWorkingDir(dir), tt.opt).DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitDiffer_Window(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	// commit qux.go with a committer date two days ago, and then modify it.
+	writeFile(t, dir, "qux.go", "package foo\n")
+	old := &git{dir: dir, env: append(gtatest.Env, "GIT_COMMITTER_DATE="+time.Now().Add(-48*time.Hour).Format(time.RFC3339))}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "qux"}} {
+		if _, err := old.output(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.Git("tag", "v1.0.0")
+	writeFile(t, dir, "qux.go", "package foo\n\nvar Qux int\n")
+	repo.Commit("change qux")
+	repo.Git("tag", "-a", "-m", "nightly", "nightly")
+
+	tests := []struct {
+		desc string
+		opt  GitDifferOption
+		want map[string]bool
+	}{
+		{
+			desc: "last commit",
+			opt:  SetCommitCount(1),
+			want: map[string]bool{
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+		{
+			desc: "all commits",
+			opt:  SetCommitCount(10),
+			want: map[string]bool{
+				filepath.Join(dir, "foo.go"): true,
+				filepath.Join(dir, "bar.go"): true,
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+		{
+			desc: "since",
+			opt:  SetSince(24 * time.Hour),
+			want: map[string]bool{
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+		{
+			desc: "since latest tag",
+			opt:  SetSinceLatestTag("v*"),
+			want: map[string]bool{
+				filepath.Join(dir, "qux.go"): true,
+			},
+		},
+		{
+			desc: "since annotated tag at HEAD",
+			opt:  SetSinceLatestTag("nightly"),
+			want: map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"), tt.opt).DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := NewGitDiffer(SetWorkingDir(dir), SetSinceLatestTag("release-*")).DiffFiles(); err == nil {
+		t.Error("expected an error when no tag matches")
+	}
+}
+
+func TestGitDiffer_FetchMissingBase(t *testing.T) {
+	upstream := newChangedRepo(t)
+
+	// make the fork point deeper than the depth that is fetched at a time.
+	for i := 0; i < 3; i++ {
+		upstream.WriteFile("qux.go", fmt.Sprintf("package foo\n\nvar Qux = %d\n", i))
+		upstream.Commit("qux")
+	}
+	upstream.Git("branch", "feature")
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream.Git("clone", "-q", "--depth", "1", "--single-branch", "--branch", "feature", "file://"+upstream.Dir, filepath.Join(dir, "clone"))
+	clone := filepath.Join(dir, "clone")
+
+	sut := NewGitDiffer(SetWorkingDir(clone), SetBaseBranch("origin/base"))
+	if _, err := sut.DiffFiles(); err == nil {
+		t.Fatal("expected an error when the base branch is missing")
+	}
+
+	sut = NewGitDiffer(SetWorkingDir(clone), SetBaseBranch("origin/base"), SetFetchMissingBase("origin"), SetFetchDepth(1))
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(clone, "foo.go"): true,
+		filepath.Join(clone, "bar.go"): true,
+		filepath.Join(clone, "qux.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitDiffer_ReadBase(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	sut, ok := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base")).(BaseReader)
+	if !ok {
+		t.Fatal("expected git differ to implement BaseReader")
+	}
+
+	got, err := sut.ReadBase(filepath.Join(dir, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package foo\n"; string(got) != want {
+		t.Errorf("ReadBase(foo.go) = %q; want %q", got, want)
+	}
+
+	_, err = sut.ReadBase(filepath.Join(dir, "bar.go"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadBase(bar.go) err = %v; want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestGitDiffer_FileChanges(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	writeFile(t, dir, "baz.go", "package foo\n\n// Baz is long enough to be detected as renamed.\nvar Baz int\n")
+	repo.Commit("add baz")
+	repo.Git("branch", "-f", "base")
+	repo.Git("mv", "baz.go", "qux.go")
+	repo.Git("rm", "-q", "bar.go")
+	writeFile(t, dir, "new.go", "package foo\n\nfunc New() {}\n")
+	repo.Commit("rename baz")
+
+	difr := NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"))
+	got, err := fileChanges(difr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]FileChange{
+		filepath.Join(dir, "bar.go"): {Path: filepath.Join(dir, "bar.go"), Status: StatusDeleted},
+		filepath.Join(dir, "baz.go"): {Path: filepath.Join(dir, "baz.go"), Status: StatusDeleted},
+		filepath.Join(dir, "new.go"): {Path: filepath.Join(dir, "new.go"), Status: StatusAdded},
+		filepath.Join(dir, "qux.go"): {Path: filepath.Join(dir, "qux.go"), Status: StatusRenamed, OldPath: filepath.Join(dir, "baz.go")},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteDOT writes g to w in the DOT language of Graphviz. There is an edge from
+// each node to each of its adjacent nodes; for a graph of dependents, the
+// edges point from a package to the packages that import it, the direction in
+// which changes fan out. The nodes and edges are sorted, so that the output is
+// stable.
+func (g *Graph) WriteDOT(w io.Writer, opts ...GraphOption) error {
+	cfg := newGraphConfig(opts)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph gta {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+
+	nodes := cfg.sortedNodes(g)
+	for _, node := range nodes {
+		if cfg.highlighted(node) {
+			fmt.Fprintf(bw, "\t%q [style=filled, fillcolor=lightcoral];\n", node)
+			continue
+		}
+		fmt.Fprintf(bw, "\t%q;\n", node)
+	}
+
+	for _, node := range nodes {
+		for _, edge := range cfg.sortedEdges(g, node) {
+			fmt.Fprintf(bw, "\t%q -> %q;\n", node, edge)
+		}
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGraphWriteDOT(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"example.com/c": {"example.com/b": true, "example.com/d": true},
+			"example.com/b": {"example.com/a": true, "example.com/b": true},
+		},
+	}
+
+	tests := []struct {
+		desc string
+		opts []GraphOption
+		want string
+	}{
+		{
+			desc: "whole graph",
+			want: `digraph gta {
+	node [shape=box];
+	"example.com/a";
+	"example.com/b";
+	"example.com/c";
+	"example.com/d";
+	"example.com/b" -> "example.com/a";
+	"example.com/c" -> "example.com/b";
+	"example.com/c" -> "example.com/d";
+}
+`,
+		},
+		{
+			desc: "subgraph",
+			opts: []GraphOption{GraphNodes("example.com/b", "example.com/c", "example.com/e"), GraphHighlight("example.com/c")},
+			want: `digraph gta {
+	node [shape=box];
+	"example.com/b";
+	"example.com/c" [style=filled, fillcolor=lightcoral];
+	"example.com/e";
+	"example.com/c" -> "example.com/b";
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := graph.WriteDOT(&buf, tt.opts...); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// An EmbedFiler is a Packager that knows the files embedded with go:embed
+// directives by the packages that match the patterns it loaded.
+type EmbedFiler interface {
+	// EmbedFiles returns the sorted absolute paths of the files embedded by
+	// the packages that match the patterns, including by their tests, keyed
+	// by their import paths.
+	EmbedFiles() (map[string][]string, error)
+}
+
+// EmbedFiles returns the sorted absolute paths of the files embedded by the
+// packages that match the patterns, including by their tests, keyed by their
+// import paths.
+func (p *packageContext) EmbedFiles() (map[string][]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.embeds == nil {
+		p.embeds = embeddedFiles(p.loaded)
+	}
+	return p.embeds, nil
+}
+
+// referringPackages returns the import paths of the packages that embed the
+// file abs, or that include it with cgo, according to g's packager.
+func (g *GTA) referringPackages(abs string) ([]string, error) {
+	if g.referrers == nil {
+		g.referrers = make(map[string][]string)
+
+		var refs []map[string][]string
+		if ef, ok := g.packager.(EmbedFiler); ok {
+			embeds, err := ef.EmbedFiles()
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, embeds)
+		}
+		if cf, ok := g.packager.(CgoFiler); ok {
+			cgo, err := cf.CgoFiles()
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, cgo)
+		}
+		for _, m := range refs {
+			for importPath, paths := range m {
+				for _, p := range paths {
+					g.referrers[p] = append(g.referrers[p], importPath)
+				}
+			}
+		}
+	}
+
+	// include directories refer to the files within them.
+	var pkgs []string
+	for p := abs; ; p = filepath.Dir(p) {
+		pkgs = append(pkgs, g.referrers[p]...)
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+	return pkgs, nil
+}
+
+// embeddedFiles returns the sorted absolute paths of the files embedded by
+// the Go files of roots, keyed by the import paths of the packages, into which
+// test variants and external tests are flattened.
+func embeddedFiles(roots []*packages.Package) map[string][]string {
+	sets := make(map[string]map[string]struct{})
+	for _, pkg := range roots {
+		if len(pkg.GoFiles) == 0 || strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		pkgPath := normalizeImportPath(pkg)
+		for _, fn := range pkg.GoFiles {
+			if filepath.Ext(fn) != ".go" {
+				continue
+			}
+			for _, embedded := range fileEmbeds(fn) {
+				if _, ok := sets[pkgPath]; !ok {
+					sets[pkgPath] = make(map[string]struct{})
+				}
+				sets[pkgPath][embedded] = struct{}{}
+			}
+		}
+	}
+
+	embeds := setsToLists(sets)
+	for _, list := range embeds {
+		sort.Strings(list)
+	}
+	return embeds
+}
+
+// fileEmbeds returns the absolute paths of the files matched by the go:embed
+// directives of the Go file fn. Files that cannot be read or parsed embed
+// nothing; they fail to build regardless.
+func fileEmbeds(fn string) []string {
+	src, err := ioutil.ReadFile(fn)
+	if err != nil || !bytes.Contains(src, []byte("//go:embed")) {
+		return nil
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), fn, src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	dir := filepath.Dir(fn)
+	for _, pattern := range embedPatterns(f) {
+		files = append(files, matchEmbed(dir, pattern)...)
+	}
+	return files
+}
+
+// embedPatterns returns the patterns of the go:embed directives of f. The
+// patterns are unquoted, and keep their all: prefix.
+func embedPatterns(f *ast.File) []string {
+	var patterns []string
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//go:embed ") {
+				continue
+			}
+
+			for _, pattern := range strings.Fields(strings.TrimPrefix(c.Text, "//go:embed ")) {
+				if s, err := strconv.Unquote(pattern); err == nil {
+					pattern = s
+				}
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
+
+// matchEmbed returns the absolute paths of the files in the directory pkgDir
+// that the go:embed pattern matches. A matched directory embeds the files
+// within it, except for the files whose names begin with '.' or '_' unless
+// the pattern has the all: prefix, and except for the directories of other
+// modules.
+func matchEmbed(pkgDir, pattern string) []string {
+	all := strings.HasPrefix(pattern, "all:")
+	pattern = strings.TrimPrefix(pattern, "all:")
+
+	matches, err := filepath.Glob(filepath.Join(pkgDir, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, match := range matches {
+		filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			name := info.Name()
+			if path != match && !all && (name[0] == '.' || name[0] == '_') {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil && path != pkgDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+// newEmbedModule creates a module example.com/m whose package web embeds
+// templates and static files, and returns its directory and the loader of its
+// packages.
+func newEmbedModule(t *testing.T) (string, Loader) {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"web/templates/partials", "web/static", "web/nested", "cmd"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.mod", "module example.com/m\n\ngo 1.16\n")
+	writeFile(t, dir, "web/web.go", "package web\n\nimport \"embed\"\n\n//go:embed templates/*.tmpl \"static\"\nvar files embed.FS\n\n//go:embed all:templates/partials\nvar partials embed.FS\n")
+	writeFile(t, dir, "web/web_test.go", "package web\n\nimport _ \"embed\"\n\n//go:embed testdata.txt\nvar golden string\n")
+	writeFile(t, dir, "web/testdata.txt", "golden\n")
+	writeFile(t, dir, "web/templates/index.tmpl", "index\n")
+	writeFile(t, dir, "web/templates/index.txt", "not embedded\n")
+	writeFile(t, dir, "web/templates/partials/_header.tmpl", "header\n")
+	writeFile(t, dir, "web/static/app.js", "app\n")
+	writeFile(t, dir, "web/static/.hidden", "hidden\n")
+	writeFile(t, dir, "cmd/main.go", "package main\n\nimport _ \"example.com/m/web\"\n\nfunc main() {}\n")
+
+	module := &packages.Module{Path: "example.com/m", Dir: dir, Main: true}
+	web := &packages.Package{
+		ID:      "example.com/m/web",
+		Name:    "web",
+		PkgPath: "example.com/m/web",
+		GoFiles: []string{filepath.Join(dir, "web", "web.go")},
+		Module:  module,
+	}
+	webTest := &packages.Package{
+		ID:      "example.com/m/web [example.com/m/web.test]",
+		Name:    "web",
+		PkgPath: "example.com/m/web",
+		GoFiles: []string{filepath.Join(dir, "web", "web.go"), filepath.Join(dir, "web", "web_test.go")},
+		Module:  module,
+	}
+	cmd := &packages.Package{
+		ID:      "example.com/m/cmd",
+		Name:    "main",
+		PkgPath: "example.com/m/cmd",
+		GoFiles: []string{filepath.Join(dir, "cmd", "main.go")},
+		Imports: map[string]*packages.Package{"example.com/m/web": web},
+		Module:  module,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{web, webTest, cmd}, nil
+	})
+	return dir, loader
+}
+
+func TestPackageContext_EmbedFiles(t *testing.T) {
+	dir, loader := newEmbedModule(t)
+
+	pkgr := NewPackagerWithLoader(loader, []string{"example.com/m/..."}, nil)
+	got, err := pkgr.(EmbedFiler).EmbedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"example.com/m/web": {
+			filepath.Join(dir, "web", "static", "app.js"),
+			filepath.Join(dir, "web", "templates", "index.tmpl"),
+			filepath.Join(dir, "web", "templates", "partials", "_header.tmpl"),
+			filepath.Join(dir, "web", "testdata.txt"),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_EmbeddedFiles(t *testing.T) {
+	dir, loader := newEmbedModule(t)
+
+	tests := []struct {
+		desc string
+		diff map[string]Directory
+		want []string
+	}{
+		{
+			desc: "template",
+			diff: map[string]Directory{
+				filepath.Join(dir, "web", "templates"): {Exists: true, Files: []string{"index.tmpl"}},
+			},
+			want: []string{"example.com/m/cmd", "example.com/m/web"},
+		},
+		{
+			desc: "not embedded",
+			diff: map[string]Directory{
+				filepath.Join(dir, "web", "templates"): {Exists: true, Files: []string{"index.txt"}},
+			},
+			want: nil,
+		},
+		{
+			desc: "hidden",
+			diff: map[string]Directory{
+				filepath.Join(dir, "web", "static"): {Exists: true, Files: []string{".hidden"}},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			gt, err := New(SetDir(dir), SetLoader(loader), SetPrefixes("example.com/m/"), SetDiffer(&testDiffer{diff: tt.diff}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// ExportFormat is the version of the layout of the archives written by Export.
+// It is incremented when the layout changes incompatibly.
+const ExportFormat = 1
+
+// Provenance describes how an exported analysis was produced.
+type Provenance struct {
+	// Format is the version of the archive's layout. Export sets it to
+	// ExportFormat.
+	Format int `json:"format"`
+
+	// Created is the time the analysis was exported. Export sets it to the
+	// current time when it is zero.
+	Created time.Time `json:"created"`
+
+	// Args are the command line arguments that produced the analysis.
+	Args []string `json:"args,omitempty"`
+
+	// Base is the revision that the changes were determined against.
+	Base string `json:"base,omitempty"`
+}
+
+// A Reason explains why a package is affected by the changes.
+type Reason struct {
+	// Changed are the import paths of the changed packages that the package is,
+	// or depends on.
+	Changed []string `json:"changed"`
+
+	// Files are the changed files that were attributed to the package when the
+	// package itself changed.
+	Files []string `json:"files,omitempty"`
+
+	// Origins are the labels of the differs that reported the changes.
+	Origins []string `json:"origins,omitempty"`
+}
+
+// Export writes a gzip compressed tar archive of the analysis of the changed
+// packages to w, so that downstream tooling can consume a single artifact. The
+// archive contains the following files:
+//
+//	packages.json   - the changed packages as returned by ChangedPackages
+//	graph.json      - the subgraph of affected packages, as a map of each
+//	                  affected package to the affected packages that import it
+//	reasons.json    - a map of each affected package to its Reason
+//	provenance.json - prov
+func (g *GTA) Export(w io.Writer, prov Provenance) error {
+	cp, m, err := g.changedPackages()
+	if err != nil {
+		return err
+	}
+
+	prov.Format = ExportFormat
+	if prov.Created.IsZero() {
+		prov.Created = time.Now().UTC()
+	}
+
+	affected := make(map[string]struct{}, len(cp.AllChanges))
+	for _, pkg := range cp.AllChanges {
+		affected[pkg.ImportPath] = struct{}{}
+	}
+
+	graph := make(map[string][]string)
+	for importPath := range affected {
+		dependents := []string{}
+		if m.graph != nil {
+			for dependent := range m.graph.graph[importPath] {
+				if _, ok := affected[dependent]; ok && dependent != importPath {
+					dependents = append(dependents, dependent)
+				}
+			}
+		}
+		sort.Strings(dependents)
+		graph[importPath] = dependents
+	}
+
+	reasons := make(map[string]*Reason)
+	for changed, marked := range m.paths {
+		for importPath := range marked {
+			if _, ok := affected[importPath]; !ok {
+				continue
+			}
+
+			reason, ok := reasons[importPath]
+			if !ok {
+				reason = &Reason{
+					Origins: cp.Origins[importPath],
+				}
+				reasons[importPath] = reason
+			}
+			reason.Changed = append(reason.Changed, changed)
+			if importPath == changed {
+				reason.Files = append(reason.Files, m.files[changed]...)
+			}
+		}
+	}
+	for _, reason := range reasons {
+		sort.Strings(reason.Changed)
+		sort.Strings(reason.Files)
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	entries := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "packages.json", v: cp},
+		{name: "graph.json", v: graph},
+		{name: "reasons.json", v: reasons},
+		{name: "provenance.json", v: prov},
+	}
+
+	for _, entry := range entries {
+		b, err := json.MarshalIndent(entry.v, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		hdr := &tar.Header{
+			Name:    entry.name,
+			Mode:    0644,
+			Size:    int64(len(b)),
+			ModTime: prov.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_Export(t *testing.T) {
+	// A depends on B
+	// C depends on A
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true, Files: []string{"b.go"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+			"A": map[string]bool{
+				"C": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	gta, err := New(AddDiffer("git", difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	err = gta.Export(&buf, Provenance{Created: created, Base: "origin/master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+
+	files := make(map[string][]byte)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		files[hdr.Name] = b
+	}
+
+	wantNames := []string{"packages.json", "graph.json", "reasons.json", "provenance.json"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Fatalf("(-want, +got)\n%s", diff)
+	}
+
+	var gotPackages Packages
+	if err := json.Unmarshal(files["packages.json"], &gotPackages); err != nil {
+		t.Fatal(err)
+	}
+	wantPackages := Packages{
+		Dependencies: map[string][]Package{
+			"B": []Package{
+				{ImportPath: "A"},
+				{ImportPath: "C"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+			{ImportPath: "C"},
+		},
+		Origins: map[string][]string{
+			"A": []string{"git"},
+			"B": []string{"git"},
+			"C": []string{"git"},
+		},
+	}
+	if diff := cmp.Diff(wantPackages, gotPackages); diff != "" {
+		t.Errorf("packages.json (-want, +got)\n%s", diff)
+	}
+
+	var gotGraph map[string][]string
+	if err := json.Unmarshal(files["graph.json"], &gotGraph); err != nil {
+		t.Fatal(err)
+	}
+	wantGraph := map[string][]string{
+		"A": []string{"C"},
+		"B": []string{"A"},
+		"C": []string{},
+	}
+	if diff := cmp.Diff(wantGraph, gotGraph); diff != "" {
+		t.Errorf("graph.json (-want, +got)\n%s", diff)
+	}
+
+	var gotReasons map[string]Reason
+	if err := json.Unmarshal(files["reasons.json"], &gotReasons); err != nil {
+		t.Fatal(err)
+	}
+	wantReasons := map[string]Reason{
+		"A": {Changed: []string{"B"}, Origins: []string{"git"}},
+		"B": {Changed: []string{"B"}, Files: []string{"dirB/b.go"}, Origins: []string{"git"}},
+		"C": {Changed: []string{"B"}, Origins: []string{"git"}},
+	}
+	if diff := cmp.Diff(wantReasons, gotReasons); diff != "" {
+		t.Errorf("reasons.json (-want, +got)\n%s", diff)
+	}
+
+	var gotProvenance Provenance
+	if err := json.Unmarshal(files["provenance.json"], &gotProvenance); err != nil {
+		t.Fatal(err)
+	}
+	wantProvenance := Provenance{
+		Format:  ExportFormat,
+		Created: created,
+		Base:    "origin/master",
+	}
+	if diff := cmp.Diff(wantProvenance, gotProvenance); diff != "" {
+		t.Errorf("provenance.json (-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Fixture describes a directory of test fixtures that is shared by
+// packages. Changes to the files within the directory mark its consumers as
+// changed, but not their dependents, because only the consumers' tests use the
+// fixtures.
+type Fixture struct {
+	// Dir is the absolute path of the directory that contains the fixtures.
+	Dir string
+
+	// Consumers are the import paths of the packages whose tests use the
+	// fixtures.
+	Consumers []string
+
+	// DetectConsumers causes the packages whose test files contain a string
+	// literal that refers to Dir, or to a file within it, to be consumers, too.
+	// The test files of the packages within the directory of the GTA, which is
+	// the current working directory unless it is set with SetDir, are scanned.
+	DetectConsumers bool
+}
+
+// fixtureConsumers returns the import paths of the consumers of the fixtures
+// that contain the file abs.
+func (g *GTA) fixtureConsumers(abs string) ([]string, error) {
+	var consumers []string
+	for i, fixture := range g.fixtures {
+		if !withinDir(abs, fixture.Dir) {
+			continue
+		}
+
+		consumers = append(consumers, fixture.Consumers...)
+		if !fixture.DetectConsumers {
+			continue
+		}
+
+		if g.detectedConsumers == nil {
+			g.detectedConsumers = make(map[int][]string)
+		}
+		detected, ok := g.detectedConsumers[i]
+		if !ok {
+			wd, err := g.workingDir()
+			if err != nil {
+				return nil, err
+			}
+
+			detected, err = detectConsumers(wd, fixture.Dir, g.packager.PackageFromDir)
+			if err != nil {
+				return nil, err
+			}
+			g.detectedConsumers[i] = detected
+		}
+		consumers = append(consumers, detected...)
+	}
+
+	return consumers, nil
+}
+
+// detectConsumers returns the sorted import paths of the packages within root
+// whose test files contain a string literal that refers to dir or a file
+// within it. A literal refers to dir when it is a path relative to the
+// package's directory that is within dir, or when it contains the path of dir
+// relative to root.
+func detectConsumers(root, dir string, packageFromDir func(string) (*Package, error)) ([]string, error) {
+	isTestFile := func(name string) bool {
+		return strings.HasSuffix(name, "_test.go")
+	}
+	return detectReferrers(root, dir, isTestFile, packageFromDir)
+}
+
+// detectReferrers returns the sorted import paths of the packages within root
+// that have a Go file, selected by include, that refers to dir or a file
+// within it. A file refers to dir when it contains a string literal that
+// refers to dir, as described by detectConsumers, or a go:embed directive
+// whose pattern may match files within dir.
+func detectReferrers(root, dir string, include func(name string) bool, packageFromDir func(string) (*Package, error)) ([]string, error) {
+	relDir, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil, err
+	}
+	relDir = filepath.ToSlash(relDir)
+
+	refersToDir := func(pkgDir, lit string) bool {
+		if relDir != "." && !strings.HasPrefix(relDir, "../") && strings.Contains(lit, relDir) {
+			return true
+		}
+		return !filepath.IsAbs(lit) && withinDir(filepath.Join(pkgDir, filepath.FromSlash(lit)), dir)
+	}
+
+	consumers := make(map[string]struct{})
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if path != root && (name == "vendor" || name == "testdata" || name[0] == '.' || name[0] == '_') {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(name) != ".go" || !include(name) {
+			return nil
+		}
+
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+		if err != nil {
+			// ignore files that cannot be parsed; they will fail to build
+			// regardless of dir.
+			return nil
+		}
+
+		pkgDir := filepath.Dir(path)
+		found := embedsDir(f, pkgDir, dir)
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if found || !ok || lit.Kind != token.STRING {
+				return !found
+			}
+
+			s, err := strconv.Unquote(lit.Value)
+			if err == nil && s != "" && refersToDir(pkgDir, s) {
+				found = true
+			}
+			return !found
+		})
+		if !found {
+			return nil
+		}
+
+		// the package may be returned along with an error (e.g. when the
+		// directory contains files for multiple packages).
+		pkg, _ := packageFromDir(pkgDir)
+		if pkg == nil || pkg.ImportPath == "" || pkg.ImportPath == "." {
+			return nil
+		}
+		consumers[pkg.ImportPath] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for importPath := range consumers {
+		out = append(out, importPath)
+	}
+	sort.Strings(out)
+
+	return out, nil
+}
+
+// embedsDir reports whether the file f, in the directory pkgDir, has a
+// go:embed directive with a pattern that may match files within dir.
+func embedsDir(f *ast.File, pkgDir, dir string) bool {
+	for _, pattern := range embedPatterns(f) {
+		pattern = strings.TrimPrefix(pattern, "all:")
+
+		// only the part of the pattern before the first meta character is
+		// known to be a path.
+		if i := strings.IndexAny(pattern, "*?[\\"); i >= 0 {
+			pattern = path.Dir(pattern[:i] + "x")
+		}
+
+		p := filepath.Join(pkgDir, filepath.FromSlash(pattern))
+		if withinDir(p, dir) || withinDir(dir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDir reports whether path is dir or a path within dir.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
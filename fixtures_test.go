@@ -0,0 +1,131 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_Fixtures(t *testing.T) {
+	// A depends on B
+	// C depends on A
+	// fixtures are used by A and B
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo/fixtures": Directory{Exists: true, Files: []string{"data.json"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+			"A": map[string]bool{
+				"C": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: graph,
+		errs: map[string]error{
+			"/repo/fixtures": &build.NoGoError{Dir: "/repo/fixtures"},
+		},
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{},
+		Changes: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+		},
+	}
+
+	fixture := Fixture{
+		Dir:       "/repo/fixtures",
+		Consumers: []string{"A", "B"},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetFixtures(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestDetectConsumers(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"testfixtures/data.json": `{}`,
+		"relative/relative_test.go": `package relative
+
+const fn = "../testfixtures/data.json"
+`,
+		"rooted/rooted_test.go": `package rooted
+
+var fn = filepath.Join(root, "testfixtures/data.json")
+`,
+		"unrelated/unrelated_test.go": `package unrelated
+
+const fn = "testdata/data.json"
+`,
+		"nottest/nottest.go": `package nottest
+
+const fn = "../testfixtures/data.json"
+`,
+	}
+
+	for fn, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(fn))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, root, fn, content)
+	}
+
+	packageFromDir := func(dir string) (*Package, error) {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		return &Package{ImportPath: "example.com/" + filepath.ToSlash(rel)}, nil
+	}
+
+	got, err := detectConsumers(root, filepath.Join(root, "testfixtures"), packageFromDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/relative", "example.com/rooted"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A GenerateMapping maps a generator run by go:generate directives to the
+// inputs that it reads without them being named by the arguments of the
+// directives, such as the configuration file and queries read by sqlc.
+type GenerateMapping struct {
+	// Generator is the name of the generator: the base name of the command
+	// of the directives, or of the package of go run directives.
+	Generator string
+
+	// Patterns are the glob patterns of the inputs, relative to the directory
+	// of the package with the directive. The syntax is that of path.Match,
+	// with the addition that a "**" element matches zero or more directories.
+	Patterns []string
+}
+
+// generator is a go:generate directive of a package and its inputs.
+type generator struct {
+	// importPath is the import path of the package with the directive.
+	importPath string
+	// inputs are the absolute paths of the files and directories named by
+	// the arguments of the directive.
+	inputs []string
+	// patterns are the absolute glob patterns of the inputs of the
+	// directive's generator.
+	patterns []string
+}
+
+// generatePackages returns the import paths of the packages with a
+// go:generate directive that reads the file abs, when the detection of
+// generator inputs is enabled.
+func (g *GTA) generatePackages(abs string) ([]string, error) {
+	if !g.genInputs {
+		return nil, nil
+	}
+
+	if g.detectedGenerators == nil {
+		wd, err := g.workingDir()
+		if err != nil {
+			return nil, err
+		}
+
+		g.detectedGenerators, err = detectGenerators(wd, g.genMappings, g.packager.PackageFromDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pkgs []string
+	for _, gen := range g.detectedGenerators {
+		if gen.reads(abs) {
+			pkgs = append(pkgs, gen.importPath)
+		}
+	}
+	return pkgs, nil
+}
+
+// reads reports whether the file abs is an input of gen.
+func (gen generator) reads(abs string) bool {
+	for _, input := range gen.inputs {
+		if withinDir(abs, input) {
+			return true
+		}
+	}
+	for _, pattern := range gen.patterns {
+		if matchGlob(filepath.ToSlash(pattern), filepath.ToSlash(abs)) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectGenerators returns the go:generate directives of the Go files of the
+// packages within root, with their inputs: the existing files and
+// directories named by their arguments, and the patterns of mappings for
+// their generators. Directories that contain the package's directory are not
+// inputs, since they would make every change an input.
+func detectGenerators(root string, mappings []GenerateMapping, packageFromDir func(string) (*Package, error)) ([]generator, error) {
+	generators := []generator{}
+	err := filepath.Walk(root, func(fn string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := info.Name()
+		if info.IsDir() {
+			if fn != root && (name == "vendor" || name == "testdata" || name[0] == '.' || name[0] == '_') {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(name) != ".go" {
+			return nil
+		}
+
+		src, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(src, []byte("//go:generate")) {
+			return nil
+		}
+
+		pkgDir := filepath.Dir(fn)
+		var pkg *Package
+		scanner := bufio.NewScanner(bytes.NewReader(src))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "//go:generate ") && !strings.HasPrefix(line, "//go:generate\t") {
+				continue
+			}
+
+			args := generateArgs(strings.TrimPrefix(line, "//go:generate"), fn)
+			if len(args) == 0 {
+				continue
+			}
+
+			// the package may be returned along with an error (e.g. when the
+			// directory contains files for multiple packages).
+			if pkg == nil {
+				pkg, _ = packageFromDir(pkgDir)
+				if pkg == nil || pkg.ImportPath == "" || pkg.ImportPath == "." {
+					return nil
+				}
+			}
+
+			gen := generator{importPath: pkg.ImportPath}
+			for _, arg := range args[1:] {
+				// the values of flags may name inputs, too, e.g. -in=schema.sql.
+				if i := strings.Index(arg, "="); i >= 0 && strings.HasPrefix(arg, "-") {
+					arg = arg[i+1:]
+				}
+				if arg == "" || strings.HasPrefix(arg, "-") {
+					continue
+				}
+				input := arg
+				if !filepath.IsAbs(input) {
+					input = filepath.Join(pkgDir, filepath.FromSlash(input))
+				}
+				if _, err := os.Stat(input); err != nil || withinDir(pkgDir, input) {
+					continue
+				}
+				gen.inputs = append(gen.inputs, input)
+			}
+
+			name := generatorName(args)
+			for _, mapping := range mappings {
+				if mapping.Generator != name {
+					continue
+				}
+				for _, pattern := range mapping.Patterns {
+					gen.patterns = append(gen.patterns, filepath.Join(pkgDir, filepath.FromSlash(pattern)))
+				}
+			}
+
+			if len(gen.inputs) > 0 || len(gen.patterns) > 0 {
+				generators = append(generators, gen)
+			}
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return generators, nil
+}
+
+// generateArgs returns the arguments of the go:generate directive s of the Go
+// file fn, split the way the go command splits them: at spaces, except within
+// double quoted strings, and with the environment variables that go generate
+// sets expanded.
+func generateArgs(s, fn string) []string {
+	env := func(name string) string {
+		switch name {
+		case "GOFILE":
+			return filepath.Base(fn)
+		case "$":
+			return "$"
+		}
+		return os.Getenv(name)
+	}
+
+	var args []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return args
+		}
+
+		if s[0] == '"' {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(s) {
+				if arg, err := strconv.Unquote(s[:end+1]); err == nil {
+					args = append(args, os.Expand(arg, env))
+					s = s[end+1:]
+					continue
+				}
+			}
+		}
+
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		args = append(args, os.Expand(s[:end], env))
+		s = s[end:]
+	}
+}
+
+// generatorName returns the name of the generator run by the go:generate
+// directive with the arguments args: the base name of the package of go run
+// directives, and the base name of the command otherwise.
+func generatorName(args []string) string {
+	if filepath.Base(args[0]) == "go" && len(args) > 1 && args[1] == "run" {
+		for _, arg := range args[2:] {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			if i := strings.Index(arg, "@"); i >= 0 {
+				arg = arg[:i]
+			}
+			return path.Base(arg)
+		}
+	}
+	return filepath.Base(args[0])
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerateArgs(t *testing.T) {
+	defer Setenv(t, "GTA_TEST_GENERATE", "value")()
+
+	got := generateArgs(` stringer -type=T "a b" $GOFILE ${GTA_TEST_GENERATE} $$x`, "/src/t.go")
+	want := []string{"stringer", "-type=T", "a b", "t.go", "value", "$x"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGeneratorName(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"protoc", "-I", "."}, want: "protoc"},
+		{args: []string{"/usr/local/bin/sqlc", "generate"}, want: "sqlc"},
+		{args: []string{"go", "run", "-mod=mod", "example.com/tools/cmd/gen@v1.0.0", "x"}, want: "gen"},
+		{args: []string{"go", "generate"}, want: "go"},
+	}
+
+	for _, tt := range tests {
+		if got := generatorName(tt.args); got != tt.want {
+			t.Errorf("generatorName(%q) = %q; want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestGTA_GenerateInputs(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"proto", "api", "db/queries", "cmd"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "proto/api.proto", "syntax = \"proto3\";\n")
+	writeFile(t, dir, "api/api.go", "package api\n\n//go:generate protoc -I ../proto --go_out=. ../proto/api.proto\n")
+	writeFile(t, dir, "db/db.go", "package db\n\n//go:generate sqlc generate\n")
+	writeFile(t, dir, "db/queries/users.sql", "SELECT 1;\n")
+	writeFile(t, dir, "cmd/main.go", "package main\n")
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			filepath.Join(dir, "api"): "example.com/api",
+			filepath.Join(dir, "db"):  "example.com/db",
+			filepath.Join(dir, "cmd"): "example.com/cmd",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"example.com/api": {"example.com/cmd": true},
+			},
+		},
+		errs: map[string]error{
+			filepath.Join(dir, "proto"):      &build.NoGoError{Dir: filepath.Join(dir, "proto")},
+			filepath.Join(dir, "db/queries"): &build.NoGoError{Dir: filepath.Join(dir, "db/queries")},
+		},
+	}
+
+	sqlc := GenerateMapping{Generator: "sqlc", Patterns: []string{"queries/*.sql", "sqlc.yaml"}}
+	tests := []struct {
+		desc string
+		opts []Option
+		diff map[string]Directory
+		want []string
+	}{
+		{
+			desc: "disabled",
+			diff: map[string]Directory{filepath.Join(dir, "proto"): {Exists: true, Files: []string{"api.proto"}}},
+		},
+		{
+			desc: "argument",
+			opts: []Option{SetGenerateInputs()},
+			diff: map[string]Directory{filepath.Join(dir, "proto"): {Exists: true, Files: []string{"api.proto"}}},
+			want: []string{"example.com/api", "example.com/cmd"},
+		},
+		{
+			desc: "unmapped generator",
+			opts: []Option{SetGenerateInputs()},
+			diff: map[string]Directory{filepath.Join(dir, "db/queries"): {Exists: true, Files: []string{"users.sql"}}},
+		},
+		{
+			desc: "mapped generator",
+			opts: []Option{SetGenerateInputs(sqlc)},
+			diff: map[string]Directory{filepath.Join(dir, "db/queries"): {Exists: true, Files: []string{"users.sql"}}},
+			want: []string{"example.com/db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			opts := append([]Option{SetDir(dir), SetDiffer(&testDiffer{diff: tt.diff}), SetPackager(pkgr)}, tt.opts...)
+			gt, err := New(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GitHubDifferOption is an option function used to modify a GitHub pull
+// request differ.
+type GitHubDifferOption func(*githubDiffer)
+
+// SetGitHubBaseURL sets the URL of the GitHub API, e.g. for GitHub Enterprise
+// Server. It defaults to https://api.github.com.
+func SetGitHubBaseURL(baseURL string) GitHubDifferOption {
+	return func(d *githubDiffer) {
+		d.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// SetGitHubHTTPClient sets the HTTP client used to make requests to the GitHub
+// API. It defaults to http.DefaultClient.
+func SetGitHubHTTPClient(client *http.Client) GitHubDifferOption {
+	return func(d *githubDiffer) {
+		d.client = client
+	}
+}
+
+// SetGitHubRoot sets the directory that contains the checkout of the
+// repository, which the paths of the changed files are relative to. When it
+// is not set, the current working directory is used.
+func SetGitHubRoot(root string) GitHubDifferOption {
+	return func(d *githubDiffer) {
+		d.root = root
+	}
+}
+
+// SetGitHubContext sets the context of the requests made to the GitHub API.
+func SetGitHubContext(ctx context.Context) GitHubDifferOption {
+	return func(d *githubDiffer) {
+		d.ctx = ctx
+	}
+}
+
+// NewGitHubPRDiffer returns a Differ that determines the files changed by the
+// pull request numbered prNumber in the GitHub repository owner/repo using the
+// GitHub API, so that the history of the repository need not be available
+// locally. token is used to authenticate the requests; when it is empty, the
+// requests are not authenticated. Removed files, and the previous paths of
+// renamed files, are reported as deleted.
+func NewGitHubPRDiffer(owner, repo string, prNumber int, token string, opts ...GitHubDifferOption) Differ {
+	d := &githubDiffer{
+		owner:   owner,
+		repo:    repo,
+		number:  prNumber,
+		token:   token,
+		baseURL: "https://api.github.com",
+		client:  http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// githubDiffer implements the Differ interface using the GitHub API.
+type githubDiffer struct {
+	owner   string
+	repo    string
+	number  int
+	token   string
+	baseURL string
+	client  *http.Client
+	root    string
+	ctx     context.Context
+
+	once    sync.Once
+	changes Differ
+	err     error
+}
+
+// githubFile is a file in the response of the GitHub API's list pull request
+// files endpoint.
+type githubFile struct {
+	Filename         string `json:"filename"`
+	Status           string `json:"status"`
+	PreviousFilename string `json:"previous_filename"`
+}
+
+// Diff returns a set of changed directories. The keys of the returned map are
+// absolute paths.
+func (d *githubDiffer) Diff() (map[string]Directory, error) {
+	changes, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	return changes.Diff()
+}
+
+// DiffFiles returns a set of changed files. The keys of the returned map are
+// absolute paths. The map values indicate whether or not the file exists.
+func (d *githubDiffer) DiffFiles() (map[string]bool, error) {
+	changes, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	return changes.DiffFiles()
+}
+
+// load returns a Differ for the changes of the pull request.
+func (d *githubDiffer) load() (Differ, error) {
+	d.once.Do(func() {
+		root := d.root
+		if root == "" {
+			var err error
+			if root, err = os.Getwd(); err != nil {
+				d.err = err
+				return
+			}
+		}
+		root, err := filepath.Abs(root)
+		if err != nil {
+			d.err = err
+			return
+		}
+
+		files, err := d.files()
+		if err != nil {
+			d.err = err
+			return
+		}
+
+		var changes []FileChange
+		for _, f := range files {
+			c := FileChange{
+				Path: filepath.Join(root, filepath.FromSlash(f.Filename)),
+			}
+			switch f.Status {
+			case "added":
+				c.Status = StatusAdded
+			case "removed":
+				c.Status = StatusDeleted
+			case "renamed":
+				c.Status = StatusRenamed
+				c.OldPath = filepath.Join(root, filepath.FromSlash(f.PreviousFilename))
+			case "copied":
+				c.Status = StatusCopied
+				c.OldPath = filepath.Join(root, filepath.FromSlash(f.PreviousFilename))
+			default:
+				c.Status = StatusModified
+			}
+			changes = append(changes, c)
+		}
+
+		d.changes, d.err = NewFileChangesDiffer(changes)
+	})
+
+	return d.changes, d.err
+}
+
+// files returns the files changed by the pull request, following the
+// pagination of the GitHub API.
+func (d *githubDiffer) files() ([]githubFile, error) {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	next := fmt.Sprintf("%s/repos/%s/%s/pulls/%d/files?per_page=100", d.baseURL, url.PathEscape(d.owner), url.PathEscape(d.repo), d.number)
+
+	var files []githubFile
+	for next != "" {
+		req, err := http.NewRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if d.token != "" {
+			req.Header.Set("Authorization", "Bearer "+d.token)
+		}
+
+		page, link, err := d.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("listing files of %s/%s#%d: %w", d.owner, d.repo, d.number, err)
+		}
+		files = append(files, page...)
+		next = nextLink(link)
+	}
+
+	return files, nil
+}
+
+// do makes req and returns the files in the response and its Link header.
+func (d *githubDiffer) do(req *http.Request) ([]githubFile, string, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(b, &body) == nil && body.Message != "" {
+			return nil, "", fmt.Errorf("%s: %s", resp.Status, body.Message)
+		}
+		return nil, "", fmt.Errorf("%s", resp.Status)
+	}
+
+	var files []githubFile
+	if err := json.NewDecoder(resp.Body).Decode(&files); err != nil {
+		return nil, "", err
+	}
+	return files, resp.Header.Get("Link"), nil
+}
+
+// nextLink returns the URL of the next page in the Link header link, or an
+// empty string when there is no next page.
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(segments[0]), "<>")
+			}
+		}
+	}
+	return ""
+}
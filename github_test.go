@@ -0,0 +1,98 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGitHubPRDiffer(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/pulls/7/files" {
+			http.NotFound(w, r)
+			return
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			t.Errorf("Authorization = %q; want %q", got, want)
+		}
+
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/owner/repo/pulls/7/files?per_page=100&page=2>; rel="next", <%s/repos/owner/repo/pulls/7/files?per_page=100&page=2>; rel="last"`, srv.URL, srv.URL))
+			fmt.Fprint(w, `[{"filename": "foo/foo.go", "status": "modified"}, {"filename": "bar/bar.go", "status": "added"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"filename": "baz/baz.go", "status": "removed"}, {"filename": "qux/qux.go", "status": "renamed", "previous_filename": "quux/qux.go"}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	root := filepath.FromSlash("/src/repo")
+	sut := NewGitHubPRDiffer("owner", "repo", 7, "secret", SetGitHubBaseURL(srv.URL), SetGitHubRoot(root))
+
+	got, err := sut.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(root, "foo", "foo.go"):  true,
+		filepath.Join(root, "bar", "bar.go"):  true,
+		filepath.Join(root, "baz", "baz.go"):  false,
+		filepath.Join(root, "qux", "qux.go"):  true,
+		filepath.Join(root, "quux", "qux.go"): false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGitHubPRDiffer_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+	}))
+	defer srv.Close()
+
+	sut := NewGitHubPRDiffer("owner", "repo", 7, "", SetGitHubBaseURL(srv.URL))
+
+	_, err := sut.Diff()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if want := "listing files of owner/repo#7: 404 Not Found: Not Found"; err.Error() != want {
+		t.Errorf("err = %q; want %q", err, want)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: "", want: ""},
+		{link: `<https://api.github.com/x?page=3>; rel="last"`, want: ""},
+		{link: `<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`, want: "https://api.github.com/x?page=3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.link), func(t *testing.T) {
+			if got := nextLink(tt.link); got != tt.want {
+				t.Errorf("nextLink(%q) = %q; want %q", tt.link, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A ModulePathChange is a change to the module directive of a go.mod file,
+// which changes the import paths of all of the packages of the module.
+type ModulePathChange struct {
+	// Dir is the directory of the go.mod file.
+	Dir string `json:"dir"`
+	// From and To are the module paths before and after the change.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// String implements the fmt.Stringer interface.
+func (c ModulePathChange) String() string {
+	return fmt.Sprintf("the module path of %s changed from %s to %s", c.Dir, c.From, c.To)
+}
+
+// goModPackages returns the sorted packages affected by the change to the
+// go.mod file abs and, when the change renames the module, the change of its
+// module path. The packages are those that import, directly or through other
+// modules, a package of a module whose requirement or replacement changed and,
+// when the module was renamed, all of the packages of the module, whose import
+// paths all changed. It returns nothing when no differ can read the content of
+// abs before the change.
+func (g *GTA) goModPackages(abs string) ([]string, *ModulePathChange, error) {
+	before, err := g.readBase(abs)
+	switch {
+	case err == errNoBaseReader:
+		return nil, nil, nil
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, nil, err
+	}
+	after, err := ioutil.ReadFile(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	beforeDeps, err := goModDependencies(before)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s before the change: %v", abs, err)
+	}
+	afterDeps, err := goModDependencies(after)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", abs, err)
+	}
+
+	// modules maps the modules of the go.mod file before and after the change
+	// to whether the change affects them.
+	modules := make(map[string]bool)
+	for path, version := range beforeDeps {
+		after, ok := afterDeps[path]
+		modules[path] = !ok || after != version
+	}
+	for path := range afterDeps {
+		if _, ok := beforeDeps[path]; !ok {
+			modules[path] = true
+		}
+	}
+
+	var change *ModulePathChange
+	from, to := goModModulePath(before), goModModulePath(after)
+	if from != "" && to != "" && from != to {
+		change = &ModulePathChange{Dir: filepath.Dir(abs), From: from, To: to}
+	}
+
+	changed := false
+	for _, c := range modules {
+		changed = changed || c
+	}
+	if !changed && change == nil {
+		return nil, nil, nil
+	}
+
+	graph, err := g.packager.DependentGraph()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pkgs := moduleDependents(graph, modules)
+	if change != nil {
+		for _, node := range graph.Nodes() {
+			if moduleOf(node, modules) == "" && (node == change.To || strings.HasPrefix(node, change.To+"/")) {
+				pkgs[node] = struct{}{}
+			}
+		}
+	}
+	return sortedSet(pkgs), change, nil
+}
+
+// moduleDependents returns the packages of the repository in the dependent
+// graph that depend on the packages of the modules that modules maps to true.
+// The dependents are walked through the packages of the other modules in
+// modules until reaching packages of the repository, which are the packages
+// of none of the modules.
+func moduleDependents(graph *Graph, modules map[string]bool) map[string]struct{} {
+	var queue []string
+	for _, node := range graph.Nodes() {
+		if module := moduleOf(node, modules); module != "" && modules[module] {
+			queue = append(queue, node)
+		}
+	}
+
+	pkgs := make(map[string]struct{})
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for dependent := range graph.graph[node] {
+			if visited[dependent] {
+				continue
+			}
+			visited[dependent] = true
+			if moduleOf(dependent, modules) == "" {
+				pkgs[dependent] = struct{}{}
+				continue
+			}
+			queue = append(queue, dependent)
+		}
+	}
+	return pkgs
+}
+
+// moduleOf returns the longest module path in modules that provides the
+// package importPath, or an empty string when none does.
+func moduleOf(importPath string, modules map[string]bool) string {
+	for path := importPath; ; {
+		if _, ok := modules[path]; ok {
+			return path
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return ""
+		}
+		path = path[:i]
+	}
+}
+
+// goModDependencies returns the module paths that are required or replaced by
+// the go.mod file b, each mapped to a description of the version of the
+// module that is used, so that two descriptions differ when the module used
+// differs.
+func goModDependencies(b []byte) (map[string]string, error) {
+	deps := make(map[string]string)
+
+	requires, err := goModDirectives(b, "require")
+	if err != nil {
+		return nil, err
+	}
+	for _, args := range requires {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("invalid require directive %q", strings.Join(args, " "))
+		}
+		deps[args[0]] = args[1]
+	}
+
+	replaces, err := goModDirectives(b, "replace")
+	if err != nil {
+		return nil, err
+	}
+	for _, args := range replaces {
+		i := 0
+		for i < len(args) && args[i] != "=>" {
+			i++
+		}
+		if i == 0 || i == len(args) {
+			return nil, fmt.Errorf("invalid replace directive %q", strings.Join(args, " "))
+		}
+		deps[args[0]] += " " + strings.Join(args, " ")
+	}
+	return deps, nil
+}
+
+// goModDirectives returns the arguments of the directives named verb in the
+// go.mod or go.work file b, both of single directives and of the lines of
+// blocks.
+func goModDirectives(b []byte, verb string) ([][]string, error) {
+	var directives [][]string
+	block := ""
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		tokens, err := goModTokens(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q: %v", strings.TrimSpace(line), err)
+		}
+
+		switch {
+		case len(tokens) == 0:
+		case block != "" && len(tokens) == 1 && tokens[0] == ")":
+			block = ""
+		case block != "":
+			if block == verb {
+				directives = append(directives, tokens)
+			}
+		case len(tokens) == 2 && tokens[1] == "(":
+			block = tokens[0]
+		case tokens[0] == verb:
+			directives = append(directives, tokens[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return directives, nil
+}
+
+// goModTokens splits a line of a go.mod or go.work file into its tokens.
+// Quoted tokens are unquoted, parentheses are tokens of their own, and
+// comments are dropped.
+func goModTokens(line string) ([]string, error) {
+	var tokens []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+			return tokens, nil
+		case line[0] == '(' || line[0] == ')':
+			tokens = append(tokens, line[:1])
+			line = line[1:]
+		case line[0] == '"' || line[0] == '`':
+			end := 1
+			for end < len(line) && line[end] != line[0] {
+				if line[0] == '"' && line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errors.New("unterminated quoted string")
+			}
+			token, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			line = line[end+1:]
+		default:
+			end := strings.IndexAny(line, " \t\r()")
+			if i := strings.Index(line, "//"); i >= 0 && (end < 0 || i < end) {
+				end = i
+			}
+			if end < 0 {
+				end = len(line)
+			}
+			tokens = append(tokens, line[:end])
+			line = line[end:]
+		}
+	}
+}
+
+// localReplacements returns the absolute paths of the directories that the
+// replace directives of the go.mod file in the directory dir replace modules
+// with. A missing go.mod file replaces no modules.
+func localReplacements(dir string) ([]string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	replaces, err := goModDirectives(b, "replace")
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, args := range replaces {
+		i := 0
+		for i < len(args) && args[i] != "=>" {
+			i++
+		}
+		if i+1 >= len(args) || !isLocalPath(args[i+1]) {
+			continue
+		}
+		replacement := filepath.FromSlash(args[i+1])
+		if !filepath.IsAbs(replacement) {
+			replacement = filepath.Join(dir, replacement)
+		}
+		dirs = append(dirs, filepath.Clean(replacement))
+	}
+	return dirs, nil
+}
+
+// isLocalPath reports whether the replacement of a replace directive is a
+// directory rather than a module path, which is the case when it is an
+// absolute path or begins with ./ or ../.
+func isLocalPath(p string) bool {
+	return filepath.IsAbs(p) || p == "." || p == ".." ||
+		strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") ||
+		strings.HasPrefix(p, `.\`) || strings.HasPrefix(p, `..\`)
+}
+
+// replacedDir reports whether abs is within a directory that the replace
+// directives of the go.mod file of g's module replace a module with.
+func (g *GTA) replacedDir(abs string) (bool, error) {
+	if g.replaceDirs == nil {
+		dirs, err := localReplacements(g.moduleDir)
+		if err != nil {
+			return false, err
+		}
+		g.replaceDirs = append([]string{}, dirs...)
+	}
+
+	for _, dir := range g.replaceDirs {
+		if withinDir(abs, dir) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,287 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGoModDependencies(t *testing.T) {
+	b := []byte(`module example.com/app // the app
+
+go 1.16
+
+require example.com/a v1.0.0
+
+require (
+	example.com/b v1.2.0 // indirect
+	"example.com/c" v0.1.0
+)
+
+replace example.com/a => ../a
+
+replace (
+	example.com/b v1.2.0 => example.com/fork/b v1.2.1
+)
+
+exclude example.com/d v1.0.0
+`)
+
+	got, err := goModDependencies(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"example.com/a": "v1.0.0 example.com/a => ../a",
+		"example.com/b": "v1.2.0 example.com/b v1.2.0 => example.com/fork/b v1.2.1",
+		"example.com/c": "v0.1.0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	for _, invalid := range []string{
+		"require example.com/a\n",
+		"replace example.com/a ../a\n",
+		"require \"example.com/a v1.0.0\n",
+	} {
+		if _, err := goModDependencies([]byte(invalid)); err == nil {
+			t.Errorf("goModDependencies(%q): expected an error", invalid)
+		}
+	}
+}
+
+func TestGTA_ModuleBump(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", `module example.com/app
+
+go 1.16
+
+require (
+	example.com/dep v1.1.0
+	example.com/dep/v2 v2.0.0
+	example.com/other v1.0.0
+	example.com/new v1.0.0
+)
+`)
+
+	difr := &testBaseDiffer{
+		testDiffer: testDiffer{
+			diff: map[string]Directory{
+				dir: {Exists: true, Files: []string{"go.mod"}},
+			},
+		},
+		base: map[string][]byte{
+			filepath.Join(dir, "go.mod"): []byte(`module example.com/app
+
+go 1.16
+
+require (
+	example.com/dep v1.0.0
+	example.com/dep/v2 v2.0.0
+	example.com/other v1.0.0
+)
+`),
+		},
+	}
+
+	// a imports dep, b imports dep through other/wrap, c imports other, d
+	// imports dep/v2, e imports new, and cmd imports a.
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA":   "example.com/app/a",
+			"dirB":   "example.com/app/b",
+			"dirC":   "example.com/app/c",
+			"dirD":   "example.com/app/d",
+			"dirE":   "example.com/app/e",
+			"dirCmd": "example.com/app/cmd",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"example.com/dep":          {"example.com/app/a": true},
+				"example.com/dep/x":        {"example.com/other/wrap": true},
+				"example.com/other/wrap":   {"example.com/app/b": true},
+				"example.com/other":        {"example.com/app/c": true},
+				"example.com/dep/v2":       {"example.com/app/d": true},
+				"example.com/new/sub":      {"example.com/app/e": true},
+				"example.com/app/a":        {"example.com/app/cmd": true},
+				"example.com/unrequired/x": {"example.com/app/c": true},
+			},
+		},
+		errs: map[string]error{
+			dir: &build.NoGoError{Dir: dir},
+		},
+	}
+
+	gt, err := New(SetDiffer(difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/app/a", "example.com/app/b", "example.com/app/cmd", "example.com/app/e"}
+	if diff := cmp.Diff(want, stringify(pkgs.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_ModulePathChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "module example.com/new\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n")
+
+	difr := &testBaseDiffer{
+		testDiffer: testDiffer{
+			diff: map[string]Directory{
+				dir: {Exists: true, Files: []string{"go.mod"}},
+			},
+		},
+		base: map[string][]byte{
+			filepath.Join(dir, "go.mod"): []byte("module example.com/old\n\ngo 1.16\n\nrequire example.com/dep v1.0.0\n"),
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA":   "example.com/new/a",
+			"dirB":   "example.com/new/b",
+			"dirCmd": "example.com/new/cmd",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"example.com/dep":   {"example.com/new/a": true},
+				"example.com/new/a": {"example.com/new/cmd": true},
+				"example.com/new/b": {},
+			},
+		},
+		errs: map[string]error{
+			dir: &build.NoGoError{Dir: dir},
+		},
+	}
+
+	gt, err := New(SetDiffer(difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/new/a", "example.com/new/b", "example.com/new/cmd"}
+	if diff := cmp.Diff(want, stringify(pkgs.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	wantChanges := []ModulePathChange{{Dir: dir, From: "example.com/old", To: "example.com/new"}}
+	if diff := cmp.Diff(wantChanges, pkgs.ModulePathChanges); diff != "" {
+		t.Errorf("module path changes: (-want, +got)\n%s", diff)
+	}
+}
+
+func TestLocalReplacements(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", `module example.com/app
+
+go 1.16
+
+replace example.com/x => ../x
+
+replace (
+	example.com/y v1.0.0 => ./third_party/y
+	example.com/z => example.com/z v1.2.0
+	"example.com/w" => "/opt/w"
+)
+`)
+
+	got, err := localReplacements(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(filepath.Dir(dir), "x"),
+		filepath.Join(dir, "third_party", "y"),
+		filepath.FromSlash("/opt/w"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_LocalReplace(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	appDir, xDir := filepath.Join(root, "app"), filepath.Join(root, "x")
+	for _, d := range []string{filepath.Join(appDir, "a"), filepath.Join(xDir, "sub")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, appDir, "go.mod", "module example.com/app\n\ngo 1.16\n\nrequire example.com/x v0.0.0\n\nreplace example.com/x => ../x\n")
+	writeFile(t, appDir, "a/a.go", "package a\n\nimport _ \"example.com/x/sub\"\n")
+	writeFile(t, xDir, "go.mod", "module example.com/x\n\ngo 1.16\n")
+	writeFile(t, xDir, "sub/sub.go", "package sub\n")
+
+	app := &packages.Module{Path: "example.com/app", Dir: appDir, Main: true}
+	x := &packages.Module{
+		Path:    "example.com/x",
+		Version: "v0.0.0",
+		Dir:     xDir,
+		Replace: &packages.Module{Path: "../x", Dir: xDir},
+	}
+	sub := &packages.Package{
+		ID:      "example.com/x/sub",
+		Name:    "sub",
+		PkgPath: "example.com/x/sub",
+		GoFiles: []string{filepath.Join(xDir, "sub", "sub.go")},
+		Module:  x,
+	}
+	a := &packages.Package{
+		ID:      "example.com/app/a",
+		Name:    "a",
+		PkgPath: "example.com/app/a",
+		GoFiles: []string{filepath.Join(appDir, "a", "a.go")},
+		Imports: map[string]*packages.Package{"example.com/x/sub": sub},
+		Module:  app,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{a}, nil
+	})
+
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(xDir, "sub"): {Exists: true, Files: []string{"sub.go"}},
+		},
+	}
+	gt, err := New(SetModuleDir(appDir), SetLoader(loader), SetDiffer(difr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/app/a", "example.com/x/sub"}
+	if diff := cmp.Diff(want, stringify(pkgs.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -6,6 +6,11 @@ in the LICENSE file.
 */
 package gta
 
+import (
+	"sort"
+	"strings"
+)
+
 // Graph is an adjacency list representation of a graph using maps.
 type Graph struct {
 	graph map[string]map[string]bool
@@ -28,3 +33,162 @@ func (g *Graph) Traverse(node string, mark map[string]bool) {
 
 	return
 }
+
+// Nodes returns the sorted nodes of the graph.
+func (g *Graph) Nodes() []string {
+	nodes := make(map[string]struct{})
+	for node, edges := range g.graph {
+		nodes[node] = struct{}{}
+		for edge := range edges {
+			nodes[edge] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(nodes))
+	for node := range nodes {
+		sorted = append(sorted, node)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Reachable returns the sorted nodes that can be reached from node by following
+// at most maxDepth edges, or any number of edges when maxDepth is zero. node
+// itself is not included. For a graph of dependents, these are the packages
+// that depend on node.
+func (g *Graph) Reachable(node string, maxDepth int) []string {
+	depths := map[string]int{node: 0}
+	queue := []string{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if maxDepth > 0 && depths[current] >= maxDepth {
+			continue
+		}
+		for edge := range g.graph[current] {
+			if _, ok := depths[edge]; ok {
+				continue
+			}
+			depths[edge] = depths[current] + 1
+			queue = append(queue, edge)
+		}
+	}
+
+	reached := make([]string, 0, len(depths)-1)
+	for n := range depths {
+		if n != node {
+			reached = append(reached, n)
+		}
+	}
+	sort.Strings(reached)
+	return reached
+}
+
+// Path returns the nodes of a shortest path from the node from to the node to,
+// including both, or nil when to cannot be reached from from. Ties between
+// shortest paths are broken by visiting adjacent nodes in lexical order, so
+// that the result is stable.
+func (g *Graph) Path(from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 && from != to {
+		current := queue[0]
+		queue = queue[1:]
+
+		edges := make([]string, 0, len(g.graph[current]))
+		for edge := range g.graph[current] {
+			edges = append(edges, edge)
+		}
+		sort.Strings(edges)
+		for _, edge := range edges {
+			if _, ok := prev[edge]; ok {
+				continue
+			}
+			prev[edge] = current
+			queue = append(queue, edge)
+		}
+		if _, ok := prev[to]; ok {
+			break
+		}
+	}
+
+	if _, ok := prev[to]; !ok {
+		return nil
+	}
+
+	var path []string
+	for n := to; n != from; n = prev[n] {
+		path = append(path, n)
+	}
+	path = append(path, from)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// closure returns the nodes that can reach a node that matches any of the
+// patterns, including the matching nodes. For a graph of dependents, these are
+// the matching packages and the packages that they depend on. A pattern
+// matches a node when they are equal, or when the pattern ends with "/..." and
+// the node is the pattern's prefix or beneath it.
+func (g *Graph) closure(patterns []string) map[string]bool {
+	// reverse the edges so that the nodes can be found by traversing from the
+	// matching nodes.
+	reversed := g.Reversed()
+	matching := make(map[string]struct{})
+	for node, edges := range g.graph {
+		if matchNode(patterns, node) {
+			matching[node] = struct{}{}
+		}
+		for edge := range edges {
+			if matchNode(patterns, edge) {
+				matching[edge] = struct{}{}
+			}
+		}
+	}
+
+	// exact patterns match even nodes without edges.
+	for _, pattern := range patterns {
+		if !strings.HasSuffix(pattern, "/...") {
+			matching[pattern] = struct{}{}
+		}
+	}
+
+	mark := make(map[string]bool)
+	for node := range matching {
+		reversed.Traverse(node, mark)
+	}
+	return mark
+}
+
+// Reversed returns a graph with the edges of g reversed. For a graph of
+// dependents, it is the graph of dependencies.
+func (g *Graph) Reversed() *Graph {
+	reversed := &Graph{graph: make(map[string]map[string]bool)}
+	for node, edges := range g.graph {
+		for edge := range edges {
+			if _, ok := reversed.graph[edge]; !ok {
+				reversed.graph[edge] = make(map[string]bool)
+			}
+			reversed.graph[edge][node] = true
+		}
+	}
+	return reversed
+}
+
+// matchNode reports whether node matches any of the patterns.
+func matchNode(patterns []string, node string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+			if node == prefix || strings.HasPrefix(node, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if node == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ package gta
 import (
 	"testing"
 
+	"github.com/digitalocean/gta/gtatest"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -91,3 +92,91 @@ func TestGraphTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestGraphNodes(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"C": {"B": true, "E": true},
+			"B": {"A": true},
+		},
+	}
+
+	want := []string{"A", "B", "C", "E"}
+	if diff := cmp.Diff(want, graph.Nodes()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func BenchmarkGraphTraversal(b *testing.B) {
+	runSizes(b, func(b *testing.B, loader Loader) {
+		graph, err := NewPackagerWithLoader(loader, nil, nil).DependentGraph()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// the first package is depended on by the most packages.
+			graph.Traverse(gtatest.SyntheticImportPath(0), make(map[string]bool))
+		}
+	})
+}
+
+func TestGraphReachable(t *testing.T) {
+	// a is imported by b and c, b by d, and d by e.
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"a": {"b": true, "c": true},
+			"b": {"d": true},
+			"d": {"e": true, "b": true},
+		},
+	}
+
+	tests := []struct {
+		node     string
+		maxDepth int
+		want     []string
+	}{
+		{node: "a", maxDepth: 1, want: []string{"b", "c"}},
+		{node: "a", maxDepth: 2, want: []string{"b", "c", "d"}},
+		{node: "a", want: []string{"b", "c", "d", "e"}},
+		{node: "e", want: []string{}},
+		{node: "b", want: []string{"d", "e"}},
+	}
+
+	for _, tt := range tests {
+		got := graph.Reachable(tt.node, tt.maxDepth)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Reachable(%q, %d): (-want, +got)\n%s", tt.node, tt.maxDepth, diff)
+		}
+	}
+}
+
+func TestGraphPath(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"a": {"c": true, "b": true, "e": true},
+			"b": {"d": true},
+			"c": {"d": true},
+			"e": {"f": true},
+			"f": {"d": true},
+		},
+	}
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{from: "a", to: "d", want: []string{"a", "b", "d"}},
+		{from: "a", to: "a", want: []string{"a"}},
+		{from: "e", to: "d", want: []string{"e", "f", "d"}},
+		{from: "d", to: "a"},
+	}
+
+	for _, tt := range tests {
+		got := graph.Path(tt.from, tt.to)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Path(%q, %q): (-want, +got)\n%s", tt.from, tt.to, diff)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// graphCacheVersion is the version of the format of cached dependency graphs.
+// It is part of the cache key, so that changing the format invalidates the
+// cached graphs.
+const graphCacheVersion = 7
+
+// DefaultGraphCacheDir returns the default directory of the dependency graph
+// cache, gta under the user's cache directory.
+func DefaultGraphCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gta"), nil
+}
+
+// graphSnapshot is the part of a packageContext that is cached on disk.
+type graphSnapshot struct {
+	Forward      map[string][]string `json:"forward"`
+	Reverse      map[string][]string `json:"reverse"`
+	Names        map[string]string   `json:"names"`
+	ModulesByDir map[string]string   `json:"modules_by_dir"`
+	Dirs         map[string]string   `json:"dirs"`
+	Files        map[string][]string `json:"files"`
+	Embeds       map[string][]string `json:"embeds"`
+	Cgo          map[string][]string `json:"cgo"`
+	BuildReverse map[string][]string `json:"build_reverse"`
+	Anomalies    []Anomaly           `json:"anomalies"`
+	LoadErrors   LoadErrors          `json:"load_errors"`
+}
+
+// cachedPackager returns the default packager, reading its dependency graph
+// from the cache in g's cache directory when it is present, and loading the
+// packages and writing the graph to the cache otherwise. The cache is not used
+// when its key cannot be determined, e.g. outside of a git repository or when
+// the working tree has uncommitted changes, and failing to read or write the
+// cache does not fail loading.
+func (g *GTA) cachedPackager(cfg *packages.Config, ctx build.Context) Packager {
+	key, err := g.graphCacheKey(cfg)
+	if err != nil {
+		return loadPackager(g.loader, cfg, ctx, g.patterns, g.parallelism)
+	}
+
+	path := filepath.Join(g.cacheDir, key+".json")
+	if b, err := ioutil.ReadFile(path); err == nil {
+		var snap graphSnapshot
+		if err := json.Unmarshal(b, &snap); err == nil {
+			return snap.packager(ctx)
+		}
+	}
+
+	packager := loadPackager(g.loader, cfg, ctx, g.patterns, g.parallelism)
+	if pc, ok := packager.(*packageContext); ok && pc.err == nil {
+		writeGraphSnapshot(path, pc.snapshot())
+	}
+	return packager
+}
+
+// graphCacheKey returns the key of the dependency graph that g loads with cfg:
+// a hash of the commit checked out, the go.mod, go.sum, and go.work files of
+// the directories from the working directory up to the root of the
+// repository, the build tags and patterns, the environment that affects
+// loading, and the extras set with SetCacheKeyExtras. It returns an error
+// when the working tree has uncommitted changes, because they are not part of
+// the key.
+func (g *GTA) graphCacheKey(cfg *packages.Config) (string, error) {
+	wd, err := g.workingDir()
+	if err != nil {
+		return "", err
+	}
+
+	gd := &git{dir: wd, ctx: g.context()}
+	out, err := gd.output("status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return "", err
+	}
+	if len(out) > 0 {
+		return "", errors.New("the working tree has uncommitted changes")
+	}
+	head, err := gd.output("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	root, err := gd.toplevel()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "version %d\nhead %s\n", graphCacheVersion, strings.TrimSpace(string(head)))
+	fmt.Fprintf(h, "tags %q\npatterns %q\nflags %q\nmode %d\n", g.tags, g.patterns, cfg.BuildFlags, cfg.Mode)
+	// only the variables that affect loading are part of the key: the
+	// environment of cfg includes that of the process, which changes from run
+	// to run, e.g. with the IDs of CI jobs.
+	for _, name := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GO111MODULE", "GOWORK", "GOROOT"} {
+		fmt.Fprintf(h, "%s=%s\n", name, lookupEnv(cfg.Env, name))
+	}
+	var goEnv []string
+	for k, v := range g.goEnv {
+		// the limits of resources do not change the loaded packages.
+		if k != "GOMAXPROCS" && k != "GOMEMLIMIT" {
+			goEnv = append(goEnv, k+"="+v)
+		}
+	}
+	sort.Strings(goEnv)
+	fmt.Fprintf(h, "goenv %q\n", goEnv)
+	fmt.Fprintf(h, "extras %q\n", g.cacheExtras)
+	overlay := make([]string, 0, len(cfg.Overlay))
+	for fn := range cfg.Overlay {
+		overlay = append(overlay, fn)
+	}
+	sort.Strings(overlay)
+	for _, fn := range overlay {
+		fmt.Fprintf(h, "overlay %s %x\n", fn, sha256.Sum256(cfg.Overlay[fn]))
+	}
+
+	for dir := wd; withinDir(dir, root); dir = filepath.Dir(dir) {
+		for _, name := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			sum := sha256.Sum256(b)
+			fmt.Fprintf(h, "%s %x\n", filepath.Join(dir, name), sum)
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookupEnv returns the value of the variable name in env, where later values
+// take precedence like they do for the go command, or in the environment of
+// the process when env is nil.
+func lookupEnv(env []string, name string) string {
+	if env == nil {
+		return os.Getenv(name)
+	}
+	for i := len(env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(env[i], name+"=") {
+			return strings.TrimPrefix(env[i], name+"=")
+		}
+	}
+	return ""
+}
+
+// writeGraphSnapshot writes snap to path. The snapshot is written to a
+// temporary file that is renamed, so that concurrent runs never read a partial
+// snapshot. Errors are ignored, because the cache only saves time.
+func writeGraphSnapshot(path string, snap *graphSnapshot) {
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".graph-*")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// snapshot returns the part of p that is cached. The anomalies, directories,
+// files, embedded files, files included with cgo, and the build dependent
+// graph are determined so that
+// they are available from the cache.
+func (p *packageContext) snapshot() *graphSnapshot {
+	dirs, _ := p.PackageDirs()
+	files, _ := p.PackageFiles()
+	embeds, _ := p.EmbedFiles()
+	cgo, _ := p.CgoFiles()
+	p.BuildDependentGraph()
+	anomalies, _ := p.Anomalies()
+	loadErrors, _ := p.LoadErrors()
+	return &graphSnapshot{
+		Forward:      setsToLists(p.forward),
+		Reverse:      setsToLists(p.reverse),
+		Names:        p.names,
+		ModulesByDir: p.modulesNamesByDir,
+		Dirs:         dirs,
+		Files:        files,
+		Embeds:       embeds,
+		Cgo:          cgo,
+		BuildReverse: setsToLists(p.buildReverse),
+		Anomalies:    anomalies,
+		LoadErrors:   loadErrors,
+	}
+}
+
+// packager returns a Packager with the dependency graph of snap.
+func (snap *graphSnapshot) packager(ctx build.Context) Packager {
+	anomalies := snap.Anomalies
+	if anomalies == nil {
+		anomalies = []Anomaly{}
+	}
+	loadErrors := snap.LoadErrors
+	if loadErrors == nil {
+		loadErrors = LoadErrors{}
+	}
+	return &packageContext{
+		ctx:               &ctx,
+		packages:          make(map[string]struct{}),
+		forward:           listsToSets(snap.Forward),
+		reverse:           listsToSets(snap.Reverse),
+		names:             snap.Names,
+		modulesNamesByDir: snap.ModulesByDir,
+		dirs:              snap.Dirs,
+		files:             snap.Files,
+		embeds:            snap.Embeds,
+		cgo:               snap.Cgo,
+		buildReverse:      listsToSets(snap.BuildReverse),
+		anomalies:         anomalies,
+		loadErrors:        loadErrors,
+	}
+}
+
+// setsToLists returns the sets of m as lists.
+func setsToLists(m map[string]map[string]struct{}) map[string][]string {
+	lists := make(map[string][]string, len(m))
+	for k, set := range m {
+		list := make([]string, 0, len(set))
+		for v := range set {
+			list = append(list, v)
+		}
+		lists[k] = list
+	}
+	return lists
+}
+
+// listsToSets returns the lists of m as sets.
+func listsToSets(m map[string][]string) map[string]map[string]struct{} {
+	sets := make(map[string]map[string]struct{}, len(m))
+	for k, list := range m {
+		set := make(map[string]struct{}, len(list))
+		for _, v := range list {
+			set[v] = struct{}{}
+		}
+		sets[k] = set
+	}
+	return sets
+}
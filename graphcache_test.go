@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGTA_GraphCache(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", nil)
+	dir := repo.Dir
+
+	cacheDir := t.TempDir()
+
+	var loads int
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		loads++
+		b := &packages.Package{
+			ID:      "example.com/foo/b",
+			Name:    "b",
+			PkgPath: "example.com/foo/b",
+			GoFiles: []string{filepath.Join(dir, "b", "b.go")},
+		}
+		a := &packages.Package{
+			ID:      "example.com/foo/a",
+			Name:    "a",
+			PkgPath: "example.com/foo/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Imports: map[string]*packages.Package{"example.com/foo/b": b},
+		}
+		return []*packages.Package{a, b}, nil
+	})
+
+	check := func(desc string, wantLoads int) {
+		t.Helper()
+
+		gt, err := New(SetDir(dir), SetLoader(loader), SetGraphCache(cacheDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loads != wantLoads {
+			t.Errorf("%s: got %d loads, want %d", desc, loads, wantLoads)
+		}
+
+		graph, err := gt.packager.DependentGraph()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantGraph := map[string]map[string]bool{
+			"example.com/foo/b": {"example.com/foo/a": true},
+		}
+		if diff := cmp.Diff(wantGraph, graph.graph); diff != "" {
+			t.Errorf("%s: (-want, +got)\n%s", desc, diff)
+		}
+
+		dirs, err := gt.packager.(PackageLocator).PackageDirs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDirs := map[string]string{
+			"example.com/foo/a": filepath.Join(dir, "a"),
+			"example.com/foo/b": filepath.Join(dir, "b"),
+		}
+		if diff := cmp.Diff(wantDirs, dirs); diff != "" {
+			t.Errorf("%s: (-want, +got)\n%s", desc, diff)
+		}
+
+		files, err := gt.PackageFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantFiles := map[string][]string{
+			"example.com/foo/a": {filepath.Join(dir, "a", "a.go")},
+			"example.com/foo/b": {filepath.Join(dir, "b", "b.go")},
+		}
+		if diff := cmp.Diff(wantFiles, files); diff != "" {
+			t.Errorf("%s: (-want, +got)\n%s", desc, diff)
+		}
+	}
+
+	check("first load", 1)
+	check("cached", 1)
+
+	// uncommitted changes are not part of the key, so the cache is bypassed.
+	writeFile(t, dir, "baz.go", "package foo\n")
+	check("uncommitted changes", 2)
+	check("uncommitted changes again", 3)
+
+	repo.Commit("baz")
+	check("new commit", 4)
+	check("new commit cached", 4)
+
+	entries, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d cached graphs, want 2", len(entries))
+	}
+}
+
+func TestGTA_GraphCacheKeyExtras(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", nil)
+	dir := repo.Dir
+
+	key := func(extras ...string) string {
+		t.Helper()
+
+		gt, err := New(SetDir(dir), SetPackager(&testPackager{}), SetCacheKeyExtras(extras...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := gt.graphCacheKey(gt.loadConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	none := key()
+	protoc := key("protoc=3.21")
+	if none == protoc {
+		t.Errorf("got the same key with and without extras: %s", none)
+	}
+	if got := key("protoc=3.22"); got == protoc {
+		t.Errorf("got the same key for different extras: %s", got)
+	}
+	if got := key("protoc=3.21"); got != protoc {
+		t.Errorf("got key %s for the same extras, want %s", got, protoc)
+	}
+	if got := key("a", "b"); got == key("b", "a") {
+		t.Errorf("got the same key for differently ordered extras: %s", got)
+	}
+}
+
+func TestGTA_GraphCacheKeyEnv(t *testing.T) {
+	repo := gtatest.NewRepo(t, "example.com/foo", nil)
+	dir := repo.Dir
+
+	key := func(opts ...Option) string {
+		t.Helper()
+
+		opts = append(opts, SetDir(dir), SetPackager(&testPackager{}), SetParallelism(2), SetMemoryLimit("1GiB"))
+		gt, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := gt.graphCacheKey(gt.loadConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	defer Setenv(t, "GTA_TEST_JOB_ID", "1")()
+	base := key(SetGoEnv(map[string]string{"CGO_ENABLED": "0"}))
+
+	defer Setenv(t, "GTA_TEST_JOB_ID", "2")()
+	if got := key(SetGoEnv(map[string]string{"CGO_ENABLED": "0"})); got != base {
+		t.Errorf("got key %s after changing an unrelated variable, want %s", got, base)
+	}
+	if got := key(SetGoEnv(map[string]string{"CGO_ENABLED": "0", "GOMAXPROCS": "4"})); got != base {
+		t.Errorf("got key %s after setting GOMAXPROCS, want %s", got, base)
+	}
+	if got := key(SetGoEnv(map[string]string{"CGO_ENABLED": "1"})); got == base {
+		t.Errorf("got the same key after changing CGO_ENABLED: %s", got)
+	}
+	if got := key(SetGoEnv(map[string]string{"CGO_ENABLED": "0", "GOOS": "windows"})); got == base {
+		t.Errorf("got the same key for another platform: %s", got)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// PackageFiles returns the sorted absolute paths of the files of the packages
+// that match g's prefixes, keyed by their import paths, e.g. to write them
+// with a graph using GraphFiles. g's packager must be a PackageFiler.
+func (g *GTA) PackageFiles() (map[string][]string, error) {
+	filer, ok := g.packager.(PackageFiler)
+	if !ok {
+		return nil, errors.New("the packager does not report package files")
+	}
+	return filer.PackageFiles()
+}
+
+// graphJSON is the JSON representation of a graph written by WriteJSON.
+type graphJSON struct {
+	Nodes []graphNodeJSON `json:"nodes"`
+	Edges []graphEdgeJSON `json:"edges"`
+}
+
+// graphNodeJSON is a node of a graph written by WriteJSON.
+type graphNodeJSON struct {
+	ID          string   `json:"id"`
+	Files       []string `json:"files,omitempty"`
+	Highlighted bool     `json:"highlighted,omitempty"`
+}
+
+// graphEdgeJSON is an edge of a graph written by WriteJSON.
+type graphEdgeJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// WriteJSON writes g to w as a JSON object with a list of nodes, each with its
+// id, its files when they are set with GraphFiles, and whether it is
+// highlighted, and a list of edges, each from a node to an adjacent node. The
+// nodes and edges are sorted, so that the output is stable.
+func (g *Graph) WriteJSON(w io.Writer, opts ...GraphOption) error {
+	cfg := newGraphConfig(opts)
+
+	out := graphJSON{
+		Nodes: []graphNodeJSON{},
+		Edges: []graphEdgeJSON{},
+	}
+	nodes := cfg.sortedNodes(g)
+	for _, node := range nodes {
+		out.Nodes = append(out.Nodes, graphNodeJSON{
+			ID:          node,
+			Files:       cfg.files[node],
+			Highlighted: cfg.highlighted(node),
+		})
+	}
+	for _, node := range nodes {
+		for _, edge := range cfg.sortedEdges(g, node) {
+			out.Edges = append(out.Edges, graphEdgeJSON{From: node, To: edge})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
+
+// graphML is the GraphML document written by WriteGraphML.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// graphMLKey declares an attribute of the nodes of a GraphML document.
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+// graphMLGraph is the graph of a GraphML document.
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+// graphMLNode is a node of a GraphML document.
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+// graphMLData is the value of an attribute of a node of a GraphML document.
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// graphMLEdge is an edge of a GraphML document.
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+// WriteGraphML writes g to w as a directed GraphML document. The files of the
+// nodes, when they are set with GraphFiles, are the newline separated value
+// of the files attribute, and the highlighted nodes have the highlighted
+// attribute set. The nodes and edges are sorted, so that the output is stable.
+func (g *Graph) WriteGraphML(w io.Writer, opts ...GraphOption) error {
+	cfg := newGraphConfig(opts)
+
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "files", For: "node", Name: "files", Type: "string"},
+			{ID: "highlighted", For: "node", Name: "highlighted", Type: "boolean"},
+		},
+		Graph: graphMLGraph{ID: "gta", EdgeDefault: "directed"},
+	}
+	nodes := cfg.sortedNodes(g)
+	for _, node := range nodes {
+		n := graphMLNode{ID: node}
+		if files := cfg.files[node]; len(files) > 0 {
+			n.Data = append(n.Data, graphMLData{Key: "files", Value: strings.Join(files, "\n")})
+		}
+		if cfg.highlighted(node) {
+			n.Data = append(n.Data, graphMLData{Key: "highlighted", Value: "true"})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+	for _, node := range nodes {
+		for _, edge := range cfg.sortedEdges(g, node) {
+			doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: node, Target: edge})
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"go/build"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGraphWriteJSON(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"example.com/b": {"example.com/a": true, "example.com/b": true},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := graph.WriteJSON(&buf,
+		GraphHighlight("example.com/b"),
+		GraphFiles(map[string][]string{"example.com/b": {"/src/b/b.go", "/src/b/b_test.go"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"nodes":[{"id":"example.com/a"},{"id":"example.com/b","files":["/src/b/b.go","/src/b/b_test.go"],"highlighted":true}],"edges":[{"from":"example.com/b","to":"example.com/a"}]}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGraphWriteGraphML(t *testing.T) {
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"example.com/b": {"example.com/a": true, "example.com/c": true},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := graph.WriteGraphML(&buf,
+		GraphNodes("example.com/a", "example.com/b"),
+		GraphHighlight("example.com/b"),
+		GraphFiles(map[string][]string{"example.com/b": {"/src/b/b.go", "/src/b/b&c.go"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="files" for="node" attr.name="files" attr.type="string"></key>
+  <key id="highlighted" for="node" attr.name="highlighted" attr.type="boolean"></key>
+  <graph id="gta" edgedefault="directed">
+    <node id="example.com/a"></node>
+    <node id="example.com/b">
+      <data key="files">/src/b/b.go&#xA;/src/b/b&amp;c.go</data>
+      <data key="highlighted">true</data>
+    </node>
+    <edge source="example.com/b" target="example.com/a"></edge>
+  </graph>
+</graphml>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestPackageContext_PackageFiles(t *testing.T) {
+	a := &packages.Package{
+		ID:         "example.com/a",
+		PkgPath:    "example.com/a",
+		GoFiles:    []string{"/src/a/a.go"},
+		OtherFiles: []string{"/src/a/a.s"},
+	}
+	aTest := &packages.Package{
+		ID:      "example.com/a [example.com/a.test]",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go", "/src/a/a_test.go"},
+	}
+	aXTest := &packages.Package{
+		ID:      "example.com/a_test [example.com/a.test]",
+		PkgPath: "example.com/a_test",
+		GoFiles: []string{"/src/a/example_test.go"},
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{a, aTest, aXTest}, nil
+	})
+
+	pkgr := newPackager(loader, newLoadConfig(nil), build.Default, nil)
+	got, err := pkgr.(PackageFiler).PackageFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"example.com/a": {"/src/a/a.go", "/src/a/a.s", "/src/a/a_test.go", "/src/a/example_test.go"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import "sort"
+
+// GraphOption is an option function used to modify how a graph is written by
+// WriteDOT, WriteJSON, and WriteGraphML.
+type GraphOption func(*graphConfig)
+
+// graphConfig is the configuration of the graph writers.
+type graphConfig struct {
+	nodes     map[string]struct{}
+	highlight map[string]struct{}
+	files     map[string][]string
+}
+
+// GraphNodes restricts the written graph to the subgraph of nodes, e.g. to the
+// affected packages, and the edges between them.
+func GraphNodes(nodes ...string) GraphOption {
+	return func(c *graphConfig) {
+		c.nodes = make(map[string]struct{}, len(nodes))
+		for _, node := range nodes {
+			c.nodes[node] = struct{}{}
+		}
+	}
+}
+
+// GraphHighlight highlights nodes in the written graph, e.g. the packages that
+// were changed directly.
+func GraphHighlight(nodes ...string) GraphOption {
+	return func(c *graphConfig) {
+		c.highlight = make(map[string]struct{}, len(nodes))
+		for _, node := range nodes {
+			c.highlight[node] = struct{}{}
+		}
+	}
+}
+
+// GraphFiles sets the files of each node, e.g. the files of each package as
+// returned by GTA.PackageFiles, to write with the nodes. DOT output does not
+// include files.
+func GraphFiles(files map[string][]string) GraphOption {
+	return func(c *graphConfig) {
+		c.files = files
+	}
+}
+
+// newGraphConfig returns the configuration set by opts.
+func newGraphConfig(opts []GraphOption) *graphConfig {
+	cfg := new(graphConfig)
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// included reports whether node is part of the written graph.
+func (c *graphConfig) included(node string) bool {
+	if c.nodes == nil {
+		return true
+	}
+	_, ok := c.nodes[node]
+	return ok
+}
+
+// highlighted reports whether node is highlighted.
+func (c *graphConfig) highlighted(node string) bool {
+	_, ok := c.highlight[node]
+	return ok
+}
+
+// sortedNodes returns the sorted nodes of g that are written. Nodes of the
+// subgraph without edges are written too.
+func (c *graphConfig) sortedNodes(g *Graph) []string {
+	if c.nodes == nil {
+		return g.Nodes()
+	}
+
+	nodes := make([]string, 0, len(c.nodes))
+	for node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// sortedEdges returns the sorted nodes adjacent to node in g that are written.
+// Edges from a node to itself are omitted.
+func (c *graphConfig) sortedEdges(g *Graph, node string) []string {
+	if !c.included(node) {
+		return nil
+	}
+
+	edges := make([]string, 0, len(g.graph[node]))
+	for edge := range g.graph[node] {
+		if edge != node && c.included(edge) {
+			edges = append(edges, edge)
+		}
+	}
+	sort.Strings(edges)
+	return edges
+}
@@ -7,15 +7,21 @@ in the LICENSE file.
 package gta
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go/build"
 	"go/scanner"
+	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
 )
 
 var (
@@ -25,10 +31,32 @@ var (
 	ErrNoPackager = errors.New("there is no packager set")
 )
 
+// Direction is a direction in which the dependency graph is followed from
+// changed packages.
+type Direction string
+
+const (
+	// DependentsDirection follows the graph to the packages that depend on
+	// the changed packages, directly or transitively, e.g. to find the
+	// packages whose tests must be run.
+	DependentsDirection Direction = "dependents"
+
+	// DependenciesDirection follows the graph to the packages that the
+	// changed packages depend on, directly or transitively, e.g. to find the
+	// packages needed to build artifacts from the changed packages.
+	DependenciesDirection Direction = "dependencies"
+
+	// BothDirections follows the graph to both the dependents and the
+	// dependencies of the changed packages.
+	BothDirections Direction = "both"
+)
+
 // Packages contains various detailed information about the structure of
 // packages GTA has detected.
 type Packages struct {
-	// Dependencies contains a map of changed packages to their dependencies
+	// Dependencies contains a map of changed packages to their dependents, or
+	// to their dependencies when the GTA's Direction is DependenciesDirection,
+	// or to both when it is BothDirections.
 	Dependencies map[string][]Package
 
 	// Changes represents the changed files
@@ -37,12 +65,170 @@ type Packages struct {
 	// AllChanges represents all packages that are dirty including the initial
 	// changed packages.
 	AllChanges []Package
+
+	// Origins contains a map of packages in AllChanges to the sorted labels of
+	// the differs whose changes caused them to be dirty. It is nil when no
+	// labeled differs are set.
+	Origins map[string][]string
+
+	// APIChanges contains a map of changed packages to the sorted exported
+	// identifiers whose declarations were changed or removed. It is only set
+	// when the API report is enabled.
+	APIChanges map[string][]string
+
+	// Risks contains a map of the dependents of changed packages to the risk
+	// the changes pose to them. It is only set when the API report is enabled.
+	Risks map[string]Risk
+
+	// ChangeKinds contains a map of changed packages to the kind of their
+	// changes. It is only set when the change kind report is enabled.
+	ChangeKinds map[string]ChangeKind
+
+	// FileChanges contains a map of changed packages to the sorted changes to
+	// their files, such as whether each file was added or deleted. It is only
+	// set when the file change report is enabled.
+	FileChanges map[string][]FileChange
+
+	// BlastRadii contains a map of the directly changed packages to their
+	// blast radii. It is only set when the blast radius report is enabled.
+	BlastRadii map[string]BlastRadius
+
+	// Orphans contains the sorted packages that no package depends on and
+	// that are not main packages. It is only set when the orphan report is
+	// enabled.
+	Orphans []string
+
+	// TagSets contains a map of affected packages to the sorted names of the
+	// tag sets under which they are affected. It is only set when tag sets
+	// are configured.
+	TagSets map[string][]string
+
+	// Platforms contains a map of each configured platform, of the form
+	// goos/goarch, to the sorted packages that are affected on the platform.
+	// It is only set when platforms are configured.
+	Platforms map[string][]string
+
+	// BuildAffected contains the sorted packages in AllChanges whose compiled
+	// code is affected, because their non-test files changed or they depend
+	// on such a package without going through test files. They need to be
+	// built and tested. It is only set when the build and test report is
+	// enabled.
+	BuildAffected []string
+
+	// TestAffected contains the sorted packages in AllChanges that are only
+	// affected through their tests: their test files or fixtures changed, or
+	// only their test files depend on a changed package. Only their tests need
+	// to run. It is only set when the build and test report is enabled.
+	TestAffected []string
+
+	// InternalScopes contains a map of changed internal packages to the
+	// import path of the tree whose packages may import them.
+	InternalScopes map[string]string
+
+	// VisibilityViolations contains a map of changed internal packages to the
+	// sorted packages that import them from outside of their InternalScopes.
+	VisibilityViolations map[string][]string
+
+	// ModulePathChanges contains the changes to the module directives of
+	// go.mod files, sorted by directory. All of the packages of a module whose
+	// path changed are among the changed packages, because their import paths
+	// changed.
+	ModulePathChanges []ModulePathChange
+
+	// Targets contains the sorted pseudo-targets of the mapping rules that
+	// match the changed files.
+	Targets []string
+
+	// LoadErrors contains the errors found while loading packages, which may
+	// cause changes not to be attributed to the dependents of the packages
+	// with errors. It is not set when load errors are ignored.
+	LoadErrors LoadErrors
+
+	// Anomalies contains the anomalies found while building the dependency
+	// graph, which can cause changes to be attributed to the wrong packages.
+	// It is only set when the anomaly report is enabled.
+	Anomalies []Anomaly
+
+	// Metadata contains a map of the packages in AllChanges to their
+	// metadata, such as their owners. Packages without metadata are omitted.
+	// It is only set when the metadata report is enabled.
+	Metadata map[string]PackageMetadata
+
+	// ReasonChains contains a map of the dependents of changed packages in
+	// AllChanges to the import chain that explains why they are affected: a
+	// shortest chain from the nearest changed package, in which each package
+	// is imported by the next. It is only set when the reason report is
+	// enabled.
+	ReasonChains map[string][]string
+
+	// Meta describes the cost of the analysis. It is only set when the meta
+	// report is enabled.
+	Meta *Meta
+
+	// Partial is true when the GTA's context was done before the dependency
+	// graph was built and partial results are enabled. AllChanges then
+	// contains the changed packages and the prefixes, which stand in for
+	// every package beneath them, instead of the dependents.
+	Partial bool
+}
+
+// Contains reports whether the package with the import path pkgPath is among
+// the changed packages or their dependents. When pkgPath ends with "/...", it
+// reports whether any package in the tree rooted at pkgPath is.
+func (p *Packages) Contains(pkgPath string) bool {
+	for _, pkg := range p.AllChanges {
+		if matchNode([]string{pkgPath}, pkg.ImportPath) {
+			return true
+		}
+	}
+	return false
 }
 
 type packagesJSON struct {
 	Dependencies map[string][]string `json:"dependencies,omitempty"`
 	Changes      []string            `json:"changes,omitempty"`
 	AllChanges   []string            `json:"all_changes,omitempty"`
+	Origins      map[string][]string `json:"origins,omitempty"`
+	APIChanges   map[string][]string `json:"api_changes,omitempty"`
+	Risks        map[string]Risk     `json:"risks,omitempty"`
+	Orphans      []string            `json:"orphans,omitempty"`
+	TagSets      map[string][]string `json:"tag_sets,omitempty"`
+	Platforms    map[string][]string `json:"platforms,omitempty"`
+
+	BuildAffected []string `json:"build_affected,omitempty"`
+	TestAffected  []string `json:"test_affected,omitempty"`
+
+	BlastRadii map[string]BlastRadius `json:"blast_radii,omitempty"`
+
+	ChangeKinds map[string]ChangeKind   `json:"change_kinds,omitempty"`
+	FileChanges map[string][]FileChange `json:"file_changes,omitempty"`
+
+	InternalScopes       map[string]string   `json:"internal_scopes,omitempty"`
+	VisibilityViolations map[string][]string `json:"visibility_violations,omitempty"`
+	ModulePathChanges    []ModulePathChange  `json:"module_path_changes,omitempty"`
+	Targets              []string            `json:"targets,omitempty"`
+
+	LoadErrors LoadErrors `json:"load_errors,omitempty"`
+	Anomalies  []Anomaly  `json:"anomalies,omitempty"`
+	Meta       *Meta      `json:"meta,omitempty"`
+
+	Metadata map[string]PackageMetadata `json:"metadata,omitempty"`
+
+	ReasonChains map[string][]string `json:"reason_chains,omitempty"`
+
+	Partial bool `json:"partial,omitempty"`
+}
+
+// JSONFields returns the names of the fields of the json encoding of Packages,
+// in the order they are encoded.
+func JSONFields() []string {
+	t := reflect.TypeOf(packagesJSON{})
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		fields = append(fields, name)
+	}
+	return fields
 }
 
 // MarshalJSON implements the json.Marshaler interface.
@@ -51,6 +237,33 @@ func (p *Packages) MarshalJSON() ([]byte, error) {
 		Dependencies: mapify(p.Dependencies),
 		Changes:      stringify(p.Changes),
 		AllChanges:   stringify(p.AllChanges),
+		Origins:      p.Origins,
+		APIChanges:   p.APIChanges,
+		Risks:        p.Risks,
+		ChangeKinds:  p.ChangeKinds,
+		BlastRadii:   p.BlastRadii,
+		Orphans:      p.Orphans,
+		TagSets:      p.TagSets,
+		Platforms:    p.Platforms,
+		FileChanges:  p.FileChanges,
+
+		BuildAffected: p.BuildAffected,
+		TestAffected:  p.TestAffected,
+
+		InternalScopes:       p.InternalScopes,
+		VisibilityViolations: p.VisibilityViolations,
+		ModulePathChanges:    p.ModulePathChanges,
+		Targets:              p.Targets,
+
+		LoadErrors: p.LoadErrors,
+		Anomalies:  p.Anomalies,
+		Meta:       p.Meta,
+
+		Metadata: p.Metadata,
+
+		ReasonChains: p.ReasonChains,
+
+		Partial: p.Partial,
 	}
 	return json.Marshal(s)
 }
@@ -79,24 +292,138 @@ func (p *Packages) UnmarshalJSON(b []byte) error {
 		p.AllChanges = append(p.AllChanges, Package{ImportPath: v})
 	}
 
+	p.Origins = s.Origins
+	p.APIChanges = s.APIChanges
+	p.Risks = s.Risks
+	p.ChangeKinds = s.ChangeKinds
+	p.FileChanges = s.FileChanges
+	p.BlastRadii = s.BlastRadii
+	p.Orphans = s.Orphans
+	p.TagSets = s.TagSets
+	p.Platforms = s.Platforms
+	p.BuildAffected = s.BuildAffected
+	p.TestAffected = s.TestAffected
+	p.InternalScopes = s.InternalScopes
+	p.VisibilityViolations = s.VisibilityViolations
+	p.ModulePathChanges = s.ModulePathChanges
+	p.Targets = s.Targets
+	p.LoadErrors = s.LoadErrors
+	p.Anomalies = s.Anomalies
+	p.Meta = s.Meta
+	p.Metadata = s.Metadata
+	p.ReasonChains = s.ReasonChains
+	p.Partial = s.Partial
+
 	return nil
 }
 
 // A GTA provides a method of building dirty packages, and their dependent
 // packages.
+//
+// GTAs do not share state: they do not modify global variables, the working
+// directory, or the environment of the process, so GTAs with different
+// configurations can be used concurrently, e.g. by a server that runs many
+// analyses at once. Use SetDir instead of changing the working directory to
+// analyze a different module. A single GTA must not be used concurrently.
 type GTA struct {
-	differ   Differ
-	packager Packager
-	prefixes []string
-	tags     []string
+	differs      []labeledDiffer
+	packager     Packager
+	prefixes     []string
+	modules      []string
+	pkgPatterns  []string
+	repoScope    bool
+	goEnv        map[string]string
+	buildFlags   []string
+	patterns     []string
+	roots        []string
+	direction    Direction
+	maxDepth     int
+	tags         []string
+	tagSets      []TagSet
+	platforms    []string
+	goos         string
+	goarch       string
+	apiReport    bool
+	orphanReport bool
+	anomalyCheck bool
+	kindReport   bool
+	blastReport  bool
+	loadErrMode  LoadErrorMode
+	strict       bool
+	fileReport   bool
+	metaReport   bool
+	infoReport   bool
+	whyReport    bool
+	skipVendored bool
+	splitReport  bool
+	skipComments bool
+	mapBinary    bool
+	resolver     DiffResolver
+	mapper       FileMapper
+	analyzer     ImpactAnalyzer
+	reporter     Reporter
+	partial      bool
+	fixtures     []Fixture
+	migrations   []Migrations
+	specMappings []SpecMapping
+	mappingRules []MappingRule
+	triggerFiles []string
+	testdataMode TestdataMode
+	deployFiles  []string
+	genInputs    bool
+	genMappings  []GenerateMapping
+	loader       Loader
+	cacheDir     string
+	cacheExtras  []string
+	depGraph     *DependencyGraph
+	overlay      map[string][]byte
+	parallelism  int
+	dir          string
+	moduleDir    string
+	memoryLimit  string
+	loadMode     packages.LoadMode
+	loadSyntax   bool
+	symbolImpact bool
+	ctx          context.Context
+	callCtx      context.Context
+
+	// loadTime is the duration of loading the packages of the default
+	// packager, diffTime the duration of the latest diff, and graphTime the
+	// duration of the latest mapping and impact analysis.
+	loadTime  time.Duration
+	diffTime  time.Duration
+	graphTime time.Duration
+
+	// detectedConsumers caches the consumers detected for each fixture by
+	// index.
+	detectedConsumers map[int][]string
+	// detectedMigrationPackages caches the packages detected for each
+	// migrations directory by index.
+	detectedMigrationPackages map[int][]string
+	// detectedGenerators caches the go:generate directives detected when
+	// the detection of generator inputs is enabled.
+	detectedGenerators []generator
+	// referrers caches the packages that embed each file, or that include
+	// each file or directory with cgo, according to the packager.
+	referrers map[string][]string
+	// replaceDirs caches the directories that the replace directives of the
+	// go.mod file of the GTA's module replace modules with.
+	replaceDirs []string
+	// vendored caches the modules vendored in the vendor directory of the
+	// module that contains the GTA's directory.
+	vendored map[string]bool
+	// knownModules caches the paths of the modules the packager knows of,
+	// mapped to whether they are among the GTA's modules.
+	knownModules map[string]bool
+	// excluded caches the packages that negated patterns exclude from the
+	// GTA's patterns.
+	excluded map[string]bool
 }
 
 // New returns a new GTA with various options passed to New. Options will be
 // applied in order so that later options can override earlier options.
 func New(opts ...Option) (*GTA, error) {
-	gta := &GTA{
-		differ: NewGitDiffer(),
-	}
+	gta := &GTA{}
 
 	for _, opt := range opts {
 		err := opt(gta)
@@ -105,31 +432,117 @@ func New(opts ...Option) (*GTA, error) {
 		}
 	}
 
+	if len(gta.differs) == 0 {
+		gta.differs = []labeledDiffer{{differ: NewGitDiffer(SetWorkingDir(gta.dir), SetGitContext(gta.ctx))}}
+	}
+
+	if len(gta.overlay) > 0 {
+		gta.differs = append(gta.differs, labeledDiffer{label: "overlay", differ: &contentsDiffer{overlay: gta.overlay}})
+	}
+
+	if gta.loader == nil {
+		gta.loader = defaultLoader
+	}
+
+	gta.patterns = prefixPatterns(gta.prefixes)
+
 	// set the default packager after applying option so that the default
 	// packager implementation does not load packages unnecessarily when the
 	// packager is provided as an option.
 	if gta.packager == nil {
-		/*
-			patterns, err := patternsFrom(gta.differ, gta.prefixes)
-			if err != nil {
+		// A vendor directory that is out of sync with go.mod is detected before
+		// loading, because the go command reports it as loading errors that do
+		// not point at the cause.
+		cfg := gta.loadConfig()
+		wd, err := gta.workingDir()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkVendor(wd, cfg.Env, cfg.BuildFlags); err != nil {
+			return nil, err
+		}
+
+		if gta.patterns, err = gta.loadPatterns(cfg); err != nil {
+			return nil, fmt.Errorf("reading workspace, %v", err)
+		}
+
+		// When no prefixes are provided, cause NewPackager to return a packager
+		// that loads all packages by passing a nil pattern. This is important to
+		// ensure that all packages are loaded and that nothing is skipped based
+		// on build tag constraints when a file is changed. e.g. if a vendored
+		// file that is constrained to Windows is changed, that package wouldn't
+		// load at all and trying to find the package's dependencies would fail.
+		// In a go.work workspace, all packages are the packages of the
+		// workspace's modules.
+		//
+		// When prefixes are provided, only the trees rooted at the prefixes are
+		// loaded. Dependencies are loaded transitively, so every import chain
+		// from a changed package to a package within the prefixes is part of the
+		// graph, and packages outside the prefixes are never reported.
+		start := time.Now()
+		switch {
+		case gta.depGraph != nil:
+			if gta.packager, err = gta.dependencyGraphPackager(); err != nil {
 				return nil, err
 			}
+		case gta.cacheDir != "" && !gta.loadSyntax:
+			gta.packager = gta.cachedPackager(cfg, gta.buildContext(gta.tags))
+		default:
+			gta.packager = loadPackager(gta.loader, cfg, gta.buildContext(gta.tags), gta.patterns, gta.parallelism)
+		}
+		gta.loadTime = time.Since(start)
+	}
 
-			gta.packager = NewPackager(patterns, gta.tags)
-		*/
-
-		// Cause NewPackager to return a packager that loads all packages by
-		// passing a nil pattern.  This is important to ensure that all packages
-		// are loaded and that nothing is skipped based on build tag constraints
-		// when a file is changed. e.g. if a vendored file that is constrained to
-		// Windows is changed, that package wouldn't load at all and trying to find
-		// the package's dependencies would fail.
-		gta.packager = NewPackager(nil, gta.tags)
+	if gta.strict {
+		if err := gta.strictErrors(); err != nil {
+			return nil, err
+		}
 	}
 
 	return gta, nil
 }
 
+// buildContext returns a copy of the default build context that uses tags,
+// g's directory, and g's platform. The default build context itself is never
+// modified, so that GTAs with different configurations do not affect one
+// another.
+func (g *GTA) buildContext(tags []string) build.Context {
+	ctx := build.Default
+	ctx.BuildTags = tags
+	ctx.Dir = g.dir
+	if g.goos != "" {
+		ctx.GOOS = g.goos
+	}
+	if g.goarch != "" {
+		ctx.GOARCH = g.goarch
+	}
+	overlayContext(&ctx, g.overlay)
+	return ctx
+}
+
+// NewContext is like New, with ctx as the context used to load packages and,
+// when no differ is set, to run git. Options can override it with SetContext.
+func NewContext(ctx context.Context, opts ...Option) (*GTA, error) {
+	return New(append([]Option{SetContext(ctx)}, opts...)...)
+}
+
+// context returns the context of the current call of ChangedPackagesContext,
+// or g's context when there is none. It is nil when neither is set.
+func (g *GTA) context() context.Context {
+	if g.callCtx != nil {
+		return g.callCtx
+	}
+	return g.ctx
+}
+
+// workingDir returns the directory in which g loads packages.
+func (g *GTA) workingDir() (string, error) {
+	if g.dir != "" {
+		return g.dir, nil
+	}
+	return os.Getwd()
+}
+
 // ChangedPackages uses the differ and packager to build a map of changed root
 // packages to their dependent packages where dependent is defined as "changed"
 // as well due to their dependency to the changed packages. It returns the
@@ -152,13 +565,81 @@ func New(opts ...Option) (*GTA, error) {
 //   Changes      = ["foo", "foo2"]
 //   AllChanges   = ["foo", "foo2", "afa", "bar", "qux]
 func (g *GTA) ChangedPackages() (*Packages, error) {
-	paths, err := g.markedPackages()
+	cp, _, err := g.changedPackages()
+	return cp, err
+}
+
+// ChangedPackagesContext is like ChangedPackages, with ctx as the context of
+// the call: the commands of the differs that are DifferContexts, such as the
+// git differ, and the packages loaded for tag sets, are cancelled when ctx is
+// done, and an error is returned. The packages loaded by New are not affected;
+// use NewContext to cancel them.
+func (g *GTA) ChangedPackagesContext(ctx context.Context) (*Packages, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	g.callCtx = ctx
+	defer func() {
+		g.callCtx = nil
+	}()
+
+	cp, _, err := g.changedPackages()
 	if err != nil {
 		return nil, err
 	}
+	// the stages that do not run commands are not interrupted.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+// DependencyGraph returns the dependent graph of g's packager, in which each
+// package is adjacent to the packages that import it, without determining any
+// changes, e.g. to answer queries about the graph.
+func (g *GTA) DependencyGraph() (*Graph, error) {
+	if g.packager == nil {
+		return nil, ErrNoPackager
+	}
+	return g.packager.DependentGraph()
+}
+
+// changedPackages returns the changed packages along with the marks they were
+// built from.
+func (g *GTA) changedPackages() (*Packages, *marks, error) {
+	start := time.Now()
+	g.diffTime = 0
+	impact, err := g.impact()
+	if err != nil {
+		return nil, nil, err
+	}
+	g.graphTime = time.Since(start) - g.diffTime
+
+	var reporter Reporter = g
+	if g.reporter != nil {
+		reporter = g.reporter
+	}
+	cp, err := reporter.Report(impact)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cp, impact.marks(), nil
+}
+
+// Report is the default Reporter of a GTA. It returns the changed packages
+// and their dependents in impact, along with the reports that are enabled.
+func (g *GTA) Report(impact *Impact) (*Packages, error) {
+	if g.packager == nil {
+		return nil, ErrNoPackager
+	}
+
+	start := time.Now()
+	m := impact.marks()
 
 	cp := &Packages{
 		Dependencies: map[string][]Package{},
+		Partial:      m.partial,
 	}
 
 	packageFromImport := func(path string) (*Package, error) {
@@ -170,13 +651,32 @@ func (g *GTA) ChangedPackages() (*Packages, error) {
 		return pkg, nil
 	}
 
+	var vendored map[string]bool
+	if g.skipVendored {
+		var err error
+		vendored, err = g.vendoredModules()
+		if err != nil {
+			return nil, fmt.Errorf("reading vendored modules, %v", err)
+		}
+	}
+
 	// build our packages
 	allChanges := map[string]Package{}
-	for changed, marked := range paths {
+	allOrigins := map[string]map[string]struct{}{}
+	for changed, marked := range m.paths {
 		var packages []Package
 
 		// add any dependents of the changed package; the changed package will be included in marked.
 		for path, check := range marked {
+			// packages outside of the prefixes may not have been loaded, so skip
+			// them before trying to look them up.
+			if !g.included(path) {
+				continue
+			}
+			if moduleOf(path, vendored) != "" {
+				continue
+			}
+
 			pkg := new(Package)
 			pkg.ImportPath = path
 
@@ -190,6 +690,12 @@ func (g *GTA) ChangedPackages() (*Packages, error) {
 
 			addPackage := func(pkg Package) {
 				allChanges[pkg.ImportPath] = pkg
+				for label := range m.origins[changed] {
+					if _, ok := allOrigins[pkg.ImportPath]; !ok {
+						allOrigins[pkg.ImportPath] = make(map[string]struct{})
+					}
+					allOrigins[pkg.ImportPath][label] = struct{}{}
+				}
 				if changed == pkg.ImportPath {
 					cp.Changes = append(cp.Changes, pkg)
 				} else {
@@ -197,9 +703,7 @@ func (g *GTA) ChangedPackages() (*Packages, error) {
 				}
 			}
 
-			if hasPrefixIn(pkg.ImportPath, g.prefixes) {
-				addPackage(*pkg)
-			}
+			addPackage(*pkg)
 		}
 
 		if len(packages) != 0 {
@@ -214,48 +718,392 @@ func (g *GTA) ChangedPackages() (*Packages, error) {
 	sort.Sort(byPackageImportPath(cp.AllChanges))
 	sort.Sort(byPackageImportPath(cp.Changes))
 
+	if len(allOrigins) != 0 {
+		cp.Origins = make(map[string][]string, len(allOrigins))
+		for importPath, labels := range allOrigins {
+			for label := range labels {
+				cp.Origins[importPath] = append(cp.Origins[importPath], label)
+			}
+			sort.Strings(cp.Origins[importPath])
+		}
+	}
+
+	g.reportVisibility(cp, m)
+	cp.ModulePathChanges = m.modulePathChanges
+	cp.Targets = m.targets
+
+	if g.kindReport {
+		cp.ChangeKinds = changeKinds(m)
+	}
+
+	if g.fileReport {
+		fileChanges, err := g.fileChangeReport(m)
+		if err != nil {
+			return nil, fmt.Errorf("reporting file changes, %v", err)
+		}
+		cp.FileChanges = fileChanges
+	}
+
+	// the reports require the packages that could not be loaded.
+	if m.partial {
+		g.reportMeta(cp, m, g.graphTime, time.Since(start))
+		return cp, nil
+	}
+
+	if g.loadErrMode == LoadErrorsWarn || g.loadErrMode == LoadErrorsFail {
+		loadErrs, err := g.loadErrors()
+		if err != nil {
+			return nil, fmt.Errorf("reporting load errors, %v", err)
+		}
+		if len(loadErrs) > 0 && g.loadErrMode == LoadErrorsFail {
+			return nil, loadErrs
+		}
+		if len(loadErrs) > 0 {
+			cp.LoadErrors = loadErrs
+		}
+	}
+
+	if g.apiReport {
+		if err := g.reportAPI(cp, m); err != nil {
+			return nil, fmt.Errorf("reporting API changes, %v", err)
+		}
+	}
+
+	if g.blastReport {
+		radii, err := g.blastRadii(cp)
+		if err != nil {
+			return nil, fmt.Errorf("reporting blast radii, %v", err)
+		}
+		cp.BlastRadii = radii
+	}
+
+	if g.orphanReport {
+		orphans, err := g.orphans(m.graph)
+		if err != nil {
+			return nil, fmt.Errorf("reporting orphans, %v", err)
+		}
+		cp.Orphans = orphans
+	}
+
+	if g.infoReport {
+		metadata, err := g.metadata(cp)
+		if err != nil {
+			return nil, fmt.Errorf("reporting metadata, %v", err)
+		}
+		cp.Metadata = metadata
+	}
+
+	if g.whyReport {
+		cp.ReasonChains = g.reasonChains(cp, m)
+	}
+
+	if g.splitReport {
+		build, test, err := g.buildTestAffected(cp, m)
+		if err != nil {
+			return nil, fmt.Errorf("reporting build and test affected packages, %v", err)
+		}
+		cp.BuildAffected, cp.TestAffected = build, test
+	}
+
+	if g.anomalyCheck {
+		anomalies, err := g.anomalies()
+		if err != nil {
+			return nil, fmt.Errorf("reporting anomalies, %v", err)
+		}
+		cp.Anomalies = anomalies
+	}
+
+	if len(g.tagSets) > 0 {
+		tagSets, err := g.tagSetPackages()
+		if err != nil {
+			return nil, fmt.Errorf("reporting tag sets, %v", err)
+		}
+		cp.TagSets = tagSets
+	}
+
+	if len(g.platforms) > 0 {
+		platforms, err := g.platformPackages()
+		if err != nil {
+			return nil, fmt.Errorf("reporting platforms, %v", err)
+		}
+		cp.Platforms = platforms
+	}
+
+	g.reportMeta(cp, m, g.graphTime, time.Since(start))
 	return cp, nil
 }
 
-// markedPackages returns a map of maps. The outer map's key is the import path
-// of a package that was changed according to g.differ. The inner maps' (i.e.
-// the values of the outer map) keys are import paths of the dependents of the
-// packages in respective key of the outer map. The inner maps' boolean values
-// are true when the respective package exists and false when the respective
-// package was deleted.
-func (g *GTA) markedPackages() (map[string]map[string]bool, error) {
-	if g.differ == nil {
-		return nil, ErrNoDiffer
+// traversed returns the graphs that are traversed from the changed packages
+// according to g's direction, given the dependent graph.
+func (g *GTA) traversed(graph *Graph) []*Graph {
+	switch g.direction {
+	case DependenciesDirection:
+		return []*Graph{graph.Reversed()}
+	case BothDirections:
+		return []*Graph{graph, graph.Reversed()}
 	}
-	if g.packager == nil {
-		return nil, ErrNoPackager
+	return []*Graph{graph}
+}
+
+// marks describes the packages that are dirty due to the changes reported by
+// a GTA's differs.
+type marks struct {
+	// paths is a map of maps. The outer map's key is the import path of a
+	// package that was changed. The inner maps' (i.e. the values of the outer
+	// map) keys are import paths of the dependents of the packages in
+	// respective key of the outer map. The inner maps' boolean values are true
+	// when the respective package exists and false when the respective package
+	// was deleted.
+	paths map[string]map[string]bool
+	// origins is a set of the labels of the differs that reported changes for
+	// each changed package.
+	origins map[string]map[string]struct{}
+	// files contains the absolute paths of the changed files of each changed
+	// package.
+	files map[string][]string
+	// graph is the dependent graph that was traversed to find the dependents.
+	// It is nil when partial is true.
+	graph *Graph
+	// partial is true when the dependency graph could not be built before the
+	// GTA's context was done, so paths contains a conservative superset of the
+	// dependents.
+	partial bool
+	// modulePathChanges are the changes to the module paths of go.mod files.
+	modulePathChanges []ModulePathChange
+	// targets are the sorted pseudo-targets of the changed files.
+	targets []string
+}
+
+// markedPackages returns the packages that were changed according to g's
+// differs and their dependents.
+func (g *GTA) markedPackages() (*marks, error) {
+	impact, err := g.impact()
+	if err != nil {
+		return nil, err
+	}
+	return impact.marks(), nil
+}
+
+// ResolveDiff is the default DiffResolver of a GTA. It returns the union of
+// the changes reported by the GTA's differs, without the Go files whose only
+// changes are to comments when they are ignored.
+func (g *GTA) ResolveDiff() (*Diff, error) {
+	for _, ld := range g.differs {
+		if ld.differ == nil {
+			return nil, ErrNoDiffer
+		}
 	}
 
 	// get our diff'd directories
-	dirs, err := g.differ.Diff()
+	start := time.Now()
+	dirs, labels, err := g.diff()
 	if err != nil {
-		return nil, fmt.Errorf("diffing directory for dirty packages, %v", err)
+		return nil, err
+	}
+	g.diffTime = time.Since(start)
+
+	if g.skipComments {
+		dirs, err = g.dropCommentChanges(dirs)
+		if err != nil {
+			return nil, fmt.Errorf("dropping comment changes, %v", err)
+		}
+	}
+
+	var binary map[string]struct{}
+	if g.mapBinary {
+		binary, err = g.binaryFiles()
+		if err != nil {
+			return nil, fmt.Errorf("finding binary files, %v", err)
+		}
+	}
+
+	return &Diff{Dirs: dirs, Labels: labels, Binary: binary}, nil
+}
+
+// MapFiles is the default FileMapper of a GTA. It maps the changed files in
+// diff to the packages that contain them, the packages that consume them as
+// shared test fixtures, and the packages that the GTA's migrations and
+// specification mappings map them to.
+func (g *GTA) MapFiles(diff *Diff) (*Mapping, error) {
+	if g.packager == nil {
+		return nil, ErrNoPackager
+	}
+	binary := diff.Binary
+
+	// origins is a set of differ labels for each changed package.
+	origins := make(map[string]map[string]struct{})
+	// files contains the changed files of each changed package.
+	files := make(map[string][]string)
+	addChanged := func(importPath, abs string, dir Directory) {
+		for _, label := range diff.Labels[abs] {
+			if _, ok := origins[importPath]; !ok {
+				origins[importPath] = make(map[string]struct{})
+			}
+			origins[importPath][label] = struct{}{}
+		}
+		for _, fn := range dir.Files {
+			files[importPath] = append(files[importPath], filepath.Join(abs, fn))
+		}
 	}
 
 	// we build our set of initial dirty packages from the git diff. The map
 	// value is true when the package was deleted.
 	changed := make(map[string]bool)
-	for abs, dir := range dirs {
-		// TODO(bc): handle changes to go.mod when vendoring is not being used.
+	// fixtureChanges is a set of packages whose shared test fixtures changed.
+	fixtureChanges := make(map[string]struct{})
+	var modulePathChanges []ModulePathChange
+	// targets is a set of the pseudo-targets of the changed files.
+	targets := make(map[string]struct{})
+	for abs, dir := range diff.Dirs {
+		for _, fn := range dir.Files {
+			for _, target := range g.ruleTargets(filepath.Join(abs, fn)) {
+				targets[target] = struct{}{}
+			}
+
+			consumers, err := g.fixtureConsumers(filepath.Join(abs, fn))
+			if err != nil {
+				return nil, fmt.Errorf("finding fixture consumers, %v", err)
+			}
+
+			for _, consumer := range consumers {
+				fixtureChanges[consumer] = struct{}{}
+				addChanged(consumer, abs, Directory{Files: []string{fn}})
+			}
+
+			pkgs, err := g.mappedPackages(filepath.Join(abs, fn))
+			if err != nil {
+				return nil, fmt.Errorf("finding mapped packages, %v", err)
+			}
+
+			for _, pkg := range pkgs {
+				if _, ok := changed[pkg]; !ok {
+					changed[pkg] = false
+				}
+				addChanged(pkg, abs, Directory{Files: []string{fn}})
+			}
+
+			// trigger files change every package, which leaves no dependents
+			// to be found.
+			triggered, err := g.triggerPackages(filepath.Join(abs, fn))
+			if err != nil {
+				return nil, fmt.Errorf("finding triggered packages, %v", err)
+			}
+
+			for _, pkg := range triggered {
+				if _, ok := changed[pkg]; !ok {
+					changed[pkg] = false
+				}
+				addChanged(pkg, abs, Directory{Files: []string{fn}})
+			}
+
+			// embedded files and files included with cgo change the packages
+			// that refer to them, even when they are not within the packages'
+			// directories.
+			referrers, err := g.referringPackages(filepath.Join(abs, fn))
+			if err != nil {
+				return nil, fmt.Errorf("finding referring packages, %v", err)
+			}
+
+			for _, pkg := range referrers {
+				if _, ok := changed[pkg]; !ok {
+					changed[pkg] = false
+				}
+				addChanged(pkg, abs, Directory{Files: []string{fn}})
+			}
+		}
+
+		// binary files only change the packages they are mapped to.
+		if len(binary) > 0 {
+			dir = withoutBinaryFiles(abs, dir, binary)
+			if len(dir.Files) == 0 {
+				continue
+			}
+		}
+
+		// Go and assembly files only change the packages of the platforms for
+		// which they are built.
+		if g.goos != "" || g.goarch != "" {
+			dir = g.withoutForeignFiles(abs, dir)
+			if len(dir.Files) == 0 {
+				continue
+			}
+		}
+
+		// only the files within the module, and within the directories that
+		// its replace directives replace modules with, belong to its packages.
+		if g.moduleDir != "" && !withinDir(abs, g.moduleDir) {
+			replaced, err := g.replacedDir(abs)
+			if err != nil {
+				return nil, fmt.Errorf("reading replace directives, %v", err)
+			}
+			if !replaced {
+				continue
+			}
+		}
+
+		// files within testdata directories are read by the tests of the
+		// package that owns the directory, rather than belonging to a package.
+		if importPath, ok := g.testdataPackage(abs); ok {
+			if importPath == "" || g.testdataMode == TestdataIgnore {
+				continue
+			}
+			if g.testdataMode == TestdataDependents {
+				if _, ok := changed[importPath]; !ok {
+					changed[importPath] = false
+				}
+			} else {
+				fixtureChanges[importPath] = struct{}{}
+			}
+			addChanged(importPath, abs, dir)
+			continue
+		}
+
+		// build and deployment files only change the packages in their
+		// directories, whose dependents are not built from them.
+		if len(g.deployFiles) > 0 {
+			var deploy []string
+			dir, deploy = g.splitDeployFiles(abs, dir)
+			if len(deploy) > 0 {
+				if pkg, err := g.packager.PackageFromDir(abs); err == nil {
+					fixtureChanges[pkg.ImportPath] = struct{}{}
+					addChanged(pkg.ImportPath, abs, Directory{Files: deploy})
+				}
+			}
+			if len(dir.Files) == 0 {
+				continue
+			}
+		}
+
+		// a change to the requirements of a go.mod file changes the packages that
+		// import the modules whose requirements changed, and a change to its
+		// module path changes all of the packages of the module.
+		if dir.Exists && hasFile(dir.Files, "go.mod") {
+			pkgs, change, err := g.goModPackages(filepath.Join(abs, "go.mod"))
+			if err != nil {
+				return nil, fmt.Errorf("finding packages affected by go.mod, %v", err)
+			}
+			if change != nil {
+				modulePathChanges = append(modulePathChanges, *change)
+			}
+
+			for _, pkg := range pkgs {
+				if _, ok := changed[pkg]; !ok {
+					changed[pkg] = false
+				}
+				addChanged(pkg, abs, Directory{Files: []string{"go.mod"}})
+			}
+		}
 
 		// ignore deleted directories that contained no go files.
-		// TODO(bc): make sure it was not within a testdata directory.
 		if !dir.Exists && !hasGoFile(dir.Files) {
 			continue
 		}
 
-		// Avoid .foo, _foo, and testdata directory trees how the go tool does!
+		// Avoid .foo and _foo directory trees how the go tool does!
 		// See https://github.com/golang/tools/blob/3a85b8d/go/buildutil/allpackages.go#L93
 		// Above link is not guaranteed to work.
 		base := filepath.Base(abs)
-		parent := filepath.Base(filepath.Dir(abs))
-		// TODO(bc): do not ignore testdata directories - use their parent instead.
-		if base == "" || base[0] == '.' || base[0] == '_' || base == "testdata" || parent == "testdata" {
+		if base == "" || base[0] == '.' || base[0] == '_' {
 			continue
 		}
 
@@ -264,13 +1112,14 @@ func (g *GTA) markedPackages() (map[string]map[string]bool, error) {
 			switch err.(type) {
 			case *build.NoGoError:
 				if hasGoFile(dir.Files) {
-					importPath, err := g.findImportPath(abs)
+					importPath, err := g.deletedImportPath(abs)
 					if err != nil {
 						continue
 					}
 					pkg.ImportPath = importPath
 
 					changed[pkg.ImportPath] = true
+					addChanged(pkg.ImportPath, abs, dir)
 					continue
 				}
 				// there are and were no buildable go files in this directory
@@ -281,11 +1130,12 @@ func (g *GTA) markedPackages() (map[string]map[string]bool, error) {
 				continue
 			default:
 				if !dir.Exists && hasGoFile(dir.Files) {
-					importPath, err := g.findImportPath(abs)
+					importPath, err := g.deletedImportPath(abs)
 					if err != nil {
 						continue
 					}
 					changed[importPath] = true
+					addChanged(importPath, abs, dir)
 					continue
 				}
 			}
@@ -294,20 +1144,98 @@ func (g *GTA) markedPackages() (map[string]map[string]bool, error) {
 
 		// create a simple set of changed pkgs by import path
 		changed[pkg.ImportPath] = false
+		addChanged(pkg.ImportPath, abs, dir)
 	}
 
+	mapping := &Mapping{
+		Changed:          changed,
+		Files:            files,
+		FixtureConsumers: make([]string, 0, len(fixtureChanges)),
+
+		ModulePathChanges: modulePathChanges,
+	}
+	if len(targets) != 0 {
+		mapping.Targets = sortedSet(targets)
+	}
+	sort.Slice(mapping.ModulePathChanges, func(i, j int) bool {
+		return mapping.ModulePathChanges[i].Dir < mapping.ModulePathChanges[j].Dir
+	})
+	for consumer := range fixtureChanges {
+		mapping.FixtureConsumers = append(mapping.FixtureConsumers, consumer)
+	}
+	sort.Strings(mapping.FixtureConsumers)
+	if len(origins) != 0 {
+		mapping.Origins = make(map[string][]string, len(origins))
+		for importPath, labels := range origins {
+			mapping.Origins[importPath] = sortedSet(labels)
+		}
+	}
+	return mapping, nil
+}
+
+// AnalyzeImpact is the default ImpactAnalyzer of a GTA. It traverses the
+// dependency graph of the GTA's packager from the changed packages in mapping,
+// in the GTA's direction, to find the packages they affect.
+func (g *GTA) AnalyzeImpact(mapping *Mapping) (*Impact, error) {
+	if g.packager == nil {
+		return nil, ErrNoPackager
+	}
+	changed := mapping.Changed
+
 	// we build the dependent graph
 	graph, err := g.packager.DependentGraph()
 	if err != nil {
+		if ctx := g.context(); g.partial && len(g.prefixes) > 0 && ctx != nil && ctx.Err() != nil {
+			return g.partialImpact(mapping), nil
+		}
 		return nil, fmt.Errorf("building dependency graph, %v", err)
 	}
 
+	traversed := g.traversed(graph)
+	precise, err := g.preciseDependents(graph, mapping)
+	if err != nil {
+		return nil, err
+	}
+
 	paths := map[string]map[string]bool{}
 	for change := range changed {
 		marked := make(map[string]bool)
 
-		// we traverse the graph and build our list of mark all dependents
-		graph.Traverse(change, marked)
+		// we traverse the graphs and build our list of mark all dependents. Each
+		// graph is traversed with its own marks, because the traversal stops at
+		// nodes that are already marked, such as the changed package.
+		for _, tg := range traversed {
+			m := make(map[string]bool)
+			if dependents, ok := precise[change]; ok && tg == graph {
+				// only the dependents that refer to the changed identifiers, and
+				// their own dependents, are affected.
+				m[change] = true
+				for _, dependent := range dependents {
+					if g.maxDepth > 0 {
+						// a depth of zero is unlimited, so the dependents are
+						// only traversed when they are not at the maximum depth.
+						m[dependent] = true
+						if g.maxDepth > 1 {
+							for _, importPath := range tg.Reachable(dependent, g.maxDepth-1) {
+								m[importPath] = true
+							}
+						}
+						continue
+					}
+					tg.Traverse(dependent, m)
+				}
+			} else if g.maxDepth > 0 {
+				m[change] = true
+				for _, importPath := range tg.Reachable(change, g.maxDepth) {
+					m[importPath] = true
+				}
+			} else {
+				tg.Traverse(change, m)
+			}
+			for importPath := range m {
+				marked[importPath] = true
+			}
+		}
 
 		// clear the boolean value on the paths that no longer contain packages (i.e.
 		// the Go files were deleted...).
@@ -320,7 +1248,97 @@ func (g *GTA) markedPackages() (map[string]map[string]bool, error) {
 		paths[change] = marked
 	}
 
-	return paths, nil
+	// the dependents of the consumers of fixtures are not affected by changes
+	// to the fixtures.
+	for _, consumer := range mapping.FixtureConsumers {
+		if _, ok := paths[consumer]; ok {
+			continue
+		}
+		paths[consumer] = map[string]bool{consumer: true}
+	}
+
+	// only the packages that the roots depend on are of interest; when a
+	// changed package is not among them, neither are its dependents.
+	if len(g.roots) > 0 {
+		closure := graph.closure(g.roots)
+		for change, marked := range paths {
+			if !closure[change] {
+				delete(paths, change)
+				continue
+			}
+			for importPath := range marked {
+				if !closure[importPath] {
+					delete(marked, importPath)
+				}
+			}
+		}
+	}
+
+	return &Impact{
+		Affected: paths,
+		Origins:  mapping.Origins,
+		Files:    mapping.Files,
+		Graph:    graph,
+
+		ModulePathChanges: mapping.ModulePathChanges,
+		Targets:           mapping.Targets,
+	}, nil
+}
+
+// partialImpact returns the impact of the changed packages in mapping when
+// the dependency graph could not be built. Without the graph the dependents of
+// the changed packages are unknown, so every changed package is marked with
+// all of g's prefixes, which is a conservative superset of its dependents.
+func (g *GTA) partialImpact(mapping *Mapping) *Impact {
+	paths := make(map[string]map[string]bool, len(mapping.Changed))
+	for change := range mapping.Changed {
+		// the packages cannot be looked up without the graph, so none of them
+		// are checked.
+		marked := map[string]bool{change: false}
+		for _, prefix := range g.prefixes {
+			marked[prefix] = false
+		}
+		paths[change] = marked
+	}
+
+	return &Impact{
+		Affected: paths,
+		Origins:  mapping.Origins,
+		Files:    mapping.Files,
+		Partial:  true,
+
+		ModulePathChanges: mapping.ModulePathChanges,
+		Targets:           mapping.Targets,
+	}
+}
+
+// diff returns the union of the directories changed according to each of g's
+// differs. The second map returned contains the labels of the differs that
+// reported changes for each directory.
+func (g *GTA) diff() (map[string]Directory, map[string][]string, error) {
+	dirs := make(map[string]Directory)
+	labels := make(map[string][]string)
+	for _, ld := range g.differs {
+		var diffed map[string]Directory
+		var err error
+		if g.callCtx != nil {
+			diffed, err = diffContext(g.callCtx, ld.differ)
+		} else {
+			diffed, err = ld.differ.Diff()
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("diffing directory for dirty packages, %w", err)
+		}
+
+		if ld.label != "" {
+			for abs := range diffed {
+				labels[abs] = append(labels[abs], ld.label)
+			}
+		}
+		mergeDirs(dirs, diffed)
+	}
+
+	return dirs, labels, nil
 }
 
 var errImportPathNotFound = errors.New("could not find import path")
@@ -381,6 +1399,34 @@ func mapify(pkgs map[string][]Package) map[string][]string {
 	return out
 }
 
+// mergeFiles returns a with the values of b that are not already in a
+// appended.
+func mergeFiles(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a))
+	for _, fn := range a {
+		seen[fn] = struct{}{}
+	}
+
+	for _, fn := range b {
+		if _, ok := seen[fn]; ok {
+			continue
+		}
+		seen[fn] = struct{}{}
+		a = append(a, fn)
+	}
+	return a
+}
+
+// hasFile reports whether files contains the file named name.
+func hasFile(files []string, name string) bool {
+	for _, fn := range files {
+		if fn == name {
+			return true
+		}
+	}
+	return false
+}
+
 func hasGoFile(files []string) bool {
 	for _, fn := range files {
 		if filepath.Ext(fn) == ".go" {
@@ -7,6 +7,7 @@ in the LICENSE file.
 package gta
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,10 +15,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/digitalocean/gta/gtatest"
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/go/packages/packagestest"
 )
 
@@ -323,7 +327,7 @@ func TestGTA_ChangedPackages(t *testing.T) {
 			}
 			defer AllSetenv(t, e.Config.Env)()
 
-			sut, err := New(SetDiffer(difr), SetPackager(newPackager(e.Config, build.Default, []string{testModule + "/"})))
+			sut, err := New(SetDiffer(difr), SetPackager(newPackager(defaultLoader, e.Config, build.Default, []string{testModule + "/"})))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -627,6 +631,479 @@ func TestGTA_Prefix(t *testing.T) {
 	}
 }
 
+func TestGTA_Roots(t *testing.T) {
+	// A depends on B and foo
+	// B depends on C and bar
+	// cmd/x depends on B
+	// cmd/y depends on foo
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirC":   Directory{Exists: true},
+			"dirFoo": Directory{Exists: true},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"C": map[string]bool{
+				"B": true,
+			},
+			"B": map[string]bool{
+				"A":     true,
+				"cmd/x": true,
+			},
+			"foo": map[string]bool{
+				"A":     true,
+				"cmd/y": true,
+			},
+			"bar": map[string]bool{
+				"B": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA":    "A",
+			"dirB":    "B",
+			"dirC":    "C",
+			"dirFoo":  "foo",
+			"dirBar":  "bar",
+			"dirCmdX": "cmd/x",
+			"dirCmdY": "cmd/y",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	tests := []struct {
+		desc  string
+		roots []string
+		want  []Package
+	}{
+		{
+			desc:  "single root",
+			roots: []string{"cmd/x"},
+			want: []Package{
+				Package{ImportPath: "B"},
+				Package{ImportPath: "C"},
+				Package{ImportPath: "cmd/x"},
+			},
+		},
+		{
+			desc:  "pattern",
+			roots: []string{"cmd/..."},
+			want: []Package{
+				Package{ImportPath: "B"},
+				Package{ImportPath: "C"},
+				Package{ImportPath: "cmd/x"},
+				Package{ImportPath: "cmd/y"},
+				Package{ImportPath: "foo"},
+			},
+		},
+		{
+			desc:  "unaffected root",
+			roots: []string{"bar"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetRoots(tt.roots...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gta.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, pkgs.AllChanges); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGTA_Direction(t *testing.T) {
+	// A depends on B depends on C
+	// D depends on B
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"C": map[string]bool{
+				"B": true,
+			},
+			"B": map[string]bool{
+				"A": true,
+				"D": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+			"dirD": "D",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	tests := []struct {
+		direction Direction
+		want      []Package
+	}{
+		{
+			direction: DependentsDirection,
+			want: []Package{
+				Package{ImportPath: "A"},
+				Package{ImportPath: "B"},
+				Package{ImportPath: "D"},
+			},
+		},
+		{
+			direction: DependenciesDirection,
+			want: []Package{
+				Package{ImportPath: "B"},
+				Package{ImportPath: "C"},
+			},
+		},
+		{
+			direction: BothDirections,
+			want: []Package{
+				Package{ImportPath: "A"},
+				Package{ImportPath: "B"},
+				Package{ImportPath: "C"},
+				Package{ImportPath: "D"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetDirection(tt.direction))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gta.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, pkgs.AllChanges); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := New(SetDiffer(difr), SetPackager(pkgr), SetDirection("sideways")); err == nil {
+		t.Error("expected an error for an unknown direction")
+	}
+}
+
+func TestGTA_MaxDepth(t *testing.T) {
+	// A depends on B depends on C depends on D
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirD": Directory{Exists: true},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+			"dirD": "D",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"D": map[string]bool{"C": true},
+				"C": map[string]bool{"B": true},
+				"B": map[string]bool{"A": true},
+			},
+		},
+		errs: make(map[string]error),
+	}
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{maxDepth: 0, want: []string{"A", "B", "C", "D"}},
+		{maxDepth: 1, want: []string{"C", "D"}},
+		{maxDepth: 2, want: []string{"B", "C", "D"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.maxDepth), func(t *testing.T) {
+			gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetMaxDepth(tt.maxDepth))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gta.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := New(SetDiffer(difr), SetPackager(pkgr), SetMaxDepth(-1)); err == nil {
+		t.Error("expected an error for a negative max depth")
+	}
+}
+
+func TestGTA_Partial(t *testing.T) {
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pkgr := &errGraphPackager{
+		testPackager: &testPackager{
+			dirs2Imports: map[string]string{
+				"dirB": "example.com/B",
+			},
+			errs: make(map[string]error),
+		},
+		err: ctx.Err(),
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{
+			"example.com/B": []Package{
+				{ImportPath: "example.com/cmd"},
+				{ImportPath: "example.com/internal"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "example.com/B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "example.com/B"},
+			{ImportPath: "example.com/cmd"},
+			{ImportPath: "example.com/internal"},
+		},
+		Partial: true,
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetPrefixes("example.com/cmd", "example.com/internal", "example.com/B"), SetContext(ctx), SetPartial(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	gta, err = New(SetDiffer(difr), SetPackager(pkgr), SetPrefixes("example.com/cmd"), SetContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error when partial results are not enabled")
+	}
+}
+
+func TestGTA_Concurrent(t *testing.T) {
+	defaultTags := build.Default.BuildTags
+
+	// each analysis loads a package named after its tag from its own
+	// directory, which depends on a package shared by all of them.
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		tag := strings.TrimPrefix(cfg.BuildFlags[0], "-tags=")
+		if want := filepath.Join("/repo", tag); cfg.Dir != want {
+			return nil, fmt.Errorf("Dir = %q; want %q", cfg.Dir, want)
+		}
+		shared := &packages.Package{
+			ID:      "example.com/shared",
+			PkgPath: "example.com/shared",
+			GoFiles: []string{"/repo/shared/shared.go"},
+		}
+		return []*packages.Package{
+			shared,
+			{
+				ID:      "example.com/" + tag,
+				PkgPath: "example.com/" + tag,
+				GoFiles: []string{filepath.Join("/repo", tag, tag+".go")},
+				Imports: map[string]*packages.Package{"example.com/shared": shared},
+			},
+		}, nil
+	})
+
+	const n = 50
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		tag := fmt.Sprintf("tag%d", i)
+		go func() {
+			errs <- func() error {
+				gta, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetDir(filepath.Join("/repo", tag)), SetPrefixes("example.com"), SetTags(tag))
+				if err != nil {
+					return err
+				}
+
+				if got := gta.packager.(*packageContext).ctx.BuildTags; len(got) != 1 || got[0] != tag {
+					return fmt.Errorf("BuildTags = %v; want [%s]", got, tag)
+				}
+
+				graph, err := gta.packager.DependentGraph()
+				if err != nil {
+					return err
+				}
+				want := map[string]map[string]bool{
+					"example.com/shared": {"example.com/" + tag: true},
+				}
+				if diff := cmp.Diff(want, graph.graph); diff != "" {
+					return fmt.Errorf("%s: (-want, +got)\n%s", tag, diff)
+				}
+				return nil
+			}()
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if diff := cmp.Diff(defaultTags, build.Default.BuildTags); diff != "" {
+		t.Errorf("build.Default.BuildTags was modified (-want, +got)\n%s", diff)
+	}
+}
+
+// errGraphPackager is a testPackager that fails to build the dependency graph.
+type errGraphPackager struct {
+	*testPackager
+	err error
+}
+
+func (p *errGraphPackager) DependentGraph() (*Graph, error) {
+	return nil, p.err
+}
+
+func TestGTA_AddDiffer(t *testing.T) {
+	// A depends on B depends on C
+	// D depends on C
+	// E is not depended on
+	gitDiffer := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true, Files: []string{"b.go"}},
+		},
+	}
+	codegenDiffer := &testDiffer{
+		diff: map[string]Directory{
+			"dirC": Directory{Exists: true, Files: []string{"c.pb.go"}},
+			"dirE": Directory{Exists: true, Files: []string{"e.pb.go"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"C": map[string]bool{
+				"B": true,
+				"D": true,
+			},
+			"B": map[string]bool{
+				"A": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+			"dirD": "D",
+			"dirE": "E",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	want := map[string][]string{
+		"A": []string{"codegen", "git"},
+		"B": []string{"codegen", "git"},
+		"C": []string{"codegen"},
+		"D": []string{"codegen"},
+		"E": []string{"codegen"},
+	}
+
+	gta, err := New(AddDiffer("git", gitDiffer), AddDiffer("codegen", codegenDiffer), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, pkgs.Origins); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_GitRepo(t *testing.T) {
+	// b depends on a
+	// c depends on b
+	repo := gtatest.NewRepo(t, "example.com/repo", map[string]string{
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n\nimport _ \"example.com/repo/a\"\n",
+		"c/c.go": "package c\n\nimport _ \"example.com/repo/b\"\n",
+		"d/d.go": "package d\n",
+	})
+
+	repo.Checkout("change")
+	repo.WriteFile("b/b.go", "package b\n\nimport _ \"example.com/repo/a\"\n\nvar B int\n")
+	repo.Commit("change b")
+	repo.Chdir()
+
+	gta, err := New(SetDiffer(NewGitDiffer(SetBaseBranch(gtatest.BaseBranch))), SetPrefixes("example.com/repo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotChanges []string
+	for _, pkg := range got.AllChanges {
+		gotChanges = append(gotChanges, pkg.ImportPath)
+	}
+
+	want := []string{"example.com/repo/b", "example.com/repo/c"}
+	if diff := cmp.Diff(want, gotChanges); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
 func TestNoBuildableGoFiles(t *testing.T) {
 	// we have changes but they don't belong to any dirty golang files, so no dirty packages
 	const dir = "docs"
@@ -727,6 +1204,34 @@ func TestSpecialCaseDirectory(t *testing.T) {
 	}
 }
 
+func TestPackages_Contains(t *testing.T) {
+	pkgs := &Packages{
+		AllChanges: []Package{
+			{ImportPath: "example.com/cmd/a"},
+			{ImportPath: "example.com/b"},
+		},
+	}
+
+	tests := []struct {
+		pkgPath string
+		want    bool
+	}{
+		{pkgPath: "example.com/b", want: true},
+		{pkgPath: "example.com/c", want: false},
+		{pkgPath: "example.com/cmd", want: false},
+		{pkgPath: "example.com/cmd/...", want: true},
+		{pkgPath: "example.com/c/...", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pkgPath, func(t *testing.T) {
+			if got := pkgs.Contains(tt.pkgPath); got != tt.want {
+				t.Errorf("Contains(%q) = %v; want %v", tt.pkgPath, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestUnmarshalJSON(t *testing.T) {
 	want := &Packages{
 		Dependencies: map[string][]Package{
@@ -786,6 +1291,9 @@ func TestJSONRoundtrip(t *testing.T) {
 				ImportPath: "do/teams/compute/octopus",
 			},
 		},
+		Origins: map[string][]string{
+			"do/teams/compute/octopus": []string{"git"},
+		},
 	}
 
 	b, err := json.Marshal(want)
@@ -803,3 +1311,138 @@ func TestJSONRoundtrip(t *testing.T) {
 		t.Errorf("(-want, +got)\n%s", diff)
 	}
 }
+
+func TestJSONFields(t *testing.T) {
+	fields := make(map[string]bool)
+	for _, name := range JSONFields() {
+		if fields[name] {
+			t.Errorf("json field %q is listed more than once", name)
+		}
+		fields[name] = true
+	}
+
+	// every exported field of Packages must be encoded, and so selectable,
+	// under the name of the field of packagesJSON it is copied to.
+	typ, enc := reflect.TypeOf(Packages{}), reflect.TypeOf(packagesJSON{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		f, ok := enc.FieldByName(field.Name)
+		if !ok {
+			t.Errorf("field %s of Packages is not encoded", field.Name)
+			continue
+		}
+		if name := strings.Split(f.Tag.Get("json"), ",")[0]; !fields[name] {
+			t.Errorf("field %s of Packages is encoded as %q, which is not a json field", field.Name, name)
+		}
+	}
+}
+
+func TestGTA_ModuleDir(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	backend := filepath.Join(root, "backend")
+
+	// the tools directory is outside of the module, so its changes do not
+	// change the module's packages even when they resolve to one.
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(backend, "foo"): {Exists: true, Files: []string{"foo.go"}},
+			filepath.Join(root, "tools"):  {Exists: true, Files: []string{"tools.go"}},
+		},
+	}
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			filepath.Join(backend, "foo"): "example.com/backend/foo",
+			filepath.Join(backend, "bar"): "example.com/backend/bar",
+			filepath.Join(root, "tools"):  "example.com/backend/tools",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"example.com/backend/foo": {"example.com/backend/bar": true},
+			},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetModuleDir(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/backend/bar", "example.com/backend/foo"}
+	if diff := cmp.Diff(want, stringify(got.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+	if gta.dir != backend {
+		t.Errorf("got dir %q, want %q", gta.dir, backend)
+	}
+}
+
+func TestGTA_ChangedPackagesContext(t *testing.T) {
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirA": {Exists: true, Files: []string{"a.go"}},
+		},
+	}
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{"dirA": "A"},
+		graph:        &Graph{graph: map[string]map[string]bool{}},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := gta.ChangedPackagesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v; want %v", err, context.Canceled)
+	}
+
+	got, err := gta.ChangedPackagesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"A"}, stringify(got.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_ChangedPackagesContextGit(t *testing.T) {
+	repo := newChangedRepo(t)
+	dir := repo.Dir
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{dir: "example.com/foo"},
+		graph:        &Graph{graph: map[string]map[string]bool{}},
+	}
+	gta, err := New(SetDiffer(NewGitDiffer(SetWorkingDir(dir), SetBaseBranch("base"))), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the git commands are run with the context of the call, which is
+	// cancelled here after the call has started.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	gta.callCtx = ctx
+	if _, _, err := gta.changedPackages(); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v; want %v", err, context.Canceled)
+	}
+	gta.callCtx = nil
+
+	got, err := gta.ChangedPackagesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"example.com/foo"}, stringify(got.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
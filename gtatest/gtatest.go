@@ -0,0 +1,186 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+
+// Package gtatest provides utilities to build temporary git repositories with
+// synthetic module layouts, so that tests can make end-to-end assertions about
+// the packages that gta finds to be affected by changes.
+package gtatest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// BaseBranch is the name of the branch that NewRepo commits the initial files
+// to.
+const BaseBranch = "master"
+
+// Env is the environment, in addition to the current process's environment,
+// used to run git in repositories. It isolates the repositories from the
+// user's and system's git configuration.
+var Env = []string{
+	"GIT_AUTHOR_NAME=gta",
+	"GIT_AUTHOR_EMAIL=gta@example.com",
+	"GIT_COMMITTER_NAME=gta",
+	"GIT_COMMITTER_EMAIL=gta@example.com",
+	"GIT_CONFIG_NOSYSTEM=1",
+}
+
+// A Repo is a temporary git repository. Its methods fail the test when they
+// encounter an error.
+type Repo struct {
+	// Dir is the absolute path of the root of the repository.
+	Dir string
+
+	t testing.TB
+}
+
+// NewRepo creates a git repository in a temporary directory that is removed
+// when the test completes. The repository contains a go.mod file declaring a
+// module with path modulePath, when modulePath is not empty, and files, which
+// maps slash separated paths relative to the root of the repository to their
+// content. The files are committed to BaseBranch. The test is skipped when git
+// is not available.
+func NewRepo(t testing.TB, modulePath string, files map[string]string) *Repo {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "gtatest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// resolve symlinks so that paths reported by git and go match Dir.
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Repo{Dir: dir, t: t}
+	r.Git("init", "-q")
+	r.Git("symbolic-ref", "HEAD", "refs/heads/"+BaseBranch)
+
+	if modulePath != "" {
+		r.WriteFile("go.mod", fmt.Sprintf("module %s\n\ngo 1.15\n", modulePath))
+	}
+	r.WriteFiles(files)
+	r.Commit("initial")
+
+	return r
+}
+
+// Path returns the absolute path of the slash separated path name relative to
+// the root of the repository.
+func (r *Repo) Path(name string) string {
+	return filepath.Join(r.Dir, filepath.FromSlash(name))
+}
+
+// Git runs git with args in the root of the repository and returns its
+// standard output.
+func (r *Repo) Git(args ...string) string {
+	r.t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	cmd.Env = append(os.Environ(), Env...)
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		r.t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, stderr.String())
+	}
+
+	return string(out)
+}
+
+// WriteFile writes content to the file identified by the slash separated path
+// name relative to the root of the repository, creating its parent
+// directories as needed.
+func (r *Repo) WriteFile(name, content string) {
+	r.t.Helper()
+
+	fn := r.Path(name)
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// WriteFiles writes each file in files, which maps slash separated paths
+// relative to the root of the repository to their content.
+func (r *Repo) WriteFiles(files map[string]string) {
+	r.t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.WriteFile(name, files[name])
+	}
+}
+
+// Remove removes the file or directory tree identified by the slash separated
+// path name relative to the root of the repository.
+func (r *Repo) Remove(name string) {
+	r.t.Helper()
+
+	if err := os.RemoveAll(r.Path(name)); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// Checkout creates a branch named branch at the current commit and checks it
+// out.
+func (r *Repo) Checkout(branch string) {
+	r.t.Helper()
+
+	r.Git("checkout", "-q", "-b", branch)
+}
+
+// Commit commits all of the changes in the working tree with the commit
+// message msg.
+func (r *Repo) Commit(msg string) {
+	r.t.Helper()
+
+	r.Git("add", "-A")
+	r.Git("commit", "-q", "--allow-empty", "-m", msg)
+}
+
+// Chdir changes the current working directory to the root of the repository
+// until the test completes. Tests that use Chdir must not run in parallel.
+func (r *Repo) Chdir() {
+	r.t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.Chdir(r.Dir); err != nil {
+		r.t.Fatal(err)
+	}
+	r.t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			r.t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gtatest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRepo(t *testing.T) {
+	r := NewRepo(t, "example.com/repo", map[string]string{
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n",
+	})
+
+	r.Checkout("change")
+	r.WriteFile("a/a.go", "package a\n\nvar A int\n")
+	r.WriteFile("c/c.go", "package c\n")
+	r.Remove("b")
+	r.Commit("change")
+
+	got := strings.Fields(r.Git("diff", "--name-only", BaseBranch+"...change"))
+	want := []string{"a/a.go", "b/b.go", "c/c.go"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if got, want := r.Git("show", BaseBranch+":go.mod"), "module example.com/repo\n\ngo 1.15\n"; got != want {
+		t.Errorf("go.mod = %q; want %q", got, want)
+	}
+}
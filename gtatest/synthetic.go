@@ -0,0 +1,28 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gtatest
+
+import (
+	"github.com/digitalocean/gta/internal/synthetic"
+	"golang.org/x/tools/go/packages"
+)
+
+// SyntheticModule is the module path of the packages returned by
+// SyntheticPackages.
+const SyntheticModule = synthetic.Module
+
+// SyntheticPackages returns n in-memory packages that form an acyclic import
+// graph, suitable for use with a gta.Loader. See synthetic.Packages.
+func SyntheticPackages(n, fanout int, seed int64) []*packages.Package {
+	return synthetic.Packages(n, fanout, seed)
+}
+
+// SyntheticImportPath returns the import path of the i'th package returned by
+// SyntheticPackages.
+func SyntheticImportPath(i int) string {
+	return synthetic.ImportPath(i)
+}
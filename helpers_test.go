@@ -1,11 +1,49 @@
 package gta
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/digitalocean/gta/gtatest"
+	"golang.org/x/tools/go/packages"
 )
 
+var flagBenchSizes = flag.String("gta.benchsizes", "10000,50000", "comma separated numbers of packages in the synthetic graphs used by benchmarks")
+
+// benchSizes returns the sizes of the synthetic graphs to use in benchmarks.
+func benchSizes(b *testing.B) []int {
+	b.Helper()
+
+	var sizes []int
+	for _, s := range strings.Split(*flagBenchSizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			b.Fatalf("invalid -gta.benchsizes: %v", err)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
+
+// runSizes runs f as a sub-benchmark for each of the benchmark sizes with a
+// Loader that returns the synthetic packages of that size.
+func runSizes(b *testing.B, f func(b *testing.B, loader Loader)) {
+	for _, n := range benchSizes(b) {
+		pkgs := gtatest.SyntheticPackages(n, 5, 1)
+		loader := LoaderFunc(func(*packages.Config, ...string) ([]*packages.Package, error) {
+			return pkgs, nil
+		})
+
+		b.Run(fmt.Sprintf("packages=%d", n), func(b *testing.B) {
+			f(b, loader)
+		})
+	}
+}
+
 // Setenv sets an environment variable, name, to value and returns a function
 // to restore the environment variable to its former value.
 func Setenv(t *testing.T, name, value string) func() {
@@ -0,0 +1,270 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HgDifferOption is an option function used to modify a Mercurial differ.
+type HgDifferOption func(*hg)
+
+// SetHgBase sets the revision, such as a branch or bookmark, that a Mercurial
+// differ compares the working directory's parent against. The changes are
+// determined from the common ancestor of the two revisions, like git's
+// three-dot diff.
+func SetHgBase(base string) HgDifferOption {
+	return func(h *hg) {
+		h.base = base
+	}
+}
+
+// SetHgUseMergeCommit sets whether a Mercurial differ determines the changes
+// introduced by the most recent merge commit instead of the changes since the
+// base revision.
+func SetHgUseMergeCommit(useMergeCommit bool) HgDifferOption {
+	return func(h *hg) {
+		h.useMergeCommit = useMergeCommit
+	}
+}
+
+// SetHgWorkingDir sets the directory in which a Mercurial differ runs hg. When
+// it is not set, hg is run in the current working directory.
+func SetHgWorkingDir(dir string) HgDifferOption {
+	return func(h *hg) {
+		h.dir = dir
+	}
+}
+
+// SetHgCommandTimeout sets the maximum duration of each hg command run by a
+// Mercurial differ. A timeout of zero means hg commands do not time out.
+func SetHgCommandTimeout(timeout time.Duration) HgDifferOption {
+	return func(h *hg) {
+		h.timeout = timeout
+	}
+}
+
+// SetHgContext sets the context of the hg commands run by a Mercurial differ.
+// The commands are killed when ctx is done.
+func SetHgContext(ctx context.Context) HgDifferOption {
+	return func(h *hg) {
+		h.ctx = ctx
+	}
+}
+
+// NewHgDiffer returns a Differ that determines differences using Mercurial.
+// The returned Differ is also a BaseReader.
+func NewHgDiffer(opts ...HgDifferOption) Differ {
+	h := &hg{
+		base: "default",
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return &hgDiffer{
+		differ: differ{
+			diff: h.diff,
+		},
+		hg: h,
+	}
+}
+
+// hgDiffer implements the BaseReader interface using Mercurial.
+type hgDiffer struct {
+	differ
+	hg *hg
+}
+
+// ReadBase returns the content of the file at abs as of the common ancestor
+// of the revisions being compared.
+func (d *hgDiffer) ReadBase(abs string) ([]byte, error) {
+	return d.hg.readBase(abs)
+}
+
+// hg implements the Differ interface using the Mercurial version control
+// system.
+type hg struct {
+	base           string
+	useMergeCommit bool
+	dir            string
+	timeout        time.Duration
+	ctx            context.Context
+
+	onceDiff     sync.Once
+	changedFiles map[string]struct{}
+	diffErr      error
+
+	// root and from are set by diff: root is the absolute path of the
+	// repository and from is the node of the revision the changes are
+	// determined from.
+	root string
+	from string
+}
+
+// revisions returns the revisions that the changes are determined between.
+func (h *hg) revisions(root string) (from, to string, err error) {
+	if !h.useMergeCommit {
+		from, err = h.revision(root, fmt.Sprintf("ancestor(%s, .)", quoteRevsetString(h.base)))
+		if err != nil {
+			return "", "", err
+		}
+		if from == "" {
+			return "", "", fmt.Errorf("hg: %s and . have no common ancestor", h.base)
+		}
+		return from, ".", nil
+	}
+
+	// when the working directory's parent is a merge, the changes are those
+	// introduced by its second parent.
+	p2, err := h.revision(root, "p2(.)")
+	if err != nil {
+		return "", "", err
+	}
+	if p2 != "" {
+		from, err := h.revision(root, "ancestor(p1(.), p2(.))")
+		return from, p2, err
+	}
+
+	// for squash-merge/rebase commits, the changes are those since the most
+	// recent merge.
+	from, err = h.revision(root, "last(merge() and ::.)")
+	if err != nil {
+		return "", "", err
+	}
+	if from == "" {
+		return "", "", errors.New("hg: no merge commit found")
+	}
+	return from, ".", nil
+}
+
+// revision returns the node of the revision identified by revset, or an empty
+// string when the revset is empty.
+func (h *hg) revision(root, revset string) (string, error) {
+	out, err := h.output(root, "log", "-r", revset, "--template", "{node}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// quoteRevsetString quotes s so that it is interpreted as a revision symbol in
+// a revset.
+func quoteRevsetString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// context returns a context that is done when h's context is done or h's
+// command timeout elapses.
+func (h *hg) context() (context.Context, context.CancelFunc) {
+	ctx := h.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if h.timeout > 0 {
+		return context.WithTimeout(ctx, h.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// output runs hg with args in dir and returns its standard output. When dir is
+// empty, h's working directory is used.
+func (h *hg) output(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := h.context()
+	defer cancel()
+
+	if dir == "" {
+		dir = h.dir
+	}
+
+	cmd := exec.CommandContext(ctx, "hg", args...)
+	cmd.Dir = dir
+	// HGPLAIN disables user configuration that changes hg's output.
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("hg %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// diff returns a set of changed files.
+func (h *hg) diff() (map[string]struct{}, error) {
+	h.onceDiff.Do(func() {
+		out, err := h.output("", "root")
+		if err != nil {
+			h.diffErr = err
+			return
+		}
+		root := strings.TrimSpace(string(out))
+
+		from, to, err := h.revisions(root)
+		if err != nil {
+			h.diffErr = err
+			return
+		}
+
+		// run in the repository's root so that the listed paths are relative
+		// to it.
+		out, err = h.output(root, "status", "--rev", from, "--rev", to, "--modified", "--added", "--removed", "--no-status")
+		if err != nil {
+			h.diffErr = err
+			return
+		}
+
+		files, err := diffPaths(root, bytes.NewReader(out))
+		if err != nil {
+			h.diffErr = err
+			return
+		}
+
+		h.root, h.from = root, from
+		h.changedFiles = files
+	})
+
+	return h.changedFiles, h.diffErr
+}
+
+// readBase returns the content of the file at abs as of the revision that
+// the changes are determined from.
+func (h *hg) readBase(abs string) ([]byte, error) {
+	if _, err := h.diff(); err != nil {
+		return nil, err
+	}
+
+	rel, err := filepath.Rel(h.root, abs)
+	if err != nil {
+		return nil, err
+	}
+	pattern := "path:" + filepath.ToSlash(rel)
+
+	// hg files exits with a non-zero status when no file matches.
+	if _, err := h.output(h.root, "files", "-r", h.from, pattern); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, &os.PathError{Op: "read", Path: abs, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+
+	return h.output(h.root, "cat", "-r", h.from, pattern)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// runHg runs hg with args in dir.
+func runHg(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	h := &hg{dir: dir}
+	if _, err := h.output("", append([]string{"--config", "ui.username=gta <gta@example.com>"}, args...)...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newHgTestRepo creates a Mercurial repository with a bookmark named base and
+// a commit on top of base that modifies foo.go and adds bar.go. The returned
+// path is the root of the repository.
+func newHgTestRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg is not available")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runHg(t, dir, "init")
+	writeFile(t, dir, "foo.go", "package foo\n")
+	runHg(t, dir, "commit", "-A", "-m", "base")
+	runHg(t, dir, "bookmark", "base")
+	runHg(t, dir, "bookmark", "--inactive")
+
+	writeFile(t, dir, "foo.go", "package foo\n\nvar Foo int\n")
+	writeFile(t, dir, "bar.go", "package foo\n")
+	runHg(t, dir, "commit", "-A", "-m", "change")
+
+	return dir
+}
+
+func TestHgDiffer(t *testing.T) {
+	dir := newHgTestRepo(t)
+
+	difr := NewHgDiffer(SetHgWorkingDir(dir), SetHgBase("base"))
+	got, err := difr.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "bar.go"): true,
+		filepath.Join(dir, "foo.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	br, ok := difr.(BaseReader)
+	if !ok {
+		t.Fatal("expected the hg differ to be a BaseReader")
+	}
+
+	b, err := br.ReadBase(filepath.Join(dir, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "package foo\n"; got != want {
+		t.Errorf("ReadBase(foo.go) = %q; want %q", got, want)
+	}
+
+	_, err = br.ReadBase(filepath.Join(dir, "bar.go"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadBase(bar.go) error = %v; want an error wrapping os.ErrNotExist", err)
+	}
+}
+
+func TestHgDiffer_MergeCommit(t *testing.T) {
+	dir := newHgTestRepo(t)
+
+	runHg(t, dir, "update", "base")
+	writeFile(t, dir, "qux.go", "package foo\n")
+	runHg(t, dir, "commit", "-A", "-m", "qux")
+	runHg(t, dir, "merge", "1")
+	runHg(t, dir, "commit", "-m", "merge")
+
+	// the merge brings in the changes from the first branch.
+	difr := NewHgDiffer(SetHgWorkingDir(dir), SetHgUseMergeCommit(true))
+	got, err := difr.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "bar.go"): true,
+		filepath.Join(dir, "foo.go"): true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestQuoteRevsetString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "default", want: `'default'`},
+		{in: "feature's", want: `'feature\'s'`},
+		{in: `a\b`, want: `'a\\b'`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteRevsetString(tt.in); got != tt.want {
+			t.Errorf("quoteRevsetString(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// TestHistory records the outcomes of past test runs of packages.
+type TestHistory struct {
+	runs     map[string]int
+	failures map[string]int
+}
+
+// NewTestHistory returns an empty TestHistory.
+func NewTestHistory() *TestHistory {
+	return &TestHistory{
+		runs:     make(map[string]int),
+		failures: make(map[string]int),
+	}
+}
+
+// testEvent is an event in the output of go test -json.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+}
+
+// Read adds the outcomes of the test runs in r, which is the output of go test
+// -json, to h. Each pass or fail of a package counts as a run of the package.
+func (h *TestHistory) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e testEvent
+		// go test -json interleaves lines that are not events, e.g. build
+		// errors, with the events.
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Package == "" || e.Test != "" {
+			continue
+		}
+
+		switch e.Action {
+		case "pass":
+			h.runs[e.Package]++
+		case "fail":
+			h.runs[e.Package]++
+			h.failures[e.Package]++
+		}
+	}
+	return scanner.Err()
+}
+
+// FailureRate returns the fraction of the recorded runs of the package with
+// the import path importPath that failed. It is zero when no runs of the
+// package are recorded.
+func (h *TestHistory) FailureRate(importPath string) float64 {
+	runs := h.runs[importPath]
+	if runs == 0 {
+		return 0
+	}
+	return float64(h.failures[importPath]) / float64(runs)
+}
+
+// Prioritize returns a copy of pkgs ordered so that the packages whose tests
+// failed most often run first, which shortens the time to the first failure
+// of a CI run. Packages with the same failure rate are ordered by their number
+// of failures and then by import path.
+func (h *TestHistory) Prioritize(pkgs []Package) []Package {
+	prioritized := append([]Package(nil), pkgs...)
+	sort.SliceStable(prioritized, func(i, j int) bool {
+		a, b := prioritized[i].ImportPath, prioritized[j].ImportPath
+		if ra, rb := h.FailureRate(a), h.FailureRate(b); ra != rb {
+			return ra > rb
+		}
+		if fa, fb := h.failures[a], h.failures[b]; fa != fb {
+			return fa > fb
+		}
+		return a < b
+	})
+	return prioritized
+}
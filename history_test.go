@@ -0,0 +1,57 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTestHistory_Prioritize(t *testing.T) {
+	runs := []string{
+		`{"Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Action":"fail","Package":"example.com/a","Test":"TestA"}
+{"Action":"fail","Package":"example.com/a"}
+{"Action":"pass","Package":"example.com/b"}
+{"Action":"fail","Package":"example.com/c"}
+# example.com/d [build failed]
+`,
+		`{"Action":"pass","Package":"example.com/a"}
+{"Action":"pass","Package":"example.com/b"}
+{"Action":"fail","Package":"example.com/c"}
+`,
+	}
+
+	h := NewTestHistory()
+	for _, run := range runs {
+		if err := h.Read(strings.NewReader(run)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := h.FailureRate("example.com/a"), 0.5; got != want {
+		t.Errorf("FailureRate(example.com/a) = %v; want %v", got, want)
+	}
+
+	pkgs := []Package{
+		{ImportPath: "example.com/a"},
+		{ImportPath: "example.com/b"},
+		{ImportPath: "example.com/c"},
+		{ImportPath: "example.com/e"},
+	}
+	want := []Package{
+		{ImportPath: "example.com/c"},
+		{ImportPath: "example.com/a"},
+		{ImportPath: "example.com/b"},
+		{ImportPath: "example.com/e"},
+	}
+	if diff := cmp.Diff(want, h.Prioritize(pkgs)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
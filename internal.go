@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"sort"
+	"strings"
+)
+
+// reportVisibility sets the InternalScopes and VisibilityViolations of cp for
+// the changed internal packages in m.
+func (g *GTA) reportVisibility(cp *Packages, m *marks) {
+	for changed := range m.paths {
+		scope, ok := internalScope(changed)
+		if !ok {
+			continue
+		}
+
+		if _, ok := cp.Dependencies[changed]; !ok && !g.included(changed) {
+			continue
+		}
+
+		if cp.InternalScopes == nil {
+			cp.InternalScopes = make(map[string]string)
+		}
+		cp.InternalScopes[changed] = scope
+
+		if m.graph == nil {
+			continue
+		}
+
+		// only direct dependents can violate the visibility rules; transitive
+		// dependents outside of the scope are affected through packages that are
+		// allowed to import the internal package.
+		var violations []string
+		for dependent := range m.graph.graph[changed] {
+			if withinScope(dependent, scope) || !g.included(dependent) {
+				continue
+			}
+			violations = append(violations, dependent)
+		}
+
+		if len(violations) == 0 {
+			continue
+		}
+
+		sort.Strings(violations)
+		if cp.VisibilityViolations == nil {
+			cp.VisibilityViolations = make(map[string][]string)
+		}
+		cp.VisibilityViolations[changed] = violations
+	}
+}
+
+// internalScope returns the import path of the tree that may import the
+// package identified by importPath when it is an internal package. When the
+// path contains more than one internal element, the most restrictive scope is
+// returned. The boolean return value is false when importPath is not an
+// internal package or when it can be imported by any package in the standard
+// library.
+func internalScope(importPath string) (string, bool) {
+	switch {
+	case strings.HasSuffix(importPath, "/internal"):
+		importPath = strings.TrimSuffix(importPath, "/internal")
+	case strings.Contains(importPath, "/internal/"):
+		importPath = importPath[:strings.LastIndex(importPath, "/internal/")]
+	default:
+		return "", false
+	}
+
+	return importPath, true
+}
+
+// withinScope reports whether the package identified by importPath is within
+// the tree rooted at scope.
+func withinScope(importPath, scope string) bool {
+	return importPath == scope || strings.HasPrefix(importPath, scope+"/")
+}
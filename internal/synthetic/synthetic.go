@@ -0,0 +1,58 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+
+// Package synthetic generates in-memory package graphs for benchmarks and
+// tests. It does not depend on package testing so that it can be linked into
+// the gta command.
+package synthetic
+
+import (
+	"fmt"
+	"math/rand"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Module is the module path of the packages returned by Packages.
+const Module = "example.com/synthetic"
+
+// Packages returns n in-memory packages that form an acyclic import graph,
+// suitable for use with a gta.Loader. Each package imports up to fanout
+// packages that precede it, chosen randomly using seed, so that the graph is
+// deterministic for a given set of arguments. The packages are returned in
+// the order they were created; the first package has no imports and the last
+// package is not imported by any package.
+func Packages(n, fanout int, seed int64) []*packages.Package {
+	rnd := rand.New(rand.NewSource(seed))
+
+	pkgs := make([]*packages.Package, n)
+	for i := range pkgs {
+		importPath := ImportPath(i)
+		pkg := &packages.Package{
+			ID:      importPath,
+			Name:    fmt.Sprintf("p%d", i),
+			PkgPath: importPath,
+			GoFiles: []string{fmt.Sprintf("/synthetic/p%d/p%d.go", i, i)},
+			Imports: make(map[string]*packages.Package),
+		}
+
+		for j := 0; j < fanout && i > 0; j++ {
+			dep := pkgs[rnd.Intn(i)]
+			pkg.Imports[dep.PkgPath] = dep
+		}
+
+		pkgs[i] = pkg
+	}
+
+	return pkgs
+}
+
+// ImportPath returns the import path of the i'th package returned by
+// Packages.
+func ImportPath(i int) string {
+	return fmt.Sprintf("%s/p%d", Module, i)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package synthetic
+
+import (
+	"testing"
+)
+
+func TestPackages(t *testing.T) {
+	pkgs := Packages(100, 3, 1)
+	if got, want := len(pkgs), 100; got != want {
+		t.Fatalf("len(pkgs) = %d; want %d", got, want)
+	}
+
+	index := make(map[string]int)
+	for i, pkg := range pkgs {
+		if got, want := pkg.PkgPath, ImportPath(i); got != want {
+			t.Errorf("pkgs[%d].PkgPath = %q; want %q", i, got, want)
+		}
+		if len(pkg.Imports) > 3 {
+			t.Errorf("pkgs[%d] has %d imports; want at most 3", i, len(pkg.Imports))
+		}
+
+		// imports must precede the package so that the graph is acyclic.
+		for importPath := range pkg.Imports {
+			j, ok := index[importPath]
+			if !ok {
+				t.Errorf("pkgs[%d] imports %s, which does not precede it", i, importPath)
+				continue
+			}
+			if j >= i {
+				t.Errorf("pkgs[%d] imports pkgs[%d]", i, j)
+			}
+		}
+		index[pkg.PkgPath] = i
+	}
+
+	again := Packages(100, 3, 1)
+	for i := range pkgs {
+		if len(pkgs[i].Imports) != len(again[i].Imports) {
+			t.Fatalf("packages are not deterministic for the same seed")
+		}
+		for importPath := range pkgs[i].Imports {
+			if _, ok := again[i].Imports[importPath]; !ok {
+				t.Fatalf("packages are not deterministic for the same seed")
+			}
+		}
+	}
+}
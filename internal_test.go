@@ -0,0 +1,86 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_InternalVisibility(t *testing.T) {
+	// a/y and b/z depend on a/internal/x
+	// b/w depends on a/y
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirX": Directory{Exists: true},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"a/internal/x": map[string]bool{
+				"a/y": true,
+				"b/z": true,
+			},
+			"a/y": map[string]bool{
+				"b/w": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirX": "a/internal/x",
+			"dirY": "a/y",
+			"dirZ": "b/z",
+			"dirW": "b/w",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(map[string]string{"a/internal/x": "a"}, pkgs.InternalScopes); diff != "" {
+		t.Errorf("InternalScopes (-want, +got)\n%s", diff)
+	}
+
+	if diff := cmp.Diff(map[string][]string{"a/internal/x": {"b/z"}}, pkgs.VisibilityViolations); diff != "" {
+		t.Errorf("VisibilityViolations (-want, +got)\n%s", diff)
+	}
+}
+
+func TestInternalScope(t *testing.T) {
+	tests := []struct {
+		importPath string
+		scope      string
+		ok         bool
+	}{
+		{importPath: "a/b", ok: false},
+		{importPath: "internal/x", ok: false},
+		{importPath: "a/internal", scope: "a", ok: true},
+		{importPath: "a/internal/x", scope: "a", ok: true},
+		{importPath: "a/internal/x/internal/y", scope: "a/internal/x", ok: true},
+		{importPath: "a/internalx/y", ok: false},
+	}
+
+	for _, tt := range tests {
+		scope, ok := internalScope(tt.importPath)
+		if scope != tt.scope || ok != tt.ok {
+			t.Errorf("internalScope(%q) = %q, %t; want %q, %t", tt.importPath, scope, ok, tt.scope, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChangeKind describes the nature of the changes to a package.
+type ChangeKind string
+
+const (
+	// CodeChange indicates that the implementation of a package changed, or
+	// that its changes are of several kinds, so it must be rebuilt and
+	// tested.
+	CodeChange ChangeKind = "code"
+
+	// TestChange indicates that only the test files of a package changed, so
+	// its tests must be run but it need not be rebuilt.
+	TestChange ChangeKind = "test"
+
+	// AssetChange indicates that only files other than Go files changed in
+	// the directory of a package.
+	AssetChange ChangeKind = "asset"
+)
+
+// changeKinds returns a map of the changed packages in m to the kind of their
+// changes. Packages whose changed files are unknown are omitted.
+func changeKinds(m *marks) map[string]ChangeKind {
+	kinds := make(map[string]ChangeKind)
+	for importPath, files := range m.files {
+		if _, ok := m.paths[importPath]; !ok || len(files) == 0 {
+			continue
+		}
+		kinds[importPath] = changeKind(files)
+	}
+	return kinds
+}
+
+// changeKind returns the kind of the changes to the files.
+func changeKind(files []string) ChangeKind {
+	var tests, assets bool
+	for _, fn := range files {
+		switch {
+		case strings.HasSuffix(fn, "_test.go"), inTestdata(fn):
+			tests = true
+		case filepath.Ext(fn) == ".go":
+			return CodeChange
+		default:
+			assets = true
+		}
+	}
+
+	switch {
+	case tests && assets:
+		return CodeChange
+	case tests:
+		return TestChange
+	default:
+		return AssetChange
+	}
+}
+
+// fileChangeReport returns a map of the changed packages in m to the sorted
+// changes to their files, as described by g's differs.
+func (g *GTA) fileChangeReport(m *marks) (map[string][]FileChange, error) {
+	changes := make(map[string]FileChange)
+	for _, ld := range g.differs {
+		described, err := fileChanges(ld.differ)
+		if err != nil {
+			return nil, err
+		}
+		// the first differ to report a file describes its change.
+		for abs, c := range described {
+			if _, ok := changes[abs]; !ok {
+				changes[abs] = c
+			}
+		}
+	}
+
+	report := make(map[string][]FileChange)
+	for importPath, files := range m.files {
+		if _, ok := m.paths[importPath]; !ok {
+			continue
+		}
+		for _, fn := range files {
+			c, ok := changes[fn]
+			if !ok {
+				// files attributed to the package by a spec or fixture
+				// mapping that no differ described.
+				c = FileChange{Path: fn}
+			}
+			report[importPath] = append(report[importPath], c)
+		}
+		sort.Slice(report[importPath], func(i, j int) bool {
+			return report[importPath][i].Path < report[importPath][j].Path
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_ChangeKinds(t *testing.T) {
+	// A depends on B, C, and D
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true, Files: []string{"b.go", "b_test.go"}},
+			"dirC": Directory{Exists: true, Files: []string{"c_test.go"}},
+			"dirD": Directory{Exists: true, Files: []string{"schema.sql", "README.md"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{"A": true},
+			"C": map[string]bool{"A": true},
+			"D": map[string]bool{"A": true},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+			"dirD": "D",
+		},
+		graph: graph,
+		errs:  make(map[string]error),
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetChangeKindReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]ChangeKind{
+		"B": CodeChange,
+		"C": TestChange,
+		"D": AssetChange,
+	}
+	if diff := cmp.Diff(want, pkgs.ChangeKinds); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestChangeKind(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  ChangeKind
+	}{
+		{files: []string{"/a/a.go"}, want: CodeChange},
+		{files: []string{"/a/a_test.go", "/a/b_test.go"}, want: TestChange},
+		{files: []string{"/a/a.sql"}, want: AssetChange},
+		{files: []string{"/a/a_test.go", "/a/a.sql"}, want: CodeChange},
+		{files: []string{"/a/a.sql", "/a/a.go"}, want: CodeChange},
+	}
+
+	for _, tt := range tests {
+		if got := changeKind(tt.files); got != tt.want {
+			t.Errorf("changeKind(%q) = %q; want %q", tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestGTA_FileChanges(t *testing.T) {
+	difr, err := NewFileChangesDiffer([]FileChange{
+		{Path: "/repo/b/b.go", Status: StatusModified},
+		{Path: "/repo/b/new.go", Status: StatusRenamed, OldPath: "/repo/c/old.go"},
+		{Path: "/repo/c/c.go", Status: StatusDeleted},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"/repo/a": "A",
+			"/repo/b": "B",
+			"/repo/c": "C",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"B": map[string]bool{"A": true},
+			},
+		},
+		errs: make(map[string]error),
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetFileChangeReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]FileChange{
+		"B": {
+			{Path: "/repo/b/b.go", Status: StatusModified},
+			{Path: "/repo/b/new.go", Status: StatusRenamed, OldPath: "/repo/c/old.go"},
+		},
+		"C": {
+			{Path: "/repo/c/c.go", Status: StatusDeleted},
+			{Path: "/repo/c/old.go", Status: StatusDeleted},
+		},
+	}
+	if diff := cmp.Diff(want, pkgs.FileChanges); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// memoryLimitPattern matches the values accepted by the GOMEMLIMIT environment
+// variable.
+var memoryLimitPattern = regexp.MustCompile(`^(off|[0-9]+(B|KiB|MiB|GiB|TiB)?)$`)
+
+// loadConfig returns a *packages.Config for loading packages that respects
+// g's context, build tags, environment, build flags, and resource limits.
+func (g *GTA) loadConfig() *packages.Config {
+	cfg := newLoadConfig(g.tags)
+	cfg.Context = g.context()
+	cfg.Dir = g.dir
+	if g.loadMode != 0 {
+		cfg.Mode = g.loadMode
+	}
+	if g.loadSyntax {
+		cfg.Mode |= SyntaxLoadMode
+	}
+	cfg.Overlay = g.overlay
+	cfg.BuildFlags = append(cfg.BuildFlags, g.buildFlags...)
+
+	var env []string
+	for k, v := range g.goEnv {
+		env = append(env, k+"="+v)
+	}
+	// sort the variables so that the environment, which is part of the key of
+	// cached graphs, does not depend on the order of iteration of the map.
+	sort.Strings(env)
+	if g.parallelism > 0 {
+		// -p limits the number of programs the go command runs in parallel, and
+		// GOMAXPROCS limits the threads the go command itself uses to load
+		// packages.
+		cfg.BuildFlags = append(cfg.BuildFlags, fmt.Sprintf("-p=%d", g.parallelism))
+		env = append(env, "GOMAXPROCS="+strconv.Itoa(g.parallelism))
+	}
+	if g.memoryLimit != "" {
+		env = append(env, "GOMEMLIMIT="+g.memoryLimit)
+	}
+	if g.goos != "" {
+		env = append(env, "GOOS="+g.goos)
+	}
+	if g.goarch != "" {
+		env = append(env, "GOARCH="+g.goarch)
+	}
+	if len(env) > 0 {
+		cfg.Env = append(os.Environ(), env...)
+	}
+
+	return cfg
+}
+
+// validMemoryLimit returns an error when limit is not a valid value for the
+// GOMEMLIMIT environment variable.
+func validMemoryLimit(limit string) error {
+	if !memoryLimitPattern.MatchString(limit) {
+		return fmt.Errorf("invalid memory limit %q: must be a number of bytes with an optional unit suffix (B, KiB, MiB, GiB, or TiB) or off", limit)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGTA_ResourceLimits(t *testing.T) {
+	var got *packages.Config
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg
+		return nil, nil
+	})
+
+	_, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetParallelism(2), SetMemoryLimit("512MiB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"-tags=", "-p=2"}, got.BuildFlags); diff != "" {
+		t.Errorf("BuildFlags (-want, +got)\n%s", diff)
+	}
+
+	env := make(map[string]bool)
+	for _, v := range got.Env {
+		env[v] = true
+	}
+	for _, want := range []string{"GOMAXPROCS=2", "GOMEMLIMIT=512MiB"} {
+		if !env[want] {
+			t.Errorf("Env does not contain %q", want)
+		}
+	}
+}
+
+func TestGTA_ResourceLimitsDefault(t *testing.T) {
+	var got *packages.Config
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg
+		return nil, nil
+	})
+
+	_, err := New(SetDiffer(&testDiffer{}), SetLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"-tags="}, got.BuildFlags); diff != "" {
+		t.Errorf("BuildFlags (-want, +got)\n%s", diff)
+	}
+	if got.Env != nil {
+		t.Errorf("Env = %v; want nil", got.Env)
+	}
+}
+
+func TestGTA_GoEnvAndBuildFlags(t *testing.T) {
+	var got *packages.Config
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg
+		return nil, nil
+	})
+
+	env := map[string]string{"GOPRIVATE": "example.com/*", "GOFLAGS": "-mod=mod"}
+	_, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetGoEnv(env), SetBuildFlags("-mod=mod", "-trimpath"), SetParallelism(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"-tags=", "-mod=mod", "-trimpath", "-p=2"}, got.BuildFlags); diff != "" {
+		t.Errorf("BuildFlags (-want, +got)\n%s", diff)
+	}
+
+	// the variables are set after those of the process, so that they take
+	// precedence.
+	n := len(got.Env)
+	if n < 3 {
+		t.Fatalf("Env = %v; want at least 3 variables", got.Env)
+	}
+	if diff := cmp.Diff([]string{"GOFLAGS=-mod=mod", "GOPRIVATE=example.com/*", "GOMAXPROCS=2"}, got.Env[n-3:]); diff != "" {
+		t.Errorf("Env (-want, +got)\n%s", diff)
+	}
+}
+
+func TestSetBuildFlags_Invalid(t *testing.T) {
+	for _, flags := range [][]string{{"mod=vendor"}, {"-tags=integration"}, {"--tags", "integration"}} {
+		if _, err := New(SetPackager(&testPackager{}), SetBuildFlags(flags...)); err == nil {
+			t.Errorf("SetBuildFlags(%q): got nil error", flags)
+		}
+	}
+	if _, err := New(SetPackager(&testPackager{}), SetGoEnv(map[string]string{"A=B": "C"})); err == nil {
+		t.Error("SetGoEnv: got nil error for an invalid name")
+	}
+}
+
+func TestGTA_Context(t *testing.T) {
+	var got *packages.Config
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Context != ctx {
+		t.Errorf("Context = %v; want %v", got.Context, ctx)
+	}
+}
+
+func TestSetParallelism_Invalid(t *testing.T) {
+	if _, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetParallelism(-1)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestValidMemoryLimit(t *testing.T) {
+	tests := []struct {
+		limit string
+		valid bool
+	}{
+		{limit: "off", valid: true},
+		{limit: "1073741824", valid: true},
+		{limit: "512MiB", valid: true},
+		{limit: "2GiB", valid: true},
+		{limit: "2GB", valid: false},
+		{limit: "-1", valid: false},
+		{limit: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			err := validMemoryLimit(tt.limit)
+			if got := err == nil; got != tt.valid {
+				t.Errorf("validMemoryLimit(%q) = %v; want valid = %v", tt.limit, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestGTA_LoadMode(t *testing.T) {
+	var got *packages.Config
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		got = cfg
+		return nil, nil
+	})
+
+	if _, err := New(SetDiffer(&testDiffer{}), SetLoader(loader)); err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode != DefaultLoadMode {
+		t.Errorf("Mode = %v; want %v", got.Mode, DefaultLoadMode)
+	}
+
+	if _, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetLoadMode(RequiredLoadMode)); err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode != RequiredLoadMode {
+		t.Errorf("Mode = %v; want %v", got.Mode, RequiredLoadMode)
+	}
+}
+
+func TestSetLoadMode_Invalid(t *testing.T) {
+	if _, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetLoadMode(packages.NeedName|packages.NeedFiles)); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,30 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"golang.org/x/tools/go/packages"
+)
+
+// A Loader loads the metadata of the Go packages matching patterns. The
+// default Loader uses packages.Load. Providing a Loader allows the dependency
+// graph to be built from in-memory packages, e.g. in tests.
+type Loader interface {
+	Load(cfg *packages.Config, patterns ...string) ([]*packages.Package, error)
+}
+
+// LoaderFunc is an adapter to allow the use of ordinary functions as a
+// Loader.
+type LoaderFunc func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error)
+
+// Load implements the Loader interface.
+func (f LoaderFunc) Load(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	return f(cfg, patterns...)
+}
+
+// defaultLoader loads packages using the go command.
+var defaultLoader Loader = LoaderFunc(packages.Load)
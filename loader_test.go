@@ -0,0 +1,83 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSetLoader(t *testing.T) {
+	// b depends on a
+	// c depends on b
+	a := &packages.Package{
+		ID:      "example.com/a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/repo/a/a.go"},
+	}
+	b := &packages.Package{
+		ID:      "example.com/b",
+		PkgPath: "example.com/b",
+		GoFiles: []string{"/repo/b/b.go"},
+		Imports: map[string]*packages.Package{
+			"example.com/a": a,
+		},
+	}
+	c := &packages.Package{
+		ID:      "example.com/c",
+		PkgPath: "example.com/c",
+		GoFiles: []string{"/repo/c/c.go"},
+		Imports: map[string]*packages.Package{
+			"example.com/b": b,
+		},
+	}
+
+	var gotPatterns []string
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		gotPatterns = patterns
+		return []*packages.Package{a, b, c}, nil
+	})
+
+	gta, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetPrefixes("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"example.com..."}, gotPatterns); diff != "" {
+		t.Errorf("patterns (-want, +got)\n%s", diff)
+	}
+
+	got, err := gta.packager.DependentGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Graph{
+		graph: map[string]map[string]bool{
+			"example.com/a": map[string]bool{
+				"example.com/b": true,
+			},
+			"example.com/b": map[string]bool{
+				"example.com/c": true,
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Graph{})); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	pkg, err := gta.packager.PackageFromImport("example.com/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.ImportPath != "example.com/c" {
+		t.Errorf("PackageFromImport(%q).ImportPath = %q", "example.com/c", pkg.ImportPath)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A LoadError is an error in a package that was found while loading it, such
+// as a syntax error or an import that cannot be resolved. The package is still
+// part of the dependency graph, but its imports may be incomplete, so changes
+// may not be attributed to its dependents.
+type LoadError struct {
+	// ImportPath is the import path of the package.
+	ImportPath string `json:"import_path"`
+	// Kind is the kind of the error: list, parse, type, or unknown.
+	Kind string `json:"kind"`
+	// Pos is the position of the error, e.g. file:line:col, when it is known.
+	Pos string `json:"pos,omitempty"`
+	// Msg is the message of the error.
+	Msg string `json:"msg"`
+}
+
+// String returns a description of the error.
+func (e LoadError) String() string {
+	if e.Pos != "" {
+		return fmt.Sprintf("%s: %s: %s", e.ImportPath, e.Pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.ImportPath, e.Msg)
+}
+
+// LoadErrors are the errors found while loading packages. They are an error
+// when a GTA fails on load errors.
+type LoadErrors []LoadError
+
+// Error implements the error interface.
+func (errs LoadErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.String())
+	}
+	return fmt.Sprintf("errors loading packages: %s", strings.Join(msgs, "; "))
+}
+
+// LoadErrorMode describes how a GTA handles the errors found while loading
+// packages.
+type LoadErrorMode string
+
+const (
+	// LoadErrorsWarn reports the load errors with the changed packages.
+	LoadErrorsWarn LoadErrorMode = "warn"
+
+	// LoadErrorsFail fails with the load errors, as LoadErrors, instead of
+	// reporting the changed packages.
+	LoadErrorsFail LoadErrorMode = "fail"
+
+	// LoadErrorsIgnore ignores the load errors. It is the default.
+	LoadErrorsIgnore LoadErrorMode = "ignore"
+)
+
+// SetLoadErrorMode sets how a GTA handles the errors found while loading
+// packages, so that an analysis of broken code can be told apart from one of
+// code that loads cleanly. Load errors are only found by a Packager that
+// implements LoadErrorReporter, such as the default packager.
+func SetLoadErrorMode(mode LoadErrorMode) Option {
+	return func(g *GTA) error {
+		switch mode {
+		case LoadErrorsWarn, LoadErrorsFail, LoadErrorsIgnore:
+		default:
+			return fmt.Errorf("unknown load error mode %q", mode)
+		}
+		g.loadErrMode = mode
+		return nil
+	}
+}
+
+// SetStrict sets whether New fails when the packages loaded by a GTA's
+// packager have list or parse errors, such as imports that cannot be resolved
+// or files that cannot be parsed, e.g. because misconfigured build tags
+// exclude the files of imported packages. Otherwise, the packages with errors
+// are part of the dependency graph with their imports missing, which silently
+// shrinks the set of affected packages. Type errors do not fail a strict GTA.
+func SetStrict(enabled bool) Option {
+	return func(g *GTA) error {
+		g.strict = enabled
+		return nil
+	}
+}
+
+// strictErrors returns the list and parse errors of the packages loaded by g's
+// packager as LoadErrors, or nil when there are none. Failing to load the
+// packages altogether is left to the stages that use the dependency graph.
+func (g *GTA) strictErrors() error {
+	loadErrs, err := g.loadErrors()
+	if err != nil {
+		return nil
+	}
+
+	var strict LoadErrors
+	for _, e := range loadErrs {
+		if e.Kind == "list" || e.Kind == "parse" {
+			strict = append(strict, e)
+		}
+	}
+	if len(strict) > 0 {
+		return strict
+	}
+	return nil
+}
+
+// A LoadErrorReporter is a Packager that reports the errors it found while
+// loading packages.
+type LoadErrorReporter interface {
+	// LoadErrors returns the errors found while loading packages.
+	LoadErrors() (LoadErrors, error)
+}
+
+// loadErrors returns the load errors reported by g's packager, which are none
+// when it does not report them.
+func (g *GTA) loadErrors() (LoadErrors, error) {
+	reporter, ok := g.packager.(LoadErrorReporter)
+	if !ok {
+		return nil, nil
+	}
+	return reporter.LoadErrors()
+}
+
+// packageLoadErrors returns the errors of the packages in roots and their
+// dependencies, sorted by import path, position, and message. The errors of
+// the test variants of a package are attributed to the package.
+func packageLoadErrors(roots []*packages.Package) LoadErrors {
+	seen := make(map[LoadError]struct{})
+	var errs LoadErrors
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			e := LoadError{
+				ImportPath: normalizeImportPath(pkg),
+				Kind:       loadErrorKind(err.Kind),
+				Pos:        err.Pos,
+				Msg:        err.Msg,
+			}
+			if _, ok := seen[e]; ok {
+				continue
+			}
+			seen[e] = struct{}{}
+			errs = append(errs, e)
+		}
+	})
+
+	sort.Slice(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if a.ImportPath != b.ImportPath {
+			return a.ImportPath < b.ImportPath
+		}
+		if a.Pos != b.Pos {
+			return a.Pos < b.Pos
+		}
+		return a.Msg < b.Msg
+	})
+	return errs
+}
+
+// loadErrorKind returns the name of the kind of a packages.Error.
+func loadErrorKind(kind packages.ErrorKind) string {
+	switch kind {
+	case packages.ListError:
+		return "list"
+	case packages.ParseError:
+		return "parse"
+	case packages.TypeError:
+		return "type"
+	}
+	return "unknown"
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+// brokenLoader returns a Loader that loads a package with an unresolved
+// import, whose test variant repeats the error, and a package with a syntax
+// error.
+func brokenLoader() Loader {
+	b := &packages.Package{
+		ID:      "example.com/b",
+		Name:    "b",
+		PkgPath: "example.com/b",
+		GoFiles: []string{"/src/b/b.go"},
+		Errors: []packages.Error{
+			{Pos: "/src/b/b.go:3:2", Msg: "syntax error: unexpected newline", Kind: packages.ParseError},
+		},
+	}
+	a := &packages.Package{
+		ID:      "example.com/a",
+		Name:    "a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go"},
+		Imports: map[string]*packages.Package{"example.com/b": b},
+		Errors: []packages.Error{
+			{Pos: "/src/a/a.go:4:2", Msg: "could not import example.com/gone", Kind: packages.ListError},
+		},
+	}
+	aTest := &packages.Package{
+		ID:      "example.com/a [example.com/a.test]",
+		Name:    "a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go", "/src/a/a_test.go"},
+		Imports: map[string]*packages.Package{"example.com/b": b},
+		Errors:  a.Errors,
+	}
+	return LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{a, aTest, b}, nil
+	})
+}
+
+func TestPackageLoadErrors(t *testing.T) {
+	pkgs, err := brokenLoader().Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := LoadErrors{
+		{ImportPath: "example.com/a", Kind: "list", Pos: "/src/a/a.go:4:2", Msg: "could not import example.com/gone"},
+		{ImportPath: "example.com/b", Kind: "parse", Pos: "/src/b/b.go:3:2", Msg: "syntax error: unexpected newline"},
+	}
+	if diff := cmp.Diff(want, packageLoadErrors(pkgs)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_LoadErrorMode(t *testing.T) {
+	tests := []struct {
+		mode    LoadErrorMode
+		want    int
+		wantErr bool
+	}{
+		{mode: LoadErrorsIgnore},
+		{mode: LoadErrorsWarn, want: 2},
+		{mode: LoadErrorsFail, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			gt, err := New(SetDiffer(&testDiffer{}), SetLoader(brokenLoader()), SetLoadErrorMode(tt.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if tt.wantErr {
+				var loadErrs LoadErrors
+				if !errors.As(err, &loadErrs) || len(loadErrs) != 2 {
+					t.Errorf("err = %v; want the load errors", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pkgs.LoadErrors) != tt.want {
+				t.Errorf("len(LoadErrors) = %d; want %d", len(pkgs.LoadErrors), tt.want)
+			}
+		})
+	}
+}
+
+func TestSetLoadErrorMode_Unknown(t *testing.T) {
+	if _, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetLoadErrorMode("panic")); err == nil {
+		t.Error("expected an error for an unknown load error mode")
+	}
+}
+
+func TestSetStrict(t *testing.T) {
+	if _, err := New(SetDiffer(&testDiffer{}), SetLoader(brokenLoader())); err != nil {
+		t.Errorf("expected no error without strict, got %v", err)
+	}
+
+	_, err := New(SetDiffer(&testDiffer{}), SetLoader(brokenLoader()), SetStrict(true))
+	var loadErrs LoadErrors
+	if !errors.As(err, &loadErrs) || len(loadErrs) != 2 {
+		t.Errorf("err = %v; want the load errors", err)
+	}
+
+	// type errors do not fail a strict GTA.
+	typeErrLoader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{{
+			ID:      "example.com/a",
+			Name:    "a",
+			PkgPath: "example.com/a",
+			GoFiles: []string{"/src/a/a.go"},
+			Errors: []packages.Error{
+				{Pos: "/src/a/a.go:5:9", Msg: "undefined: b", Kind: packages.TypeError},
+			},
+		}}, nil
+	})
+	if _, err := New(SetDiffer(&testDiffer{}), SetLoader(typeErrLoader), SetStrict(true)); err != nil {
+		t.Errorf("expected no error for type errors, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MappingRulesFile is the conventional name of the file of mapping rules at
+// the root of a repository.
+const MappingRulesFile = ".gta-mappings"
+
+// A MappingRule maps non-Go files, such as protocol buffer definitions or SQL
+// migrations, to the packages they affect or to pseudo-targets, which name
+// things other than packages that the files affect, such as documentation.
+// Changes to the files mark the packages, and their dependents, as changed,
+// and are reported as changes to the pseudo-targets.
+type MappingRule struct {
+	// Pattern is the absolute glob pattern of the files, with the syntax of
+	// the patterns of a SpecMapping.
+	Pattern string
+
+	// Dirs are the absolute paths of the directories of the packages.
+	Dirs []string
+
+	// Targets are the names of the pseudo-targets.
+	Targets []string
+}
+
+// ReadMappingRules reads the mapping rules of the file fn. Each line of the
+// file that is not blank or a comment, which starts with #, is a rule of the
+// form
+//
+//	pattern -> target...
+//
+// where pattern is a glob pattern of files, and the targets are directories of
+// packages or, when they start with a colon, pseudo-targets. The patterns and
+// directories are slash separated paths relative to the directory of fn, e.g.
+//
+//	api/*.proto -> gen/apipb
+//	migrations/*.sql -> internal/db
+//	docs/** -> :docs
+func ReadMappingRules(fn string) ([]MappingRule, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	abs, err := filepath.Abs(fn)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(abs)
+
+	var rules []MappingRule
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		i := strings.Index(text, "->")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: missing -> in mapping rule", fn, line)
+		}
+		pattern := strings.TrimSpace(text[:i])
+		targets := strings.Fields(text[i+len("->"):])
+		if pattern == "" || len(targets) == 0 {
+			return nil, fmt.Errorf("%s:%d: mapping rule needs a pattern and a target", fn, line)
+		}
+
+		rule := MappingRule{Pattern: filepath.Join(dir, filepath.FromSlash(pattern))}
+		for _, target := range targets {
+			if strings.HasPrefix(target, ":") {
+				rule.Targets = append(rule.Targets, strings.TrimPrefix(target, ":"))
+				continue
+			}
+			rule.Dirs = append(rule.Dirs, filepath.Join(dir, filepath.FromSlash(target)))
+		}
+		rules = append(rules, rule)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// rulePackages returns the import paths of the packages that g's mapping
+// rules map the file abs to.
+func (g *GTA) rulePackages(abs string) ([]string, error) {
+	var pkgs []string
+	for _, rule := range g.mappingRules {
+		if !matchGlob(filepath.ToSlash(rule.Pattern), filepath.ToSlash(abs)) {
+			continue
+		}
+		for _, dir := range rule.Dirs {
+			pkg, err := g.packager.PackageFromDir(dir)
+			if err != nil {
+				return nil, fmt.Errorf("mapping rule %s, %v", rule.Pattern, err)
+			}
+			pkgs = append(pkgs, pkg.ImportPath)
+		}
+	}
+	return pkgs, nil
+}
+
+// triggerPackages returns the import paths of every package of g's packager
+// when the file abs matches any of g's trigger files.
+func (g *GTA) triggerPackages(abs string) ([]string, error) {
+	matched := false
+	for _, pattern := range g.triggerFiles {
+		if matchGlob(filepath.ToSlash(pattern), filepath.ToSlash(abs)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil, nil
+	}
+
+	locator, ok := g.packager.(PackageLocator)
+	if !ok {
+		return nil, fmt.Errorf("trigger file %s, the packager can't list its packages", abs)
+	}
+	dirs, err := locator.PackageDirs()
+	if err != nil {
+		return nil, fmt.Errorf("trigger file %s, %v", abs, err)
+	}
+
+	pkgs := make([]string, 0, len(dirs))
+	for importPath := range dirs {
+		pkgs = append(pkgs, importPath)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// ruleTargets returns the pseudo-targets that g's mapping rules map the file
+// abs to.
+func (g *GTA) ruleTargets(abs string) []string {
+	var targets []string
+	for _, rule := range g.mappingRules {
+		if matchGlob(filepath.ToSlash(rule.Pattern), filepath.ToSlash(abs)) {
+			targets = append(targets, rule.Targets...)
+		}
+	}
+	return targets
+}
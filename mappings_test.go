@@ -0,0 +1,222 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadMappingRules(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, MappingRulesFile, `# generated code
+api/*.proto -> gen/apipb
+
+migrations/*.sql -> internal/db internal/db/testing
+docs/** -> :docs
+`)
+
+	got, err := ReadMappingRules(filepath.Join(dir, MappingRulesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MappingRule{
+		{
+			Pattern: filepath.Join(dir, "api", "*.proto"),
+			Dirs:    []string{filepath.Join(dir, "gen", "apipb")},
+		},
+		{
+			Pattern: filepath.Join(dir, "migrations", "*.sql"),
+			Dirs:    []string{filepath.Join(dir, "internal", "db"), filepath.Join(dir, "internal", "db", "testing")},
+		},
+		{
+			Pattern: filepath.Join(dir, "docs", "**"),
+			Targets: []string{"docs"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestReadMappingRules_Invalid(t *testing.T) {
+	for _, rules := range []string{"api/*.proto gen/apipb\n", "api/*.proto ->\n", "-> gen/apipb\n"} {
+		dir := t.TempDir()
+		writeFile(t, dir, MappingRulesFile, rules)
+		if _, err := ReadMappingRules(filepath.Join(dir, MappingRulesFile)); err == nil {
+			t.Errorf("expected an error for %q", rules)
+		}
+	}
+}
+
+func TestGTA_MappingRules(t *testing.T) {
+	// A depends on B
+	// B is generated from the protocol buffer definitions
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo/api":  Directory{Exists: true, Files: []string{"droplets.proto"}},
+			"/repo/docs": Directory{Exists: true, Files: []string{"index.md"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"/repo/a":        "A",
+			"/repo/gen/b":    "B",
+			"/repo/internal": "C",
+		},
+		graph: graph,
+		errs: map[string]error{
+			"/repo/api":  &build.NoGoError{Dir: "/repo/api"},
+			"/repo/docs": &build.NoGoError{Dir: "/repo/docs"},
+		},
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{
+			"B": []Package{
+				{ImportPath: "A"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+		},
+		Targets: []string{"docs"},
+	}
+
+	rules := []MappingRule{
+		{Pattern: "/repo/api/*.proto", Dirs: []string{"/repo/gen/b"}},
+		{Pattern: "/repo/docs/**", Targets: []string{"docs"}},
+		{Pattern: "/repo/migrations/*.sql", Dirs: []string{"/repo/internal"}},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetMappingRules(rules...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_TriggerFiles(t *testing.T) {
+	// A depends on B
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo": Directory{Exists: true, Files: []string{"Makefile"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+		},
+	}
+
+	dirs := map[string]string{
+		"A": "/repo/a",
+		"B": "/repo/b",
+		"C": "/repo/c",
+	}
+	pkgr := &locatingPackager{
+		testPackager: &testPackager{
+			dirs2Imports: map[string]string{
+				"/repo/a": "A",
+				"/repo/b": "B",
+				"/repo/c": "C",
+			},
+			graph: graph,
+			errs: map[string]error{
+				"/repo": &build.NoGoError{Dir: "/repo"},
+			},
+		},
+		dirs: dirs,
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{
+			"B": []Package{
+				{ImportPath: "A"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+			{ImportPath: "C"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+			{ImportPath: "C"},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetTriggerFiles("/repo/Makefile", "/repo/.ci/*.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_TriggerFilesWithoutLocator(t *testing.T) {
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo": Directory{Exists: true, Files: []string{"Makefile"}},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"/repo/a": "A",
+		},
+		graph: &Graph{graph: map[string]map[string]bool{}},
+		errs: map[string]error{
+			"/repo": &build.NoGoError{Dir: "/repo"},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetTriggerFiles("/repo/Makefile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error when the packager can't list its packages")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Phases of an analysis whose durations are reported in Meta.
+const (
+	// LoadPhase is loading the packages to build the dependency graph. It is
+	// only reported when the GTA loads the packages itself, rather than using
+	// a Packager set with SetPackager.
+	LoadPhase = "load"
+	// DiffPhase is determining the changed files.
+	DiffPhase = "diff"
+	// GraphPhase is finding the changed packages and traversing the
+	// dependency graph from them.
+	GraphPhase = "graph"
+	// ReportPhase is building the results and the enabled reports.
+	ReportPhase = "report"
+)
+
+// Meta describes the cost of an analysis, e.g. for dashboards that track it
+// alongside the results.
+type Meta struct {
+	// Durations contains a map of the phases of the analysis to their
+	// durations, which are in nanoseconds in JSON.
+	Durations map[string]time.Duration `json:"durations"`
+	// GraphPackages and GraphEdges are the number of packages and imports in
+	// the dependency graph that was traversed.
+	GraphPackages int `json:"graph_packages"`
+	GraphEdges    int `json:"graph_edges"`
+	// Version is the version of the gta module that performed the analysis,
+	// or "(devel)" or empty when it is not known.
+	Version string `json:"version,omitempty"`
+}
+
+// reportMeta sets cp.Meta when the meta report is enabled. m are the marks cp
+// was built from, and graphTime and reportTime the durations of the graph and
+// report phases.
+func (g *GTA) reportMeta(cp *Packages, m *marks, graphTime, reportTime time.Duration) {
+	if !g.metaReport {
+		return
+	}
+
+	meta := &Meta{
+		Durations: map[string]time.Duration{
+			DiffPhase:   g.diffTime,
+			GraphPhase:  graphTime,
+			ReportPhase: reportTime,
+		},
+		Version: moduleVersion(),
+	}
+	if g.loadTime > 0 {
+		meta.Durations[LoadPhase] = g.loadTime
+	}
+
+	// the graph is nil for partial results.
+	if m.graph != nil {
+		nodes := make(map[string]struct{})
+		for pkg, dependents := range m.graph.graph {
+			nodes[pkg] = struct{}{}
+			for dependent := range dependents {
+				nodes[dependent] = struct{}{}
+			}
+			meta.GraphEdges += len(dependents)
+		}
+		meta.GraphPackages = len(nodes)
+	}
+
+	cp.Meta = meta
+}
+
+// gtaModule is the path of the module that contains this package.
+const gtaModule = "github.com/digitalocean/gta"
+
+// moduleVersion returns the version of the gta module in the running binary.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == gtaModule {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == gtaModule {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_Meta(t *testing.T) {
+	// A depends on B and C, and B depends on C
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirC": Directory{Exists: true, Files: []string{"c.go"}},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"B": map[string]bool{"A": true},
+				"C": map[string]bool{"A": true, "B": true},
+			},
+		},
+		errs: make(map[string]error),
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetMetaReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgs.Meta == nil {
+		t.Fatal("expected meta to be reported")
+	}
+
+	// the packages were not loaded by the GTA.
+	var phases []string
+	for phase := range pkgs.Meta.Durations {
+		phases = append(phases, phase)
+	}
+	sort.Strings(phases)
+	if diff := cmp.Diff([]string{DiffPhase, GraphPhase, ReportPhase}, phases); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if got, want := pkgs.Meta.GraphPackages, 3; got != want {
+		t.Errorf("GraphPackages = %d; want %d", got, want)
+	}
+	if got, want := pkgs.Meta.GraphEdges, 3; got != want {
+		t.Errorf("GraphEdges = %d; want %d", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MetadataFile is the name of the file in the directory of a package that
+// describes the package, e.g.
+//
+//	{"description": "Billing API", "owner": "@payments", "tier": "1"}
+const MetadataFile = "PACKAGE.json"
+
+// PackageMetadata describes a package to the reviewers of its changes.
+type PackageMetadata struct {
+	// Description is a short description of the package. When the metadata
+	// file does not set it, it is the synopsis of the package's
+	// documentation.
+	Description string `json:"description,omitempty"`
+	// Owner is the team or person that owns the package.
+	Owner string `json:"owner,omitempty"`
+	// Tier is the criticality of the package, e.g. "1" for the most critical
+	// packages.
+	Tier string `json:"tier,omitempty"`
+}
+
+// metadata returns a map of the affected packages in cp to their metadata.
+// Packages without metadata are omitted.
+func (g *GTA) metadata(cp *Packages) (map[string]PackageMetadata, error) {
+	locator, ok := g.packager.(PackageLocator)
+	if !ok {
+		return nil, errors.New("the packager does not report package directories")
+	}
+
+	dirs, err := locator.PackageDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]PackageMetadata)
+	for _, pkg := range cp.AllChanges {
+		dir, ok := dirs[pkg.ImportPath]
+		if !ok {
+			continue
+		}
+
+		md, err := readPackageMetadata(dir)
+		if err != nil {
+			return nil, err
+		}
+		if md != (PackageMetadata{}) {
+			metadata[pkg.ImportPath] = md
+		}
+	}
+	return metadata, nil
+}
+
+// readPackageMetadata returns the metadata of the package in dir from its
+// metadata file and its documentation.
+func readPackageMetadata(dir string) (PackageMetadata, error) {
+	var md PackageMetadata
+
+	fn := filepath.Join(dir, MetadataFile)
+	b, err := ioutil.ReadFile(fn)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &md); err != nil {
+			return PackageMetadata{}, fmt.Errorf("parsing %s: %v", fn, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return PackageMetadata{}, err
+	}
+
+	if md.Description == "" {
+		if md.Description, err = docSynopsis(dir); err != nil {
+			return PackageMetadata{}, err
+		}
+	}
+	return md, nil
+}
+
+// docSynopsis returns the first sentence of the documentation of the package
+// in dir, preferring the comment in doc.go, or an empty string when the
+// package is not documented.
+func docSynopsis(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+
+	// the package comment is conventionally in doc.go.
+	sort.SliceStable(matches, func(i, j int) bool {
+		return filepath.Base(matches[i]) == "doc.go" && filepath.Base(matches[j]) != "doc.go"
+	})
+
+	fset := token.NewFileSet()
+	for _, fn := range matches {
+		if strings.HasSuffix(fn, "_test.go") {
+			continue
+		}
+
+		f, err := parser.ParseFile(fset, fn, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			// files that cannot be parsed are reported when the package is
+			// built.
+			continue
+		}
+		// the synopsis of a copyright header that precedes the package
+		// clause is empty.
+		if f.Doc != nil {
+			if synopsis := doc.Synopsis(f.Doc.Text()); synopsis != "" {
+				return synopsis, nil
+			}
+		}
+	}
+	return "", nil
+}
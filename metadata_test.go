@@ -0,0 +1,102 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// locatingPackager is a testPackager that locates packages.
+type locatingPackager struct {
+	*testPackager
+	dirs map[string]string
+}
+
+func (p *locatingPackager) PackageDirs() (map[string]string, error) {
+	return p.dirs, nil
+}
+
+func TestGTA_Metadata(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, root, "a/PACKAGE.json", `{"owner": "@team-a", "tier": "1"}`)
+	writeFile(t, root, "a/a.go", "package a\n")
+	writeFile(t, root, "a/doc.go", "// Package a bills customers. It is critical.\npackage a\n")
+	writeFile(t, root, "b/PACKAGE.json", `{"description": "Package b ships orders.", "owner": "@team-b"}`)
+	writeFile(t, root, "c/c.go", "/*\nCopyright 2016 The gta AUTHORS. All rights reserved.\n*/\npackage c\n")
+
+	// A depends on B and C
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true, Files: []string{"b.go"}},
+			"dirC": Directory{Exists: true, Files: []string{"c.go"}},
+		},
+	}
+
+	pkgr := &locatingPackager{
+		testPackager: &testPackager{
+			dirs2Imports: map[string]string{
+				"dirA": "A",
+				"dirB": "B",
+				"dirC": "C",
+			},
+			graph: &Graph{
+				graph: map[string]map[string]bool{
+					"B": map[string]bool{"A": true},
+					"C": map[string]bool{"A": true},
+				},
+			},
+			errs: make(map[string]error),
+		},
+		dirs: map[string]string{
+			"A": filepath.Join(root, "a"),
+			"B": filepath.Join(root, "b"),
+			"C": filepath.Join(root, "c"),
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetMetadataReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]PackageMetadata{
+		"A": {Description: "Package a bills customers.", Owner: "@team-a", Tier: "1"},
+		"B": {Description: "Package b ships orders.", Owner: "@team-b"},
+	}
+	if diff := cmp.Diff(want, pkgs.Metadata); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_MetadataUnsupported(t *testing.T) {
+	pkgr := &testPackager{
+		graph: &Graph{},
+	}
+
+	gta, err := New(SetDiffer(&testDiffer{}), SetPackager(pkgr), SetMetadataReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error")
+	}
+}
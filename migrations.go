@@ -0,0 +1,70 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+// Migrations describes a directory of database migrations and the packages
+// that embed or reference it. Changes to the files within the directory mark
+// the packages, and their dependents, as changed even though no Go file
+// changed.
+type Migrations struct {
+	// Dir is the absolute path of the directory that contains the migrations.
+	Dir string
+
+	// Packages are the import paths of the packages that embed or reference the
+	// migrations.
+	Packages []string
+
+	// DetectPackages causes the packages with a Go file that has a go:embed
+	// directive matching files within Dir, or a string literal that refers to
+	// Dir or a file within it, to be included, too. The Go files of the
+	// packages within the directory of the GTA, which is the current working
+	// directory unless it is set with SetDir, are scanned.
+	DetectPackages bool
+}
+
+// migrationPackages returns the import paths of the packages that embed or
+// reference the migrations that contain the file abs.
+func (g *GTA) migrationPackages(abs string) ([]string, error) {
+	var pkgs []string
+	for i, migrations := range g.migrations {
+		if !withinDir(abs, migrations.Dir) {
+			continue
+		}
+
+		pkgs = append(pkgs, migrations.Packages...)
+		if !migrations.DetectPackages {
+			continue
+		}
+
+		if g.detectedMigrationPackages == nil {
+			g.detectedMigrationPackages = make(map[int][]string)
+		}
+		detected, ok := g.detectedMigrationPackages[i]
+		if !ok {
+			wd, err := g.workingDir()
+			if err != nil {
+				return nil, err
+			}
+
+			detected, err = detectMigrationPackages(wd, migrations.Dir, g.packager.PackageFromDir)
+			if err != nil {
+				return nil, err
+			}
+			g.detectedMigrationPackages[i] = detected
+		}
+		pkgs = append(pkgs, detected...)
+	}
+
+	return pkgs, nil
+}
+
+// detectMigrationPackages returns the sorted import paths of the packages
+// within root with a Go file that embeds or refers to dir or a file within it.
+func detectMigrationPackages(root, dir string, packageFromDir func(string) (*Package, error)) ([]string, error) {
+	all := func(string) bool { return true }
+	return detectReferrers(root, dir, all, packageFromDir)
+}
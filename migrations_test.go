@@ -0,0 +1,145 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_Migrations(t *testing.T) {
+	// A depends on B
+	// C depends on A
+	// migrations are embedded by B
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo/migrations": Directory{Exists: true, Files: []string{"0001_init.sql"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+			"A": map[string]bool{
+				"C": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: graph,
+		errs: map[string]error{
+			"/repo/migrations": &build.NoGoError{Dir: "/repo/migrations"},
+		},
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{
+			"B": []Package{
+				{ImportPath: "A"},
+				{ImportPath: "C"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+			{ImportPath: "C"},
+		},
+	}
+
+	migrations := Migrations{
+		Dir:      "/repo/migrations",
+		Packages: []string{"B"},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetMigrations(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestDetectMigrationPackages(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		"db/migrations/0001_init.sql": `CREATE TABLE droplets (id INTEGER);`,
+		"db/db.go": `package db
+
+import "embed"
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+`,
+		"store/store.go": `package store
+
+const migrations = "db/migrations"
+`,
+		"parent/parent.go": `package parent
+
+import "embed"
+
+//go:embed all:db
+var db embed.FS
+`,
+		"unrelated/unrelated.go": `package unrelated
+
+import "embed"
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+`,
+	}
+
+	for fn, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(fn))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, root, fn, content)
+	}
+
+	packageFromDir := func(dir string) (*Package, error) {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		return &Package{ImportPath: "example.com/" + filepath.ToSlash(rel)}, nil
+	}
+
+	got, err := detectMigrationPackages(root, filepath.Join(root, "db", "migrations"), packageFromDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/db", "example.com/store"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+// SetModules restricts a GTA to the packages of the modules with the paths,
+// e.g. to analyze some of the modules of a multi-module repository or go.work
+// workspace. Unless prefixes are set, only the packages of the modules are
+// loaded, and only they are reported. Unlike prefixes, which match import
+// paths as strings, the packages of modules nested within the modules, whose
+// paths have the paths of the modules as prefixes, are excluded when the
+// default packager loads them.
+func SetModules(paths ...string) Option {
+	return func(g *GTA) error {
+		g.modules = paths
+		return nil
+	}
+}
+
+// included reports whether the package importPath is within g's prefixes and
+// modules, and not excluded by g's patterns, and so may be reported.
+func (g *GTA) included(importPath string) bool {
+	return hasPrefixIn(importPath, g.prefixes) && g.inModules(importPath) && !g.excluded[importPath]
+}
+
+// inModules reports whether the package importPath belongs to one of g's
+// modules, which it always does when no modules are set.
+func (g *GTA) inModules(importPath string) bool {
+	if len(g.modules) == 0 {
+		return true
+	}
+
+	if g.knownModules == nil {
+		g.knownModules = make(map[string]bool)
+		if p, ok := g.packager.(*packageContext); ok {
+			for _, path := range p.modulesNamesByDir {
+				g.knownModules[path] = false
+			}
+		}
+		for _, path := range g.modules {
+			g.knownModules[path] = true
+		}
+	}
+
+	// the package belongs to the module with the longest path that provides
+	// it, which may be a module nested within one of g's modules.
+	return g.knownModules[moduleOf(importPath, g.knownModules)]
+}
+
+// modulePatterns returns the patterns that match the packages of g's modules.
+func (g *GTA) modulePatterns() []string {
+	patterns := make([]string, 0, len(g.modules))
+	for _, path := range g.modules {
+		patterns = append(patterns, path+"/...")
+	}
+	return patterns
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSetModules(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Setenv(t, "GOWORK", "")()
+	for _, d := range []string{"lib", "tools"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.mod", "module example.com/app\n")
+	writeFile(t, dir, "tools/go.mod", "module example.com/app/tools\n")
+	writeFile(t, dir, "go.work", "go 1.18\n\nuse (\n\t.\n\t./tools\n)\n")
+
+	var gotPatterns []string
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		gotPatterns = patterns
+		appModule := &packages.Module{Path: "example.com/app", Dir: dir, Main: true}
+		toolsModule := &packages.Module{Path: "example.com/app/tools", Dir: filepath.Join(dir, "tools"), Main: true}
+		lib := &packages.Package{
+			ID:      "example.com/app/lib",
+			Name:    "lib",
+			PkgPath: "example.com/app/lib",
+			GoFiles: []string{filepath.Join(dir, "lib", "lib.go")},
+			Module:  appModule,
+		}
+		cmd := &packages.Package{
+			ID:      "example.com/app/cmd",
+			Name:    "main",
+			PkgPath: "example.com/app/cmd",
+			GoFiles: []string{filepath.Join(dir, "cmd", "main.go")},
+			Imports: map[string]*packages.Package{"example.com/app/lib": lib},
+			Module:  appModule,
+		}
+		gen := &packages.Package{
+			ID:      "example.com/app/tools/gen",
+			Name:    "main",
+			PkgPath: "example.com/app/tools/gen",
+			GoFiles: []string{filepath.Join(dir, "tools", "gen", "main.go")},
+			Imports: map[string]*packages.Package{"example.com/app/lib": lib},
+			Module:  toolsModule,
+		}
+		return []*packages.Package{lib, cmd, gen}, nil
+	})
+
+	writeFile(t, dir, "lib/lib.go", "package lib\n")
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(dir, "lib"): {Exists: true, Files: []string{"lib.go"}},
+		},
+	}
+
+	gt, err := New(SetDir(dir), SetLoader(loader), SetDiffer(difr), SetModules("example.com/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"example.com/app/..."}, gotPatterns); diff != "" {
+		t.Errorf("patterns: (-want, +got)\n%s", diff)
+	}
+
+	cp, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the package of the nested tools module of the workspace depends on the
+	// changed package, but is not reported, although its import path has the
+	// path of the app module as a prefix.
+	want := []string{"example.com/app/cmd", "example.com/app/lib"}
+	if diff := cmp.Diff(want, stringify(cp.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// NewNameStatusDiffer returns a Differ for the changes listed in a saved
+// output of git diff --name-status or git diff --raw read from r, with or
+// without -z, preserving the status of each change. The paths in the listing
+// are relative to the current working directory.
+func NewNameStatusDiffer(r io.Reader) (Differ, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return NewNameStatusDifferWithRoot(wd, r)
+}
+
+// NewNameStatusDifferWithRoot returns a Differ for the changes listed in a
+// saved output of git diff --name-status or git diff --raw read from r. The
+// paths in the listing are relative to root, such as the root of the
+// repository, as git lists them. The returned Differ is also a
+// FileChangeDiffer.
+func NewNameStatusDifferWithRoot(root string, r io.Reader) (Differ, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := nameStatusFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := nameStatusFieldChanges(root, fields)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileChangesDiffer(changes)
+}
+
+// nameStatusFields returns the fields of the output of git diff --name-status
+// or --raw in b as though it was written with -z.
+func nameStatusFields(b []byte) ([]string, error) {
+	if bytes.IndexByte(b, 0) >= 0 {
+		return strings.Split(strings.TrimSuffix(string(b), "\x00"), "\x00"), nil
+	}
+
+	// without -z, each change is a line of tab separated fields, and paths
+	// with unusual characters are quoted.
+	var fields []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("malformed change %q", line)
+		}
+		for i := 1; i < len(parts); i++ {
+			parts[i] = unquotePatchPath(parts[i])
+		}
+		fields = append(fields, parts...)
+	}
+	return fields, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewNameStatusDifferWithRoot(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	abs := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+
+	want := []FileChange{
+		{Path: abs("foo/foo.go"), Status: StatusModified},
+		{Path: abs("bar/bar.go"), Status: StatusAdded},
+		{Path: abs("baz/baz.go"), Status: StatusDeleted},
+		{Path: abs("qux/new.go"), Status: StatusRenamed, OldPath: abs("qux/old.go")},
+	}
+
+	tests := []struct {
+		desc string
+		in   string
+		want []FileChange
+	}{
+		{
+			desc: "name-status",
+			in:   "M\tfoo/foo.go\nA\tbar/bar.go\nD\tbaz/baz.go\nR087\tqux/old.go\tqux/new.go\n",
+			want: want,
+		},
+		{
+			desc: "name-status -z",
+			in:   "M\x00foo/foo.go\x00A\x00bar/bar.go\x00D\x00baz/baz.go\x00R087\x00qux/old.go\x00qux/new.go\x00",
+			want: want,
+		},
+		{
+			desc: "raw",
+			in: ":100644 100644 bcd1234 0123456 M\tfoo/foo.go\n" +
+				":000000 100644 0000000 1234567 A\tbar/bar.go\n" +
+				":100644 000000 1234567 0000000 D\tbaz/baz.go\n" +
+				":100644 100644 abcd123 1234567 R087\tqux/old.go\tqux/new.go\n",
+			want: want,
+		},
+		{
+			desc: "raw -z",
+			in: ":100644 100644 bcd1234 0123456 M\x00foo/foo.go\x00" +
+				":000000 100644 0000000 1234567 A\x00bar/bar.go\x00" +
+				":100644 000000 1234567 0000000 D\x00baz/baz.go\x00" +
+				":100644 100644 abcd123 1234567 R087\x00qux/old.go\x00qux/new.go\x00",
+			want: want,
+		},
+		{
+			desc: "quoted",
+			in:   "M\t\"sp\\303\\244ce/tab\\tname.go\"\r\nC100\tfoo/a.go\tfoo/b.go\n",
+			want: []FileChange{
+				{Path: abs("späce/tab\tname.go"), Status: StatusModified},
+				{Path: abs("foo/b.go"), Status: StatusCopied, OldPath: abs("foo/a.go")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			difr, err := NewNameStatusDifferWithRoot(root, strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := difr.(FileChangeDiffer).FileChanges()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewNameStatusDifferWithRoot_Malformed(t *testing.T) {
+	for _, in := range []string{"foo/foo.go\n", "R100\x00old.go\x00"} {
+		if _, err := NewNameStatusDifferWithRoot("/repo", strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
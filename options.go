@@ -6,13 +6,33 @@ in the LICENSE file.
 */
 package gta
 
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
 // Option is an option function used to modify a GTA.
 type Option func(*GTA) error
 
-// SetDiffer sets a differ on a GTA.
+// SetDiffer sets a differ on a GTA, replacing any differs that were
+// previously set.
 func SetDiffer(d Differ) Option {
 	return func(g *GTA) error {
-		g.differ = d
+		g.differs = []labeledDiffer{{differ: d}}
+		return nil
+	}
+}
+
+// AddDiffer adds a differ to a GTA. The changes of all differs are combined,
+// and the label of each differ is reported as an origin of the packages its
+// changes cause to be dirty (e.g. "git", "codegen", or "manual").
+func AddDiffer(label string, d Differ) Option {
+	return func(g *GTA) error {
+		g.differs = append(g.differs, labeledDiffer{label: label, differ: d})
 		return nil
 	}
 }
@@ -25,6 +45,155 @@ func SetPackager(p Packager) Option {
 	}
 }
 
+// SetLoader sets the Loader used to load packages when a GTA builds its
+// default Packager and when it type checks packages. It has no effect on the
+// dependency graph when a Packager is set with SetPackager.
+func SetLoader(loader Loader) Option {
+	return func(g *GTA) error {
+		g.loader = loader
+		return nil
+	}
+}
+
+// SetGraphCache sets the directory in which a GTA caches the dependency graph
+// of its default Packager, e.g. the directory returned by
+// DefaultGraphCacheDir. Cached graphs are keyed by the commit checked out, the
+// go.mod and go.sum files, the build tags, and the patterns loaded, so that a
+// graph is loaded again whenever any of them change. The cache is not used
+// when the working tree has uncommitted changes. When dir is empty, the
+// dependency graph is not cached.
+func SetGraphCache(dir string) Option {
+	return func(g *GTA) error {
+		g.cacheDir = dir
+		return nil
+	}
+}
+
+// SetCacheKeyExtras adds inputs to the key of the cached dependency graph,
+// e.g. the version of a code generator or a hash of a vendored toolchain, so
+// that the cached graph is loaded again when inputs other than Go files that
+// influence the generated code change. The extras are part of the key in
+// order.
+func SetCacheKeyExtras(extras ...string) Option {
+	return func(g *GTA) error {
+		g.cacheExtras = extras
+		return nil
+	}
+}
+
+// SetParallelism limits the number of packages that are loaded in parallel,
+// the number of programs the go command runs in parallel, and the number of
+// goroutines that build the dependency graph, to n. When n is zero, the go
+// command's defaults are used, and GOMAXPROCS goroutines build the graph.
+func SetParallelism(n int) Option {
+	return func(g *GTA) error {
+		if n < 0 {
+			return fmt.Errorf("invalid parallelism %d: must not be negative", n)
+		}
+		g.parallelism = n
+		return nil
+	}
+}
+
+// SetMemoryLimit sets a soft memory limit, in the format of the GOMEMLIMIT
+// environment variable (e.g. "2GiB"), for the go commands run to load
+// packages.
+func SetMemoryLimit(limit string) Option {
+	return func(g *GTA) error {
+		if err := validMemoryLimit(limit); err != nil {
+			return err
+		}
+		g.memoryLimit = limit
+		return nil
+	}
+}
+
+// SetGoEnv sets environment variables of the go commands run to load
+// packages, in addition to those of the process, e.g. GOFLAGS, GOPRIVATE, or
+// GOPROXY, so that packages are loaded the same way in every environment.
+func SetGoEnv(env map[string]string) Option {
+	return func(g *GTA) error {
+		for k := range env {
+			if k == "" || strings.Contains(k, "=") {
+				return fmt.Errorf("invalid environment variable name %q", k)
+			}
+		}
+		g.goEnv = env
+		return nil
+	}
+}
+
+// SetBuildFlags sets additional build flags of the go commands run to load
+// packages, e.g. -mod=vendor. Build tags are set with SetTags rather than
+// with a -tags flag.
+func SetBuildFlags(flags ...string) Option {
+	return func(g *GTA) error {
+		for _, flag := range flags {
+			if !strings.HasPrefix(flag, "-") {
+				return fmt.Errorf("invalid build flag %q: must start with -", flag)
+			}
+			if name := strings.TrimLeft(strings.SplitN(flag, "=", 2)[0], "-"); name == "tags" {
+				return fmt.Errorf("invalid build flag %q: use SetTags to set build tags", flag)
+			}
+		}
+		g.buildFlags = flags
+		return nil
+	}
+}
+
+// SetLoadMode sets the mode in which the default packager loads packages,
+// which must include RequiredLoadMode. Leaving out packages.NeedDeps from
+// DefaultLoadMode lowers the memory used to load the packages of large
+// repositories, at the cost of import chains through the dependencies outside
+// of the prefixes, which are then not followed.
+func SetLoadMode(mode packages.LoadMode) Option {
+	return func(g *GTA) error {
+		if mode&RequiredLoadMode != RequiredLoadMode {
+			return fmt.Errorf("invalid load mode %v: must include %v", mode, RequiredLoadMode)
+		}
+		g.loadMode = mode
+		return nil
+	}
+}
+
+// SetContext sets the context used to load packages and, when no differ is
+// set, to run git. Loading is cancelled when ctx is done. Differs have their
+// own options to set their context.
+func SetContext(ctx context.Context) Option {
+	return func(g *GTA) error {
+		g.ctx = ctx
+		return nil
+	}
+}
+
+// SetDir sets the directory in which a GTA loads packages and, when no differ
+// is set, runs git. When it is not set, the current working directory is used.
+// Setting it allows modules in different directories to be analyzed in one
+// process without changing the working directory.
+func SetDir(dir string) Option {
+	return func(g *GTA) error {
+		g.dir = dir
+		return nil
+	}
+}
+
+// SetModuleDir sets the directory of the Go module that a GTA analyzes when
+// the module is in a subdirectory of the repository, e.g. backend. Packages
+// are loaded in dir, as with SetDir, while the changes are still determined
+// for the whole repository. Changed files outside of dir only change the
+// packages that the GTA's fixtures, migrations, and specification mappings map
+// them to.
+func SetModuleDir(dir string) Option {
+	return func(g *GTA) error {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		g.dir, g.moduleDir = abs, abs
+		return nil
+	}
+}
+
 // SetPrefixes sets a list of prefix to be included
 func SetPrefixes(prefixes ...string) Option {
 	return func(g *GTA) error {
@@ -33,6 +202,48 @@ func SetPrefixes(prefixes ...string) Option {
 	}
 }
 
+// SetRoots restricts the packages that are reported as changed to those that
+// the root packages depend on, directly or transitively, and the roots
+// themselves, e.g. the packages that feed into a single deployable. A root is
+// an import path, or an import path followed by "/..." to match the package
+// and all packages beneath it. When no roots are set, all packages are
+// considered.
+func SetRoots(roots ...string) Option {
+	return func(g *GTA) error {
+		g.roots = roots
+		return nil
+	}
+}
+
+// SetDirection sets the direction in which a GTA follows the dependency graph
+// from changed packages to find the other packages that are reported as
+// changed. It defaults to DependentsDirection.
+func SetDirection(direction Direction) Option {
+	return func(g *GTA) error {
+		switch direction {
+		case DependentsDirection, DependenciesDirection, BothDirections:
+		default:
+			return fmt.Errorf("unknown direction %q", direction)
+		}
+		g.direction = direction
+		return nil
+	}
+}
+
+// SetMaxDepth limits the number of imports a GTA follows from each changed
+// package, in its direction, to n, e.g. 1 to report only the direct importers
+// of the changed packages. When n is zero, which is the default, every
+// package that transitively depends on a changed package is reported.
+func SetMaxDepth(n int) Option {
+	return func(g *GTA) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max depth %d: must not be negative", n)
+		}
+		g.maxDepth = n
+		return nil
+	}
+}
+
 // SetTags sets a list of build tags to consider.
 func SetTags(tags ...string) Option {
 	return func(g *GTA) error {
@@ -40,3 +251,294 @@ func SetTags(tags ...string) Option {
 		return nil
 	}
 }
+
+// SetTagSets sets the build tag configurations under which a GTA reports
+// whether each affected package is affected, so that only the relevant
+// configurations need to be tested for each package. The packages are loaded
+// once for each tag set using the GTA's Loader. Tag set names must be unique.
+func SetTagSets(sets ...TagSet) Option {
+	return func(g *GTA) error {
+		names := make(map[string]struct{}, len(sets))
+		for _, set := range sets {
+			if _, ok := names[set.Name]; ok {
+				return fmt.Errorf("duplicate tag set %q", set.Name)
+			}
+			names[set.Name] = struct{}{}
+		}
+
+		g.tagSets = sets
+		return nil
+	}
+}
+
+// SetPlatforms sets the platforms, of the form goos/goarch, for which a GTA
+// reports the affected packages. The packages are loaded once for each
+// platform using the GTA's Loader, and changes to Go and assembly files only
+// affect the platforms for which the files are built.
+func SetPlatforms(platforms ...string) Option {
+	return func(g *GTA) error {
+		seen := make(map[string]struct{}, len(platforms))
+		for _, platform := range platforms {
+			if err := validPlatform(platform); err != nil {
+				return err
+			}
+			if _, ok := seen[platform]; ok {
+				return fmt.Errorf("duplicate platform %q", platform)
+			}
+			seen[platform] = struct{}{}
+		}
+
+		g.platforms = platforms
+		return nil
+	}
+}
+
+// SetAPIReport sets whether a GTA reports the changes to the exported API of
+// changed packages and the risk they pose to dependents. The report requires
+// type checking the dependents that import changed packages directly.
+func SetAPIReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.apiReport = enabled
+		return nil
+	}
+}
+
+// SetOrphanReport sets whether a GTA reports the packages that no package
+// depends on and that are not main packages, which are candidates for
+// deletion. The report requires a Packager that implements PackageNamer.
+func SetOrphanReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.orphanReport = enabled
+		return nil
+	}
+}
+
+// SetAnomalyReport sets whether a GTA reports the anomalies found while
+// building the dependency graph, such as files claimed by several packages,
+// that can cause changes to be attributed to the wrong packages. The report
+// requires a Packager that implements AnomalyReporter.
+func SetAnomalyReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.anomalyCheck = enabled
+		return nil
+	}
+}
+
+// SetFileChangeReport sets whether a GTA reports how each changed file of the
+// changed packages was changed: whether it was added, modified, deleted,
+// renamed, or copied. Differs that are FileChangeDiffers describe the changes;
+// the changes of other differs are determined from whether the files exist.
+func SetFileChangeReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.fileReport = enabled
+		return nil
+	}
+}
+
+// SetMetaReport sets whether a GTA reports the cost of its analysis: the
+// duration of each phase, the size of the dependency graph, and the version of
+// gta.
+func SetMetaReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.metaReport = enabled
+		return nil
+	}
+}
+
+// SetMetadataReport sets whether a GTA reports the metadata of the affected
+// packages, such as their descriptions and owners, from the MetadataFile in
+// their directories and their documentation. The report requires a Packager
+// that implements PackageLocator.
+func SetMetadataReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.infoReport = enabled
+		return nil
+	}
+}
+
+// SetReasonReport sets whether a GTA reports why each dependent of the changed
+// packages is affected, as the import chain from the nearest changed package
+// to it.
+func SetReasonReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.whyReport = enabled
+		return nil
+	}
+}
+
+// SetBuildTestReport sets whether a GTA reports which of the affected packages
+// have compiled code that is affected, and which are only affected through
+// their tests, so that the former can be built and the latter only tested.
+// The split requires a Packager that implements BuildGrapher; otherwise every
+// affected package is reported as build-affected.
+func SetBuildTestReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.splitReport = enabled
+		return nil
+	}
+}
+
+// SetExcludeVendored sets whether a GTA omits the packages of the modules
+// vendored in the vendor directory of the module that contains its directory
+// from the changed packages and their dependents. Changes within the vendor
+// directory still affect the packages of the repository that depend on the
+// vendored modules.
+func SetExcludeVendored(enabled bool) Option {
+	return func(g *GTA) error {
+		g.skipVendored = enabled
+		return nil
+	}
+}
+
+// SetPartial sets whether a GTA reports partial results instead of failing
+// when its context is done, e.g. because a deadline was exceeded, before the
+// dependency graph is built. The partial results are a conservative superset:
+// the changed packages are reported along with all of the GTA's prefixes, and
+// Packages.Partial is set. Partial results require prefixes, because without
+// them the superset cannot be expressed, and the context to be set with
+// SetContext.
+func SetPartial(enabled bool) Option {
+	return func(g *GTA) error {
+		g.partial = enabled
+		return nil
+	}
+}
+
+// SetChangeKindReport sets whether a GTA reports the kind of the changes to
+// each changed package: whether only its tests, only files other than Go
+// files, or its implementation changed.
+func SetChangeKindReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.kindReport = enabled
+		return nil
+	}
+}
+
+// SetBlastRadiusReport sets whether a GTA reports the blast radius of each
+// directly changed package: the number of its dependents that its changes
+// affect, and the fraction of the packages within the prefixes that they are.
+// The report requires a Packager that implements PackageNamer.
+func SetBlastRadiusReport(enabled bool) Option {
+	return func(g *GTA) error {
+		g.blastReport = enabled
+		return nil
+	}
+}
+
+// SetIgnoreCommentChanges sets whether a GTA drops the changed Go files whose
+// only changes are to comments, so that documentation changes do not mark
+// their packages and dependents as changed. It requires a differ that is a
+// BaseReader; the files are kept when their original content cannot be read.
+func SetIgnoreCommentChanges(enabled bool) Option {
+	return func(g *GTA) error {
+		g.skipComments = enabled
+		return nil
+	}
+}
+
+// SetBinaryFileMapping sets whether changes to binary files, such as fixtures
+// checked into package directories, mark only the packages that they are
+// mapped to by a GTA's fixtures, migrations, and specification mappings rather
+// than the packages in their directories. It requires a differ that is a
+// BinaryFileDiffer, such as the git differ.
+func SetBinaryFileMapping(enabled bool) Option {
+	return func(g *GTA) error {
+		g.mapBinary = enabled
+		return nil
+	}
+}
+
+// SetDiffResolver sets the stage of a GTA that determines the changed files,
+// replacing the GTA's ResolveDiff.
+func SetDiffResolver(r DiffResolver) Option {
+	return func(g *GTA) error {
+		g.resolver = r
+		return nil
+	}
+}
+
+// SetFileMapper sets the stage of a GTA that maps the changed files to the
+// changed packages, replacing the GTA's MapFiles.
+func SetFileMapper(m FileMapper) Option {
+	return func(g *GTA) error {
+		g.mapper = m
+		return nil
+	}
+}
+
+// SetImpactAnalyzer sets the stage of a GTA that determines the packages
+// affected by the changed packages, replacing the GTA's AnalyzeImpact.
+func SetImpactAnalyzer(a ImpactAnalyzer) Option {
+	return func(g *GTA) error {
+		g.analyzer = a
+		return nil
+	}
+}
+
+// SetReporter sets the stage of a GTA that builds the changed packages from
+// the affected packages, replacing the GTA's Report.
+func SetReporter(r Reporter) Option {
+	return func(g *GTA) error {
+		g.reporter = r
+		return nil
+	}
+}
+
+// SetFixtures sets the shared test fixture directories of a GTA.
+func SetFixtures(fixtures ...Fixture) Option {
+	return func(g *GTA) error {
+		g.fixtures = fixtures
+		return nil
+	}
+}
+
+// SetMigrations sets the database migration directories of a GTA.
+func SetMigrations(migrations ...Migrations) Option {
+	return func(g *GTA) error {
+		g.migrations = migrations
+		return nil
+	}
+}
+
+// SetSpecMappings sets the mappings of API specification files to the
+// packages generated from them of a GTA.
+func SetSpecMappings(mappings ...SpecMapping) Option {
+	return func(g *GTA) error {
+		g.specMappings = mappings
+		return nil
+	}
+}
+
+// SetMappingRules sets the mapping rules of non-Go files to packages and
+// pseudo-targets of a GTA.
+func SetMappingRules(rules ...MappingRule) Option {
+	return func(g *GTA) error {
+		g.mappingRules = rules
+		return nil
+	}
+}
+
+// SetTriggerFiles sets the absolute glob patterns, with the syntax of the
+// patterns of a SpecMapping, of the files whose changes mark every package of
+// a GTA as changed, such as build scripts and CI configuration. The packages
+// are those of the GTA's Packager, which must be a PackageLocator.
+func SetTriggerFiles(patterns ...string) Option {
+	return func(g *GTA) error {
+		g.triggerFiles = patterns
+		return nil
+	}
+}
+
+// SetGenerateInputs enables the detection of the inputs of the go:generate
+// directives of the packages within the directory of a GTA, which is the
+// current working directory unless it is set with SetDir. Changes to the
+// files and directories named by the arguments of a directive, or matched by
+// the patterns of the mappings for its generator, mark the package with the
+// directive, which consumes the generated output, and its dependents.
+func SetGenerateInputs(mappings ...GenerateMapping) Option {
+	return func(g *GTA) error {
+		g.genInputs = true
+		g.genMappings = mappings
+		return nil
+	}
+}
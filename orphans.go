@@ -0,0 +1,40 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"sort"
+)
+
+// orphans returns the sorted import paths of the packages within g's prefixes
+// that have no dependents in graph and are not main packages.
+func (g *GTA) orphans(graph *Graph) ([]string, error) {
+	namer, ok := g.packager.(PackageNamer)
+	if !ok {
+		return nil, errors.New("the packager does not report package names")
+	}
+
+	names, err := namer.PackageNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for importPath, name := range names {
+		if name == "main" || !g.included(importPath) {
+			continue
+		}
+		if len(graph.graph[importPath]) > 0 {
+			continue
+		}
+		orphans = append(orphans, importPath)
+	}
+
+	sort.Strings(orphans)
+	return orphans, nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+var _ PackageNamer = &namedTestPackager{}
+
+// namedTestPackager is a testPackager that implements PackageNamer.
+type namedTestPackager struct {
+	testPackager
+	names map[string]string
+}
+
+func (t *namedTestPackager) PackageNames() (map[string]string, error) {
+	return t.names, nil
+}
+
+func TestGTA_Orphans(t *testing.T) {
+	// cmd/a depends on B
+	// C and cmd/d depend on nothing and nothing depends on them
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirB": Directory{Exists: true},
+		},
+	}
+
+	pkgr := &namedTestPackager{
+		testPackager: testPackager{
+			dirs2Imports: map[string]string{
+				"dirA": "cmd/a",
+				"dirB": "B",
+				"dirC": "C",
+				"dirD": "cmd/d",
+			},
+			graph: &Graph{
+				graph: map[string]map[string]bool{
+					"B": map[string]bool{
+						"cmd/a": true,
+					},
+				},
+			},
+			errs: make(map[string]error),
+		},
+		names: map[string]string{
+			"cmd/a": "main",
+			"B":     "b",
+			"C":     "c",
+			"cmd/d": "main",
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetOrphanReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"C"}, pkgs.Orphans); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_OrphansUnsupported(t *testing.T) {
+	pkgr := &testPackager{
+		graph: &Graph{},
+	}
+
+	gta, err := New(SetDiffer(&testDiffer{}), SetPackager(pkgr), SetOrphanReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gta.ChangedPackages(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestPackageNames(t *testing.T) {
+	cmdA := &packages.Package{
+		ID:      "example.com/cmd/a",
+		Name:    "main",
+		PkgPath: "example.com/cmd/a",
+		GoFiles: []string{"/src/cmd/a/main.go"},
+	}
+	external := &packages.Package{
+		ID:      "example.com/b_test",
+		Name:    "b_test",
+		PkgPath: "example.com/b_test",
+		GoFiles: []string{"/src/b/b_test.go"},
+	}
+	b := &packages.Package{
+		ID:      "example.com/b",
+		Name:    "b",
+		PkgPath: "example.com/b",
+		GoFiles: []string{"/src/b/b.go"},
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{cmdA, external, b}, nil
+	})
+
+	pkgr, ok := NewPackagerWithLoader(loader, nil, nil).(PackageNamer)
+	if !ok {
+		t.Fatal("expected the packager to implement PackageNamer")
+	}
+
+	got, err := pkgr.PackageNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"example.com/cmd/a": "main",
+		"example.com/b":     "b",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bytes"
+	"errors"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewOverlayDiffer returns a Differ for the files in the directory overlay,
+// which mirrors the layout of the repository at root, that differ from the
+// files at the same paths under root or that do not exist under root. It
+// allows code that was generated in a CI workspace, and that is not part of
+// the diff, to be accounted for. The reported paths are the paths under root,
+// so files that only exist in the overlay are reported as not existing.
+func NewOverlayDiffer(overlay, root string) Differ {
+	d := &overlayDiffer{
+		overlay: overlay,
+		root:    root,
+	}
+	d.differ.diff = d.files
+	return d
+}
+
+// overlayDiffer implements the Differ interface by comparing a directory with
+// the repository it overlays.
+type overlayDiffer struct {
+	differ
+	overlay string
+	root    string
+
+	once    sync.Once
+	changed map[string]struct{}
+	err     error
+}
+
+// files returns the absolute paths under d's root of the files in d's overlay
+// that differ from the files under root.
+func (d *overlayDiffer) files() (map[string]struct{}, error) {
+	d.once.Do(func() {
+		root, err := filepath.Abs(d.root)
+		if err != nil {
+			d.err = err
+			return
+		}
+
+		changed := make(map[string]struct{})
+		d.err = filepath.Walk(d.overlay, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+
+			rel, err := filepath.Rel(d.overlay, p)
+			if err != nil {
+				return err
+			}
+			abs := filepath.Join(root, rel)
+
+			same, err := sameContent(p, abs)
+			if err != nil {
+				return err
+			}
+			if !same {
+				changed[abs] = struct{}{}
+			}
+			return nil
+		})
+		d.changed = changed
+	})
+
+	return d.changed, d.err
+}
+
+// sameContent reports whether the files a and b have the same content. It
+// returns false when b does not exist.
+func sameContent(a, b string) (bool, error) {
+	bb, err := ioutil.ReadFile(b)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ab, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ab, bb), nil
+}
+
+// SetOverlay sets the contents of files, keyed by their paths, that a GTA
+// uses instead of the contents of the files on disk, or of files that do not
+// exist on disk, when it loads packages and resolves the packages of
+// directories. The files whose contents differ from the files on disk are
+// changes, too, reported by a differ labeled overlay, so that a GTA reports
+// what would be affected if the files had the contents without writing them,
+// e.g. for editor integrations and generated code.
+func SetOverlay(overlay map[string][]byte) Option {
+	return func(g *GTA) error {
+		g.overlay = make(map[string][]byte, len(overlay))
+		for fn, b := range overlay {
+			abs, err := filepath.Abs(fn)
+			if err != nil {
+				return err
+			}
+			g.overlay[abs] = b
+		}
+		return nil
+	}
+}
+
+// contentsDiffer implements the Differ interface for the files of an
+// overlay of contents whose contents differ from the files on disk.
+type contentsDiffer struct {
+	overlay map[string][]byte
+}
+
+// Diff returns the directories of the files whose contents in d's overlay
+// differ from the files on disk. The directories exist, since the overlay
+// provides the files.
+func (d *contentsDiffer) Diff() (map[string]Directory, error) {
+	files, err := d.DiffFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]Directory)
+	for abs := range files {
+		dir := dirs[filepath.Dir(abs)]
+		dir.Exists = true
+		dir.Files = append(dir.Files, filepath.Base(abs))
+		dirs[filepath.Dir(abs)] = dir
+	}
+	for _, dir := range dirs {
+		sort.Strings(dir.Files)
+	}
+	return dirs, nil
+}
+
+// DiffFiles returns the files whose contents in d's overlay differ from the
+// files on disk, which all exist.
+func (d *contentsDiffer) DiffFiles() (map[string]bool, error) {
+	files := make(map[string]bool)
+	for abs, b := range d.overlay {
+		disk, err := ioutil.ReadFile(abs)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil && bytes.Equal(disk, b) {
+			continue
+		}
+		files[abs] = true
+	}
+	return files, nil
+}
+
+// overlayContext sets the file system functions of ctx so that go/build
+// reads the files of overlay instead of the files on disk, and sees the files
+// of overlay that do not exist on disk.
+func overlayContext(ctx *build.Context, overlay map[string][]byte) {
+	if len(overlay) == 0 {
+		return
+	}
+
+	dirs := make(map[string]map[string][]byte)
+	for abs, b := range overlay {
+		dir := filepath.Dir(abs)
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = make(map[string][]byte)
+		}
+		dirs[dir][filepath.Base(abs)] = b
+	}
+
+	ctx.IsDir = func(p string) bool {
+		if _, ok := dirs[filepath.Clean(p)]; ok {
+			return true
+		}
+		fi, err := os.Stat(p)
+		return err == nil && fi.IsDir()
+	}
+	ctx.ReadDir = func(dir string) ([]os.FileInfo, error) {
+		files, ok := dirs[filepath.Clean(dir)]
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil && !(ok && os.IsNotExist(err)) {
+			return nil, err
+		}
+		if !ok {
+			return infos, nil
+		}
+
+		merged := make([]os.FileInfo, 0, len(infos)+len(files))
+		for _, info := range infos {
+			if _, ok := files[info.Name()]; !ok {
+				merged = append(merged, info)
+			}
+		}
+		for name, b := range files {
+			merged = append(merged, overlayFileInfo{name: name, size: int64(len(b))})
+		}
+		sort.Slice(merged, func(i, j int) bool {
+			return merged[i].Name() < merged[j].Name()
+		})
+		return merged, nil
+	}
+	ctx.OpenFile = func(p string) (io.ReadCloser, error) {
+		if b, ok := overlay[filepath.Clean(p)]; ok {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		return os.Open(p)
+	}
+}
+
+// overlayFileInfo is the os.FileInfo of a file of an overlay.
+type overlayFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi overlayFileInfo) Name() string       { return fi.name }
+func (fi overlayFileInfo) Size() int64        { return fi.size }
+func (fi overlayFileInfo) Mode() os.FileMode  { return 0644 }
+func (fi overlayFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi overlayFileInfo) IsDir() bool        { return false }
+func (fi overlayFileInfo) Sys() interface{}   { return nil }
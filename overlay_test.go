@@ -0,0 +1,157 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestOverlayDiffer(t *testing.T) {
+	root, overlay := t.TempDir(), t.TempDir()
+	for _, dir := range []string{root, overlay} {
+		if err := os.MkdirAll(filepath.Join(dir, "pb"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// foo.pb.go is unchanged, bar.pb.go is regenerated, and baz.pb.go is new.
+	writeFile(t, root, "pb/foo.pb.go", "package pb\n")
+	writeFile(t, root, "pb/bar.pb.go", "package pb\n")
+	writeFile(t, overlay, "pb/foo.pb.go", "package pb\n")
+	writeFile(t, overlay, "pb/bar.pb.go", "package pb\n\nvar Bar int\n")
+	if err := os.Mkdir(filepath.Join(overlay, "newpb"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, overlay, "newpb/baz.pb.go", "package newpb\n")
+
+	got, err := NewOverlayDiffer(overlay, root).DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(root, "pb", "bar.pb.go"):    true,
+		filepath.Join(root, "newpb", "baz.pb.go"): false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestContentsDiffer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "same.go", "package foo\n")
+	writeFile(t, dir, "changed.go", "package foo\n")
+
+	d := &contentsDiffer{overlay: map[string][]byte{
+		filepath.Join(dir, "same.go"):    []byte("package foo\n"),
+		filepath.Join(dir, "changed.go"): []byte("package foo\n\nvar Foo int\n"),
+		filepath.Join(dir, "new.go"):     []byte("package foo\n"),
+	}}
+
+	got, err := d.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Directory{
+		dir: {Exists: true, Files: []string{"changed.go", "new.go"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestOverlayContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.go", "package a\n")
+	newDir := filepath.Join(dir, "b")
+
+	ctx := build.Default
+	overlayContext(&ctx, map[string][]byte{
+		filepath.Join(dir, "a.go"):    []byte("package a\n\nimport _ \"example.com/c\"\n"),
+		filepath.Join(newDir, "b.go"): []byte("package b\n"),
+	})
+
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"example.com/c"}, pkg.Imports); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	pkg, err = ctx.ImportDir(newDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name != "b" {
+		t.Errorf("pkg.Name = %q; want %q", pkg.Name, "b")
+	}
+}
+
+func TestGTA_Overlay(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "go.mod", "module example.com/foo\n")
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "a/a.go", "package a\n\nimport _ \"example.com/foo/b\"\n")
+	writeFile(t, dir, "b/b.go", "package b\n")
+
+	overlay := map[string][]byte{
+		filepath.Join(dir, "b", "b.go"): []byte("package b\n\nvar B int\n"),
+	}
+
+	mod := &packages.Module{Path: "example.com/foo", Dir: dir, Main: true}
+	b := &packages.Package{
+		ID:      "example.com/foo/b",
+		Name:    "b",
+		PkgPath: "example.com/foo/b",
+		GoFiles: []string{filepath.Join(dir, "b", "b.go")},
+		Module:  mod,
+	}
+	a := &packages.Package{
+		ID:      "example.com/foo/a",
+		Name:    "a",
+		PkgPath: "example.com/foo/a",
+		GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+		Imports: map[string]*packages.Package{"example.com/foo/b": b},
+		Module:  mod,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		if diff := cmp.Diff(overlay, cfg.Overlay); diff != "" {
+			t.Errorf("overlay (-want, +got)\n%s", diff)
+		}
+		return []*packages.Package{a, b}, nil
+	})
+
+	gt, err := New(SetModuleDir(dir), SetLoader(loader), SetDiffer(&testDiffer{}), SetOverlay(overlay))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"example.com/foo/a", "example.com/foo/b"}
+	if diff := cmp.Diff(want, stringify(pkgs.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
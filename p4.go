@@ -0,0 +1,385 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// P4DifferOption is an option function used to modify a Perforce differ.
+type P4DifferOption func(*p4)
+
+// SetP4Changelists sets the numbers of the changelists whose changes a
+// Perforce differ reports. The changes of a submitted changelist are those it
+// submitted, and the changes of a pending changelist are those shelved in it.
+// When no changelists are set, the files opened in the client workspace are
+// reported instead.
+func SetP4Changelists(changelists ...int) P4DifferOption {
+	return func(p *p4) {
+		p.changelists = changelists
+	}
+}
+
+// SetP4WorkingDir sets the directory in which a Perforce differ runs p4. When
+// it is not set, p4 is run in the current working directory.
+func SetP4WorkingDir(dir string) P4DifferOption {
+	return func(p *p4) {
+		p.dir = dir
+	}
+}
+
+// SetP4CommandTimeout sets the maximum duration of each p4 command run by a
+// Perforce differ. A timeout of zero means p4 commands do not time out.
+func SetP4CommandTimeout(timeout time.Duration) P4DifferOption {
+	return func(p *p4) {
+		p.timeout = timeout
+	}
+}
+
+// SetP4Context sets the context of the p4 commands run by a Perforce differ.
+// The commands are killed when ctx is done.
+func SetP4Context(ctx context.Context) P4DifferOption {
+	return func(p *p4) {
+		p.ctx = ctx
+	}
+}
+
+// NewP4Differ returns a Differ that determines differences using Perforce.
+// The files in the depot are mapped to the client workspace with p4 where, so
+// the client workspace must be set, e.g. with P4CLIENT. The returned Differ is
+// also a BaseReader.
+func NewP4Differ(opts ...P4DifferOption) Differ {
+	p := &p4{}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return &p4Differ{
+		differ: differ{
+			diff: p.diff,
+		},
+		p4: p,
+	}
+}
+
+// p4Differ implements the BaseReader interface using Perforce.
+type p4Differ struct {
+	differ
+	p4 *p4
+}
+
+// ReadBase returns the content of the file at abs before the changes.
+func (d *p4Differ) ReadBase(abs string) ([]byte, error) {
+	return d.p4.readBase(abs)
+}
+
+// p4 implements the Differ interface using the Perforce version control
+// system.
+type p4 struct {
+	changelists []int
+	dir         string
+	timeout     time.Duration
+	ctx         context.Context
+
+	onceDiff     sync.Once
+	changedFiles map[string]struct{}
+	diffErr      error
+
+	// bases is set by diff to a map of the absolute paths of the changed
+	// files to the file revisions before the changes, or to an empty string
+	// when the files did not exist before the changes.
+	bases map[string]string
+}
+
+// p4Change is a change to a file in the depot.
+type p4Change struct {
+	depotFile string
+	action    string
+	// rev is the revision of the file the change created, or, for opened
+	// files, the revision the change is made to.
+	rev int
+	// opened is whether the change is to a file opened in the client
+	// workspace rather than a change of a changelist.
+	opened bool
+}
+
+// base returns the file revision before the change, or an empty string when
+// the file did not exist before the change.
+func (c p4Change) base() string {
+	switch c.action {
+	case "add", "branch", "move/add", "import":
+		return ""
+	}
+	if c.opened {
+		if c.rev == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%s#%d", c.depotFile, c.rev)
+	}
+	if c.rev <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", c.depotFile, c.rev-1)
+}
+
+// context returns a context that is done when p's context is done or p's
+// command timeout elapses.
+func (p *p4) context() (context.Context, context.CancelFunc) {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if p.timeout > 0 {
+		return context.WithTimeout(ctx, p.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// output runs p4 with args in p's working directory and returns its standard
+// output.
+func (p *p4) output(args ...string) ([]byte, error) {
+	ctx, cancel := p.context()
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "p4", args...)
+	cmd.Dir = p.dir
+	// p4 uses PWD rather than the working directory of the process to find
+	// the client workspace and configuration files.
+	if p.dir != "" {
+		cmd.Env = append(os.Environ(), "PWD="+p.dir)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("p4 %s: %w", p4Command(args), err)
+	}
+	return out, nil
+}
+
+// p4Command returns the name of the command run by p4 with args, which
+// follows the global options.
+func p4Command(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// records runs p4 with tagged output and args and returns its records.
+func (p *p4) records(args ...string) ([]map[string]string, error) {
+	out, err := p.output(append([]string{"-ztag"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseZtag(out)
+}
+
+// parseZtag parses the tagged output of p4, in which each line of a record is
+// a field of the form "... name value" and records are separated by empty
+// lines.
+func parseZtag(out []byte) ([]map[string]string, error) {
+	var recs []map[string]string
+	var rec map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			rec = nil
+			continue
+		}
+
+		if !strings.HasPrefix(line, "... ") {
+			// the value of the previous field continues on this line, e.g.
+			// the description of a changelist.
+			continue
+		}
+		field := strings.SplitN(strings.TrimPrefix(line, "... "), " ", 2)
+		if rec == nil {
+			rec = make(map[string]string)
+			recs = append(recs, rec)
+		}
+		if len(field) == 2 {
+			rec[field[0]] = field[1]
+		} else {
+			rec[field[0]] = ""
+		}
+	}
+	return recs, scanner.Err()
+}
+
+// changes returns the changes of p's changelists, or the changes to the
+// files opened in the client workspace when p has no changelists.
+func (p *p4) changes() ([]p4Change, error) {
+	if len(p.changelists) == 0 {
+		recs, err := p.records("opened")
+		if err != nil {
+			return nil, err
+		}
+
+		var changes []p4Change
+		for _, rec := range recs {
+			if _, ok := rec["depotFile"]; !ok {
+				continue
+			}
+			rev, _ := strconv.Atoi(rec["haveRev"])
+			changes = append(changes, p4Change{
+				depotFile: rec["depotFile"],
+				action:    rec["action"],
+				rev:       rev,
+				opened:    true,
+			})
+		}
+		return changes, nil
+	}
+
+	changelists := append([]int(nil), p.changelists...)
+	sort.Ints(changelists)
+
+	var changes []p4Change
+	for _, cl := range changelists {
+		recs, err := p.records("describe", "-s", strconv.Itoa(cl))
+		if err != nil {
+			return nil, err
+		}
+		if len(recs) == 0 {
+			return nil, fmt.Errorf("p4: changelist %d not found", cl)
+		}
+
+		// the files of a pending changelist are those shelved in it.
+		if recs[0]["status"] != "submitted" {
+			if recs, err = p.records("describe", "-s", "-S", strconv.Itoa(cl)); err != nil {
+				return nil, err
+			}
+			if len(recs) == 0 {
+				return nil, fmt.Errorf("p4: changelist %d not found", cl)
+			}
+		}
+
+		rec := recs[0]
+		for i := 0; ; i++ {
+			depotFile, ok := rec["depotFile"+strconv.Itoa(i)]
+			if !ok {
+				break
+			}
+			rev, _ := strconv.Atoi(rec["rev"+strconv.Itoa(i)])
+			changes = append(changes, p4Change{
+				depotFile: depotFile,
+				action:    rec["action"+strconv.Itoa(i)],
+				rev:       rev,
+				opened:    rec["status"] != "submitted",
+			})
+		}
+	}
+	return changes, nil
+}
+
+// where returns a map of the depot files to their absolute paths in the
+// client workspace. Files that are not mapped to the client workspace are
+// omitted.
+func (p *p4) where(depotFiles []string) (map[string]string, error) {
+	paths := make(map[string]string, len(depotFiles))
+	if len(depotFiles) == 0 {
+		return paths, nil
+	}
+
+	recs, err := p.records(append([]string{"where"}, depotFiles...)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recs {
+		if _, unmapped := rec["unmap"]; unmapped || rec["path"] == "" {
+			continue
+		}
+		paths[rec["depotFile"]] = filepath.Clean(rec["path"])
+	}
+	return paths, nil
+}
+
+// diff returns a set of changed files.
+func (p *p4) diff() (map[string]struct{}, error) {
+	p.onceDiff.Do(func() {
+		changes, err := p.changes()
+		if err != nil {
+			p.diffErr = err
+			return
+		}
+
+		var depotFiles []string
+		seen := make(map[string]bool)
+		for _, c := range changes {
+			if !seen[c.depotFile] {
+				seen[c.depotFile] = true
+				depotFiles = append(depotFiles, c.depotFile)
+			}
+		}
+
+		paths, err := p.where(depotFiles)
+		if err != nil {
+			p.diffErr = err
+			return
+		}
+
+		files := make(map[string]struct{}, len(paths))
+		bases := make(map[string]string, len(paths))
+		for _, c := range changes {
+			abs, ok := paths[c.depotFile]
+			if !ok {
+				continue
+			}
+			// the changes are in ascending order of changelist, so the
+			// first change to a file determines its content before all of
+			// the changes.
+			if _, ok := files[abs]; !ok {
+				files[abs] = struct{}{}
+				bases[abs] = c.base()
+			}
+		}
+
+		p.changedFiles, p.bases = files, bases
+	})
+
+	return p.changedFiles, p.diffErr
+}
+
+// readBase returns the content of the file at abs before the changes.
+func (p *p4) readBase(abs string) ([]byte, error) {
+	if _, err := p.diff(); err != nil {
+		return nil, err
+	}
+
+	base, ok := p.bases[abs]
+	if !ok {
+		// the file did not change, so its content in the client workspace
+		// is its content before the changes.
+		return ioutil.ReadFile(abs)
+	}
+	if base == "" {
+		return nil, &os.PathError{Op: "read", Path: abs, Err: os.ErrNotExist}
+	}
+
+	return p.output("print", "-q", base)
+}
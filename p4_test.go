@@ -0,0 +1,111 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseZtag(t *testing.T) {
+	out := "... change 12\n... desc fix foo\nand bar\n\n... depotFile0 //depot/foo.go\n... action0 edit\n... unmap\n"
+
+	got, err := parseZtag([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]string{
+		{"change": "12", "desc": "fix foo"},
+		{"depotFile0": "//depot/foo.go", "action0": "edit", "unmap": ""},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+// fakeP4 is a shell script that stands in for p4. It describes changelist 12,
+// which edits foo.go and adds bar.go, and maps the depot to the directory in
+// the first argument to fmt.
+const fakeP4 = `#!/bin/sh
+case "$*" in
+"-ztag describe -s 12")
+	printf '... change 12\n... status submitted\n... depotFile0 //depot/foo.go\n... action0 edit\n... rev0 3\n... depotFile1 //depot/bar.go\n... action1 add\n... rev1 1\n'
+	;;
+"-ztag where //depot/foo.go //depot/bar.go")
+	printf '... depotFile //depot/foo.go\n... path %[1]s/foo.go\n\n... depotFile //depot/bar.go\n... path %[1]s/bar.go\n'
+	;;
+"print -q //depot/foo.go#2")
+	printf 'package foo\n'
+	;;
+*)
+	echo "unexpected arguments: $*" >&2
+	exit 1
+	;;
+esac
+`
+
+func TestP4Differ(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, bin, "p4", fmt.Sprintf(fakeP4, dir))
+	if err := os.Chmod(filepath.Join(bin, "p4"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	difr := NewP4Differ(SetP4WorkingDir(dir), SetP4Changelists(12))
+	got, err := difr.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "bar.go"): false,
+		filepath.Join(dir, "foo.go"): false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	br, ok := difr.(BaseReader)
+	if !ok {
+		t.Fatal("expected the p4 differ to be a BaseReader")
+	}
+
+	b, err := br.ReadBase(filepath.Join(dir, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "package foo\n"; got != want {
+		t.Errorf("ReadBase(foo.go) = %q; want %q", got, want)
+	}
+
+	if _, err := br.ReadBase(filepath.Join(dir, "bar.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadBase(bar.go) error = %v; want %v", err, os.ErrNotExist)
+	}
+}
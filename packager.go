@@ -12,7 +12,10 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/tools/go/packages"
 )
@@ -27,17 +30,6 @@ type Package struct {
 	Dir string
 }
 
-// graphError is a collection of errors from attempting to build the
-// dependent graph.
-type graphError struct {
-	Errors map[string]error
-}
-
-// Error implements the error interface for GraphError.
-func (g *graphError) Error() string {
-	return fmt.Sprintf("errors while generating import graph: %v", g.Errors)
-}
-
 // Packager interface defines a set of means to access golang build Package information.
 type Packager interface {
 	// Get a go package from directory. Should return a *build.NoGoError value
@@ -53,32 +45,86 @@ type Packager interface {
 	DependentGraph() (*Graph, error)
 }
 
+// A PackageLocator is a Packager that knows the directories of the packages
+// that match the patterns it loaded.
+type PackageLocator interface {
+	// PackageDirs returns the absolute paths of the directories of the
+	// packages that match the patterns, keyed by their import paths.
+	PackageDirs() (map[string]string, error)
+}
+
+// A PackageFiler is a Packager that knows the files of the packages that match
+// the patterns it loaded.
+type PackageFiler interface {
+	// PackageFiles returns the sorted absolute paths of the Go files,
+	// including test files, and the other files of the packages that match
+	// the patterns, keyed by their import paths.
+	PackageFiles() (map[string][]string, error)
+}
+
+// A PackageNamer is a Packager that knows the names of the packages that
+// match the patterns it loaded.
+type PackageNamer interface {
+	// PackageNames returns the names of the packages that match the patterns,
+	// keyed by their import paths.
+	PackageNames() (map[string]string, error)
+}
+
 func NewPackager(patterns, tags []string) Packager {
-	build.Default.BuildTags = tags
-	return newPackager(newLoadConfig(tags), build.Default, patterns)
+	return NewPackagerWithLoader(defaultLoader, patterns, tags)
+}
+
+// NewPackagerWithLoader returns a Packager like NewPackager whose dependency
+// graph is built from the packages returned by loader.
+func NewPackagerWithLoader(loader Loader, patterns, tags []string) Packager {
+	// copy the default context so that packagers with different tags do not
+	// affect one another.
+	ctx := build.Default
+	ctx.BuildTags = tags
+	return newPackager(loader, newLoadConfig(tags), ctx, patterns)
+}
+
+// newPackager returns a Packager whose dependency graph is built from the
+// packages within the prefixes.
+func newPackager(loader Loader, cfg *packages.Config, ctx build.Context, prefixes []string) Packager {
+	return loadPackager(loader, cfg, ctx, prefixPatterns(prefixes), 0)
 }
 
-func newPackager(cfg *packages.Config, ctx build.Context, patterns []string) Packager {
-	moduleNamesByDir, forward, reverse, err := dependencyGraph(cfg, patterns)
+// loadPackager returns a Packager whose dependency graph is built from the
+// packages that match the patterns, which are not treated as prefixes, by up
+// to workers goroutines, or GOMAXPROCS goroutines when workers is zero.
+func loadPackager(loader Loader, cfg *packages.Config, ctx build.Context, patterns []string, workers int) Packager {
+	moduleNamesByDir, forward, reverse, names, loaded, err := loadDependencyGraph(loader, cfg, patterns, workers)
 	return &packageContext{
 		ctx:               &ctx,
 		err:               err,
 		packages:          make(map[string]struct{}),
 		forward:           forward,
 		reverse:           reverse,
+		names:             names,
 		modulesNamesByDir: moduleNamesByDir,
+		loaded:            loaded,
 	}
 }
 
+// RequiredLoadMode is the part of the mode in which the default packager loads
+// packages that it cannot do without: the names, files, imports, and modules of
+// the packages.
+const RequiredLoadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedImports |
+	packages.NeedModule
+
+// DefaultLoadMode is the mode in which the default packager loads packages
+// unless it is set with SetLoadMode. NeedDeps fills in the fields of the
+// dependencies of the packages that match the patterns, too.
+const DefaultLoadMode = RequiredLoadMode | packages.NeedDeps
+
 // newLoadConfig returns a *packages.Config suitable for use by packages.Load.
 // The constructor here is mostly useful for tests.
 func newLoadConfig(tags []string) *packages.Config {
 	return &packages.Config{
-		Mode: packages.NeedName |
-			packages.NeedFiles |
-			packages.NeedImports |
-			packages.NeedDeps |
-			packages.NeedModule,
+		Mode: DefaultLoadMode,
 		BuildFlags: []string{
 			fmt.Sprintf(`-tags=%s`, strings.Join(tags, ",")),
 		},
@@ -96,8 +142,33 @@ type packageContext struct {
 	forward map[string]map[string]struct{}
 	// reverse is a reverse dependency graph (import path -> (dependent import path -> struct{}{}))
 	reverse map[string]map[string]struct{}
+	// names is a map of the packages that match the patterns to their names.
+	names map[string]string
 	// modulesNamesByDir is a map of directories to import paths. absolute path directory -> import path/module name
 	modulesNamesByDir map[string]string
+	// loaded are the packages that match the patterns, from which the
+	// anomalies and the directories are determined when they are first
+	// requested.
+	loaded     []*packages.Package
+	anomalies  []Anomaly
+	loadErrors LoadErrors
+	// dirs are the directories of the packages that match the patterns when
+	// the dependency graph was read from a cache instead of loaded.
+	dirs map[string]string
+	// files are the files of the packages that match the patterns when the
+	// dependency graph was read from a cache instead of loaded.
+	files map[string][]string
+	// embeds are the files embedded by the packages that match the patterns,
+	// which are determined when they are first requested unless the
+	// dependency graph was read from a cache.
+	embeds map[string][]string
+	// cgo are the include directories and files outside of their directories
+	// of the packages that match the patterns, which are determined like the
+	// embedded files.
+	cgo map[string][]string
+	// buildReverse is the reverse dependency graph without the imports of
+	// test files, which is determined like the embedded files.
+	buildReverse map[string]map[string]struct{}
 
 	packagesConfig *packages.Config
 }
@@ -159,6 +230,99 @@ func (p *packageContext) DependentGraph() (*Graph, error) {
 	return &Graph{graph: graph}, nil
 }
 
+// PackageNames returns the names of the packages that match the patterns,
+// keyed by their import paths.
+func (p *packageContext) PackageNames() (map[string]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.names, nil
+}
+
+// Anomalies returns the anomalies in the dependency graph.
+func (p *packageContext) Anomalies() ([]Anomaly, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.anomalies == nil {
+		p.anomalies = graphAnomalies(p.loaded, *p.ctx)
+		if p.anomalies == nil {
+			p.anomalies = []Anomaly{}
+		}
+	}
+	return p.anomalies, nil
+}
+
+// LoadErrors returns the errors found while loading the packages that match
+// the patterns and their dependencies.
+func (p *packageContext) LoadErrors() (LoadErrors, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.loadErrors == nil {
+		p.loadErrors = packageLoadErrors(p.loaded)
+		if p.loadErrors == nil {
+			p.loadErrors = LoadErrors{}
+		}
+	}
+	return p.loadErrors, nil
+}
+
+// PackageDirs returns the absolute paths of the directories of the packages
+// that match the patterns, keyed by their import paths.
+func (p *packageContext) PackageDirs() (map[string]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.dirs != nil {
+		return p.dirs, nil
+	}
+
+	dirs := make(map[string]string)
+	for _, pkg := range p.loaded {
+		// the directories of the primary packages, not of their external
+		// tests or test binaries.
+		if len(pkg.GoFiles) > 0 && pkg.PkgPath == normalizeImportPath(pkg) && !strings.HasSuffix(pkg.PkgPath, ".test") {
+			dirs[pkg.PkgPath] = filepath.Dir(pkg.GoFiles[0])
+		}
+	}
+	return dirs, nil
+}
+
+// PackageFiles returns the sorted absolute paths of the Go files, including
+// test files, and the other files of the packages that match the patterns,
+// keyed by their import paths.
+func (p *packageContext) PackageFiles() (map[string][]string, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.files != nil {
+		return p.files, nil
+	}
+
+	sets := make(map[string]map[string]struct{})
+	for _, pkg := range p.loaded {
+		// test variants and external tests are flattened into the package
+		// they test; test binaries have no files of their own.
+		if len(pkg.GoFiles) == 0 || strings.HasSuffix(pkg.PkgPath, ".test") {
+			continue
+		}
+		pkgPath := normalizeImportPath(pkg)
+		if _, ok := sets[pkgPath]; !ok {
+			sets[pkgPath] = make(map[string]struct{})
+		}
+		for _, fn := range append(append([]string(nil), pkg.GoFiles...), pkg.OtherFiles...) {
+			sets[pkgPath][fn] = struct{}{}
+		}
+	}
+
+	files := setsToLists(sets)
+	for _, list := range files {
+		sort.Strings(list)
+	}
+	return files, nil
+}
+
 func packageFrom(pkg *build.Package) *Package {
 	return &Package{
 		ImportPath: pkg.ImportPath,
@@ -183,10 +347,11 @@ func resolveLocal(pkg *Package, dir string, modulesByDir map[string]string) {
 			break
 		}
 
-		// there may be nested modules; make sure the directory being checked is
+		// there may be nested modules, or sibling modules whose directories share
+		// a prefix in a workspace; make sure the directory being checked is
 		// within the directory for current entry and deeper than the most recently
 		// matched prefix.
-		if !strings.HasPrefix(dir, k) || len(mruPrefix) > len(k) {
+		if !withinDir(dir, k) || len(mruPrefix) > len(k) {
 			continue
 		}
 
@@ -209,10 +374,21 @@ func resolveLocal(pkg *Package, dir string, modulesByDir map[string]string) {
 }
 
 // dependencyGraph constructs a map of directories to import paths when in
-// module aware mode and flattened forward and reverse transitive dependency
-// graphs. When in GOPATH mode the map of directories to import paths will be
-// empty.
-func dependencyGraph(cfg *packages.Config, patterns []string) (moduleNamesByDir map[string]string, forward map[string]map[string]struct{}, reverse map[string]map[string]struct{}, err error) {
+// module aware mode, flattened forward and reverse transitive dependency
+// graphs, a map of the packages that match patterns to their names, and the
+// loaded packages that match patterns. When in GOPATH mode the map of
+// directories to import paths will be empty.
+func dependencyGraph(loader Loader, cfg *packages.Config, patterns []string) (moduleNamesByDir map[string]string, forward map[string]map[string]struct{}, reverse map[string]map[string]struct{}, names map[string]string, loaded []*packages.Package, err error) {
+	return loadDependencyGraph(loader, cfg, prefixPatterns(patterns), 0)
+}
+
+// prefixPatterns returns the patterns that match the packages whose import
+// paths have any of the prefixes, or every package when there are none.
+func prefixPatterns(prefixes []string) []string {
+	// copy the prefixes so that the caller's slice (e.g. a GTA's prefixes) is
+	// not modified when they are expanded.
+	patterns := append([]string(nil), prefixes...)
+
 	loadAllPackages := true
 	for i, pat := range patterns {
 		if strings.HasPrefix(pat, "file=") {
@@ -231,16 +407,24 @@ func dependencyGraph(cfg *packages.Config, patterns []string) (moduleNamesByDir
 	if loadAllPackages {
 		patterns = []string{"..."}
 	}
+	return patterns
+}
 
-	loadedPackages, err := packages.Load(cfg, patterns...)
+// loadDependencyGraph is like dependencyGraph, but loads the packages that
+// match the patterns as they are, rather than treating them as prefixes, and
+// builds the graphs with up to workers goroutines, or GOMAXPROCS goroutines
+// when workers is zero.
+func loadDependencyGraph(loader Loader, cfg *packages.Config, patterns []string, workers int) (moduleNamesByDir map[string]string, forward map[string]map[string]struct{}, reverse map[string]map[string]struct{}, names map[string]string, loaded []*packages.Package, err error) {
+	loadedPackages, err := loader.Load(cfg, patterns...)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("loading packages: %w", err)
+		return nil, nil, nil, nil, nil, fmt.Errorf("loading packages: %w", err)
 	}
 
 	moduleNamesByDir = make(map[string]string)
-	forward = make(map[string]map[string]struct{})
-	reverse = make(map[string]map[string]struct{})
 
+	// collect the packages of the graph, walking the imports of the loaded
+	// packages, before building the graph from them concurrently.
+	var graphPackages []*packages.Package
 	seen := make(map[string]struct{})
 	var addPackage func(pkg *packages.Package)
 	addPackage = func(pkg *packages.Package) {
@@ -252,6 +436,19 @@ func dependencyGraph(cfg *packages.Config, patterns []string) (moduleNamesByDir
 			moduleNamesByDir[pkg.Module.Dir] = pkg.Module.Path
 		}
 
+		// the directories of modules that are replaced with local directories
+		// are resolved like those of the main modules, so that changes within
+		// them are attributed to the packages of the replaced modules.
+		if pkg.Module != nil && pkg.Module.Replace != nil && isLocalPath(pkg.Module.Replace.Path) {
+			dir := pkg.Module.Replace.Dir
+			if dir == "" {
+				dir = pkg.Module.Dir
+			}
+			if dir != "" {
+				moduleNamesByDir[dir] = pkg.Module.Path
+			}
+		}
+
 		seen[pkg.ID] = struct{}{}
 
 		// Ignore packages that do not have any Go files that satisfy the build
@@ -265,42 +462,133 @@ func dependencyGraph(cfg *packages.Config, patterns []string) (moduleNamesByDir
 			return
 		}
 
-		// normalize the import path so that test packages will be flattened into
-		// the package path of the primary package.
-		pkgPath := normalizeImportPath(pkg)
+		graphPackages = append(graphPackages, pkg)
+		for _, importedPkg := range pkg.Imports {
+			addPackage(importedPkg)
+		}
+	}
+	for _, pkg := range loadedPackages {
+		addPackage(pkg)
+	}
 
-		if _, ok := forward[pkgPath]; !ok {
-			forward[pkgPath] = make(map[string]struct{})
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	nodes := graphNodes(graphPackages, workers)
+
+	// the forward graph, the reverse graph, and the names are independent of
+	// one another, so they are built concurrently.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		forward = forwardGraph(nodes)
+	}()
+	go func() {
+		defer wg.Done()
+		reverse = reverseGraph(nodes)
+	}()
+
+	names = make(map[string]string)
+	for _, pkg := range loadedPackages {
+		// record the names of the primary packages, not of their external
+		// tests or test binaries.
+		if len(pkg.GoFiles) > 0 && pkg.PkgPath == normalizeImportPath(pkg) && !strings.HasSuffix(pkg.PkgPath, ".test") {
+			names[pkg.PkgPath] = pkg.Name
 		}
+	}
+	wg.Wait()
 
-		for _, importedPkg := range pkg.Imports {
-			addPackage(importedPkg)
+	return moduleNamesByDir, forward, reverse, names, loadedPackages, nil
+}
 
-			importedPath := normalizeImportPath(importedPkg)
+// graphNode is a package of the dependency graph, identified by its
+// normalized import path, and the normalized import paths of its imports.
+type graphNode struct {
+	pkgPath string
+	imports []string
+}
 
-			fwdm := forward[pkgPath]
+// testHookGraphNode, when it is not nil, is called by the goroutines of
+// graphNodes after they normalize each node.
+var testHookGraphNode func()
+
+// graphNodes returns the nodes of pkgs, which are normalized by up to workers
+// goroutines.
+func graphNodes(pkgs []*packages.Package, workers int) []graphNode {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pkgs) {
+		workers = len(pkgs)
+	}
+
+	nodes := make([]graphNode, len(pkgs))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(pkgs); i += workers {
+				// normalize the import paths so that test packages will be
+				// flattened into the package path of the primary package.
+				node := graphNode{
+					pkgPath: normalizeImportPath(pkgs[i]),
+					imports: make([]string, 0, len(pkgs[i].Imports)),
+				}
+				for _, importedPkg := range pkgs[i].Imports {
+					node.imports = append(node.imports, normalizeImportPath(importedPkg))
+				}
+				nodes[i] = node
+				if testHookGraphNode != nil {
+					testHookGraphNode()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return nodes
+}
+
+// forwardGraph returns the dependency graph of nodes (import path ->
+// (dependency import path -> struct{}{})).
+func forwardGraph(nodes []graphNode) map[string]map[string]struct{} {
+	forward := make(map[string]map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		fwdm, ok := forward[node.pkgPath]
+		if !ok {
+			fwdm = make(map[string]struct{}, len(node.imports))
+			forward[node.pkgPath] = fwdm
+		}
+		for _, importedPath := range node.imports {
 			fwdm[importedPath] = struct{}{}
+		}
+	}
+	return forward
+}
 
-			// do not attempt to add the normalized import path to the reverse graph
-			// when the normalized import path is the same as the package whose
-			// dependents are being calculated.
-			if importedPath == pkgPath {
+// reverseGraph returns the reverse dependency graph of nodes (import path ->
+// (dependent import path -> struct{}{})).
+func reverseGraph(nodes []graphNode) map[string]map[string]struct{} {
+	reverse := make(map[string]map[string]struct{}, len(nodes))
+	for _, node := range nodes {
+		for _, importedPath := range node.imports {
+			// do not attempt to add the normalized import path to the reverse
+			// graph when the normalized import path is the same as the package
+			// whose dependents are being calculated.
+			if importedPath == node.pkgPath {
 				continue
 			}
 
-			if _, ok := reverse[importedPath]; !ok {
-				reverse[importedPath] = make(map[string]struct{})
+			revm, ok := reverse[importedPath]
+			if !ok {
+				revm = make(map[string]struct{})
+				reverse[importedPath] = revm
 			}
-			revm := reverse[importedPath]
-			revm[pkgPath] = struct{}{}
+			revm[node.pkgPath] = struct{}{}
 		}
 	}
-
-	for _, pkg := range loadedPackages {
-		addPackage(pkg)
-	}
-
-	return moduleNamesByDir, forward, reverse, nil
+	return reverse
 }
 
 // normalizeImportPath will return the import path of pkg. The import path may
@@ -310,7 +598,7 @@ func dependencyGraph(cfg *packages.Config, patterns []string) (moduleNamesByDir
 func normalizeImportPath(pkg *packages.Package) string {
 	files := pkg.GoFiles
 
-	importPath := pkg.PkgPath
+	importPath := packagePath(pkg)
 	if len(files) == 0 || !(strings.HasSuffix(importPath, "_test") || strings.HasSuffix(importPath, ".test")) {
 		return importPath
 	}
@@ -328,6 +616,20 @@ func normalizeImportPath(pkg *packages.Package) string {
 	return importPath
 }
 
+// packagePath returns pkg.PkgPath, or the package path of pkg's ID when pkg
+// is a placeholder for an import that only has its ID, as when packages are
+// loaded without NeedDeps.
+func packagePath(pkg *packages.Package) string {
+	if pkg.PkgPath != "" {
+		return pkg.PkgPath
+	}
+	// the IDs of test variants are of the form "path [path.test]".
+	if i := strings.Index(pkg.ID, " ["); i >= 0 {
+		return pkg.ID[:i]
+	}
+	return pkg.ID
+}
+
 func stripVendor(importPath string) string {
 	if os.Getenv("GO111MODULE") == "off" {
 		return importPath
@@ -1,6 +1,16 @@
 package gta
 
-import "testing"
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/gta/gtatest"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
 
 func TestPackageContextImplementsPackager(t *testing.T) {
 	var sut interface{} = new(packageContext)
@@ -8,3 +18,189 @@ func TestPackageContextImplementsPackager(t *testing.T) {
 		t.Error("expected to implement Packager")
 	}
 }
+
+func TestDependencyGraph_Prefixes(t *testing.T) {
+	const prefix = "github.com/digitalocean/gta/cmd"
+	patterns := []string{prefix}
+
+	_, forward, _, _, _, err := dependencyGraph(defaultLoader, newLoadConfig(nil), patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patterns[0] != prefix {
+		t.Errorf("patterns[0] = %q; want %q", patterns[0], prefix)
+	}
+
+	if _, ok := forward["github.com/digitalocean/gta/cmd/gta"]; !ok {
+		t.Errorf("expected package within the prefix to be loaded")
+	}
+
+	// dependencies of the packages within the prefix must be loaded so that
+	// import chains ending in the prefix are complete.
+	if _, ok := forward["github.com/digitalocean/gta"]; !ok {
+		t.Errorf("expected dependency of the prefix to be loaded")
+	}
+}
+
+func TestNew_PrefixLoadingIsBounded(t *testing.T) {
+	files := map[string]string{
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n\nimport _ \"example.com/foo/a\"\n",
+		// c depends on a but is outside the prefix, so it is never reported
+		// and does not need to be loaded.
+		"c/c.go": "package c\n\nimport _ \"example.com/foo/a\"\n",
+	}
+	// packages that are unrelated to the prefix; their number must not change
+	// what is loaded.
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("unrelated/p%d/p.go", i)] = fmt.Sprintf("package p%d\n\nimport _ \"example.com/foo/c\"\n", i)
+	}
+	repo := gtatest.NewRepo(t, "example.com/foo", files)
+
+	loaded := make(map[string]bool)
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		pkgs, err := packages.Load(cfg, patterns...)
+		packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+			loaded[pkg.PkgPath] = true
+		})
+		return pkgs, err
+	})
+
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			repo.Path("a"): {Exists: true, Files: []string{"a.go"}},
+		},
+	}
+	gt, err := New(SetDir(repo.Dir), SetDiffer(difr), SetLoader(loader), SetPrefixes("example.com/foo/b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"example.com/foo/a": true, "example.com/foo/b": true}
+	if diff := cmp.Diff(want, loaded); diff != "" {
+		t.Errorf("loaded packages (-want, +got)\n%s", diff)
+	}
+
+	pkgs, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range pkgs.AllChanges {
+		got = append(got, pkg.ImportPath)
+	}
+	if diff := cmp.Diff([]string{"example.com/foo/b"}, got); diff != "" {
+		t.Errorf("changed packages (-want, +got)\n%s", diff)
+	}
+}
+
+func BenchmarkDependencyGraph(b *testing.B) {
+	runSizes(b, func(b *testing.B, loader Loader) {
+		for i := 0; i < b.N; i++ {
+			if _, _, _, _, _, err := dependencyGraph(loader, newLoadConfig(nil), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGraphNodes_Workers(t *testing.T) {
+	pkgs := gtatest.SyntheticPackages(100, 5, 1)
+	want := forwardGraph(graphNodes(pkgs, 1))
+	for _, workers := range []int{0, 3, runtime.GOMAXPROCS(0), 1000} {
+		got := forwardGraph(graphNodes(pkgs, workers))
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("workers=%d (-want, +got)\n%s", workers, diff)
+		}
+	}
+}
+
+func TestNew_ParallelismLimitsGraphWorkers(t *testing.T) {
+	pkgs := gtatest.SyntheticPackages(200, 5, 1)
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return pkgs, nil
+	})
+
+	// without the limit, more workers than any of the limits would run.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	var active, max int32
+	testHookGraphNode = func() {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		// give the other goroutines the chance to run concurrently.
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	}
+	defer func() { testHookGraphNode = nil }()
+
+	for _, n := range []int{1, 2} {
+		atomic.StoreInt32(&max, 0)
+		if _, err := New(SetDiffer(&testDiffer{}), SetLoader(loader), SetParallelism(n)); err != nil {
+			t.Fatal(err)
+		}
+		if got := atomic.LoadInt32(&max); got < 1 || got > int32(n) {
+			t.Errorf("parallelism %d: got %d concurrent workers, want between 1 and %d", n, got, n)
+		}
+	}
+}
+
+func BenchmarkGraphNodes_Workers(b *testing.B) {
+	for _, n := range benchSizes(b) {
+		pkgs := gtatest.SyntheticPackages(n, 5, 1)
+		for _, workers := range []int{1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("packages=%d/workers=%d", n, workers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					graphNodes(pkgs, workers)
+				}
+			})
+		}
+	}
+}
+
+func TestDependencyGraph_Placeholders(t *testing.T) {
+	// without NeedDeps, the imports of the packages that match the patterns
+	// may be placeholders with only their IDs.
+	a := &packages.Package{
+		ID:      "example.com/a",
+		Name:    "a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go"},
+		Imports: map[string]*packages.Package{
+			"example.com/b":      {ID: "example.com/b"},
+			"github.com/dep/lib": {ID: "github.com/dep/lib"},
+		},
+	}
+	aTest := &packages.Package{
+		ID:      "example.com/a [example.com/a.test]",
+		Name:    "a",
+		PkgPath: "example.com/a",
+		GoFiles: []string{"/src/a/a.go", "/src/a/a_test.go"},
+		Imports: map[string]*packages.Package{
+			"example.com/c": {ID: "example.com/c [example.com/a.test]"},
+		},
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{a, aTest}, nil
+	})
+
+	_, _, reverse, _, _, err := dependencyGraph(loader, newLoadConfig(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]struct{}{
+		"example.com/b":      {"example.com/a": {}},
+		"example.com/c":      {"example.com/a": {}},
+		"github.com/dep/lib": {"example.com/a": {}},
+	}
+	if diff := cmp.Diff(want, reverse); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// NewPatchDiffer returns a Differ for the files changed by the unified diff
+// read from r, such as the output of git diff or git format-patch. The paths
+// in the diff are relative to the current working directory.
+func NewPatchDiffer(r io.Reader) (Differ, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return NewPatchDifferWithRoot(wd, r)
+}
+
+// NewPatchDifferWithRoot returns a Differ for the files changed by the unified
+// diff read from r. The paths in the diff are relative to root, such as the
+// root of a repository. The a/ and b/ prefixes that git adds to paths are
+// removed.
+func NewPatchDifferWithRoot(root string, r io.Reader) (Differ, error) {
+	files, err := parsePatch(r)
+	if err != nil {
+		return nil, err
+	}
+
+	abs := func(p string) string {
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	changes := make([]FileChange, 0, len(files))
+	for _, f := range files {
+		c := FileChange{Status: f.status}
+		switch f.status {
+		case StatusDeleted:
+			c.Path = abs(f.oldPath)
+		case StatusRenamed, StatusCopied:
+			c.Path, c.OldPath = abs(f.newPath), abs(f.oldPath)
+		default:
+			c.Path = abs(f.newPath)
+		}
+		changes = append(changes, c)
+	}
+
+	return NewFileChangesDiffer(changes)
+}
+
+// patchFile describes the change to a file in a unified diff.
+type patchFile struct {
+	oldPath string
+	newPath string
+	status  FileStatus
+
+	// sawNewPath is set once the +++ line of the file has been read.
+	sawNewPath bool
+}
+
+// parsePatch returns the files changed by the unified diff read from r. Lines
+// before the first file, such as the headers of an email created by git
+// format-patch, are ignored.
+func parsePatch(r io.Reader) ([]*patchFile, error) {
+	var files []*patchFile
+	var cur *patchFile
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if cur.status == "" {
+			switch {
+			case cur.oldPath == "":
+				cur.status = StatusAdded
+			case cur.newPath == "":
+				cur.status = StatusDeleted
+			default:
+				cur.status = StatusModified
+			}
+		}
+		files = append(files, cur)
+		cur = nil
+	}
+
+	// oldLines and newLines are the number of lines remaining in the current
+	// hunk, which must be skipped because their content can resemble headers.
+	var oldLines, newLines int
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+
+		if oldLines > 0 || newLines > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLines--
+			case strings.HasPrefix(line, "+"):
+				newLines--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file"
+			default:
+				oldLines--
+				newLines--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			oldPath, newPath := parseGitDiffHeader(strings.TrimPrefix(line, "diff --git "))
+			cur = &patchFile{oldPath: oldPath, newPath: newPath}
+		case cur != nil && strings.HasPrefix(line, "new file mode "):
+			cur.status = StatusAdded
+		case cur != nil && strings.HasPrefix(line, "deleted file mode "):
+			cur.status = StatusDeleted
+		case cur != nil && strings.HasPrefix(line, "rename from "):
+			cur.status, cur.oldPath = StatusRenamed, unquotePatchPath(strings.TrimPrefix(line, "rename from "))
+		case cur != nil && strings.HasPrefix(line, "rename to "):
+			cur.newPath = unquotePatchPath(strings.TrimPrefix(line, "rename to "))
+		case cur != nil && strings.HasPrefix(line, "copy from "):
+			cur.status, cur.oldPath = StatusCopied, unquotePatchPath(strings.TrimPrefix(line, "copy from "))
+		case cur != nil && strings.HasPrefix(line, "copy to "):
+			cur.newPath = unquotePatchPath(strings.TrimPrefix(line, "copy to "))
+		case strings.HasPrefix(line, "--- "):
+			// a --- line starts a new file in diffs that were not created by
+			// git.
+			if cur == nil || cur.sawNewPath {
+				flush()
+				cur = &patchFile{}
+			}
+			cur.oldPath = parsePatchPath(strings.TrimPrefix(line, "--- "), "a/")
+		case cur != nil && strings.HasPrefix(line, "+++ "):
+			cur.newPath = parsePatchPath(strings.TrimPrefix(line, "+++ "), "b/")
+			cur.sawNewPath = true
+		case cur != nil && strings.HasPrefix(line, "@@ "):
+			var err error
+			oldLines, newLines, err = parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineno, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return files, nil
+}
+
+// parseGitDiffHeader returns the paths in the remainder of a diff --git line.
+// The paths are ambiguous when they contain spaces; the ambiguity is resolved
+// by later lines of the diff, except for binary files and mode changes of
+// files whose paths contain " b/".
+func parseGitDiffHeader(s string) (oldPath, newPath string) {
+	if strings.HasPrefix(s, `"`) {
+		if i := closingQuote(s); i > 0 {
+			return parsePatchPath(s[:i+1], "a/"), parsePatchPath(strings.TrimSpace(s[i+1:]), "b/")
+		}
+	}
+
+	if i := strings.Index(s, " b/"); i >= 0 {
+		return parsePatchPath(s[:i], "a/"), parsePatchPath(s[i+1:], "b/")
+	}
+	if i := strings.Index(s, ` "b/`); i >= 0 {
+		return parsePatchPath(s[:i], "a/"), parsePatchPath(s[i+1:], "b/")
+	}
+	return "", ""
+}
+
+// closingQuote returns the index of the quote that closes the quoted string
+// at the start of s, or -1 when there is none.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// parsePatchPath returns the path in the remainder of a ---, +++, or diff
+// --git line with prefix removed. It returns an empty string for /dev/null.
+func parsePatchPath(s, prefix string) string {
+	// diff -u appends a tab and a timestamp to paths.
+	if !strings.HasPrefix(s, `"`) {
+		if i := strings.IndexByte(s, '\t'); i >= 0 {
+			s = s[:i]
+		}
+	}
+
+	s = unquotePatchPath(strings.TrimRight(s, " "))
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// unquotePatchPath returns the path s unquoted when git quoted it because it
+// contains unusual characters.
+func unquotePatchPath(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return unquoted
+		}
+	}
+	return s
+}
+
+// parseHunkHeader returns the number of old and new lines in the hunk whose
+// header is line, e.g. "@@ -1,5 +1,6 @@ func main() {".
+func parseHunkHeader(line string) (oldLines, newLines int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+
+	count := func(r string) (int, error) {
+		i := strings.IndexByte(r, ',')
+		if i < 0 {
+			// the count is omitted when it is one.
+			if _, err := strconv.Atoi(r); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}
+		return strconv.Atoi(r[i+1:])
+	}
+
+	if oldLines, err = count(fields[1][1:]); err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	if newLines, err = count(fields[2][1:]); err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	return oldLines, newLines, nil
+}
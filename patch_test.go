@@ -0,0 +1,133 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewPatchDifferWithRoot(t *testing.T) {
+	root := filepath.FromSlash("/src/repo")
+	abs := func(p string) string {
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+
+	tests := []struct {
+		desc  string
+		patch string
+		want  map[string]bool
+	}{
+		{
+			desc: "format-patch",
+			patch: `From 1234567890abcdef1234567890abcdef12345678 Mon Sep 17 00:00:00 2001
+From: gta <gta@example.com>
+Date: Mon, 1 Jan 2018 00:00:00 +0000
+Subject: [PATCH] change things
+
+---
+ foo/foo.go | 3 ++-
+ 1 file changed, 2 insertions(+), 1 deletion(-)
+
+diff --git a/foo/foo.go b/foo/foo.go
+index 1111111..2222222 100644
+--- a/foo/foo.go
++++ b/foo/foo.go
+@@ -1,3 +1,4 @@
+ package foo
+-
+--- a/looks/like/a/header.go
++++ b/looks/like/a/header.go
++var Foo string
+diff --git a/bar/bar.go b/bar/bar.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/bar/bar.go
+@@ -0,0 +1 @@
++package bar
+diff --git a/baz/baz.go b/baz/baz.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/baz/baz.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package baz
+diff --git a/quux/qux.go b/qux/qux.go
+similarity index 100%
+rename from quux/qux.go
+rename to qux/qux.go
+diff --git a/img/logo.png b/img/logo.png
+index 5555555..6666666 100644
+Binary files a/img/logo.png and b/img/logo.png differ
+diff --git "a/with space/\303\251.go" "b/with space/\303\251.go"
+index 7777777..8888888 100644
+--- "a/with space/\303\251.go"
++++ "b/with space/\303\251.go"
+@@ -1 +1 @@
+-package a
++package b
+--
+2.30.0
+`,
+			want: map[string]bool{
+				abs("foo/foo.go"):      true,
+				abs("bar/bar.go"):      true,
+				abs("baz/baz.go"):      false,
+				abs("quux/qux.go"):     false,
+				abs("qux/qux.go"):      true,
+				abs("img/logo.png"):    true,
+				abs("with space/é.go"): true,
+			},
+		},
+		{
+			desc: "diff -u",
+			patch: `--- foo/foo.go	2018-01-01 00:00:00.000000000 +0000
++++ foo/foo.go	2018-01-02 00:00:00.000000000 +0000
+@@ -1 +1,2 @@
+ package foo
++var Foo string
+--- bar/bar.go	2018-01-01 00:00:00.000000000 +0000
++++ /dev/null	1970-01-01 00:00:00.000000000 +0000
+@@ -1 +0,0 @@
+-package bar
+`,
+			want: map[string]bool{
+				abs("foo/foo.go"): true,
+				abs("bar/bar.go"): false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			sut, err := NewPatchDifferWithRoot(root, strings.NewReader(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := sut.DiffFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewPatchDiffer_InvalidHunk(t *testing.T) {
+	patch := "--- a/foo.go\n+++ b/foo.go\n@@ -x +1 @@\n"
+	if _, err := NewPatchDiffer(strings.NewReader(patch)); err == nil {
+		t.Error("expected an error")
+	}
+}
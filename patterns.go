@@ -0,0 +1,127 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SetPatterns sets the patterns of the packages that the default packager
+// loads, instead of loading the packages within the prefixes. The patterns
+// are those of go list, e.g. an import path, ./..., or example.com/app/...,
+// and a pattern that starts with - excludes the packages that it matches from
+// those of the other patterns, e.g. -./gen/... excludes a tree of generated
+// packages from loading at all. The excluded packages are not reported as
+// changed, but are loaded when the other packages import them.
+func SetPatterns(patterns ...string) Option {
+	return func(g *GTA) error {
+		for _, pattern := range patterns {
+			if pattern == "" || pattern == "-" {
+				return fmt.Errorf("invalid pattern %q", pattern)
+			}
+		}
+		g.pkgPatterns = patterns
+		return nil
+	}
+}
+
+// expandPatterns returns the patterns, without the negated patterns. When
+// there are negated patterns, the packages that the other patterns match are
+// listed, without their dependencies, and the patterns are the import paths of
+// the packages that the negated patterns do not exclude.
+func (g *GTA) expandPatterns(cfg *packages.Config, pkgPatterns []string) ([]string, error) {
+	var include, exclude []string
+	for _, pattern := range pkgPatterns {
+		if strings.HasPrefix(pattern, "-") {
+			exclude = append(exclude, strings.TrimPrefix(pattern, "-"))
+			continue
+		}
+		include = append(include, pattern)
+	}
+	if len(include) == 0 {
+		return nil, fmt.Errorf("no patterns to include in %q", pkgPatterns)
+	}
+	if len(exclude) == 0 {
+		return include, nil
+	}
+
+	listCfg := *cfg
+	listCfg.Mode = packages.NeedName | packages.NeedFiles
+	listCfg.Tests = false
+	pkgs, err := g.loader.Load(&listCfg, include...)
+	if err != nil {
+		return nil, fmt.Errorf("listing packages: %w", err)
+	}
+
+	wd, err := g.workingDir()
+	if err != nil {
+		return nil, err
+	}
+
+	g.excluded = make(map[string]bool)
+	paths := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		if excludes(exclude, wd, pkg) {
+			g.excluded[pkg.PkgPath] = true
+			continue
+		}
+		paths[pkg.PkgPath] = struct{}{}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("patterns %q match no packages", pkgPatterns)
+	}
+
+	patterns := make([]string, 0, len(paths))
+	for path := range paths {
+		patterns = append(patterns, path)
+	}
+	sort.Strings(patterns)
+	return patterns, nil
+}
+
+// excludes reports whether any of the patterns match pkg. Relative patterns,
+// such as ./gen/..., match the directory of pkg relative to the directory wd,
+// and other patterns match its import path.
+func excludes(patterns []string, wd string, pkg *packages.Package) bool {
+	var rel string
+	if len(pkg.GoFiles) > 0 {
+		if r, err := filepath.Rel(wd, filepath.Dir(pkg.GoFiles[0])); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if !isRelativePattern(pattern) {
+			if matchNode([]string{pattern}, pkg.PkgPath) {
+				return true
+			}
+			continue
+		}
+		if rel == "" {
+			continue
+		}
+		pattern = strings.TrimPrefix(pattern, "./")
+		if pattern == "." || pattern == "..." {
+			return true
+		}
+		if matchNode([]string{pattern}, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelativePattern reports whether the pattern is relative to a directory,
+// like go list's ./... and ../x.
+func isRelativePattern(pattern string) bool {
+	return pattern == "." || pattern == ".." || strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../")
+}
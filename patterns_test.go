@@ -0,0 +1,156 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSetPatterns(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "go.mod", "module example.com/app\n")
+	defer Setenv(t, "GOWORK", "off")()
+
+	module := &packages.Module{Path: "example.com/app", Dir: dir, Main: true}
+	app := &packages.Package{
+		ID:      "example.com/app",
+		Name:    "app",
+		PkgPath: "example.com/app",
+		GoFiles: []string{filepath.Join(dir, "app.go")},
+		Module:  module,
+	}
+	gen := &packages.Package{
+		ID:      "example.com/app/gen/pb",
+		Name:    "pb",
+		PkgPath: "example.com/app/gen/pb",
+		GoFiles: []string{filepath.Join(dir, "gen", "pb", "pb.go")},
+		Module:  module,
+	}
+	server := &packages.Package{
+		ID:      "example.com/app/server",
+		Name:    "server",
+		PkgPath: "example.com/app/server",
+		GoFiles: []string{filepath.Join(dir, "server", "server.go")},
+		Imports: map[string]*packages.Package{"example.com/app/gen/pb": gen},
+		Module:  module,
+	}
+
+	tests := []struct {
+		desc     string
+		patterns []string
+		want     [][]string
+	}{
+		{
+			desc:     "verbatim",
+			patterns: []string{"./...", "example.com/other"},
+			want:     [][]string{{"./...", "example.com/other"}},
+		},
+		{
+			desc:     "relative negation",
+			patterns: []string{"./...", "-./gen/..."},
+			want:     [][]string{{"./..."}, {"example.com/app", "example.com/app/server"}},
+		},
+		{
+			desc:     "import path negation",
+			patterns: []string{"./...", "-example.com/app/gen/..."},
+			want:     [][]string{{"./..."}, {"example.com/app", "example.com/app/server"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got [][]string
+			loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+				got = append(got, patterns)
+				if cfg.Mode == packages.NeedName|packages.NeedFiles {
+					return []*packages.Package{app, gen, server}, nil
+				}
+				return []*packages.Package{app, server}, nil
+			})
+
+			if _, err := New(SetDir(dir), SetLoader(loader), SetPatterns(tt.patterns...)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGTA_PatternsExcluded(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "go.mod", "module example.com/app\n")
+	if err := os.MkdirAll(filepath.Join(dir, "gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "gen/gen.go", "package gen\n")
+	defer Setenv(t, "GOWORK", "off")()
+
+	module := &packages.Module{Path: "example.com/app", Dir: dir, Main: true}
+	gen := &packages.Package{
+		ID:      "example.com/app/gen",
+		Name:    "gen",
+		PkgPath: "example.com/app/gen",
+		GoFiles: []string{filepath.Join(dir, "gen", "gen.go")},
+		Module:  module,
+	}
+	server := &packages.Package{
+		ID:      "example.com/app/server",
+		Name:    "server",
+		PkgPath: "example.com/app/server",
+		GoFiles: []string{filepath.Join(dir, "server", "server.go")},
+		Imports: map[string]*packages.Package{"example.com/app/gen": gen},
+		Module:  module,
+	}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		if cfg.Mode == packages.NeedName|packages.NeedFiles {
+			return []*packages.Package{gen, server}, nil
+		}
+		return []*packages.Package{server}, nil
+	})
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(dir, "gen"): {Exists: true, Files: []string{"gen.go"}},
+		},
+	}
+
+	gt, err := New(SetDir(dir), SetLoader(loader), SetDiffer(difr), SetPatterns("./...", "-./gen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the excluded package is loaded as a dependency of the server package, and
+	// marks it as changed, but is not reported itself.
+	want := []string{"example.com/app/server"}
+	if diff := cmp.Diff(want, stringify(cp.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestSetPatterns_Invalid(t *testing.T) {
+	for _, patterns := range [][]string{{""}, {"./...", "-"}} {
+		if _, err := New(SetPackager(&testPackager{}), SetPatterns(patterns...)); err == nil {
+			t.Errorf("New(SetPatterns(%q)): got nil error", patterns)
+		}
+	}
+}
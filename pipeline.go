@@ -0,0 +1,146 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+// ChangedPackages runs in four stages, each of which can be replaced with
+// options:
+//
+//	DiffResolver    determines the changed files
+//	FileMapper      maps the changed files to the changed packages
+//	ImpactAnalyzer  determines the packages affected by the changed packages
+//	Reporter        builds the Packages from the affected packages
+//
+// A GTA is the default implementation of each stage, so a replacement stage
+// can delegate to a GTA for the parts it does not change.
+
+// Diff is the output of a DiffResolver.
+type Diff struct {
+	// Dirs are the changed directories, keyed by their absolute paths.
+	Dirs map[string]Directory
+	// Labels contains the labels of the differs that reported changes for
+	// each directory.
+	Labels map[string][]string
+	// Binary is the set of the absolute paths of the changed binary files
+	// that only change the packages they are mapped to.
+	Binary map[string]struct{}
+}
+
+// A DiffResolver determines the changed files.
+type DiffResolver interface {
+	ResolveDiff() (*Diff, error)
+}
+
+// Mapping is the output of a FileMapper.
+type Mapping struct {
+	// Changed contains the import paths of the changed packages. The values
+	// are true when the package was deleted.
+	Changed map[string]bool
+	// Origins contains the sorted labels of the differs that reported changes
+	// for each changed package.
+	Origins map[string][]string
+	// Files contains the absolute paths of the changed files of each changed
+	// package.
+	Files map[string][]string
+	// FixtureConsumers are the sorted import paths of the packages whose
+	// shared test fixtures, testdata directories, or build and deployment
+	// files changed. Their dependents are not affected by the changes to the
+	// files.
+	FixtureConsumers []string
+	// ModulePathChanges are the changes to the module paths of go.mod files.
+	ModulePathChanges []ModulePathChange
+	// Targets are the sorted pseudo-targets that the mapping rules map the
+	// changed files to.
+	Targets []string
+}
+
+// A FileMapper maps changed files to the changed packages.
+type FileMapper interface {
+	MapFiles(diff *Diff) (*Mapping, error)
+}
+
+// Impact is the output of an ImpactAnalyzer.
+type Impact struct {
+	// Affected maps the import path of each changed package to the import
+	// paths of the packages it affects, including itself. The values are
+	// true when the affected package exists and can be looked up with the
+	// packager.
+	Affected map[string]map[string]bool
+	// Origins, Files, ModulePathChanges, and Targets are those of the Mapping.
+	Origins           map[string][]string
+	Files             map[string][]string
+	ModulePathChanges []ModulePathChange
+	Targets           []string
+	// Graph is the dependent graph that was traversed. It is nil when
+	// Partial is true.
+	Graph *Graph
+	// Partial is true when the dependency graph could not be built, so
+	// Affected contains a conservative superset of the affected packages.
+	Partial bool
+}
+
+// An ImpactAnalyzer determines the packages affected by changed packages.
+type ImpactAnalyzer interface {
+	AnalyzeImpact(mapping *Mapping) (*Impact, error)
+}
+
+// A Reporter builds the changed packages from the packages affected by the
+// changes.
+type Reporter interface {
+	Report(impact *Impact) (*Packages, error)
+}
+
+// impact runs g's diff, mapping, and impact analysis stages.
+func (g *GTA) impact() (*Impact, error) {
+	var (
+		resolver DiffResolver   = g
+		mapper   FileMapper     = g
+		analyzer ImpactAnalyzer = g
+	)
+	if g.resolver != nil {
+		resolver = g.resolver
+	}
+	if g.mapper != nil {
+		mapper = g.mapper
+	}
+	if g.analyzer != nil {
+		analyzer = g.analyzer
+	}
+
+	diff, err := resolver.ResolveDiff()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := mapper.MapFiles(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	return analyzer.AnalyzeImpact(mapping)
+}
+
+// marks returns the marks of the impact.
+func (i *Impact) marks() *marks {
+	origins := make(map[string]map[string]struct{}, len(i.Origins))
+	for importPath, labels := range i.Origins {
+		origins[importPath] = make(map[string]struct{}, len(labels))
+		for _, label := range labels {
+			origins[importPath][label] = struct{}{}
+		}
+	}
+
+	return &marks{
+		paths:   i.Affected,
+		origins: origins,
+		files:   i.Files,
+		graph:   i.Graph,
+		partial: i.Partial,
+
+		modulePathChanges: i.ModulePathChanges,
+		targets:           i.Targets,
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// directAnalyzer is an ImpactAnalyzer that only affects the changed packages
+// themselves.
+type directAnalyzer struct{}
+
+func (directAnalyzer) AnalyzeImpact(mapping *Mapping) (*Impact, error) {
+	affected := make(map[string]map[string]bool, len(mapping.Changed))
+	for importPath, deleted := range mapping.Changed {
+		affected[importPath] = map[string]bool{importPath: !deleted}
+	}
+	return &Impact{Affected: affected, Origins: mapping.Origins, Files: mapping.Files}, nil
+}
+
+// countingReporter is a Reporter that delegates to a GTA and records the
+// number of affected packages.
+type countingReporter struct {
+	gta      *GTA
+	affected int
+}
+
+func (r *countingReporter) Report(impact *Impact) (*Packages, error) {
+	for _, marked := range impact.Affected {
+		r.affected += len(marked)
+	}
+	return r.gta.Report(impact)
+}
+
+func pipelineTestGTA(t *testing.T, opts ...Option) *GTA {
+	t.Helper()
+
+	// A depends on B depends on C, and dirC is changed.
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirC": {Exists: true, Files: []string{"c.go"}},
+		},
+	}
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"C": {"B": true},
+				"B": {"A": true},
+			},
+		},
+	}
+
+	gta, err := New(append([]Option{SetDiffer(difr), SetPackager(pkgr)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gta
+}
+
+func TestGTA_Stages(t *testing.T) {
+	gta := pipelineTestGTA(t)
+
+	diff, err := gta.ResolveDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := gta.MapFiles(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMapping := &Mapping{
+		Changed:          map[string]bool{"C": false},
+		Files:            map[string][]string{"C": {"dirC/c.go"}},
+		FixtureConsumers: []string{},
+	}
+	if diff := cmp.Diff(wantMapping, mapping); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	impact, err := gta.AnalyzeImpact(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := gta.Report(impact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_SetImpactAnalyzer(t *testing.T) {
+	gta := pipelineTestGTA(t, SetImpactAnalyzer(directAnalyzer{}))
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{},
+		Changes:      []Package{{ImportPath: "C"}},
+		AllChanges:   []Package{{ImportPath: "C"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_SetReporter(t *testing.T) {
+	reporter := new(countingReporter)
+	gta := pipelineTestGTA(t, SetReporter(reporter))
+	reporter.gta = gta
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := 3; reporter.affected != want {
+		t.Errorf("got %d affected packages, want %d", reporter.affected, want)
+	}
+	if want := []string{"A", "B", "C"}; !cmp.Equal(want, stringify(got.AllChanges)) {
+		t.Errorf("got %v, want %v", stringify(got.AllChanges), want)
+	}
+}
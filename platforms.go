@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// platformPackages returns a map of each of g's platforms to the sorted
+// packages that are affected by the changes on the platform. The packages are
+// loaded with g's Loader once for each platform.
+func (g *GTA) platformPackages() (map[string][]string, error) {
+	affected := make(map[string][]string, len(g.platforms))
+	for _, platform := range g.platforms {
+		goos, goarch := splitPlatform(platform)
+		pkgs, err := g.configPackages(g.tags, goos, goarch)
+		if err != nil {
+			return nil, fmt.Errorf("platform %s, %v", platform, err)
+		}
+		affected[platform] = pkgs
+	}
+	return affected, nil
+}
+
+// splitPlatform returns the GOOS and GOARCH of the platform goos/goarch.
+func splitPlatform(platform string) (string, string) {
+	i := strings.Index(platform, "/")
+	if i < 0 {
+		return platform, ""
+	}
+	return platform[:i], platform[i+1:]
+}
+
+// validPlatform returns an error when platform is not of the form
+// goos/goarch.
+func validPlatform(platform string) error {
+	goos, goarch := splitPlatform(platform)
+	if goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return fmt.Errorf("invalid platform %q: must be of the form goos/goarch", platform)
+	}
+	return nil
+}
+
+// isPlatformFile reports whether fn is a Go or assembly file of a package,
+// which are only built for the platforms matching their names and build
+// constraints.
+func isPlatformFile(fn string) bool {
+	switch filepath.Ext(fn) {
+	case ".go", ".s", ".S":
+		return true
+	}
+	return false
+}
+
+// withoutForeignFiles returns dir without the Go and assembly files in the
+// directory abs that are not built for g's platform, because of the GOOS and
+// GOARCH suffixes of their names or their build constraints.
+func (g *GTA) withoutForeignFiles(abs string, dir Directory) Directory {
+	ctx := g.buildContext(g.tags)
+	files := make([]string, 0, len(dir.Files))
+	for _, fn := range dir.Files {
+		if !isPlatformFile(fn) || builtFor(ctx, abs, fn) {
+			files = append(files, fn)
+		}
+	}
+	dir.Files = files
+	return dir
+}
+
+// builtFor reports whether the file name in the directory dir is built for
+// the platform of ctx. The build constraints of a file that no longer exists
+// are unknown, so only its name is considered.
+func builtFor(ctx build.Context, dir, name string) bool {
+	ok, err := ctx.MatchFile(dir, name)
+	if err == nil {
+		return ok
+	}
+
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	ok, err = ctx.MatchFile(dir, name)
+	return ok || err != nil
+}
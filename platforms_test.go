@@ -0,0 +1,113 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGTA_Platforms(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "a/a.go", "package a\n")
+	writeFile(t, dir, "a/console.go", "//go:build windows\n\npackage a\n")
+	writeFile(t, dir, "a/signal_unix.go", "//go:build !windows\n\npackage a\n")
+	writeFile(t, dir, "b/b.go", "package b\n")
+
+	// b depends on a
+	module := &packages.Module{Path: "example.com", Dir: dir, Main: true}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		a := &packages.Package{
+			ID:      "example.com/a",
+			PkgPath: "example.com/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Module:  module,
+		}
+		b := &packages.Package{
+			ID:      "example.com/b",
+			PkgPath: "example.com/b",
+			GoFiles: []string{filepath.Join(dir, "b", "b.go")},
+			Imports: map[string]*packages.Package{"example.com/a": a},
+			Module:  module,
+		}
+		return []*packages.Package{a, b}, nil
+	})
+
+	tests := []struct {
+		file string
+		want map[string][]string
+	}{
+		{
+			file: "console.go",
+			want: map[string][]string{
+				"linux/amd64":   {},
+				"windows/amd64": {"example.com/a", "example.com/b"},
+			},
+		},
+		{
+			file: "signal_unix.go",
+			want: map[string][]string{
+				"linux/amd64":   {"example.com/a", "example.com/b"},
+				"windows/amd64": {},
+			},
+		},
+		{
+			file: "a.go",
+			want: map[string][]string{
+				"linux/amd64":   {"example.com/a", "example.com/b"},
+				"windows/amd64": {"example.com/a", "example.com/b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			difr := &testDiffer{
+				diff: map[string]Directory{
+					filepath.Join(dir, "a"): {Exists: true, Files: []string{tt.file}},
+				},
+			}
+			gt, err := New(SetDir(dir), SetDiffer(difr), SetLoader(loader), SetPlatforms("linux/amd64", "windows/amd64"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, pkgs.Platforms); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetPlatforms_Invalid(t *testing.T) {
+	for _, platforms := range [][]string{
+		{"linux"},
+		{"linux/amd64/v3"},
+		{"/amd64"},
+		{"linux/amd64", "linux/amd64"},
+	} {
+		if _, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetPlatforms(platforms...)); err == nil {
+			t.Errorf("SetPlatforms(%q): expected an error", platforms)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// vcsDirs are the names of the directories that mark the roots of the
+// repositories of the supported version control systems.
+var vcsDirs = []string{".git", ".hg", ".svn"}
+
+// SetRepoScope scopes the packages that the default packager loads to those
+// of the repository that contains the GTA's directory, instead of those that
+// the prefixes expand to, which may include packages outside of the
+// repository, e.g. in GOPATH. The root of the repository and the boundaries
+// of its modules are detected, and the packages of repositories checked out
+// within it are not loaded. Patterns set with SetPatterns take precedence.
+func SetRepoScope(enabled bool) Option {
+	return func(g *GTA) error {
+		g.repoScope = enabled
+		return nil
+	}
+}
+
+// repoPatterns returns the patterns of the packages of the repository that
+// contains g's directory, loaded with the configuration cfg. The go command
+// itself excludes the packages of nested modules that are not part of the
+// build, and the packages of nested checkouts are excluded explicitly.
+func (g *GTA) repoPatterns(cfg *packages.Config) ([]string, error) {
+	wd, err := g.workingDir()
+	if err != nil {
+		return nil, err
+	}
+	root, ok := repoRoot(wd)
+	if !ok {
+		return nil, fmt.Errorf("no repository contains %s", wd)
+	}
+
+	// outside of a workspace, the go command only loads the packages of the
+	// module that contains wd, so the packages are loaded from the root of
+	// the module when it is within the repository, and from the root of the
+	// repository when the module contains the repository.
+	fn, err := FindWorkspace(wd, cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+	if modRoot, ok := moduleRoot(wd); ok && fn == "" && strings.HasPrefix(modRoot, root) {
+		root = modRoot
+	}
+
+	nested, err := nestedCheckouts(root)
+	if err != nil {
+		return nil, fmt.Errorf("finding nested checkouts, %v", err)
+	}
+
+	patterns := []string{relativePattern(wd, root)}
+	for _, dir := range nested {
+		patterns = append(patterns, "-"+relativePattern(wd, dir))
+	}
+	return g.expandPatterns(cfg, patterns)
+}
+
+// relativePattern returns the pattern, relative to the directory wd, of the
+// packages in dir and beneath it.
+func relativePattern(wd, dir string) string {
+	rel, err := filepath.Rel(wd, dir)
+	if err != nil {
+		return filepath.ToSlash(dir) + "/..."
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return "./..."
+	}
+	if !isRelativePattern(rel) {
+		rel = "./" + rel
+	}
+	return rel + "/..."
+}
+
+// repoRoot returns the root directory of the repository that contains dir.
+func repoRoot(dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	for {
+		if isCheckout(dir) {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// isCheckout reports whether dir is the root of a repository.
+func isCheckout(dir string) bool {
+	for _, name := range vcsDirs {
+		// the .git of a worktree or a submodule is a file.
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedCheckouts returns the root directories of the repositories checked
+// out within the repository whose root is root, skipping the directories that
+// the go command ignores.
+func nestedCheckouts(root string) ([]string, error) {
+	var nested []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path == root {
+			return nil
+		}
+
+		name := info.Name()
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" {
+			return filepath.SkipDir
+		}
+		if isCheckout(path) {
+			nested = append(nested, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return nested, err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSetRepoScope(t *testing.T) {
+	tests := []struct {
+		desc  string
+		goMod string
+		dir   string
+		want  [][]string
+	}{
+		{
+			desc:  "module within repository",
+			goMod: "repo/app/go.mod",
+			dir:   "repo/app/lib",
+			want:  [][]string{{"../..."}, {"example.com/app/lib"}},
+		},
+		{
+			desc:  "repository within module",
+			goMod: "go.mod",
+			dir:   "repo/app/lib",
+			want:  [][]string{{"../../..."}, {"example.com/app/lib"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tmp, err := filepath.EvalSymlinks(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Setenv(t, "GOWORK", "off")()
+			for _, d := range []string{"repo/.git", "repo/app/lib", "repo/app/third_party/other/.git", "repo/app/.cache/.git"} {
+				if err := os.MkdirAll(filepath.Join(tmp, filepath.FromSlash(d)), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeFile(t, tmp, tt.goMod, "module example.com/app\n")
+			dir := filepath.Join(tmp, filepath.FromSlash(tt.dir))
+
+			module := &packages.Module{Path: "example.com/app", Dir: filepath.Dir(filepath.Join(tmp, tt.goMod)), Main: true}
+			lib := &packages.Package{
+				ID:      "example.com/app/lib",
+				Name:    "lib",
+				PkgPath: "example.com/app/lib",
+				GoFiles: []string{filepath.Join(tmp, "repo", "app", "lib", "lib.go")},
+				Module:  module,
+			}
+			other := &packages.Package{
+				ID:      "example.com/app/third_party/other",
+				Name:    "other",
+				PkgPath: "example.com/app/third_party/other",
+				GoFiles: []string{filepath.Join(tmp, "repo", "app", "third_party", "other", "other.go")},
+				Module:  module,
+			}
+
+			var got [][]string
+			loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+				got = append(got, patterns)
+				if cfg.Mode == packages.NeedName|packages.NeedFiles {
+					return []*packages.Package{lib, other}, nil
+				}
+				return []*packages.Package{lib}, nil
+			})
+
+			if _, err := New(SetDir(dir), SetLoader(loader), SetPrefixes("example.com/"), SetRepoScope(true)); err != nil {
+				t.Fatal(err)
+			}
+
+			// the packages of the nested checkout are excluded, and the
+			// checkout in the hidden directory is ignored, like the go command
+			// ignores the directory.
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNestedCheckouts(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{".git", "a/.git", "a/b/.hg", "c/d/.svn", "vendor/e/.git", "testdata/f/.git", "g"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the .git of a worktree or submodule is a file.
+	writeFile(t, root, "g/.git", "gitdir: ../.git/modules/g\n")
+
+	got, err := nestedCheckouts(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "c", "d"), filepath.Join(root, "g")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestRepoRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".hg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := repoRoot(filepath.Join(root, "a", "b"))
+	if !ok || got != root {
+		t.Errorf("repoRoot() = %q, %t; want %q, true", got, ok, root)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A SpecMapping maps API specification files, such as OpenAPI, Thrift, or
+// GraphQL schemas, to the packages that are generated from them. Changes to
+// the specification files mark the packages, and their dependents, as changed,
+// so that changes to only the specifications still select the generated
+// packages.
+type SpecMapping struct {
+	// Patterns are the absolute glob patterns of the specification files. The
+	// syntax is that of path.Match, with the addition that a "**" element
+	// matches zero or more directories.
+	Patterns []string
+
+	// Packages are the import paths of the generated packages.
+	Packages []string
+}
+
+// specPackages returns the import paths of the packages generated from the
+// specification file abs.
+func (g *GTA) specPackages(abs string) []string {
+	var pkgs []string
+	for _, mapping := range g.specMappings {
+		for _, pattern := range mapping.Patterns {
+			if matchGlob(filepath.ToSlash(pattern), filepath.ToSlash(abs)) {
+				pkgs = append(pkgs, mapping.Packages...)
+				break
+			}
+		}
+	}
+	return pkgs
+}
+
+// mappedPackages returns the import paths of the packages that are changed by
+// a change to the file abs, along with their dependents, according to
+// g's migrations, specification mappings, mapping rules, go:generate
+// directives, and vendored modules.
+func (g *GTA) mappedPackages(abs string) ([]string, error) {
+	pkgs, err := g.migrationPackages(abs)
+	if err != nil {
+		return nil, err
+	}
+	generated, err := g.generatePackages(abs)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, generated...)
+	vendored, err := g.vendorPackages(abs)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, vendored...)
+	ruled, err := g.rulePackages(abs)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, ruled...)
+	return append(pkgs, g.specPackages(abs)...), nil
+}
+
+// MatchGlob reports whether the slash separated name matches the glob pattern,
+// with the syntax of the patterns of SpecMapping and GenerateMapping.
+func MatchGlob(pattern, name string) bool {
+	return matchGlob(pattern, name)
+}
+
+// matchGlob reports whether the slash separated name matches the shell
+// pattern. A "**" element in pattern matches zero or more elements of name.
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"go/build"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGTA_SpecMappings(t *testing.T) {
+	// A depends on B
+	// C depends on A
+	// B is generated from the OpenAPI spec
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo/api/openapi": Directory{Exists: true, Files: []string{"droplets.yaml"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+			"A": map[string]bool{
+				"C": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirA": "A",
+			"dirB": "B",
+			"dirC": "C",
+		},
+		graph: graph,
+		errs: map[string]error{
+			"/repo/api/openapi": &build.NoGoError{Dir: "/repo/api/openapi"},
+		},
+	}
+
+	want := &Packages{
+		Dependencies: map[string][]Package{
+			"B": []Package{
+				{ImportPath: "A"},
+				{ImportPath: "C"},
+			},
+		},
+		Changes: []Package{
+			{ImportPath: "B"},
+		},
+		AllChanges: []Package{
+			{ImportPath: "A"},
+			{ImportPath: "B"},
+			{ImportPath: "C"},
+		},
+	}
+
+	mappings := []SpecMapping{
+		{
+			Patterns: []string{"/repo/api/**/*.yaml"},
+			Packages: []string{"B"},
+		},
+		{
+			Patterns: []string{"/repo/api/**/*.graphql"},
+			Packages: []string{"C"},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetSpecMappings(mappings...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "/repo/api/*.yaml", name: "/repo/api/droplets.yaml", want: true},
+		{pattern: "/repo/api/*.yaml", name: "/repo/api/v2/droplets.yaml", want: false},
+		{pattern: "/repo/api/**/*.yaml", name: "/repo/api/droplets.yaml", want: true},
+		{pattern: "/repo/api/**/*.yaml", name: "/repo/api/v2/compute/droplets.yaml", want: true},
+		{pattern: "/repo/api/**", name: "/repo/api/v2/droplets.thrift", want: true},
+		{pattern: "/repo/api/**/*.yaml", name: "/repo/other/droplets.yaml", want: false},
+		{pattern: "/repo/api/[", name: "/repo/api/[", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchGlob(%q, %q) = %v; want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
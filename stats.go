@@ -0,0 +1,152 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// GraphStats are statistics of a dependency graph, which help find the
+// packages that make changes expensive because many packages depend on them.
+type GraphStats struct {
+	// Nodes and Edges are the numbers of packages and imports in the graph.
+	Nodes int `json:"nodes"`
+	Edges int `json:"edges"`
+
+	// AvgFanIn and AvgFanOut are the average numbers of packages that import
+	// a package and that a package imports, and MaxFanIn and MaxFanOut are the
+	// packages with the most of them.
+	AvgFanIn  float64      `json:"avg_fan_in"`
+	AvgFanOut float64      `json:"avg_fan_out"`
+	MaxFanIn  PackageCount `json:"max_fan_in"`
+	MaxFanOut PackageCount `json:"max_fan_out"`
+
+	// TopDependents are the packages with the most transitive dependents,
+	// sorted by the number of dependents in descending order.
+	TopDependents []PackageCount `json:"top_dependents"`
+}
+
+// A PackageCount is a package and a number of packages related to it.
+type PackageCount struct {
+	ImportPath string `json:"import_path"`
+	Count      int    `json:"count"`
+}
+
+// Stats returns the statistics of d, with the top packages with the most
+// transitive dependents.
+func (d *DependencyGraph) Stats(top int) *GraphStats {
+	// the packages are numbered in import path order so that ties are broken
+	// by their import paths.
+	nodes := make(map[string]struct{})
+	for pkg, imports := range d.snap.Forward {
+		nodes[pkg] = struct{}{}
+		for _, imported := range imports {
+			nodes[imported] = struct{}{}
+		}
+	}
+	for pkg, dependents := range d.snap.Reverse {
+		nodes[pkg] = struct{}{}
+		for _, dependent := range dependents {
+			nodes[dependent] = struct{}{}
+		}
+	}
+	names := sortedSet(nodes)
+	ids := make(map[string]int, len(names))
+	for i, name := range names {
+		ids[name] = i
+	}
+
+	fanIn := make([]int, len(names))
+	fanOut := make([]int, len(names))
+	dependents := make([][]int, len(names))
+	stats := &GraphStats{Nodes: len(names)}
+	for pkg, imports := range d.snap.Forward {
+		for _, imported := range imports {
+			from, to := ids[pkg], ids[imported]
+			fanOut[from]++
+			fanIn[to]++
+			dependents[to] = append(dependents[to], from)
+			stats.Edges++
+		}
+	}
+	if stats.Nodes == 0 {
+		return stats
+	}
+
+	stats.AvgFanIn = float64(stats.Edges) / float64(stats.Nodes)
+	stats.AvgFanOut = stats.AvgFanIn
+	for i, name := range names {
+		if fanIn[i] > stats.MaxFanIn.Count {
+			stats.MaxFanIn = PackageCount{ImportPath: name, Count: fanIn[i]}
+		}
+		if fanOut[i] > stats.MaxFanOut.Count {
+			stats.MaxFanOut = PackageCount{ImportPath: name, Count: fanOut[i]}
+		}
+	}
+
+	counts := make([]PackageCount, len(names))
+	for i, n := range transitiveCounts(dependents, runtime.GOMAXPROCS(0)) {
+		counts[i] = PackageCount{ImportPath: names[i], Count: n}
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Count > counts[j].Count
+	})
+	if top < 0 {
+		top = 0
+	}
+	if top < len(counts) {
+		counts = counts[:top]
+	}
+	stats.TopDependents = counts
+
+	return stats
+}
+
+// transitiveCounts returns the number of nodes that can be reached from each
+// node of the graph edges, whose nodes are numbered, not counting the node
+// itself. The nodes are divided among workers goroutines.
+func transitiveCounts(edges [][]int, workers int) []int {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(edges) {
+		workers = len(edges)
+	}
+
+	counts := make([]int, len(edges))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// visited holds the number of the last traversal, starting at 1,
+			// that visited each node, so it does not need to be cleared.
+			visited := make([]int, len(edges))
+			var queue []int
+			for i := w; i < len(edges); i += workers {
+				visited[i] = i + 1
+				queue = append(queue[:0], i)
+				for len(queue) > 0 {
+					node := queue[0]
+					queue = queue[1:]
+					for _, next := range edges[node] {
+						if visited[next] == i+1 {
+							continue
+						}
+						visited[next] = i + 1
+						counts[i]++
+						queue = append(queue, next)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return counts
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDependencyGraph_Stats(t *testing.T) {
+	// A imports B and C, B imports C, and C imports D.
+	d := &DependencyGraph{snap: &graphSnapshot{
+		Forward: map[string][]string{
+			"A": {"B", "C"},
+			"B": {"C"},
+			"C": {"D"},
+		},
+		Reverse: map[string][]string{
+			"B": {"A"},
+			"C": {"A", "B"},
+			"D": {"C"},
+		},
+	}}
+
+	want := &GraphStats{
+		Nodes:     4,
+		Edges:     4,
+		AvgFanIn:  1,
+		AvgFanOut: 1,
+		MaxFanIn:  PackageCount{ImportPath: "C", Count: 2},
+		MaxFanOut: PackageCount{ImportPath: "A", Count: 2},
+		TopDependents: []PackageCount{
+			{ImportPath: "D", Count: 3},
+			{ImportPath: "C", Count: 2},
+			{ImportPath: "B", Count: 1},
+		},
+	}
+	if diff := cmp.Diff(want, d.Stats(3)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestDependencyGraph_Stats_Cycle(t *testing.T) {
+	d := &DependencyGraph{snap: &graphSnapshot{
+		Forward: map[string][]string{
+			"A": {"B"},
+			"B": {"A"},
+		},
+	}}
+
+	want := []PackageCount{
+		{ImportPath: "A", Count: 1},
+		{ImportPath: "B", Count: 1},
+	}
+	if diff := cmp.Diff(want, d.Stats(10).TopDependents); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func BenchmarkDependencyGraph_Stats(b *testing.B) {
+	runSizes(b, func(b *testing.B, loader Loader) {
+		_, forward, reverse, _, _, err := dependencyGraph(loader, newLoadConfig(nil), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		d := &DependencyGraph{snap: &graphSnapshot{Forward: setsToLists(forward), Reverse: setsToLists(reverse)}}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			d.Stats(10)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SVNDifferOption is an option function used to modify a Subversion differ.
+type SVNDifferOption func(*svn)
+
+// SetSVNRevisions sets the revisions that a Subversion differ determines the
+// changes between, such as BASE and HEAD or two revision numbers. The
+// revisions are BASE and HEAD by default, which are the revision of the
+// working copy and the latest revision in the repository.
+func SetSVNRevisions(from, to string) SVNDifferOption {
+	return func(s *svn) {
+		s.from, s.to = from, to
+	}
+}
+
+// SetSVNWorkingDir sets the directory in which a Subversion differ runs svn.
+// When it is not set, svn is run in the current working directory.
+func SetSVNWorkingDir(dir string) SVNDifferOption {
+	return func(s *svn) {
+		s.dir = dir
+	}
+}
+
+// SetSVNCommandTimeout sets the maximum duration of each svn command run by a
+// Subversion differ. A timeout of zero means svn commands do not time out.
+func SetSVNCommandTimeout(timeout time.Duration) SVNDifferOption {
+	return func(s *svn) {
+		s.timeout = timeout
+	}
+}
+
+// SetSVNContext sets the context of the svn commands run by a Subversion
+// differ. The commands are killed when ctx is done.
+func SetSVNContext(ctx context.Context) SVNDifferOption {
+	return func(s *svn) {
+		s.ctx = ctx
+	}
+}
+
+// NewSVNDiffer returns a Differ that determines differences using Subversion,
+// like svn diff --summarize -r BASE:HEAD in the root of the working copy. The
+// returned Differ is also a BaseReader.
+func NewSVNDiffer(opts ...SVNDifferOption) Differ {
+	s := &svn{
+		from: "BASE",
+		to:   "HEAD",
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return &svnDiffer{
+		differ: differ{
+			diff: s.diff,
+		},
+		svn: s,
+	}
+}
+
+// svnDiffer implements the BaseReader interface using Subversion.
+type svnDiffer struct {
+	differ
+	svn *svn
+}
+
+// ReadBase returns the content of the file at abs as of the revision the
+// changes are determined from.
+func (d *svnDiffer) ReadBase(abs string) ([]byte, error) {
+	return d.svn.readBase(abs)
+}
+
+// svn implements the Differ interface using the Subversion version control
+// system.
+type svn struct {
+	from    string
+	to      string
+	dir     string
+	timeout time.Duration
+	ctx     context.Context
+
+	onceDiff     sync.Once
+	changedFiles map[string]struct{}
+	diffErr      error
+
+	// root and added are set by diff: root is the absolute path of the root
+	// of the working copy and added is the set of the absolute paths of the
+	// files that were added by the changes.
+	root  string
+	added map[string]struct{}
+}
+
+// svnSummary is the output of svn diff --summarize --xml.
+type svnSummary struct {
+	Paths []struct {
+		Item string `xml:"item,attr"`
+		Kind string `xml:"kind,attr"`
+		Path string `xml:",chardata"`
+	} `xml:"paths>path"`
+}
+
+// context returns a context that is done when s's context is done or s's
+// command timeout elapses.
+func (s *svn) context() (context.Context, context.CancelFunc) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// output runs svn with args in dir and returns its standard output. When dir
+// is empty, s's working directory is used.
+func (s *svn) output(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if dir == "" {
+		dir = s.dir
+	}
+
+	// --non-interactive keeps svn from prompting for credentials.
+	cmd := exec.CommandContext(ctx, "svn", append([]string{"--non-interactive"}, args...)...)
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("svn %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// diff returns a set of changed files.
+func (s *svn) diff() (map[string]struct{}, error) {
+	s.onceDiff.Do(func() {
+		out, err := s.output("", "info", "--show-item", "wc-root")
+		if err != nil {
+			s.diffErr = err
+			return
+		}
+		root := strings.TrimSpace(string(out))
+
+		// run in the root of the working copy so that the listed paths are
+		// relative to it.
+		out, err = s.output(root, "diff", "--summarize", "--xml", "-r", s.from+":"+s.to, ".")
+		if err != nil {
+			s.diffErr = err
+			return
+		}
+
+		var summary svnSummary
+		if err := xml.Unmarshal(out, &summary); err != nil {
+			s.diffErr = fmt.Errorf("svn diff: %w", err)
+			return
+		}
+
+		files := make(map[string]struct{})
+		added := make(map[string]struct{})
+		for _, p := range summary.Paths {
+			// changes to the properties of directories do not change any
+			// package.
+			if p.Kind != "file" {
+				continue
+			}
+
+			abs := filepath.Join(root, filepath.FromSlash(p.Path))
+			files[abs] = struct{}{}
+			if p.Item == "added" {
+				added[abs] = struct{}{}
+			}
+		}
+
+		s.root, s.added = root, added
+		s.changedFiles = files
+	})
+
+	return s.changedFiles, s.diffErr
+}
+
+// readBase returns the content of the file at abs as of the revision that
+// the changes are determined from.
+func (s *svn) readBase(abs string) ([]byte, error) {
+	if _, err := s.diff(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.added[abs]; ok {
+		return nil, &os.PathError{Op: "read", Path: abs, Err: os.ErrNotExist}
+	}
+
+	rel, err := filepath.Rel(s.root, abs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the peg revision keeps an @ in the file name from being taken as one.
+	return s.output(s.root, "cat", "-r", s.from, filepath.ToSlash(rel)+"@")
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeSVN is a shell script that stands in for svn. The working copy is the
+// directory in the first argument to fmt, and the changes between revisions 3
+// and 5 modify foo.go, add bar.go and change the properties of a directory.
+const fakeSVN = `#!/bin/sh
+case "$*" in
+"--non-interactive info --show-item wc-root")
+	echo %[1]s
+	;;
+"--non-interactive diff --summarize --xml -r 3:5 .")
+	cat <<'XML'
+<?xml version="1.0" encoding="UTF-8"?>
+<diff>
+<paths>
+<path item="modified" props="none" kind="file">foo.go</path>
+<path item="added" props="none" kind="file">sub/bar.go</path>
+<path item="none" props="modified" kind="dir">sub</path>
+</paths>
+</diff>
+XML
+	;;
+"--non-interactive cat -r 3 foo.go@")
+	printf 'package foo\n'
+	;;
+*)
+	echo "unexpected arguments: $*" >&2
+	exit 1
+	;;
+esac
+`
+
+func TestSVNDiffer(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, bin, "svn", fmt.Sprintf(fakeSVN, dir))
+	if err := os.Chmod(filepath.Join(bin, "svn"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	defer os.Setenv("PATH", path)
+
+	difr := NewSVNDiffer(SetSVNWorkingDir(dir), SetSVNRevisions("3", "5"))
+	got, err := difr.DiffFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(dir, "foo.go"):        false,
+		filepath.Join(dir, "sub", "bar.go"): false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	br, ok := difr.(BaseReader)
+	if !ok {
+		t.Fatal("expected the svn differ to be a BaseReader")
+	}
+
+	b, err := br.ReadBase(filepath.Join(dir, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "package foo\n"; got != want {
+		t.Errorf("ReadBase(foo.go) = %q; want %q", got, want)
+	}
+
+	if _, err := br.ReadBase(filepath.Join(dir, "sub", "bar.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadBase(sub/bar.go) error = %v; want %v", err, os.ErrNotExist)
+	}
+}
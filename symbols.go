@@ -0,0 +1,418 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SetSymbolImpact sets whether the changes to Go files are analyzed at the
+// level of identifiers: the changed files are parsed to determine which of
+// the exported identifiers of their packages changed, directly or through
+// the unexported identifiers they refer to, and only the dependents that
+// refer to those identifiers, and their own dependents, are affected. Changes
+// that only affect unexported identifiers that no exported identifier refers
+// to affect no dependents at all.
+//
+// The analysis needs the content of the files before the changes, and falls
+// back to affecting every dependent when it is not available, or when the
+// changes affect the package as a whole, e.g. when files other than Go files,
+// init functions, build constraints, or imports for side effects change.
+// Dependents that are only affected through interfaces, reflection, or state
+// that the exported identifiers do not refer to are missed, which is the
+// price of the precision.
+func SetSymbolImpact(enabled bool) Option {
+	return func(g *GTA) error {
+		g.symbolImpact = enabled
+		return nil
+	}
+}
+
+// preciseDependents returns the direct dependents in graph of each changed
+// package in mapping whose changes can be analyzed at the level of
+// identifiers that refer to the changed exported identifiers. Only the
+// dependents of the changed packages are affected by the changes through
+// these dependents. It returns nil when g does not analyze the impact of
+// changes at the level of identifiers.
+func (g *GTA) preciseDependents(graph *Graph, mapping *Mapping) (map[string][]string, error) {
+	if !g.symbolImpact || g.direction == DependenciesDirection {
+		return nil, nil
+	}
+
+	changes := make(map[string]map[string]struct{})
+	for change, deleted := range mapping.Changed {
+		if deleted {
+			continue
+		}
+		symbols, ok, err := g.changedSymbols(mapping.Files[change])
+		if err != nil {
+			return nil, fmt.Errorf("analyzing changes to %s, %v", change, err)
+		}
+		if ok {
+			changes[change] = symbols
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	// only the direct dependents of the changed packages can refer to the
+	// changed identifiers, so only they are type checked.
+	seen := make(map[string]struct{})
+	var patterns []string
+	for change, symbols := range changes {
+		if len(symbols) == 0 {
+			continue
+		}
+		for dependent := range graph.graph[change] {
+			if _, ok := seen[dependent]; ok || dependent == change {
+				continue
+			}
+			seen[dependent] = struct{}{}
+			patterns = append(patterns, dependent)
+		}
+	}
+	sort.Strings(patterns)
+
+	var infos map[string]*types.Info
+	if len(patterns) > 0 {
+		infos = g.loadedTypesInfo(patterns)
+		if infos == nil {
+			var err error
+			if infos, err = typeCheck(g.loader, g.loadConfig(), patterns); err != nil {
+				return nil, fmt.Errorf("type checking dependents, %v", err)
+			}
+		}
+	}
+
+	precise := make(map[string][]string, len(changes))
+	for change, symbols := range changes {
+		dependents := []string{}
+		if len(symbols) > 0 {
+			for dependent := range graph.graph[change] {
+				if dependent == change {
+					continue
+				}
+
+				// the dependents that could not be type checked are affected.
+				info, ok := infos[dependent]
+				if !ok || referencesChangedAPI(info, map[string]map[string]struct{}{change: symbols}) {
+					dependents = append(dependents, dependent)
+				}
+			}
+		}
+		sort.Strings(dependents)
+		precise[change] = dependents
+	}
+	return precise, nil
+}
+
+// changedSymbols returns the exported identifiers of the package of the
+// changed files that were changed, directly or through the identifiers of the
+// package that they refer to. Methods are identified as "T.M" where T is the
+// name of the receiver's type, and a change to a method changes its type,
+// too. It returns false when the changes cannot be analyzed at the level of
+// identifiers, and so affect the package as a whole.
+func (g *GTA) changedSymbols(files []string) (map[string]struct{}, bool, error) {
+	if len(files) == 0 {
+		return nil, false, nil
+	}
+
+	dir := filepath.Dir(files[0])
+	changed := make(map[string]struct{})
+	for _, fn := range files {
+		if filepath.Dir(fn) != dir || filepath.Ext(fn) != ".go" {
+			return nil, false, nil
+		}
+		// test files do not affect the dependents.
+		if !strings.HasSuffix(fn, "_test.go") {
+			changed[filepath.Base(fn)] = struct{}{}
+		}
+	}
+
+	before := make(map[string]string)
+	after := make(map[string]string)
+	refs := make(map[string]map[string]struct{})
+
+	// the unchanged files declare the same identifiers before and after the
+	// changes.
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+	afterHeaders := make(map[string]string)
+	for _, fi := range infos {
+		if fi.IsDir() || !isAPIFile(fi.Name()) {
+			continue
+		}
+
+		fn := filepath.Join(dir, fi.Name())
+		src, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, false, err
+		}
+		decls, ok := parseDecls(fn, src)
+		if !ok {
+			return nil, false, nil
+		}
+
+		mergeAPI(after, decls.text)
+		for key, names := range decls.refs {
+			refs[key] = names
+		}
+		if _, ok := changed[fi.Name()]; ok {
+			afterHeaders[fi.Name()] = decls.header
+			continue
+		}
+		mergeAPI(before, decls.text)
+	}
+
+	for base := range changed {
+		fn := filepath.Join(dir, base)
+		src, err := g.readBase(fn)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			src = nil
+		case err == errNoBaseReader:
+			return nil, false, nil
+		case err != nil:
+			return nil, false, err
+		}
+
+		decls, ok := parseDecls(fn, src)
+		if !ok {
+			return nil, false, nil
+		}
+		// the header of a file that was added or removed is compared with the
+		// empty header of the missing file.
+		if decls.header != afterHeaders[base] {
+			return nil, false, nil
+		}
+		mergeAPI(before, decls.text)
+	}
+
+	// the identifiers whose declarations changed, and those that refer to
+	// them, transitively, are changed.
+	changedKeys := make(map[string]struct{})
+	for key, text := range before {
+		if after[key] != text {
+			changedKeys[key] = struct{}{}
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			changedKeys[key] = struct{}{}
+		}
+	}
+	for {
+		names := make(map[string]struct{})
+		for key := range changedKeys {
+			if isPackageWide(key) {
+				return nil, false, nil
+			}
+			recv, name := splitSymbol(key)
+			names[name] = struct{}{}
+			if recv != "" {
+				names[recv] = struct{}{}
+			}
+		}
+
+		n := len(changedKeys)
+		for key, referenced := range refs {
+			if _, ok := changedKeys[key]; ok {
+				continue
+			}
+			for ref := range referenced {
+				if _, ok := names[ref]; ok {
+					changedKeys[key] = struct{}{}
+					break
+				}
+			}
+		}
+		if len(changedKeys) == n {
+			break
+		}
+	}
+
+	symbols := make(map[string]struct{})
+	for key := range changedKeys {
+		recv, name := splitSymbol(key)
+		if !ast.IsExported(name) {
+			continue
+		}
+		if recv == "" {
+			symbols[name] = struct{}{}
+			continue
+		}
+		if ast.IsExported(recv) {
+			symbols[key] = struct{}{}
+			symbols[recv] = struct{}{}
+		}
+	}
+	return symbols, true, nil
+}
+
+// fileDecls are the declarations of a Go file.
+type fileDecls struct {
+	// header is a normalized form of the parts of the file that affect its
+	// package as a whole: the directives, such as build constraints, before
+	// the package clause, and the imports for side effects.
+	header string
+	// text maps the key of each declared identifier to a normalized form of
+	// its declaration.
+	text map[string]string
+	// refs are the names that the declaration of each identifier refers to.
+	refs map[string]map[string]struct{}
+}
+
+// parseDecls parses the declarations of the Go file fn with the source src.
+// The keys of the identifiers are like the names of changedSymbols, except
+// that the init functions and blank identifiers, which affect the package as
+// a whole, are keyed by the file and their position in it. It returns false
+// when src cannot be parsed or uses cgo.
+func parseDecls(fn string, src []byte) (*fileDecls, bool) {
+	decls := &fileDecls{
+		text: make(map[string]string),
+		refs: make(map[string]map[string]struct{}),
+	}
+	if src == nil {
+		return decls, true
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fn, src, parser.ParseComments)
+	if err != nil {
+		return nil, false
+	}
+
+	// text returns the normalized form of the source from pos to end.
+	text := func(pos, end token.Pos) (string, bool) {
+		// the imports of the file are checked for cgo separately.
+		tokens, ok := scanCodeTokens(src[fset.Position(pos).Offset:fset.Position(end).Offset], false)
+		if !ok {
+			return "", false
+		}
+		var b strings.Builder
+		for _, t := range tokens {
+			b.WriteString(t.tok.String())
+			b.WriteString(t.lit)
+			b.WriteByte(' ')
+		}
+		return b.String(), true
+	}
+
+	var header []string
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if isDirective(c.Text) {
+				header = append(header, c.Text)
+			}
+		}
+	}
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"C"` {
+			return nil, false
+		}
+		if imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".") {
+			header = append(header, imp.Name.Name+" "+imp.Path.Value)
+		}
+	}
+	sort.Strings(header)
+	decls.header = strings.Join(header, "\n")
+
+	base := filepath.Base(fn)
+	add := func(key string, node ast.Node, start token.Pos) bool {
+		t, ok := text(start, node.End())
+		if !ok {
+			return false
+		}
+		decls.text[key] = t
+
+		names := make(map[string]struct{})
+		ast.Inspect(node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				names[id.Name] = struct{}{}
+			}
+			return true
+		})
+		decls.refs[key] = names
+		return true
+	}
+
+	for i, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			key := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				key = receiverName(decl.Recv.List[0].Type) + "." + key
+			} else if key == "init" {
+				key = fmt.Sprintf("init@%s#%d", base, i)
+			}
+			start := decl.Pos()
+			if decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+			if !add(key, decl, start) {
+				return nil, false
+			}
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				continue
+			}
+			for j, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !add(spec.Name.Name, spec, spec.Pos()) {
+						return nil, false
+					}
+				case *ast.ValueSpec:
+					// the values of constants may depend on the other specs of
+					// their declaration through iota, so each name is keyed to
+					// the whole declaration.
+					for _, name := range spec.Names {
+						key := name.Name
+						if key == "_" {
+							key = fmt.Sprintf("_@%s#%d.%d", base, i, j)
+						}
+						if !add(key, decl, decl.Pos()) {
+							return nil, false
+						}
+					}
+				}
+			}
+		}
+	}
+	return decls, true
+}
+
+// isPackageWide reports whether a change to the identifier with the key, an
+// init function or a blank identifier, affects its package as a whole.
+func isPackageWide(key string) bool {
+	return strings.HasPrefix(key, "init@") || strings.HasPrefix(key, "_@")
+}
+
+// splitSymbol splits the key of a method into the name of its receiver's type
+// and its name. The receiver of other identifiers is empty.
+func splitSymbol(key string) (recv, name string) {
+	if i := strings.Index(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestChangedSymbols(t *testing.T) {
+	tests := []struct {
+		desc   string
+		before map[string]string
+		after  map[string]string
+		want   []string
+		wantOK bool
+	}{
+		{
+			desc:   "unreferenced helper",
+			before: map[string]string{"a.go": "package a\n\nfunc F() {}\n\nfunc helper() int { return 1 }\n"},
+			after:  map[string]string{"a.go": "package a\n\nfunc F() {}\n\nfunc helper() int { return 2 }\n"},
+			wantOK: true,
+		},
+		{
+			desc: "referenced helper",
+			before: map[string]string{
+				"a.go": "package a\n\nfunc F() int { return helper() }\n\nfunc G() {}\n",
+				"h.go": "package a\n\nfunc helper() int { return 1 }\n",
+			},
+			after: map[string]string{
+				"a.go": "package a\n\nfunc F() int { return helper() }\n\nfunc G() {}\n",
+				"h.go": "package a\n\nfunc helper() int { return 2 }\n",
+			},
+			want:   []string{"F"},
+			wantOK: true,
+		},
+		{
+			desc:   "method",
+			before: map[string]string{"a.go": "package a\n\ntype T struct{}\n\nfunc New() T { return T{} }\n\nfunc (T) M() int { return 1 }\n\nfunc G() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\ntype T struct{}\n\nfunc New() T { return T{} }\n\nfunc (T) M() int { return 2 }\n\nfunc G() {}\n"},
+			want:   []string{"New", "T", "T.M"},
+			wantOK: true,
+		},
+		{
+			desc:   "comments and formatting",
+			before: map[string]string{"a.go": "package a\n\n// F does nothing.\nfunc F() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\n// F does nothing at all.\nfunc F() {\n}\n"},
+			wantOK: true,
+		},
+		{
+			desc:   "iota",
+			before: map[string]string{"a.go": "package a\n\nconst (\n\tA = iota\n\tB\n)\n\nconst C = 1\n"},
+			after:  map[string]string{"a.go": "package a\n\nconst (\n\tZ = iota\n\tA\n\tB\n)\n\nconst C = 1\n"},
+			want:   []string{"A", "B", "Z"},
+			wantOK: true,
+		},
+		{
+			desc:   "added and removed files",
+			before: map[string]string{"r.go": "package a\n\nfunc R() {}\n"},
+			after:  map[string]string{"n.go": "package a\n\nfunc N() {}\n"},
+			want:   []string{"N", "R"},
+			wantOK: true,
+		},
+		{
+			desc:   "test file",
+			before: map[string]string{"a_test.go": "package a\n\nfunc TestF() {}\n"},
+			after:  map[string]string{"a_test.go": "package a\n\nfunc TestF() { panic(1) }\n"},
+			wantOK: true,
+		},
+		{
+			desc:   "init function",
+			before: map[string]string{"a.go": "package a\n\nfunc init() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\nfunc init() { println() }\n"},
+		},
+		{
+			desc:   "blank identifier",
+			before: map[string]string{"a.go": "package a\n\nvar _ = 1\n"},
+			after:  map[string]string{"a.go": "package a\n\nvar _ = 2\n"},
+		},
+		{
+			desc:   "import for side effects",
+			before: map[string]string{"a.go": "package a\n\nfunc F() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\nimport _ \"embed\"\n\nfunc F() {}\n"},
+		},
+		{
+			desc:   "build constraint",
+			before: map[string]string{"a.go": "package a\n\nfunc F() {}\n"},
+			after:  map[string]string{"a.go": "//go:build linux\n\npackage a\n\nfunc F() {}\n"},
+		},
+		{
+			desc:   "cgo",
+			before: map[string]string{"a.go": "package a\n\nimport \"C\"\n\nfunc F() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\nimport \"C\"\n\nfunc F() { println() }\n"},
+		},
+		{
+			desc:   "string C",
+			before: map[string]string{"a.go": "package a\n\nconst C = \"C\"\n\nfunc helper() {}\n"},
+			after:  map[string]string{"a.go": "package a\n\nconst C = \"C\"\n\nfunc helper() { println() }\n"},
+			wantOK: true,
+		},
+		{
+			desc:   "not a Go file",
+			before: map[string]string{"a.txt": "a"},
+			after:  map[string]string{"a.txt": "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			difr := &testBaseDiffer{base: make(map[string][]byte)}
+			changed := make(map[string]struct{})
+			for fn, src := range tt.before {
+				difr.base[filepath.Join(dir, fn)] = []byte(src)
+				if tt.after[fn] != src {
+					changed[fn] = struct{}{}
+				}
+			}
+			for fn, src := range tt.after {
+				writeFile(t, dir, fn, src)
+				if tt.before[fn] != src {
+					changed[fn] = struct{}{}
+				}
+			}
+			var files []string
+			for fn := range changed {
+				files = append(files, filepath.Join(dir, fn))
+			}
+			sort.Strings(files)
+
+			g := &GTA{differs: []labeledDiffer{{differ: difr}}}
+			symbols, ok, err := g.changedSymbols(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %t; want %t", ok, tt.wantOK)
+			}
+
+			var got []string
+			for name := range symbols {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChangedSymbols_NoBaseReader(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.go", "package a\n\nfunc helper() {}\n")
+
+	g := &GTA{differs: []labeledDiffer{{differ: &testDiffer{}}}}
+	if _, ok, err := g.changedSymbols([]string{filepath.Join(dir, "a.go")}); err != nil || ok {
+		t.Errorf("changedSymbols() = %t, %v; want false, nil", ok, err)
+	}
+}
+
+func TestGTA_SymbolImpact(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "go.mod", "module example.com/m\n")
+	for _, d := range []string{"a", "b", "c", "d"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "a/a.go", "package a\n\nfunc F() int { return helper() }\n\nfunc G() int { return 1 }\n\nfunc helper() int { return 2 }\n")
+	// the dependents use constants of the standard library, whose type
+	// checking needs the sizes of types.
+	writeFile(t, dir, "b/b.go", "package b\n\nimport (\n\t\"math\"\n\n\t\"example.com/m/a\"\n)\n\nvar B = a.F() + math.MaxInt8\n")
+	writeFile(t, dir, "c/c.go", "package c\n\nimport (\n\t\"strconv\"\n\n\t\"example.com/m/a\"\n)\n\nvar C = a.G() + strconv.IntSize\n")
+	writeFile(t, dir, "d/d.go", "package d\n\nimport \"example.com/m/b\"\n\nvar D = b.B\n")
+
+	mod := &packages.Module{Path: "example.com/m", Dir: dir, Main: true}
+	pkg := func(name string, imports ...*packages.Package) *packages.Package {
+		p := &packages.Package{
+			ID:      "example.com/m/" + name,
+			Name:    name,
+			PkgPath: "example.com/m/" + name,
+			GoFiles: []string{filepath.Join(dir, name, name+".go")},
+			Imports: make(map[string]*packages.Package),
+			Module:  mod,
+		}
+		for _, imp := range imports {
+			p.Imports[imp.PkgPath] = imp
+		}
+		return p
+	}
+	a := pkg("a")
+	b := pkg("b", a)
+	c := pkg("c", a)
+	d := pkg("d", b)
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		// the dependents are type checked by go/packages.
+		if cfg.Mode&packages.NeedTypesInfo != 0 {
+			return packages.Load(cfg, patterns...)
+		}
+		return []*packages.Package{a, b, c, d}, nil
+	})
+
+	tests := []struct {
+		desc   string
+		before string
+		want   []string
+	}{
+		{
+			desc:   "referenced helper",
+			before: "package a\n\nfunc F() int { return helper() }\n\nfunc G() int { return 1 }\n\nfunc helper() int { return 1 }\n",
+			want:   []string{"example.com/m/a", "example.com/m/b", "example.com/m/d"},
+		},
+		{
+			desc:   "unexported only",
+			before: "package a\n\nfunc F() int { return helper() }\n\nfunc G() int { return 1 }\n\nfunc helper() int { return 2 }\n\nfunc unused() {}\n",
+			want:   []string{"example.com/m/a"},
+		},
+		{
+			desc:   "package wide",
+			before: "package a\n\nfunc init() {}\n\nfunc F() int { return helper() }\n\nfunc G() int { return 1 }\n\nfunc helper() int { return 2 }\n",
+			want:   []string{"example.com/m/a", "example.com/m/b", "example.com/m/c", "example.com/m/d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			difr := &testBaseDiffer{
+				testDiffer: testDiffer{
+					diff: map[string]Directory{
+						filepath.Join(dir, "a"): {Exists: true, Files: []string{"a.go"}},
+					},
+				},
+				base: map[string][]byte{filepath.Join(dir, "a", "a.go"): []byte(tt.before)},
+			}
+
+			gt, err := New(SetModuleDir(dir), SetLoader(loader), SetDiffer(difr), SetSymbolImpact(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, stringify(cp.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
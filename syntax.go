@@ -0,0 +1,96 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// SyntaxLoadMode is the part of the mode in which the default packager loads
+// packages that SetLoadSyntax adds: the syntax trees and the type information
+// of the packages.
+const SyntaxLoadMode = packages.NeedSyntax |
+	packages.NeedTypes |
+	packages.NeedTypesInfo |
+	packages.NeedTypesSizes
+
+// SetLoadSyntax sets whether the default packager loads the syntax trees and
+// type information of the packages along with the dependency graph, so that
+// analyses of the changes, e.g. of the exported API, can use the packages
+// returned by LoadedPackages instead of loading them a second time. Loading
+// them uses much more time and memory, and the graph cache is not used.
+func SetLoadSyntax(enabled bool) Option {
+	return func(g *GTA) error {
+		g.loadSyntax = enabled
+		return nil
+	}
+}
+
+// LoadedPackages returns the packages that match the patterns that the
+// default packager loaded, including their test variants, with their syntax
+// trees and type information when the GTA loads them with SetLoadSyntax.
+func (g *GTA) LoadedPackages() ([]*packages.Package, error) {
+	p, ok := g.packager.(*packageContext)
+	if !ok {
+		return nil, errors.New("only the packages of the default packager can be returned")
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.loaded == nil && p.files != nil {
+		return nil, errors.New("the packages were read from a dependency graph instead of loaded")
+	}
+	return p.loaded, nil
+}
+
+// loadedTypesInfo returns the type information of the packages with the
+// import paths, keyed by their import paths, from the packages that g loaded
+// with their syntax trees, merging the information of each package's
+// variants. It returns nil when g did not load the type information of all of
+// the packages.
+func (g *GTA) loadedTypesInfo(importPaths []string) map[string]*types.Info {
+	if !g.loadSyntax {
+		return nil
+	}
+	loaded, err := g.LoadedPackages()
+	if err != nil {
+		return nil
+	}
+
+	want := make(map[string]struct{}, len(importPaths))
+	for _, importPath := range importPaths {
+		want[importPath] = struct{}{}
+	}
+
+	infos := make(map[string]*types.Info)
+	seen := make(map[*packages.Package]struct{})
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		if _, ok := seen[pkg]; ok {
+			return
+		}
+		seen[pkg] = struct{}{}
+		for _, imp := range pkg.Imports {
+			visit(imp)
+		}
+
+		if _, ok := want[normalizeImportPath(pkg)]; ok {
+			mergeTypesInfo(infos, pkg)
+		}
+	}
+	for _, pkg := range loaded {
+		visit(pkg)
+	}
+
+	if len(infos) != len(want) {
+		return nil
+	}
+	return infos
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSetLoadSyntax(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Setenv(t, "GOWORK", "off")()
+	defer Setenv(t, "GOFLAGS", "-mod=mod")()
+	writeFile(t, dir, "go.mod", "module example.com/m\n\ngo 1.15\n")
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "a/a.go", "package a\n\nfunc F() {}\n\nfunc G() {}\n")
+	// b uses constants of the standard library, whose type checking needs the
+	// sizes of types.
+	writeFile(t, dir, "b/b.go", "package b\n\nimport (\n\t\"math\"\n\n\t\"example.com/m/a\"\n)\n\nvar N int = math.MaxInt8\n\nfunc B() { a.F() }\n")
+
+	var loads int
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		loads++
+		if cfg.Mode&SyntaxLoadMode != SyntaxLoadMode {
+			t.Errorf("mode %v does not include %v", cfg.Mode, SyntaxLoadMode)
+		}
+		return packages.Load(cfg, patterns...)
+	})
+
+	gt, err := New(SetDir(dir), SetPrefixes("example.com/m/"), SetLoader(loader), SetDiffer(&testDiffer{}), SetLoadSyntax(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := gt.LoadedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var checked int
+	for _, pkg := range loaded {
+		if len(pkg.Syntax) > 0 && pkg.TypesInfo != nil {
+			checked++
+		}
+	}
+	if checked != len(loaded) || checked == 0 {
+		t.Errorf("%d of %d loaded packages have syntax trees and type information", checked, len(loaded))
+	}
+
+	// the type information of the dependents is reused rather than loaded
+	// again.
+	infos := gt.loadedTypesInfo([]string{"example.com/m/b"})
+	if infos == nil {
+		t.Fatal("no type information of example.com/m/b was loaded")
+	}
+	if !referencesChangedAPI(infos["example.com/m/b"], map[string]map[string]struct{}{"example.com/m/a": {"F": {}}}) {
+		t.Error("example.com/m/b does not reference example.com/m/a.F")
+	}
+	if referencesChangedAPI(infos["example.com/m/b"], map[string]map[string]struct{}{"example.com/m/a": {"G": {}}}) {
+		t.Error("example.com/m/b references example.com/m/a.G")
+	}
+	if loads != 1 {
+		t.Errorf("packages were loaded %d times; want 1", loads)
+	}
+}
+
+func TestGTA_LoadedTypesInfoDefault(t *testing.T) {
+	gt, err := New(SetDiffer(&testDiffer{}), SetLoader(LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		if cfg.Mode&packages.NeedSyntax != 0 {
+			t.Errorf("mode %v includes syntax by default", cfg.Mode)
+		}
+		return nil, nil
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if infos := gt.loadedTypesInfo([]string{"example.com/m/b"}); infos != nil {
+		t.Errorf("loadedTypesInfo() = %v; want nil", infos)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A TagSet is a named configuration of build tags, such as one entry of a CI
+// build matrix.
+type TagSet struct {
+	// Name identifies the configuration in the report.
+	Name string
+
+	// Tags are the build tags of the configuration.
+	Tags []string
+
+	// GOOS and GOARCH are the target platform of the configuration. When
+	// they are empty, those of the environment are used. Go and assembly
+	// files are only attributed to the configurations of the platforms for
+	// which they are built.
+	GOOS   string
+	GOARCH string
+}
+
+// tagSetPackages returns a map of the packages that are affected by the
+// changes under any of g's tag sets to the sorted names of the tag sets under
+// which they are affected. The packages are loaded with g's Loader once for
+// each tag set.
+func (g *GTA) tagSetPackages() (map[string][]string, error) {
+	affected := make(map[string][]string)
+	for _, set := range g.tagSets {
+		pkgs, err := g.configPackages(set.Tags, set.GOOS, set.GOARCH)
+		if err != nil {
+			return nil, fmt.Errorf("tag set %q, %v", set.Name, err)
+		}
+		for _, importPath := range pkgs {
+			affected[importPath] = append(affected[importPath], set.Name)
+		}
+	}
+
+	for _, names := range affected {
+		sort.Strings(names)
+	}
+	return affected, nil
+}
+
+// configPackages returns the sorted packages within g's prefixes that are
+// affected by the changes when the packages are loaded with g's Loader for
+// the build tags tags and the platform goos/goarch. An empty goos or goarch is
+// that of the environment.
+func (g *GTA) configPackages(tags []string, goos, goarch string) ([]string, error) {
+	sub := *g
+	sub.tags = tags
+	sub.tagSets = nil
+	sub.platforms = nil
+	sub.goos, sub.goarch = goos, goarch
+	sub.referrers = nil
+
+	sub.packager = loadPackager(g.loader, sub.loadConfig(), sub.buildContext(tags), g.patterns, g.parallelism)
+
+	m, err := sub.markedPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	for _, marked := range m.paths {
+		for importPath := range marked {
+			if g.included(importPath) {
+				seen[importPath] = struct{}{}
+			}
+		}
+	}
+	return sortedSet(seen), nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestGTA_TagSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gta-tagsets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, dir, name+"/"+name+".go", "package "+name+"\n")
+	}
+
+	// b depends on a
+	// c depends on a only when the linux tag is set
+	module := &packages.Module{Path: "example.com", Dir: dir, Main: true}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		a := &packages.Package{
+			ID:      "example.com/a",
+			PkgPath: "example.com/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Module:  module,
+		}
+		b := &packages.Package{
+			ID:      "example.com/b",
+			PkgPath: "example.com/b",
+			GoFiles: []string{filepath.Join(dir, "b", "b.go")},
+			Imports: map[string]*packages.Package{"example.com/a": a},
+			Module:  module,
+		}
+		c := &packages.Package{
+			ID:      "example.com/c",
+			PkgPath: "example.com/c",
+			GoFiles: []string{filepath.Join(dir, "c", "c.go")},
+			Imports: map[string]*packages.Package{},
+			Module:  module,
+		}
+		for _, flag := range cfg.BuildFlags {
+			if strings.HasPrefix(flag, "-tags=") && strings.Contains(flag, "linux") {
+				c.Imports["example.com/a"] = a
+			}
+		}
+		return []*packages.Package{a, b, c}, nil
+	})
+
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(dir, "a"): Directory{Exists: true, Files: []string{"a.go"}},
+		},
+	}
+
+	sets := []TagSet{
+		{Name: "default"},
+		{Name: "linux", Tags: []string{"linux"}},
+	}
+	gta, err := New(SetDiffer(difr), SetLoader(loader), SetTagSets(sets...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"example.com/a": []string{"default", "linux"},
+		"example.com/b": []string{"default", "linux"},
+		"example.com/c": []string{"linux"},
+	}
+	if diff := cmp.Diff(want, pkgs.TagSets); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestSetTagSets_Duplicate(t *testing.T) {
+	_, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetTagSets(TagSet{Name: "x"}, TagSet{Name: "x"}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGTA_TagSets_Assembly(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "a/a.go", "package a\n\nfunc add(x, y int) int\n")
+	writeFile(t, dir, "a/add_amd64.s", "TEXT ·add(SB),4,$0\n")
+	writeFile(t, dir, "a/add_arm64.s", "TEXT ·add(SB),4,$0\n")
+	writeFile(t, dir, "a/add_linux.s", "//go:build arm64\n\nTEXT ·add(SB),4,$0\n")
+
+	module := &packages.Module{Path: "example.com", Dir: dir, Main: true}
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		return []*packages.Package{{
+			ID:      "example.com/a",
+			PkgPath: "example.com/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Module:  module,
+		}}, nil
+	})
+
+	sets := []TagSet{
+		{Name: "linux-amd64", GOOS: "linux", GOARCH: "amd64"},
+		{Name: "linux-arm64", GOOS: "linux", GOARCH: "arm64"},
+		{Name: "darwin-arm64", GOOS: "darwin", GOARCH: "arm64"},
+	}
+
+	tests := []struct {
+		files []string
+		want  map[string][]string
+	}{
+		{
+			files: []string{"add_amd64.s"},
+			want:  map[string][]string{"example.com/a": {"linux-amd64"}},
+		},
+		{
+			files: []string{"add_linux.s"},
+			want:  map[string][]string{"example.com/a": {"linux-arm64"}},
+		},
+		{
+			// deleted files are attributed by their names.
+			files: []string{"add_darwin_arm64.s"},
+			want:  map[string][]string{"example.com/a": {"darwin-arm64"}},
+		},
+		{
+			files: []string{"a.go", "add_arm64.s"},
+			want:  map[string][]string{"example.com/a": {"darwin-arm64", "linux-amd64", "linux-arm64"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.files, ","), func(t *testing.T) {
+			difr := &testDiffer{
+				diff: map[string]Directory{
+					filepath.Join(dir, "a"): {Exists: true, Files: tt.files},
+				},
+			}
+			gt, err := New(SetDir(dir), SetDiffer(difr), SetLoader(loader), SetTagSets(sets...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// without a platform, every change marks the package.
+			if diff := cmp.Diff([]string{"example.com/a"}, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("all changes: (-want, +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.want, pkgs.TagSets); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
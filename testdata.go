@@ -0,0 +1,82 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// TestdataMode describes how changes to the files within testdata
+// directories, which the go tool ignores, affect the packages that own the
+// directories.
+type TestdataMode string
+
+const (
+	// TestdataTests marks the package that owns a testdata directory, whose
+	// tests read its files, but not the package's dependents. It is the
+	// default.
+	TestdataTests TestdataMode = "tests"
+
+	// TestdataDependents marks the package that owns a testdata directory and
+	// its dependents, as changes to the package's files do.
+	TestdataDependents TestdataMode = "dependents"
+
+	// TestdataIgnore ignores changes to the files within testdata
+	// directories.
+	TestdataIgnore TestdataMode = "ignore"
+)
+
+// SetTestdataMode sets how changes to the files within testdata directories
+// affect the packages that own them.
+func SetTestdataMode(mode TestdataMode) Option {
+	return func(g *GTA) error {
+		switch mode {
+		case TestdataTests, TestdataDependents, TestdataIgnore:
+		default:
+			return fmt.Errorf("unknown testdata mode %q", mode)
+		}
+		g.testdataMode = mode
+		return nil
+	}
+}
+
+// testdataOwner returns the directory of the package that owns the testdata
+// directory that contains the directory dir, which is the parent of the
+// nearest testdata directory below root. The second value returned is false
+// when dir is not within a testdata directory.
+func testdataOwner(dir, root string) (string, bool) {
+	for ; dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "testdata" {
+			return filepath.Dir(dir), true
+		}
+	}
+	return "", false
+}
+
+// testdataPackage returns the import path of the package that owns the
+// testdata directory that contains the directory abs. Only the testdata
+// directories within g's directory are considered when abs is within it, so
+// that a repository checked out within a testdata directory is not mistaken
+// for test data. The second value returned is false when abs is not within a
+// testdata directory. The import path is empty when no package owns the
+// testdata directory.
+func (g *GTA) testdataPackage(abs string) (string, bool) {
+	var root string
+	if wd, err := g.workingDir(); err == nil {
+		root, _ = filepath.Abs(wd)
+	}
+	owner, ok := testdataOwner(abs, root)
+	if !ok {
+		return "", false
+	}
+	pkg, err := g.packager.PackageFromDir(owner)
+	if err != nil {
+		return "", true
+	}
+	return pkg.ImportPath, true
+}
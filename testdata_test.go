@@ -0,0 +1,93 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTestdataOwner(t *testing.T) {
+	tests := []struct {
+		dir    string
+		root   string
+		want   string
+		wantOK bool
+	}{
+		{dir: "/repo/foo/testdata", root: "/repo", want: "/repo/foo", wantOK: true},
+		{dir: "/repo/foo/testdata/golden/v2", root: "/repo", want: "/repo/foo", wantOK: true},
+		{dir: "/repo/foo/testdata/x/testdata", root: "/repo", want: "/repo/foo/testdata/x", wantOK: true},
+		{dir: "/repo/foo", root: "/repo"},
+		{dir: "/testdata/repo/foo", root: "/testdata/repo"},
+		{dir: "/testdata/repo/foo", root: "", want: "/", wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := testdataOwner(tt.dir, tt.root)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("testdataOwner(%q, %q) = %q, %v; want %q, %v", tt.dir, tt.root, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestGTA_TestdataMode(t *testing.T) {
+	// A depends on B
+	// B's tests read the files of its testdata directory
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"/repo/b/testdata/golden": Directory{Exists: true, Files: []string{"out.json"}},
+		},
+	}
+
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"B": map[string]bool{
+				"A": true,
+			},
+		},
+	}
+
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"/repo/a": "A",
+			"/repo/b": "B",
+		},
+		graph: graph,
+	}
+
+	tests := []struct {
+		mode TestdataMode
+		want []string
+	}{
+		{mode: TestdataTests, want: []string{"B"}},
+		{mode: TestdataDependents, want: []string{"A", "B"}},
+		{mode: TestdataIgnore},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			gt, err := New(SetDir("/repo"), SetDiffer(difr), SetPackager(pkgr), SetTestdataMode(tt.mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.want, stringify(got.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetTestdataMode_Unknown(t *testing.T) {
+	if _, err := New(SetDiffer(&testDiffer{}), SetPackager(&testPackager{}), SetTestdataMode("sometimes")); err == nil {
+		t.Error("expected an error for an unknown testdata mode")
+	}
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A VendorError describes how the vendor directory of a module is out of sync
+// with its go.mod file. Loading packages from such a module fails with less
+// helpful errors.
+type VendorError struct {
+	// Dir is the root directory of the module.
+	Dir string
+
+	// Problems describe each inconsistency.
+	Problems []string
+}
+
+// Error implements the error interface for VendorError.
+func (e *VendorError) Error() string {
+	return fmt.Sprintf("vendor/modules.txt in %s is out of sync with go.mod; run go mod vendor: %s", e.Dir, strings.Join(e.Problems, "; "))
+}
+
+// vendoredModule describes a module listed in vendor/modules.txt.
+type vendoredModule struct {
+	version     string
+	replacement string
+	explicit    bool
+}
+
+// checkVendor returns a *VendorError when packages are loaded from the vendor
+// directory of the module that contains dir and the vendor directory is out
+// of sync with the module's go.mod file. env is the environment of the go
+// command; when it is nil, the current process's environment is used. flags
+// are the build flags of the go command, which take precedence over GOFLAGS.
+func checkVendor(dir string, env, flags []string) error {
+	if env == nil {
+		env = os.Environ()
+	}
+	goflags, go111module := "", ""
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "GOFLAGS="):
+			goflags = strings.TrimPrefix(kv, "GOFLAGS=")
+		case strings.HasPrefix(kv, "GO111MODULE="):
+			go111module = strings.TrimPrefix(kv, "GO111MODULE=")
+		}
+	}
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, "-mod=") {
+			goflags = flag
+		}
+	}
+	if go111module == "off" {
+		return nil
+	}
+
+	root, ok := moduleRoot(dir)
+	if !ok {
+		return nil
+	}
+
+	modulesTxt, err := ioutil.ReadFile(filepath.Join(root, "vendor", "modules.txt"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mod, err := readGoMod(root, env)
+	if err != nil {
+		return err
+	}
+
+	// the vendor directory is only used by default from Go 1.14.
+	explicitVendor := strings.Contains(goflags, "-mod=vendor")
+	if !explicitVendor && (strings.Contains(goflags, "-mod=") || !atLeastGo114(mod.Go)) {
+		return nil
+	}
+
+	vendored, err := parseModulesTxt(modulesTxt)
+	if err != nil {
+		return err
+	}
+
+	replacements := make(map[string]string)
+	for _, r := range mod.Replace {
+		replacements[r.Old.Path] = strings.TrimSpace(r.New.Path + " " + r.New.Version)
+	}
+
+	var problems []string
+	required := make(map[string]struct{})
+	for _, r := range mod.Require {
+		required[r.Path] = struct{}{}
+
+		vm, ok := vendored[r.Path]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s@%s is required by go.mod but is not vendored", r.Path, r.Version))
+			continue
+		case vm.version != r.Version:
+			problems = append(problems, fmt.Sprintf("%s is required at %s by go.mod but %s is vendored", r.Path, r.Version, vm.version))
+		case atLeastGo114(mod.Go) && !vm.explicit:
+			problems = append(problems, fmt.Sprintf("%s@%s is required by go.mod but is not marked explicit in vendor/modules.txt", r.Path, r.Version))
+		}
+
+		if want := replacements[r.Path]; vm.replacement != want {
+			switch {
+			case want == "":
+				problems = append(problems, fmt.Sprintf("%s is replaced by %s in vendor/modules.txt but not in go.mod", r.Path, vm.replacement))
+			default:
+				problems = append(problems, fmt.Sprintf("%s is replaced by %s in go.mod but not in vendor/modules.txt", r.Path, want))
+			}
+		}
+	}
+
+	for path, vm := range vendored {
+		if _, ok := required[path]; !ok && vm.explicit {
+			problems = append(problems, fmt.Sprintf("%s@%s is marked explicit in vendor/modules.txt but is not required by go.mod", path, vm.version))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return &VendorError{Dir: root, Problems: problems}
+}
+
+// moduleRoot returns the root directory of the module that contains dir.
+func moduleRoot(dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// goMod is the subset of the output of go mod edit -json used by checkVendor.
+type goMod struct {
+	Go      string
+	Require []struct {
+		Path    string
+		Version string
+	}
+	Replace []struct {
+		Old struct {
+			Path    string
+			Version string
+		}
+		New struct {
+			Path    string
+			Version string
+		}
+	}
+}
+
+// readGoMod returns the content of the go.mod file in the directory root.
+func readGoMod(root string, env []string) (*goMod, error) {
+	cmd := exec.Command("go", "mod", "edit", "-json")
+	cmd.Dir = root
+	cmd.Env = env
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading go.mod: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	mod := new(goMod)
+	if err := json.Unmarshal(out, mod); err != nil {
+		return nil, fmt.Errorf("reading go.mod: %w", err)
+	}
+	return mod, nil
+}
+
+// parseModulesTxt returns the modules listed in the content of a
+// vendor/modules.txt file, keyed by their paths.
+func parseModulesTxt(b []byte) (map[string]*vendoredModule, error) {
+	modules := make(map[string]*vendoredModule)
+
+	var current *vendoredModule
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "## "):
+			if current == nil {
+				continue
+			}
+			for _, annotation := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+				if strings.TrimSpace(annotation) == "explicit" {
+					current.explicit = true
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			fields := strings.Fields(strings.TrimPrefix(line, "# "))
+			if len(fields) == 0 {
+				current = nil
+				continue
+			}
+
+			// a line is either "# path version", "# path [version] => new
+			// [version]", or "# path => new [version]" for a replacement of
+			// all versions.
+			vm := new(vendoredModule)
+			arrow := len(fields)
+			for i, f := range fields {
+				if f == "=>" {
+					arrow = i
+					vm.replacement = strings.Join(fields[i+1:], " ")
+					break
+				}
+			}
+			if arrow > 1 {
+				vm.version = fields[1]
+			}
+			modules[fields[0]] = vm
+			current = vm
+		}
+	}
+
+	return modules, scanner.Err()
+}
+
+// atLeastGo114 reports whether the go version v, as declared in a go.mod
+// file, is 1.14 or later.
+func atLeastGo114(v string) bool {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return major > 1 || (major == 1 && minor >= 14)
+}
+
+// vendorPackages returns the import paths of the packages of the repository
+// that depend on the vendored module that provides the file abs, when abs is
+// within the vendor directory of a module. A change to vendor/modules.txt
+// affects the dependents of the modules whose versions or replacements
+// changed.
+func (g *GTA) vendorPackages(abs string) ([]string, error) {
+	vendorDir, ok := vendorDirOf(abs)
+	if !ok {
+		return nil, nil
+	}
+	modulesTxt := filepath.Join(vendorDir, "modules.txt")
+
+	after, err := readModulesTxt(modulesTxt)
+	if err != nil {
+		return nil, err
+	}
+
+	// modules maps the vendored modules to whether the change affects them.
+	modules := make(map[string]bool, len(after))
+	if abs == modulesTxt {
+		b, err := g.readBase(abs)
+		switch {
+		case err == errNoBaseReader:
+			return nil, nil
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		}
+		before, err := parseModulesTxt(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s before the change: %v", abs, err)
+		}
+
+		for path, vm := range before {
+			now, ok := after[path]
+			modules[path] = !ok || now.version != vm.version || now.replacement != vm.replacement
+		}
+		for path := range after {
+			if _, ok := before[path]; !ok {
+				modules[path] = true
+			}
+		}
+	} else {
+		for path := range after {
+			modules[path] = false
+		}
+
+		rel, err := filepath.Rel(vendorDir, filepath.Dir(abs))
+		if err != nil {
+			return nil, err
+		}
+		module := moduleOf(filepath.ToSlash(rel), modules)
+		if module == "" {
+			return nil, nil
+		}
+		modules[module] = true
+	}
+
+	graph, err := g.packager.DependentGraph()
+	if err != nil {
+		return nil, err
+	}
+	return sortedSet(moduleDependents(graph, modules)), nil
+}
+
+// vendoredModules returns the modules that are vendored in the vendor
+// directory of the module that contains g's directory, mapped to true. It
+// returns an empty map when the module has no vendor directory.
+func (g *GTA) vendoredModules() (map[string]bool, error) {
+	if g.vendored != nil {
+		return g.vendored, nil
+	}
+
+	wd, err := g.workingDir()
+	if err != nil {
+		return nil, err
+	}
+
+	g.vendored = make(map[string]bool)
+	root, ok := moduleRoot(wd)
+	if !ok {
+		return g.vendored, nil
+	}
+	modules, err := readModulesTxt(filepath.Join(root, "vendor", "modules.txt"))
+	if err != nil {
+		return nil, err
+	}
+	for path := range modules {
+		g.vendored[path] = true
+	}
+	return g.vendored, nil
+}
+
+// vendorDirOf returns the vendor directory of the module that contains the
+// file abs within it, if there is one.
+func vendorDirOf(abs string) (string, bool) {
+	for dir := filepath.Dir(abs); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) != "vendor" {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(filepath.Dir(dir), "go.mod")); err == nil && !fi.IsDir() {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// readModulesTxt returns the modules listed in the vendor/modules.txt file
+// fn, keyed by their paths. A missing file lists no modules.
+func readModulesTxt(fn string) (map[string]*vendoredModule, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return parseModulesTxt(b)
+}
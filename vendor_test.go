@@ -0,0 +1,244 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"errors"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckVendor(t *testing.T) {
+	const goMod = `module example.com/m
+
+go 1.15
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0
+)
+
+replace example.com/b => ../b
+`
+
+	tests := []struct {
+		desc       string
+		modulesTxt string
+		goflags    string
+		flags      []string
+		want       []string
+	}{
+		{
+			desc: "in sync",
+			modulesTxt: `# example.com/a v1.0.0
+## explicit
+example.com/a
+# example.com/b v1.1.0 => ../b
+## explicit
+example.com/b
+`,
+		},
+		{
+			desc: "out of sync",
+			modulesTxt: `# example.com/a v0.9.0
+## explicit
+example.com/a
+# example.com/b v1.1.0
+example.com/b
+# example.com/c v1.0.0
+## explicit
+example.com/c
+`,
+			want: []string{
+				"example.com/a is required at v1.0.0 by go.mod but v0.9.0 is vendored",
+				"example.com/b is replaced by ../b in go.mod but not in vendor/modules.txt",
+				"example.com/b@v1.1.0 is required by go.mod but is not marked explicit in vendor/modules.txt",
+				"example.com/c@v1.0.0 is marked explicit in vendor/modules.txt but is not required by go.mod",
+			},
+		},
+		{
+			desc: "missing",
+			modulesTxt: `# example.com/b v1.1.0 => ../b
+## explicit
+example.com/b
+`,
+			want: []string{
+				"example.com/a@v1.0.0 is required by go.mod but is not vendored",
+			},
+		},
+		{
+			desc:       "vendor not used",
+			modulesTxt: "",
+			goflags:    "-mod=mod",
+		},
+		{
+			desc:       "vendor not used by build flags",
+			modulesTxt: "",
+			goflags:    "-mod=vendor",
+			flags:      []string{"-mod=mod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gta-vendor")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, dir, "go.mod", goMod)
+			writeFile(t, dir, "vendor/modules.txt", tt.modulesTxt)
+
+			env := append(os.Environ(), "GOFLAGS="+tt.goflags, "GO111MODULE=on")
+			err = checkVendor(dir, env, tt.flags)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *VendorError
+			if !errors.As(err, &verr) {
+				t.Fatalf("err = %v; want a *VendorError", err)
+			}
+			if verr.Dir != dir {
+				t.Errorf("Dir = %q; want %q", verr.Dir, dir)
+			}
+			if diff := cmp.Diff(tt.want, verr.Problems); diff != "" {
+				t.Errorf("Problems (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckVendor_NoVendor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gta-vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "go.mod", "module example.com/m\n\ngo 1.15\n\nrequire example.com/a v1.0.0\n")
+
+	if err := checkVendor(dir, nil, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGTA_Vendor(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "go.mod", "module example.com/app\n\ngo 1.16\n")
+	writeFile(t, dir, "vendor/modules.txt", `# example.com/dep v1.1.0
+## explicit
+example.com/dep
+example.com/dep/x
+# example.com/other v1.0.0
+## explicit
+example.com/other
+example.com/other/wrap
+`)
+
+	vendorDir := filepath.Join(dir, "vendor")
+	depDir := filepath.Join(vendorDir, "example.com", "dep")
+	depXDir := filepath.Join(depDir, "x")
+
+	// a imports dep/x, b imports dep through other/wrap, and c imports other.
+	newPackager := func() *testPackager {
+		return &testPackager{
+			dirs2Imports: map[string]string{
+				"dirA":  "example.com/app/a",
+				"dirB":  "example.com/app/b",
+				"dirC":  "example.com/app/c",
+				depXDir: "example.com/dep/x",
+			},
+			graph: &Graph{
+				graph: map[string]map[string]bool{
+					"example.com/dep/x":      {"example.com/app/a": true},
+					"example.com/dep":        {"example.com/other/wrap": true},
+					"example.com/other/wrap": {"example.com/app/b": true},
+					"example.com/other":      {"example.com/app/c": true},
+				},
+			},
+			errs: map[string]error{
+				vendorDir: &build.NoGoError{Dir: vendorDir},
+				depDir:    &build.NoGoError{Dir: depDir},
+			},
+		}
+	}
+
+	tests := []struct {
+		desc    string
+		diff    map[string]Directory
+		base    map[string][]byte
+		exclude bool
+		want    []string
+	}{
+		{
+			desc: "module file",
+			diff: map[string]Directory{
+				depDir: {Exists: true, Files: []string{"LICENSE"}},
+			},
+			want: []string{"example.com/app/a", "example.com/app/b"},
+		},
+		{
+			desc: "package",
+			diff: map[string]Directory{
+				depXDir: {Exists: true, Files: []string{"x.go"}},
+			},
+			want: []string{"example.com/app/a", "example.com/app/b", "example.com/dep/x"},
+		},
+		{
+			desc: "package excluded",
+			diff: map[string]Directory{
+				depXDir: {Exists: true, Files: []string{"x.go"}},
+			},
+			exclude: true,
+			want:    []string{"example.com/app/a", "example.com/app/b"},
+		},
+		{
+			desc: "modules.txt",
+			diff: map[string]Directory{
+				vendorDir: {Exists: true, Files: []string{"modules.txt"}},
+			},
+			base: map[string][]byte{
+				filepath.Join(vendorDir, "modules.txt"): []byte("# example.com/dep v1.1.0\n## explicit\n# example.com/other v1.0.0-old\n## explicit\n"),
+			},
+			want: []string{"example.com/app/b", "example.com/app/c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			difr := &testBaseDiffer{testDiffer: testDiffer{diff: tt.diff}, base: tt.base}
+			gt, err := New(SetDir(dir), SetDiffer(difr), SetPackager(newPackager()), SetExcludeVendored(tt.exclude))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pkgs, err := gt.ChangedPackages()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, stringify(pkgs.AllChanges)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Why returns the import chain that explains why changing the package changed
+// affects the package affected in a graph of dependents: a shortest path from
+// changed to affected, in which each package is imported by the next. It
+// returns an error when affected does not depend on changed.
+func (g *Graph) Why(changed, affected string) ([]string, error) {
+	chain := g.Path(changed, affected)
+	if chain == nil {
+		return nil, fmt.Errorf("%s does not depend on %s", affected, changed)
+	}
+	return chain, nil
+}
+
+// chains returns a shortest path to each node that can be reached from any of
+// sources, from the source nearest to it, keyed by the reached node. Sources
+// and adjacent nodes are visited in lexical order, so that the paths are
+// stable.
+func (g *Graph) chains(sources []string) map[string][]string {
+	sources = append([]string(nil), sources...)
+	sort.Strings(sources)
+
+	prev := make(map[string]string)
+	seen := make(map[string]bool)
+	queue := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if !seen[source] {
+			seen[source] = true
+			queue = append(queue, source)
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		edges := make([]string, 0, len(g.graph[current]))
+		for edge := range g.graph[current] {
+			edges = append(edges, edge)
+		}
+		sort.Strings(edges)
+		for _, edge := range edges {
+			if seen[edge] {
+				continue
+			}
+			seen[edge] = true
+			prev[edge] = current
+			queue = append(queue, edge)
+		}
+	}
+
+	chains := make(map[string][]string, len(prev))
+	for node := range prev {
+		var chain []string
+		n := node
+		for {
+			chain = append(chain, n)
+			p, ok := prev[n]
+			if !ok {
+				break
+			}
+			n = p
+		}
+		for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+			chain[i], chain[j] = chain[j], chain[i]
+		}
+		chains[node] = chain
+	}
+	return chains
+}
+
+// reasonChains returns the import chain from the nearest changed package in m
+// to each affected package in cp that was not changed itself. The chains
+// follow the graphs that were traversed in g's direction; when both
+// directions are traversed, the shorter chain is used.
+func (g *GTA) reasonChains(cp *Packages, m *marks) map[string][]string {
+	if m.graph == nil {
+		return nil
+	}
+
+	changed := make([]string, 0, len(m.paths))
+	for pkg := range m.paths {
+		changed = append(changed, pkg)
+	}
+
+	var found []map[string][]string
+	for _, tg := range g.traversed(m.graph) {
+		found = append(found, tg.chains(changed))
+	}
+
+	reasons := make(map[string][]string)
+	for _, pkg := range cp.AllChanges {
+		if _, ok := m.paths[pkg.ImportPath]; ok {
+			continue
+		}
+		for _, chains := range found {
+			chain, ok := chains[pkg.ImportPath]
+			if ok && (reasons[pkg.ImportPath] == nil || len(chain) < len(reasons[pkg.ImportPath])) {
+				reasons[pkg.ImportPath] = chain
+			}
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGraphWhy(t *testing.T) {
+	// a is imported by b, which is imported by c.
+	graph := &Graph{
+		graph: map[string]map[string]bool{
+			"a": {"b": true},
+			"b": {"c": true},
+		},
+	}
+
+	got, err := graph.Why("a", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "c"}, got); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	if _, err := graph.Why("c", "a"); err == nil {
+		t.Error("expected an error when the package does not depend on the changed package")
+	}
+}
+
+func TestGTA_ReasonReport(t *testing.T) {
+	// foo is imported by bar and qux, bar is imported by baz, and qux is
+	// imported by baz and quux. foo and qux are changed.
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			"dirFoo": {Exists: true, Files: []string{"foo.go"}},
+			"dirQux": {Exists: true, Files: []string{"qux.go"}},
+		},
+	}
+	pkgr := &testPackager{
+		dirs2Imports: map[string]string{
+			"dirFoo":  "foo",
+			"dirBar":  "bar",
+			"dirBaz":  "baz",
+			"dirQux":  "qux",
+			"dirQuux": "quux",
+		},
+		graph: &Graph{
+			graph: map[string]map[string]bool{
+				"foo": {"bar": true, "qux": true},
+				"bar": {"baz": true},
+				"qux": {"baz": true, "quux": true},
+			},
+		},
+	}
+
+	gta, err := New(SetDiffer(difr), SetPackager(pkgr), SetReasonReport(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gta.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"bar":  {"foo", "bar"},
+		"baz":  {"qux", "baz"},
+		"quux": {"qux", "quux"},
+	}
+	if diff := cmp.Diff(want, got.ReasonChains); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTripped Packages
+	if err := json.Unmarshal(b, &roundTripped); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, roundTripped.ReasonChains); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A WorkspaceModule is a module used by a go.work workspace.
+type WorkspaceModule struct {
+	// Path is the module path declared by the module's go.mod file.
+	Path string
+	// Dir is the absolute path of the module's directory.
+	Dir string
+}
+
+// FindWorkspace returns the absolute path of the go.work file that the go
+// command uses in dir: the file named by GOWORK in env, or the first go.work
+// file in dir or its parents. It returns an empty path when workspace mode is
+// disabled with GOWORK=off or when there is no go.work file. When env is nil,
+// the environment of the process is used.
+func FindWorkspace(dir string, env []string) (string, error) {
+	if env == nil {
+		env = os.Environ()
+	}
+	gowork := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GOWORK=") {
+			gowork = strings.TrimPrefix(kv, "GOWORK=")
+		}
+	}
+	switch {
+	case gowork == "off":
+		return "", nil
+	case gowork != "":
+		return filepath.Abs(gowork)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		fn := filepath.Join(dir, "go.work")
+		if _, err := os.Stat(fn); err == nil {
+			return fn, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// WorkspaceModules returns the modules used by the go.work file at fn, in the
+// order of its use directives.
+func WorkspaceModules(fn string) ([]WorkspaceModule, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := workspaceUses(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+
+	var modules []WorkspaceModule
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(fn), filepath.FromSlash(dir))
+		}
+		goMod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: reading module of %s: %v", fn, dir, err)
+		}
+		path := goModModulePath(goMod)
+		if path == "" {
+			return nil, fmt.Errorf("%s: %s has no module path", fn, filepath.Join(dir, "go.mod"))
+		}
+		modules = append(modules, WorkspaceModule{Path: path, Dir: dir})
+	}
+	return modules, nil
+}
+
+// workspaceUses returns the directories of the use directives of the go.work
+// file b, both single directives and blocks.
+func workspaceUses(b []byte) ([]string, error) {
+	uses, err := goModDirectives(b, "use")
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(uses))
+	for _, args := range uses {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("invalid use directive %q", strings.Join(args, " "))
+		}
+		dirs = append(dirs, args[0])
+	}
+	return dirs, nil
+}
+
+// loadPatterns returns the patterns of the packages that g's default
+// packager loads, with the configuration cfg: g's patterns, the packages of
+// g's repository when g is scoped to it, the packages within g's prefixes or
+// g's modules, or, when there are none of them and g's directory is in a
+// go.work workspace, the packages of each of the workspace's modules.
+// Without prefixes, the go command would otherwise load every package of the
+// standard library and of the workspace's build list.
+func (g *GTA) loadPatterns(cfg *packages.Config) ([]string, error) {
+	if len(g.pkgPatterns) > 0 {
+		return g.expandPatterns(cfg, g.pkgPatterns)
+	}
+	if g.repoScope {
+		return g.repoPatterns(cfg)
+	}
+	if len(g.prefixes) > 0 {
+		return prefixPatterns(g.prefixes), nil
+	}
+	if len(g.modules) > 0 {
+		return g.modulePatterns(), nil
+	}
+
+	wd, err := g.workingDir()
+	if err != nil {
+		return nil, err
+	}
+	fn, err := FindWorkspace(wd, cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+	if fn == "" {
+		return prefixPatterns(nil), nil
+	}
+	modules, err := WorkspaceModules(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]string, 0, len(modules))
+	for _, module := range modules {
+		patterns = append(patterns, module.Path+"/...")
+	}
+	return patterns, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2016 The gta AUTHORS. All rights reserved.
+
+Use of this source code is governed by the Apache 2 license that can be found
+in the LICENSE file.
+*/
+package gta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestWorkspaceUses(t *testing.T) {
+	tests := []struct {
+		desc    string
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{
+			desc: "block",
+			in:   "go 1.18\n\nuse (\n\t./a // the a module\n\t\"./b c\"\n\t/abs/d\n)\n",
+			want: []string{"./a", "./b c", "/abs/d"},
+		},
+		{
+			desc: "single directives",
+			in:   "go 1.18\n\nuse ./a\nuse `./b`\n\nreplace example.com/x => ./x\n",
+			want: []string{"./a", "./b"},
+		},
+		{
+			desc:    "invalid quoting",
+			in:      "use \"./a\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := workspaceUses([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+// newTestWorkspace creates a go.work workspace with the modules example.com/a
+// and example.com/ab in sibling directories, and returns its directory.
+func newTestWorkspace(t *testing.T) string {
+	t.Helper()
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"a", "ab", "ab/sub"} {
+		if err := os.Mkdir(filepath.Join(dir, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "go.work", "go 1.18\n\nuse (\n\t./a\n\t./ab\n)\n")
+	writeFile(t, dir, "a/go.mod", "module example.com/a\n\ngo 1.18\n")
+	writeFile(t, dir, "ab/go.mod", "module example.com/ab\n\ngo 1.18\n")
+	return dir
+}
+
+func TestFindWorkspace(t *testing.T) {
+	dir := newTestWorkspace(t)
+	work := filepath.Join(dir, "go.work")
+
+	tests := []struct {
+		desc string
+		dir  string
+		env  []string
+		want string
+	}{
+		{desc: "root", dir: dir, env: []string{}, want: work},
+		{desc: "module", dir: filepath.Join(dir, "ab", "sub"), env: []string{}, want: work},
+		{desc: "off", dir: dir, env: []string{"GOWORK=off"}, want: ""},
+		{desc: "explicit", dir: t.TempDir(), env: []string{"GOWORK=" + work}, want: work},
+		{desc: "none", dir: filepath.Dir(dir), env: []string{"GOWORK=off"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := FindWorkspace(tt.dir, tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	modules, err := WorkspaceModules(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WorkspaceModule{
+		{Path: "example.com/a", Dir: filepath.Join(dir, "a")},
+		{Path: "example.com/ab", Dir: filepath.Join(dir, "ab")},
+	}
+	if diff := cmp.Diff(want, modules); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestGTA_Workspace(t *testing.T) {
+	dir := newTestWorkspace(t)
+	defer Setenv(t, "GOWORK", "")()
+
+	var gotPatterns []string
+	loader := LoaderFunc(func(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+		gotPatterns = patterns
+		a := &packages.Package{
+			ID:      "example.com/a",
+			Name:    "a",
+			PkgPath: "example.com/a",
+			GoFiles: []string{filepath.Join(dir, "a", "a.go")},
+			Module:  &packages.Module{Path: "example.com/a", Dir: filepath.Join(dir, "a"), Main: true},
+		}
+		ab := &packages.Package{
+			ID:      "example.com/ab",
+			Name:    "ab",
+			PkgPath: "example.com/ab",
+			GoFiles: []string{filepath.Join(dir, "ab", "ab.go")},
+			Imports: map[string]*packages.Package{"example.com/a": a},
+			Module:  &packages.Module{Path: "example.com/ab", Dir: filepath.Join(dir, "ab"), Main: true},
+		}
+		sub := &packages.Package{
+			ID:      "example.com/ab/sub",
+			Name:    "sub",
+			PkgPath: "example.com/ab/sub",
+			GoFiles: []string{filepath.Join(dir, "ab", "sub", "sub.go")},
+			Module:  ab.Module,
+		}
+		return []*packages.Package{a, ab, sub}, nil
+	})
+
+	// the package in ab/sub is attributed to the ab module, even though the
+	// directory of the a module is a prefix of its directory.
+	writeFile(t, dir, "ab/sub/sub.go", "package sub\n")
+	difr := &testDiffer{
+		diff: map[string]Directory{
+			filepath.Join(dir, "a"):      {Exists: true, Files: []string{"a.go"}},
+			filepath.Join(dir, "ab/sub"): {Exists: true, Files: []string{"sub.go"}},
+		},
+	}
+
+	gt, err := New(SetDir(dir), SetLoader(loader), SetDiffer(difr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"example.com/a/...", "example.com/ab/..."}, gotPatterns); diff != "" {
+		t.Errorf("patterns: (-want, +got)\n%s", diff)
+	}
+
+	cp, err := gt.ChangedPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/a", "example.com/ab", "example.com/ab/sub"}
+	if diff := cmp.Diff(want, stringify(cp.AllChanges)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}